@@ -14,24 +14,70 @@ Usage:
 
 Commands:
   new [content]     Create a new note (opens editor if no content provided)
+  today [content]   Add a bullet to today's note (opens it in editor if no content)
   list              List all notes, newest first
+  recent [duration] List notes created in the last 7d (or 24h, 2w, ...)
   show <filename>   Print note content (without frontmatter)
+  cat <filename>... Print notes verbatim, including frontmatter
+  context <file>    Print a note and its related notes for AI prompts
   edit <filename>   Open note in $EDITOR
+  append <file> [text] Append text (or stdin) to a note
+  open [filename]   Open the notes directory or a note in the default app
+  search <query>    Search notes (--in frontmatter/both, --replace to rewrite)
   meta <filename>   Print note metadata as JSON
+  rename <old> <new>  Rename a note and update relations (alias: mv)
+  delete <filename> Delete a note and relations to it (--dry-run)
+  undo              Undo the last command that changed notes (--list, --dry-run, --force)
+  move <file> <folder> Move a note into a folder ("." for the top level)
+  move-to <nb> <file> Move a note to another notebook (--copy to duplicate)
+  clean [filename]  Normalize whitespace in note bodies (--all for every note)
+  template <cmd>    Manage templates (list, show, new, apply)
+  export <dir>      Copy notes to a directory (--format html|pdf|json, --changed-since)
+  import            Import .txt/.md files (--directory <path>, --tags, --move)
 
   diff              List notes that need enrichment
-  enrich            Output enrichment prompt for AI
+  enrich            Output enrichment prompt for AI (--apply <cmd> to run it)
+  watch             Report changed notes (--enrich to prompt for them)
   update <file>     Update note metadata (used by AI)
+  pin <filename>    Pin a note to the top of the list (unpin to undo)
+  relate <a> <b>    Add a bidirectional relation between two notes
+  related <file>    Suggest notes to relate by shared tags (--content, --add)
+  unrelate <a> <b>  Remove a bidirectional relation
+  backlinks <file>  List notes relating to a note (--fix to add reverse links)
+  reindex           Rebuild relations from frontmatter, meta and wikilinks
   sync              Rebuild .meta.json from frontmatter
+  verify-hashes     Check .meta.json hashes against the notes (read-only)
+  validate          Check notes and relations for problems (--fix to repair)
 
   graph [filename]  Show relationship graph
-  tags              List all tags with counts
+  orphans           List notes without relations or shared tags
+  dupes             List notes with the same body (--similarity for near-duplicates)
+  stats             Summarize the notebook (--dead-links, --reciprocity)
+  tags              List all tags with counts (--merge <tags> --into <tag>)
+  tag rename <old> <new>  Rename a tag in every note
+  tag merge <tag>... --into <tag>  Replace several tags with one
+  prune-tags        Remove tags used by fewer than --min notes
+
+  config            Show settings (get <key>, set <key> <value>)
 
 Flags vary by command. Use 'notes <command> --help' for details.
 
+Global flags:
+  --verbose   Report every file skipped while scanning notes
+
 Environment:
   NOTES_DIR   Notes directory (default: ~/notes)
   EDITOR      Editor for new/edit (default: vim)
+  NOTES_EDITOR_WAIT  Wait for Enter after the editor returns (for detaching editors)
+  NOTES_NOTEBOOKS    Other notebooks for move-to (name=dir,name=dir)
+  NOTES_CONFIG       Config file (default: ~/.config/notes/config.json)
+  NOTES_COLOR        Colors and formatting: auto, always or never
+  NOTES_APPEND_TO_DAILY  Make new append to today's note by default
+  NOTES_PDF_COMMAND  Convert HTML $1 to PDF $2 (default: wkhtmltopdf or pandoc)
+  NOTES_WPM          Reading speed in words per minute (default: 200)
+  NOTES_CJK_CPM      Reading speed for CJK text in characters per minute (default: 500)
+  NOTES_TIME_FORMAT  Write created times as minute (default) or rfc3339
+  NOTES_TAG_LOGIC    Default --tag-logic for list and search: or (default) or and
 `
 
 func main() {
@@ -40,33 +86,112 @@ func main() {
 		os.Exit(0)
 	}
 
-	cmd := os.Args[1]
-	args := os.Args[2:]
+	// Strip global flags before dispatching to the command
+	var cmdArgs []string
+	for _, arg := range os.Args[1:] {
+		if arg == "--verbose" || arg == "-verbose" {
+			notes.Verbose = true
+			continue
+		}
+		cmdArgs = append(cmdArgs, arg)
+	}
+
+	if len(cmdArgs) == 0 {
+		fmt.Print(usage)
+		os.Exit(0)
+	}
+
+	cmd := cmdArgs[0]
+	args := cmdArgs[1:]
 
 	var err error
 	switch cmd {
 	case "new":
 		err = notes.CmdNew(args)
+	case "today":
+		err = notes.CmdToday(args)
 	case "list":
 		err = notes.CmdList(args)
+	case "recent":
+		err = notes.CmdRecent(args)
 	case "show":
 		err = notes.CmdShow(args)
+	case "context":
+		err = notes.CmdContext(args)
+	case "search":
+		err = notes.CmdSearch(args)
+	case "cat":
+		err = notes.CmdCat(args)
 	case "edit":
 		err = notes.CmdEdit(args)
+	case "append":
+		err = notes.CmdAppend(args)
+	case "open":
+		err = notes.CmdOpen(args)
 	case "meta":
 		err = notes.CmdMeta(args)
+	case "rename", "mv":
+		err = notes.CmdRename(args)
+	case "delete":
+		err = notes.CmdDelete(args)
+	case "move":
+		err = notes.CmdMove(args)
+	case "move-to":
+		err = notes.CmdMoveTo(args)
+	case "clean":
+		err = notes.CmdClean(args)
+	case "template":
+		err = notes.CmdTemplate(args)
+	case "export":
+		err = notes.CmdExport(args)
+	case "import":
+		err = notes.CmdImport(args)
 	case "diff":
 		err = notes.CmdDiff(args)
 	case "enrich":
 		err = notes.CmdEnrich(args)
+	case "watch":
+		err = notes.CmdWatch(args)
 	case "update":
 		err = notes.CmdUpdate(args)
+	case "pin":
+		err = notes.CmdPin(args)
+	case "unpin":
+		err = notes.CmdUnpin(args)
+	case "related":
+		err = notes.CmdRelatedSuggest(args)
+	case "relate":
+		err = notes.CmdRelate(args)
+	case "unrelate":
+		err = notes.CmdUnrelate(args)
+	case "backlinks":
+		err = notes.CmdBacklinks(args)
+	case "reindex":
+		err = notes.CmdReindex(args)
 	case "sync":
 		err = notes.CmdSync(args)
+	case "validate":
+		err = notes.CmdValidate(args)
+	case "verify-hashes":
+		err = notes.CmdVerifyHashes(args)
+	case "config":
+		err = notes.CmdConfig(args)
+	case "undo":
+		err = notes.CmdUndo(args)
 	case "graph":
 		err = notes.CmdGraph(args)
+	case "orphans":
+		err = notes.CmdOrphans(args)
+	case "dupes":
+		err = notes.CmdDupes(args)
+	case "stats":
+		err = notes.CmdStats(args)
 	case "tags":
 		err = notes.CmdTags(args)
+	case "tag":
+		err = notes.CmdTag(args)
+	case "prune-tags":
+		err = notes.CmdPruneTags(args)
 	case "help", "-h", "--help":
 		fmt.Print(usage)
 	case "version", "-v", "--version":
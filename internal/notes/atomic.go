@@ -0,0 +1,62 @@
+package notes
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic replaces path with data so that readers, and the file left
+// behind after a crash, see either the old or the new contents but never a
+// truncated mix. The data is written to a temporary file in the same
+// directory, synced to disk and renamed over path. An existing file keeps
+// its permissions; new files get perm. The old contents are recorded for
+// 'notes undo' if the running command can be undone.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := recordUndo(path); err != nil {
+		return err
+	}
+
+	// Replace the file a symlink points to rather than the link itself
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself. Not every platform can sync a directory,
+	// and the data is already safe, so failures are ignored.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package notes
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// parseCache holds the notes parsed during this run, keyed by path, so that
+// commands which resolve a note id and then scan the notes directory, or
+// look at the same note several times, only parse each file once. An entry
+// is only used while the file's mtime and size are unchanged.
+var parseCache = struct {
+	sync.Mutex
+	entries map[string]cachedNote
+}{entries: make(map[string]cachedNote)}
+
+type cachedNote struct {
+	modTime time.Time
+	size    int64
+	note    *Note
+}
+
+// cachedParse returns a copy of the note cached for path if the file has
+// not changed since it was parsed
+func cachedParse(path string, info os.FileInfo) (*Note, bool) {
+	parseCache.Lock()
+	defer parseCache.Unlock()
+
+	entry, ok := parseCache.entries[path]
+	if !ok || !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() {
+		return nil, false
+	}
+	return entry.note.clone(), true
+}
+
+// storeParsed caches a copy of note, so later changes by the caller don't
+// leak into the cache
+func storeParsed(path string, info os.FileInfo, note *Note) {
+	parseCache.Lock()
+	defer parseCache.Unlock()
+
+	parseCache.entries[path] = cachedNote{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		note:    note.clone(),
+	}
+}
+
+// forgetParsed drops the cached note for path, e.g. after it was written
+func forgetParsed(path string) {
+	parseCache.Lock()
+	defer parseCache.Unlock()
+
+	delete(parseCache.entries, path)
+}
+
+// resetParseCache empties the parse cache
+func resetParseCache() {
+	parseCache.Lock()
+	defer parseCache.Unlock()
+
+	parseCache.entries = make(map[string]cachedNote)
+}
+
+// clone returns a copy of the note that shares no slices with n
+func (n *Note) clone() *Note {
+	c := *n
+	c.Frontmatter.Tags = cloneStrings(n.Frontmatter.Tags)
+	c.Frontmatter.Related = cloneStrings(n.Frontmatter.Related)
+	if n.Frontmatter.Extra != nil {
+		c.Frontmatter.Extra = append([]FrontmatterField(nil), n.Frontmatter.Extra...)
+	}
+	return &c
+}
+
+// cloneStrings copies s, keeping nil slices nil so a cached note looks
+// exactly like a freshly parsed one
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
@@ -0,0 +1,43 @@
+package notes
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// clipboardCommands are the tools tried, in order, to read the clipboard.
+// wl-paste is tried before xclip so Wayland sessions that also run
+// XWayland read the native clipboard.
+var clipboardCommands = [][]string{
+	{"pbpaste"},
+	{"wl-paste", "--no-newline"},
+	{"xclip", "-selection", "clipboard", "-o"},
+	{"xsel", "--clipboard", "--output"},
+	{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"},
+}
+
+// readClipboard returns the text on the system clipboard. It is a variable
+// so tests can replace it.
+var readClipboard = func() (string, error) {
+	for _, command := range clipboardCommands {
+		path, err := exec.LookPath(command[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, command[1:]...)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%s failed: %w", command[0], err)
+		}
+		return string(out), nil
+	}
+
+	var names []string
+	for _, command := range clipboardCommands {
+		names = append(names, command[0])
+	}
+	return "", fmt.Errorf("no clipboard tool found (install one of: %s)", strings.Join(names, ", "))
+}
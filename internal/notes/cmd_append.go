@@ -0,0 +1,65 @@
+package notes
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CmdAppend implements the 'notes append <filename> [content]' command
+// Adds content to the end of a note's body, reading it from stdin if no
+// content is given. .meta.json is left alone, so the changed content hash
+// flags the note for enrichment again.
+func CmdAppend(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: notes append <filename> [content] (or pipe the content to stdin)")
+	}
+
+	text := strings.Join(args[1:], " ")
+	if len(args) == 1 {
+		if isTerminal(os.Stdin) {
+			return fmt.Errorf("usage: notes append <filename> [content] (or pipe the content to stdin)")
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read stdin: %w", err)
+		}
+		text = string(data)
+	}
+	text = strings.Trim(text, "\n")
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("nothing to append")
+	}
+
+	notesDir, err := GetWritableNotesDir()
+	if err != nil {
+		return err
+	}
+
+	finish := beginUndo(notesDir, "append")
+	defer finish()
+
+	filename, err := ResolveFilename(notesDir, args[0])
+	if err != nil {
+		return err
+	}
+	notePath := filepath.Join(notesDir, filename)
+
+	note, err := ParseNote(notePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("note not found: %s", filename)
+		}
+		return fmt.Errorf("failed to parse note: %w", err)
+	}
+
+	note.Content = strings.TrimRight(note.Content, "\n") + "\n" + text + "\n"
+	if err := note.SaveKeepingFormat(notePath); err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+
+	fmt.Printf("Appended to %s\n", filename)
+	return nil
+}
@@ -0,0 +1,118 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+)
+
+// CmdBacklinks implements the 'notes backlinks <filename>' command
+// Lists the notes relating to a note, from frontmatter and .meta.json alike
+func CmdBacklinks(args []string) error {
+	fs := flag.NewFlagSet("backlinks", flag.ExitOnError)
+	fixFlag := fs.Bool("fix", false, "add the missing reverse relations to the note")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: notes backlinks <filename> [--fix]")
+	}
+
+	getDir := GetNotesDir
+	if *fixFlag {
+		getDir = GetWritableNotesDir
+	}
+	notesDir, err := getDir()
+	if err != nil {
+		return err
+	}
+
+	filename, err := ResolveNote(notesDir, positional[0])
+	if err != nil {
+		return err
+	}
+
+	if *fixFlag {
+		unlock, err := LockMeta(notesDir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		finish := beginUndo(notesDir, "backlinks")
+		defer finish()
+	}
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	notesList, err := LoadAllNotes(notesDir)
+	if err != nil {
+		return err
+	}
+
+	var target *Note
+	var backlinks []string
+	for _, note := range notesList {
+		other := note.Name()
+		if other == filename {
+			target = note
+			continue
+		}
+		if relatesTo(currentRelated(meta, other, note), filename) {
+			backlinks = append(backlinks, other)
+		}
+	}
+	sort.Strings(backlinks)
+
+	if len(backlinks) == 0 {
+		fmt.Printf("No notes relate to %s\n", filename)
+		return nil
+	}
+
+	// Backlinks the note doesn't list in return
+	targetRelated := currentRelated(meta, filename, target)
+	var missing []string
+	for _, other := range backlinks {
+		if relatesTo(targetRelated, other) {
+			fmt.Println(other)
+		} else {
+			fmt.Printf("%s  (not listed back)\n", other)
+			missing = append(missing, other)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+	if !*fixFlag {
+		fmt.Printf("\n%d of %d backlinks are not listed back (use --fix to add them)\n", len(missing), len(backlinks))
+		return nil
+	}
+
+	// AddRelation only touches existing entries
+	if meta.GetFileMeta(filename) == nil {
+		meta.SetFileMeta(filename, &FileMeta{
+			Tags:    target.Frontmatter.Tags,
+			Summary: target.Frontmatter.Summary,
+			Related: target.Frontmatter.Related,
+		})
+	}
+	for _, other := range missing {
+		meta.AddRelation(filename, other)
+		if err := setRelatedInFile(notesDir, filename, other, true); err != nil {
+			return fmt.Errorf("failed to update %s: %w", filename, err)
+		}
+	}
+	if err := meta.Save(notesDir); err != nil {
+		return fmt.Errorf("failed to save meta file: %w", err)
+	}
+
+	fmt.Printf("\nAdded %d reverse relations to %s\n", len(missing), filename)
+	return nil
+}
@@ -0,0 +1,51 @@
+package notes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CmdCat implements the 'notes cat <filename>...' command
+// Prints notes verbatim, frontmatter included. Several notes are each
+// preceded by a "==> name <==" header, like head and tail do.
+func CmdCat(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: notes cat <filename>...")
+	}
+
+	notesDir, err := GetNotesDir()
+	if err != nil {
+		return fmt.Errorf("failed to get notes directory: %w", err)
+	}
+
+	// Resolve every name first so nothing is printed for a bad argument
+	filenames := make([]string, len(args))
+	for i, arg := range args {
+		if filenames[i], err = ResolveFilename(notesDir, arg); err != nil {
+			return err
+		}
+	}
+
+	for i, filename := range filenames {
+		data, err := os.ReadFile(filepath.Join(notesDir, filename))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("note not found: %s", filename)
+			}
+			return fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+
+		if len(filenames) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("==> %s <==\n", filename)
+		}
+		os.Stdout.Write(data)
+		if len(filenames) > 1 && len(data) > 0 && data[len(data)-1] != '\n' {
+			fmt.Println()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CmdClean implements the 'notes clean [filename]' command
+// Normalizes whitespace in note bodies
+func CmdClean(args []string) error {
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	allFlag := fs.Bool("all", false, "clean all notes")
+	dryRunFlag := fs.Bool("dry-run", false, "show which notes would change without writing")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) == 0 && !*allFlag {
+		return fmt.Errorf("usage: notes clean <filename> | --all [--dry-run]")
+	}
+
+	getDir := GetWritableNotesDir
+	if *dryRunFlag {
+		getDir = GetNotesDir
+	}
+	notesDir, err := getDir()
+	if err != nil {
+		return err
+	}
+
+	var notesList []*Note
+	if *allFlag {
+		notesList, err = LoadAllNotes(notesDir)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, arg := range positional {
+			filename := NormalizeFilename(arg)
+			note, err := ParseNote(filepath.Join(notesDir, filename))
+			if err != nil {
+				if os.IsNotExist(err) {
+					return fmt.Errorf("note not found: %s", filename)
+				}
+				return fmt.Errorf("failed to parse note: %w", err)
+			}
+			notesList = append(notesList, note)
+		}
+	}
+
+	if !*dryRunFlag {
+		unlock, err := LockMeta(notesDir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		finish := beginUndo(notesDir, "clean")
+		defer finish()
+	}
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	var cleanedCount int
+	for _, note := range notesList {
+		cleaned := CleanContent(note.Content)
+		if cleaned == note.Content {
+			continue
+		}
+
+		cleanedCount++
+		filename := note.Name()
+		if *dryRunFlag {
+			fmt.Printf("Would clean: %s\n", filename)
+			continue
+		}
+
+		oldHash := note.ContentHash()
+		note.Content = cleaned
+		if err := note.SaveKeepingFormat(note.Filename); err != nil {
+			return fmt.Errorf("failed to save %s: %w", filename, err)
+		}
+		meta.RefreshHash(filename, oldHash, note.ContentHash())
+		fmt.Printf("Cleaned: %s\n", filename)
+	}
+
+	if *dryRunFlag {
+		fmt.Printf("\nDry run: would clean %d of %d notes\n", cleanedCount, len(notesList))
+		return nil
+	}
+
+	if cleanedCount > 0 {
+		if err := meta.Save(notesDir); err != nil {
+			return fmt.Errorf("failed to save meta file: %w", err)
+		}
+	}
+
+	fmt.Printf("\nCleaned %d of %d notes\n", cleanedCount, len(notesList))
+	return nil
+}
+
+// CleanContent strips trailing whitespace from every line, collapses runs
+// of blank lines into one and ends the body with a single newline
+func CleanContent(content string) string {
+	lines := strings.Split(content, "\n")
+
+	var result []string
+	blankRun := 0
+	flushBlanks := func() {
+		if blankRun > 0 {
+			result = append(result, "")
+		}
+		blankRun = 0
+	}
+
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			blankRun++
+			continue
+		}
+		flushBlanks()
+		result = append(result, line)
+	}
+
+	if len(result) == 0 {
+		return "\n"
+	}
+
+	return strings.Join(result, "\n") + "\n"
+}
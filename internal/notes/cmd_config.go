@@ -0,0 +1,121 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// CmdConfig implements the 'notes config' command
+// Reads and writes persistent settings in the config file
+func CmdConfig(args []string) error {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	getFlag := fs.String("get", "", "print the effective value of a setting")
+	setFlag := fs.String("set", "", "store a setting in the config file (key=value)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *getFlag != "" && *setFlag != "" {
+		return fmt.Errorf("--get and --set cannot be combined")
+	}
+
+	// 'notes config get <key>' and 'notes config set <key> <value>' are
+	// spellings of --get and --set
+	if positional := fs.Args(); len(positional) > 0 {
+		if *getFlag != "" || *setFlag != "" {
+			return fmt.Errorf("--get and --set cannot be combined with %s", positional[0])
+		}
+		switch {
+		case positional[0] == "get" && len(positional) == 2:
+			return getConfigValue(positional[1])
+		case positional[0] == "set" && len(positional) >= 3:
+			return setConfigValue(positional[1] + "=" + strings.Join(positional[2:], " "))
+		default:
+			return fmt.Errorf("usage: notes config [get <key> | set <key> <value>]")
+		}
+	}
+
+	switch {
+	case *setFlag != "":
+		return setConfigValue(*setFlag)
+	case *getFlag != "":
+		return getConfigValue(*getFlag)
+	default:
+		return listConfig()
+	}
+}
+
+// getConfigValue prints the effective value of key
+func getConfigValue(key string) error {
+	if findSetting(key) == nil {
+		return unknownSettingError(key)
+	}
+	value, _ := lookupSetting(key)
+	if value == "" {
+		return fmt.Errorf("%s is not set (the built-in default applies)", key)
+	}
+	fmt.Println(value)
+	return nil
+}
+
+// setConfigValue validates and stores a key=value pair
+func setConfigValue(assignment string) error {
+	key, raw, ok := strings.Cut(assignment, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("invalid --set value: %s (expected key=value)", assignment)
+	}
+
+	s := findSetting(key)
+	if s == nil {
+		return unknownSettingError(key)
+	}
+	value, err := parseSettingValue(s, strings.TrimSpace(raw))
+	if err != nil {
+		return err
+	}
+
+	config, err := loadConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to load config file: %w", err)
+	}
+	config[key] = value
+	if err := saveConfigFile(config); err != nil {
+		return fmt.Errorf("failed to save config file: %w", err)
+	}
+
+	fmt.Printf("Set %s = %v\n", key, value)
+	return nil
+}
+
+// listConfig prints every setting with its effective value and source
+func listConfig() error {
+	path, err := GetConfigPath()
+	if err != nil {
+		return err
+	}
+	if _, err := loadConfigFile(); err != nil {
+		return fmt.Errorf("failed to load config file: %w", err)
+	}
+
+	fmt.Printf("Config file: %s\n\n", path)
+	for _, key := range settingKeys() {
+		s := findSetting(key)
+		value, source := lookupSetting(key)
+		switch source {
+		case "env":
+			fmt.Printf("%s = %s  (from $%s)\n", key, value, s.Env)
+		case "config":
+			fmt.Printf("%s = %s\n", key, value)
+		default:
+			fmt.Printf("%s  (default)  # %s\n", key, s.Description)
+		}
+	}
+	return nil
+}
+
+func unknownSettingError(key string) error {
+	return fmt.Errorf("unknown setting: %s (known settings: %s)", key, strings.Join(settingKeys(), ", "))
+}
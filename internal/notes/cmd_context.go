@@ -0,0 +1,138 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// charsPerToken is a rough estimate used to turn --max-tokens into a character budget
+const charsPerToken = 4
+
+// CmdContext implements the 'notes context <filename>' command
+// Bundles a note and its related notes for pasting into an AI prompt
+func CmdContext(args []string) error {
+	fs := flag.NewFlagSet("context", flag.ExitOnError)
+	depthFlag := fs.Int("depth", 1, "how many hops of related notes to include")
+	maxCharsFlag := fs.Int("max-chars", 0, "stop adding notes beyond this many characters (0 = no limit)")
+	maxTokensFlag := fs.Int("max-tokens", 0, "stop adding notes beyond roughly this many tokens (0 = no limit)")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) == 0 {
+		return fmt.Errorf("usage: notes context <filename> [--depth N] [--max-chars N | --max-tokens N]")
+	}
+
+	notesDir, err := GetNotesDir()
+	if err != nil {
+		return fmt.Errorf("failed to get notes directory: %w", err)
+	}
+
+	filename := NormalizeFilename(positional[0])
+	if _, err := os.Stat(filepath.Join(notesDir, filename)); os.IsNotExist(err) {
+		return fmt.Errorf("note not found: %s", filename)
+	}
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	budget := *maxCharsFlag
+	if *maxTokensFlag > 0 && (budget == 0 || *maxTokensFlag*charsPerToken < budget) {
+		budget = *maxTokensFlag * charsPerToken
+	}
+
+	bundle, omitted := buildContextBundle(notesDir, meta, filename, *depthFlag, budget)
+	fmt.Print(bundle)
+
+	if omitted > 0 {
+		fmt.Fprintf(os.Stderr, "Budget reached: %d related notes omitted, increase the limit to include more\n", omitted)
+	}
+
+	return nil
+}
+
+// buildContextBundle walks related notes breadth-first from root and
+// concatenates them until the character budget is reached. The root note is
+// always included. Returns the bundle and how many queued notes were dropped.
+func buildContextBundle(notesDir string, meta *MetaFile, root string, depth, budget int) (string, int) {
+	var buf strings.Builder
+
+	visited := map[string]bool{root: true}
+	frontier := []string{root}
+	omitted := 0
+	full := false
+
+	for d := 0; d <= depth && len(frontier) > 0; d++ {
+		var next []string
+		for _, filename := range frontier {
+			note, err := ParseNote(filepath.Join(notesDir, filename))
+			if err != nil {
+				continue
+			}
+
+			if full {
+				omitted++
+				continue
+			}
+
+			section := formatContextSection(filename, note, meta.GetFileMeta(filename))
+			if budget > 0 && buf.Len() > 0 && buf.Len()+len(section) > budget {
+				full = true
+				omitted++
+				continue
+			}
+			buf.WriteString(section)
+
+			for _, rel := range contextRelated(note, meta.GetFileMeta(filename)) {
+				if !visited[rel] {
+					visited[rel] = true
+					next = append(next, rel)
+				}
+			}
+		}
+		if d < depth {
+			frontier = next
+		} else {
+			frontier = nil
+		}
+	}
+
+	return buf.String(), omitted
+}
+
+// contextRelated prefers relations from .meta.json and falls back to frontmatter
+func contextRelated(note *Note, fileMeta *FileMeta) []string {
+	if fileMeta != nil {
+		return fileMeta.Related
+	}
+	return note.Frontmatter.Related
+}
+
+func formatContextSection(filename string, note *Note, fileMeta *FileMeta) string {
+	tags := note.Frontmatter.Tags
+	summary := note.Frontmatter.Summary
+	if fileMeta != nil {
+		tags = fileMeta.Tags
+		summary = fileMeta.Summary
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "===== %s =====\n", filename)
+	if len(tags) > 0 {
+		fmt.Fprintf(&buf, "tags: %s\n", strings.Join(tags, ", "))
+	}
+	if summary != "" {
+		fmt.Fprintf(&buf, "summary: %s\n", summary)
+	}
+	buf.WriteString("\n")
+	buf.WriteString(strings.TrimSpace(note.Content))
+	buf.WriteString("\n\n")
+	return buf.String()
+}
@@ -0,0 +1,127 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// CmdDelete implements the 'notes delete <filename>' command
+// Deletes a note, its .meta.json entry and the relations pointing at it
+func CmdDelete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	dryRunFlag := fs.Bool("dry-run", false, "show what would be removed without changing anything")
+	keepBacklinksFlag := fs.Bool("keep-backlinks", false, "leave other notes' relations to the deleted note in place")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: notes delete <filename> [--dry-run] [--keep-backlinks]")
+	}
+
+	getDir := GetWritableNotesDir
+	if *dryRunFlag {
+		getDir = GetNotesDir
+	}
+	notesDir, err := getDir()
+	if err != nil {
+		return err
+	}
+
+	filename, err := ResolveNote(notesDir, positional[0])
+	if err != nil {
+		return err
+	}
+
+	if !*dryRunFlag {
+		unlock, err := LockMeta(notesDir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		finish := beginUndo(notesDir, "delete")
+		defer finish()
+	}
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	notesList, err := LoadAllNotes(notesDir)
+	if err != nil {
+		return err
+	}
+
+	// Notes relating to the deleted one in their frontmatter or .meta.json
+	var backlinks []string
+	for _, note := range notesList {
+		other := note.Name()
+		if other == filename {
+			continue
+		}
+		if relatesTo(currentRelated(meta, other, note), filename) {
+			backlinks = append(backlinks, other)
+		}
+	}
+	sort.Strings(backlinks)
+
+	verb := "Removed"
+	if *dryRunFlag {
+		verb = "Would remove"
+	}
+	for _, other := range backlinks {
+		if *keepBacklinksFlag {
+			fmt.Fprintf(os.Stderr, "Warning: %s still relates to %s\n", other, filename)
+			continue
+		}
+		fmt.Printf("%s backlink in %s\n", verb, other)
+		if *dryRunFlag {
+			continue
+		}
+
+		if fileMeta := meta.GetFileMeta(other); fileMeta != nil {
+			fileMeta.Related = removeRelated(fileMeta.Related, filename)
+		}
+		note, err := ParseNote(filepath.Join(notesDir, other))
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", other, err)
+		}
+		if err := updateRelatedInFile(notesDir, other, removeRelated(note.Frontmatter.Related, filename)); err != nil {
+			return fmt.Errorf("failed to update %s: %w", other, err)
+		}
+	}
+
+	if *dryRunFlag {
+		fmt.Printf("Would delete %s\n", filename)
+		return nil
+	}
+
+	if err := removeRecorded(filepath.Join(notesDir, filename)); err != nil {
+		return fmt.Errorf("failed to delete note: %w", err)
+	}
+	delete(meta.Files, filename)
+	if err := meta.Save(notesDir); err != nil {
+		return fmt.Errorf("failed to save meta file: %w", err)
+	}
+
+	fmt.Printf("Deleted %s\n", filename)
+	return nil
+}
+
+// removeRelated drops every entry naming filename, with or without ".md"
+func removeRelated(related []string, filename string) []string {
+	kept := make([]string, 0, len(related))
+	for _, rel := range related {
+		if NormalizeFilename(rel) != filename {
+			kept = append(kept, rel)
+		}
+	}
+	return kept
+}
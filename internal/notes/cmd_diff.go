@@ -1,15 +1,38 @@
 package notes
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+// DiffEntry is a note flagged by notes diff, with what needs attention
+type DiffEntry struct {
+	Filename string `json:"filename"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// DiffReport groups every note needing attention for notes diff --all
+type DiffReport struct {
+	NeedsEnrichment []DiffEntry `json:"needs_enrichment"`
+	NoSummary       []DiffEntry `json:"no_summary"`
+	NoTags          []DiffEntry `json:"no_tags"`
+	DanglingRelated []DiffEntry `json:"dangling_related"`
+}
+
 // CmdDiff implements the 'notes diff' command
-// Lists notes that need enrichment
+// Lists notes that need enrichment, or with --all everything needing attention
 func CmdDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	allFlag := fs.Bool("all", false, "also report missing summaries, missing tags and dangling relations")
+	jsonFlag := fs.Bool("json", false, "output as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
 	notesDir, err := GetNotesDir()
 	if err != nil {
 		return fmt.Errorf("failed to get notes directory: %w", err)
@@ -20,33 +43,116 @@ func CmdDiff(args []string) error {
 		return fmt.Errorf("failed to load meta file: %w", err)
 	}
 
-	// Find all .md files
-	entries, err := os.ReadDir(notesDir)
+	notesList, err := LoadAllNotes(notesDir)
 	if err != nil {
-		return fmt.Errorf("failed to read notes directory: %w", err)
+		return err
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
-			continue
+	report := buildDiffReport(notesDir, meta, notesList)
+
+	if !*allFlag {
+		if *jsonFlag {
+			return outputJSON(report.NeedsEnrichment, false)
+		}
+		for _, entry := range report.NeedsEnrichment {
+			fmt.Println(entry.Filename)
 		}
+		return nil
+	}
+
+	if *jsonFlag {
+		return outputJSON(report, false)
+	}
+
+	sections := []struct {
+		title   string
+		entries []DiffEntry
+	}{
+		{"Needs enrichment", report.NeedsEnrichment},
+		{"No summary", report.NoSummary},
+		{"No tags", report.NoTags},
+		{"Dangling related links", report.DanglingRelated},
+	}
 
-		notePath := filepath.Join(notesDir, entry.Name())
-		note, err := ParseNote(notePath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to parse %s: %v\n", entry.Name(), err)
+	printed := 0
+	for _, section := range sections {
+		if len(section.entries) == 0 {
 			continue
 		}
+		if printed > 0 {
+			fmt.Println()
+		}
+		printed++
 
-		currentHash := note.ContentHash()
-		if meta.NeedsEnrichment(entry.Name(), currentHash) {
-			fmt.Println(entry.Name())
+		fmt.Printf("%s (%d):\n", section.title, len(section.entries))
+		for _, entry := range section.entries {
+			if entry.Detail != "" {
+				fmt.Printf("  %s (%s)\n", entry.Filename, entry.Detail)
+			} else {
+				fmt.Printf("  %s\n", entry.Filename)
+			}
 		}
 	}
 
+	if printed == 0 {
+		fmt.Println("Nothing needs attention")
+	}
+
 	return nil
 }
 
+// buildDiffReport checks every note; a note may appear in several sections
+func buildDiffReport(notesDir string, meta *MetaFile, notesList []*Note) DiffReport {
+	report := DiffReport{
+		NeedsEnrichment: []DiffEntry{},
+		NoSummary:       []DiffEntry{},
+		NoTags:          []DiffEntry{},
+		DanglingRelated: []DiffEntry{},
+	}
+
+	for _, note := range notesList {
+		filename := note.Name()
+		fileMeta := meta.GetFileMeta(filename)
+
+		if meta.NeedsEnrichment(filename, note.ContentHash()) {
+			detail := "content changed"
+			if fileMeta == nil {
+				detail = "new"
+			}
+			report.NeedsEnrichment = append(report.NeedsEnrichment, DiffEntry{Filename: filename, Detail: detail})
+		}
+
+		if strings.TrimSpace(note.Frontmatter.Summary) == "" {
+			report.NoSummary = append(report.NoSummary, DiffEntry{Filename: filename})
+		}
+
+		if len(note.Frontmatter.Tags) == 0 {
+			report.NoTags = append(report.NoTags, DiffEntry{Filename: filename})
+		}
+
+		// Plain notes only have their relations in .meta.json
+		related := append([]string{}, note.Frontmatter.Related...)
+		if fileMeta != nil {
+			for _, rel := range fileMeta.Related {
+				if !Contains(related, rel) {
+					related = append(related, rel)
+				}
+			}
+		}
+		var missing []string
+		for _, rel := range related {
+			if _, err := os.Stat(filepath.Join(notesDir, rel)); os.IsNotExist(err) {
+				missing = append(missing, rel)
+			}
+		}
+		if len(missing) > 0 {
+			report.DanglingRelated = append(report.DanglingRelated, DiffEntry{Filename: filename, Detail: strings.Join(missing, ", ")})
+		}
+	}
+
+	return report
+}
+
 // GetNotesNeedingEnrichment returns a list of notes that need enrichment
 func GetNotesNeedingEnrichment(notesDir string) ([]*Note, error) {
 	meta, err := LoadMetaFile(notesDir)
@@ -54,25 +160,14 @@ func GetNotesNeedingEnrichment(notesDir string) ([]*Note, error) {
 		return nil, fmt.Errorf("failed to load meta file: %w", err)
 	}
 
-	entries, err := os.ReadDir(notesDir)
+	allNotes, err := LoadAllNotes(notesDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read notes directory: %w", err)
+		return nil, err
 	}
 
 	var notesList []*Note
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
-			continue
-		}
-
-		notePath := filepath.Join(notesDir, entry.Name())
-		note, err := ParseNote(notePath)
-		if err != nil {
-			continue
-		}
-
-		currentHash := note.ContentHash()
-		if meta.NeedsEnrichment(entry.Name(), currentHash) {
+	for _, note := range allNotes {
+		if meta.NeedsEnrichment(note.Name(), note.ContentHash()) {
 			notesList = append(notesList, note)
 		}
 	}
@@ -0,0 +1,149 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CmdDupes implements the 'notes dupes' command
+// Lists groups of notes with the same body, or with --similarity, bodies
+// sharing at least that share of their words
+func CmdDupes(args []string) error {
+	fs := flag.NewFlagSet("dupes", flag.ExitOnError)
+	similarityFlag := fs.Float64("similarity", 0, "also group near-duplicates sharing at least this share of words (0 to 1, e.g. 0.8)")
+	rawFlag := fs.Bool("raw", false, "show only filenames, one group per line")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *similarityFlag < 0 || *similarityFlag > 1 {
+		return fmt.Errorf("invalid --similarity value: %g (expected a number from 0 to 1)", *similarityFlag)
+	}
+
+	notesDir, err := GetNotesDir()
+	if err != nil {
+		return fmt.Errorf("failed to get notes directory: %w", err)
+	}
+
+	notesList, err := LoadAllNotes(notesDir)
+	if err != nil {
+		return err
+	}
+
+	groups := findDuplicates(notesList, *similarityFlag)
+	if len(groups) == 0 {
+		if !*rawFlag {
+			fmt.Println("No duplicate notes")
+		}
+		return nil
+	}
+
+	for i, group := range groups {
+		names := make([]string, len(group))
+		for j, note := range group {
+			names[j] = note.Name()
+		}
+		if *rawFlag {
+			fmt.Println(strings.Join(names, " "))
+			continue
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%d notes: %q\n", len(group), dupeGroupSummary(group))
+		for _, name := range names {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+	return nil
+}
+
+// findDuplicates groups notes with the same ContentHash and, if similarity
+// is above 0, notes whose terms overlap at least that much (transitively).
+// Blank notes are never duplicates. Groups are sorted by their first
+// filename, and notes within a group by filename.
+func findDuplicates(notesList []*Note, similarity float64) [][]*Note {
+	var candidates []*Note
+	for _, note := range notesList {
+		if strings.TrimSpace(note.Content) != "" {
+			candidates = append(candidates, note)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Name() < candidates[j].Name()
+	})
+
+	// Union-find over the candidates' indexes
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(i, j int) {
+		if ri, rj := find(i), find(j); ri != rj {
+			parent[rj] = ri
+		}
+	}
+
+	byHash := make(map[string]int)
+	for i, note := range candidates {
+		hash := note.ContentHash()
+		if first, ok := byHash[hash]; ok {
+			union(first, i)
+		} else {
+			byHash[hash] = i
+		}
+	}
+
+	if similarity > 0 {
+		terms := make([]map[string]bool, len(candidates))
+		for i, note := range candidates {
+			terms[i] = contentTerms(note.Content)
+		}
+		for i := range candidates {
+			for j := i + 1; j < len(candidates); j++ {
+				if find(i) != find(j) && termOverlap(terms[i], terms[j]) >= similarity {
+					union(i, j)
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]*Note)
+	var roots []int
+	for i, note := range candidates {
+		root := find(i)
+		if members[root] == nil {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], note)
+	}
+
+	var groups [][]*Note
+	for _, root := range roots {
+		if len(members[root]) > 1 {
+			groups = append(groups, members[root])
+		}
+	}
+	return groups
+}
+
+// dupeGroupSummary describes a group by the first summary any of its notes
+// has, or else by the first note's first line
+func dupeGroupSummary(group []*Note) string {
+	for _, note := range group {
+		if note.Frontmatter.Summary != "" {
+			return note.Frontmatter.Summary
+		}
+	}
+	return group[0].GetSummaryOrFirstLine()
+}
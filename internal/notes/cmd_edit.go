@@ -1,17 +1,25 @@
 package notes
 
 import (
+	"flag"
 	"fmt"
-	"os"
-	"os/exec"
 	"path/filepath"
 )
 
 // CmdEdit implements the 'notes edit <filename>' command
 // Opens note in $EDITOR
 func CmdEdit(args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("usage: notes edit <filename>")
+	fs := flag.NewFlagSet("edit", flag.ExitOnError)
+	diffAfterFlag := fs.Bool("diff-after", false, "print a diff of the body and whether enrichment is affected")
+	lineFlag := fs.Int("line", 0, "place the cursor on this line of the file (if the editor supports +N)")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) == 0 {
+		return fmt.Errorf("usage: notes edit <filename> [--line N] [--diff-after]")
 	}
 
 	notesDir, err := GetNotesDir()
@@ -19,23 +27,55 @@ func CmdEdit(args []string) error {
 		return fmt.Errorf("failed to get notes directory: %w", err)
 	}
 
-	filename := NormalizeFilename(args[0])
+	filename, err := ResolveFilename(notesDir, positional[0])
+	if err != nil {
+		return err
+	}
 	notePath := filepath.Join(notesDir, filename)
 
-	// Check if file exists
-	if _, err := os.Stat(notePath); os.IsNotExist(err) {
-		return fmt.Errorf("note not found: %s", filename)
+	var before *Note
+	if *diffAfterFlag {
+		before, err = ParseNote(notePath)
+		if err != nil {
+			return fmt.Errorf("failed to parse note: %w", err)
+		}
 	}
 
-	editor := GetEditor()
-	cmd := exec.Command(editor, notePath)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
+	if err := runEditor(notePath, *lineFlag); err != nil {
 		return fmt.Errorf("editor failed: %w", err)
 	}
 
+	if before == nil {
+		return nil
+	}
+
+	after, err := ParseNote(notePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse edited note: %w", err)
+	}
+	return reportEdit(notesDir, filename, before, after)
+}
+
+// reportEdit prints the body diff of an edit and how it affects enrichment
+func reportEdit(notesDir, filename string, before, after *Note) error {
+	oldHash, newHash := before.ContentHash(), after.ContentHash()
+	if oldHash == newHash {
+		fmt.Printf("Body unchanged (content hash %s)\n", newHash)
+		return nil
+	}
+
+	fmt.Print(unifiedDiff("a/"+filename, "b/"+filename, before.Content, after.Content))
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	fmt.Printf("\nContent hash changed: %s -> %s\n", oldHash, newHash)
+	if meta.NeedsEnrichment(filename, oldHash) {
+		fmt.Println("The note already needed enrichment")
+	} else {
+		fmt.Println("'notes diff' and 'notes enrich' will now flag this note")
+	}
 	return nil
 }
@@ -1,18 +1,51 @@
 package notes
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
 // CmdEnrich implements the 'notes enrich' command
-// Outputs structured prompt for AI enrichment
+// Outputs structured prompt for AI enrichment, or pipes it into a command
+// and applies the JSON it returns
 func CmdEnrich(args []string) error {
-	notesDir, err := GetNotesDir()
-	if err != nil {
-		return fmt.Errorf("failed to get notes directory: %w", err)
+	fs := flag.NewFlagSet("enrich", flag.ExitOnError)
+	applyFlag := fs.String("apply", "", "pipe the prompt into this shell command and apply its JSON output")
+	batchTokensFlag := fs.Int("batch-tokens", 0, "split the notes into prompts of at most about this many tokens each")
+	batchDirFlag := fs.String("batch-dir", "", "with --batch-tokens, write the prompts to numbered files in this directory")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *batchTokensFlag < 0 {
+		return fmt.Errorf("--batch-tokens must not be negative")
+	}
+	if *batchDirFlag != "" && (*batchTokensFlag == 0 || *applyFlag != "") {
+		return fmt.Errorf("--batch-dir requires --batch-tokens and cannot be used with --apply")
+	}
+
+	var notesDir string
+	var err error
+	if *applyFlag != "" {
+		notesDir, err = GetWritableNotesDir()
+		if err != nil {
+			return err
+		}
+	} else {
+		notesDir, err = GetNotesDir()
+		if err != nil {
+			return fmt.Errorf("failed to get notes directory: %w", err)
+		}
 	}
 
 	notesList, err := GetNotesNeedingEnrichment(notesDir)
@@ -31,6 +64,116 @@ func CmdEnrich(args []string) error {
 		return fmt.Errorf("failed to load meta file: %w", err)
 	}
 
+	if *batchTokensFlag == 0 {
+		return enrichNotes(notesDir, meta, notesList, *applyFlag, os.Stdout)
+	}
+
+	batches, err := splitEnrichBatches(meta, notesList, *batchTokensFlag, *applyFlag != "")
+	if err != nil {
+		return err
+	}
+
+	for i, batch := range batches {
+		switch {
+		case *batchDirFlag != "":
+			if err := writeEnrichBatchFile(*batchDirFlag, i+1, meta, batch); err != nil {
+				return err
+			}
+		case *applyFlag != "":
+			fmt.Printf("Batch %d of %d:\n", i+1, len(batches))
+			if err := enrichNotes(notesDir, meta, batch, *applyFlag, os.Stdout); err != nil {
+				return err
+			}
+			fmt.Println()
+		default:
+			fmt.Printf("<!-- batch %d of %d -->\n\n", i+1, len(batches))
+			writeEnrichPrompt(os.Stdout, meta, batch, false)
+			fmt.Println()
+		}
+	}
+
+	if *batchDirFlag != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %d batches of %d notes to %s\n", len(batches), len(notesList), *batchDirFlag)
+	} else {
+		fmt.Fprintf(os.Stderr, "Produced %d batches of %d notes\n", len(batches), len(notesList))
+	}
+	return nil
+}
+
+// estimateTokens approximates how many tokens text uses, at about four
+// characters per token
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// splitEnrichBatches groups notesList, in order, into batches whose prompts
+// are estimated to stay within budget tokens. Each note is counted with its
+// full content, whether the prompt includes it or the reader fetches it with
+// 'notes show'. A note too large for any batch gets one of its own.
+func splitEnrichBatches(meta *MetaFile, notesList []*Note, budget int, inline bool) ([][]*Note, error) {
+	var base strings.Builder
+	writeEnrichPrompt(&base, meta, nil, inline)
+	overhead := estimateTokens(base.String())
+	if overhead >= budget {
+		return nil, fmt.Errorf("--batch-tokens %d is too small: the prompt needs about %d tokens before any note", budget, overhead)
+	}
+
+	var batches [][]*Note
+	var batch []*Note
+	used := overhead
+	for _, note := range notesList {
+		var section strings.Builder
+		writeEnrichNote(&section, note, true)
+		cost := estimateTokens(section.String())
+
+		if len(batch) > 0 && used+cost > budget {
+			batches = append(batches, batch)
+			batch, used = nil, overhead
+		}
+		if overhead+cost > budget {
+			fmt.Fprintf(os.Stderr, "Warning: %s alone exceeds the token budget\n", note.Name())
+		}
+		batch = append(batch, note)
+		used += cost
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+// writeEnrichBatchFile writes the prompt for one batch to dir/batch-NNN.md
+func writeEnrichBatchFile(dir string, n int, meta *MetaFile, batch []*Note) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create batch directory: %w", err)
+	}
+
+	var prompt bytes.Buffer
+	writeEnrichPrompt(&prompt, meta, batch, false)
+	path := filepath.Join(dir, fmt.Sprintf("batch-%03d.md", n))
+	if err := os.WriteFile(path, prompt.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// enrichNotes writes the enrichment prompt for notesList to w or, if
+// command is set, pipes it into command and applies the result
+func enrichNotes(notesDir string, meta *MetaFile, notesList []*Note, command string, w io.Writer) error {
+	if command == "" {
+		writeEnrichPrompt(w, meta, notesList, false)
+		return nil
+	}
+
+	var prompt bytes.Buffer
+	writeEnrichPrompt(&prompt, meta, notesList, true)
+	return applyEnrichment(notesDir, notesList, command, &prompt)
+}
+
+// writeEnrichPrompt writes the enrichment prompt. For a piped command
+// (inline) the note bodies are included and a JSON answer is requested,
+// since the command can't run notes itself.
+func writeEnrichPrompt(w io.Writer, meta *MetaFile, notesList []*Note, inline bool) {
 	// Build context of existing enriched notes
 	var existingNotes []string
 	for filename, fileMeta := range meta.Files {
@@ -39,62 +182,183 @@ func CmdEnrich(args []string) error {
 				filename, fileMeta.Summary, strings.Join(fileMeta.Tags, ", ")))
 		}
 	}
+	sort.Strings(existingNotes)
 
 	// Output the prompt
-	fmt.Println("# Notes Enrichment Request")
-	fmt.Println()
-	fmt.Println("Please enrich the following notes by adding tags, a summary, and identifying related notes.")
-	fmt.Println()
-	fmt.Println("## Available CLI Commands")
-	fmt.Println()
-	fmt.Println("Use these commands to explore notes and find relationships:")
-	fmt.Println()
-	fmt.Println("- `notes list` - List all notes (newest first) to see what's available")
-	fmt.Println("- `notes show <filename>` - Read the full content of any note")
-	fmt.Println("- `notes meta <filename>` - View a note's metadata (tags, summary, related) as JSON")
-	fmt.Println("- `notes tags` - List all tags with counts to find thematic connections")
-	fmt.Println("- `notes graph [filename]` - Show relationship graph (all notes or specific note)")
-	fmt.Println("- `notes update <filename>` - Update a note's metadata (see below)")
-	fmt.Println()
-	fmt.Println("## Finding Related Notes")
-	fmt.Println()
-	fmt.Println("To identify meaningful relationships between notes:")
-	fmt.Println()
-	fmt.Println("1. **Browse by tags**: Run `notes tags` to see common themes, then explore notes sharing tags")
-	fmt.Println("2. **Read full content**: Use `notes show <filename>` to read notes that might be related")
-	fmt.Println("3. **Check existing relationships**: Use `notes graph` to see how notes are already connected")
-	fmt.Println("4. **Look for**: shared concepts, references to the same topics, sequential ideas, or complementary information")
-	fmt.Println()
-	fmt.Println("## Instructions")
-	fmt.Println()
-	fmt.Println("For each note below:")
-	fmt.Println("1. **Tags**: Add 2-5 relevant tags (lowercase, single words or hyphenated)")
-	fmt.Println("2. **Summary**: Write a concise one-sentence summary (under 80 chars)")
-	fmt.Println("3. **Related**: Identify related notes by exploring the existing notes")
-	fmt.Println()
-	fmt.Println("After analyzing, use the `notes update` command for each note:")
-	fmt.Println("```")
-	fmt.Println("notes update <filename> --tags \"tag1,tag2,tag3\" --summary \"Your summary here\" --related \"file1.md,file2.md\"")
-	fmt.Println("```")
-	fmt.Println()
+	fmt.Fprintln(w, "# Notes Enrichment Request")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Please enrich the following notes by adding tags, a summary, and identifying related notes.")
+	fmt.Fprintln(w)
+	if !inline {
+		fmt.Fprintln(w, "## Available CLI Commands")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Use these commands to explore notes and find relationships:")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "- `notes list` - List all notes (newest first) to see what's available")
+		fmt.Fprintln(w, "- `notes show <filename>` - Read the full content of any note")
+		fmt.Fprintln(w, "- `notes meta <filename>` - View a note's metadata (tags, summary, related) as JSON")
+		fmt.Fprintln(w, "- `notes tags` - List all tags with counts to find thematic connections")
+		fmt.Fprintln(w, "- `notes graph [filename]` - Show relationship graph (all notes or specific note)")
+		fmt.Fprintln(w, "- `notes update <filename>` - Update a note's metadata (see below)")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "## Finding Related Notes")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "To identify meaningful relationships between notes:")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "1. **Browse by tags**: Run `notes tags` to see common themes, then explore notes sharing tags")
+		fmt.Fprintln(w, "2. **Read full content**: Use `notes show <filename>` to read notes that might be related")
+		fmt.Fprintln(w, "3. **Check existing relationships**: Use `notes graph` to see how notes are already connected")
+		fmt.Fprintln(w, "4. **Look for**: shared concepts, references to the same topics, sequential ideas, or complementary information")
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "## Instructions")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "For each note below:")
+	fmt.Fprintln(w, "1. **Tags**: Add 2-5 relevant tags (lowercase, single words or hyphenated)")
+	fmt.Fprintln(w, "2. **Summary**: Write a concise one-sentence summary (under 80 chars)")
+	fmt.Fprintln(w, "3. **Related**: Identify related notes by exploring the existing notes")
+	fmt.Fprintln(w)
+	if inline {
+		fmt.Fprintln(w, "Respond with only a JSON object keyed by filename, with no other text:")
+		fmt.Fprintln(w, "```json")
+		fmt.Fprintln(w, "{\"<filename>\": {\"tags\": [\"tag1\", \"tag2\"], \"summary\": \"Your summary here\", \"related\": [\"file1.md\"]}}")
+		fmt.Fprintln(w, "```")
+	} else {
+		fmt.Fprintln(w, "After analyzing, use the `notes update` command for each note:")
+		fmt.Fprintln(w, "```")
+		fmt.Fprintln(w, "notes update <filename> --tags \"tag1,tag2,tag3\" --summary \"Your summary here\" --related \"file1.md,file2.md\"")
+		fmt.Fprintln(w, "```")
+	}
+	fmt.Fprintln(w)
 
 	if len(existingNotes) > 0 {
-		fmt.Println("## Existing Notes (for finding relations)")
-		fmt.Println()
+		fmt.Fprintln(w, "## Existing Notes (for finding relations)")
+		fmt.Fprintln(w)
 		for _, note := range existingNotes {
-			fmt.Println(note)
+			fmt.Fprintln(w, note)
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 
-	fmt.Println("## Notes to Enrich")
-	fmt.Println()
-	fmt.Println("Use `notes show <filename>` to read each note's content:")
-	fmt.Println()
+	fmt.Fprintln(w, "## Notes to Enrich")
+	fmt.Fprintln(w)
+	if !inline {
+		fmt.Fprintln(w, "Use `notes show <filename>` to read each note's content:")
+		fmt.Fprintln(w)
+	}
 	for _, note := range notesList {
-		filename := filepath.Base(note.Filename)
-		fmt.Printf("- %s (created: %s)\n", filename, note.Frontmatter.Created.Format("2006-01-02 15:04"))
+		writeEnrichNote(w, note, inline)
+	}
+}
+
+// writeEnrichNote writes a note's entry in the "Notes to Enrich" section:
+// its full content when inline, otherwise a list item
+func writeEnrichNote(w io.Writer, note *Note, inline bool) {
+	filename := note.Name()
+	created := note.Frontmatter.Created.Format("2006-01-02 15:04")
+	if !inline {
+		fmt.Fprintf(w, "- %s (created: %s)\n", filename, created)
+		return
+	}
+	fmt.Fprintf(w, "### %s (created: %s)\n\n", filename, created)
+	fmt.Fprintln(w, strings.TrimSpace(note.Content))
+	fmt.Fprintln(w)
+}
+
+// applyEnrichment runs command with the prompt on stdin and applies the
+// {filename: {tags, summary, related}} JSON it prints. Only notes that were
+// part of the prompt are updated; each note's outcome is reported.
+// .meta.json is locked and loaded again once the command is done, as it may
+// have been changed while the command ran.
+func applyEnrichment(notesDir string, notesList []*Note, command string, prompt io.Reader) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = prompt
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to run %q: %w", command, err)
 	}
 
+	updates, err := parseEnrichmentOutput(out)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := LockMeta(notesDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	finish := beginUndo(notesDir, "enrich")
+	defer finish()
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	requested := make(map[string]bool)
+	for _, note := range notesList {
+		requested[note.Name()] = true
+	}
+
+	var names []string
+	for name := range updates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	updated := 0
+	for _, name := range names {
+		filename := NormalizeFilename(name)
+		if !requested[filename] {
+			fmt.Printf("Skipped %s: not in the enrichment request\n", filename)
+			continue
+		}
+		if err := ApplyUpdate(notesDir, meta, filename, updates[name]); err != nil {
+			fmt.Printf("Skipped %s: %v\n", filename, err)
+			continue
+		}
+		delete(requested, filename)
+		updated++
+		fmt.Printf("Updated %s\n", filename)
+	}
+
+	if updated > 0 {
+		if err := meta.Save(notesDir); err != nil {
+			return fmt.Errorf("failed to save meta file: %w", err)
+		}
+	}
+
+	var missing []string
+	for filename := range requested {
+		missing = append(missing, filename)
+	}
+	sort.Strings(missing)
+	for _, filename := range missing {
+		fmt.Printf("Missing %s: no result returned\n", filename)
+	}
+
+	fmt.Printf("\nEnriched %d of %d notes\n", updated, len(notesList))
 	return nil
 }
+
+// parseEnrichmentOutput decodes the command's JSON answer, tolerating text
+// (such as a Markdown code fence) around the outermost object
+func parseEnrichmentOutput(out []byte) (map[string]NoteUpdate, error) {
+	start := bytes.IndexByte(out, '{')
+	end := bytes.LastIndexByte(out, '}')
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("command output contains no JSON object")
+	}
+
+	var updates map[string]NoteUpdate
+	dec := json.NewDecoder(bytes.NewReader(out[start : end+1]))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&updates); err != nil {
+		return nil, fmt.Errorf("invalid JSON from command: %w", err)
+	}
+	return updates, nil
+}
@@ -0,0 +1,269 @@
+package notes
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exportStampFormat is how export times are recorded in --changed-since files
+const exportStampFormat = time.RFC3339Nano
+
+// ExportNoteOutput is one note in the array written by 'notes export --format json'
+type ExportNoteOutput struct {
+	Filename string `json:"filename"`
+	FrontmatterOutput
+	Content string `json:"content"`
+}
+
+// CmdExport implements the 'notes export <dir>' command
+// Copies notes into a directory (or renders them to HTML, PDF or a single
+// JSON file), optionally only those changed since a date or since the
+// previous export
+func CmdExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	formatFlag := fs.String("format", "md", "output format: md (copy as is), html, pdf or json")
+	outFlag := fs.String("out", "", "directory to export into (instead of the positional argument)")
+	sinceFlag := fs.String("since", "", "only export notes modified after this date (YYYY-MM-DD or RFC 3339)")
+	changedSinceFlag := fs.String("changed-since", "", "only export notes modified after the time in this file, then record this export's time in it")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if *outFlag != "" {
+		positional = append(positional, *outFlag)
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: notes export <dir> [--format md|html|pdf|json] [--since <date> | --changed-since <file>]")
+	}
+
+	var pdf *pdfBackend
+	switch *formatFlag {
+	case "md", "html", "json":
+	case "pdf":
+		// Fail before exporting anything if PDFs can't be produced
+		if pdf, err = findPDFBackend(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid --format value: %s (expected md, html, pdf or json)", *formatFlag)
+	}
+	if *sinceFlag != "" && *changedSinceFlag != "" {
+		return fmt.Errorf("--since and --changed-since cannot be combined")
+	}
+
+	var since time.Time
+	if *sinceFlag != "" {
+		since, err = parseExportSince(*sinceFlag)
+		if err != nil {
+			return err
+		}
+	}
+	if *changedSinceFlag != "" {
+		since, err = readExportStamp(*changedSinceFlag)
+		if err != nil {
+			return err
+		}
+	}
+
+	notesDir, err := GetNotesDir()
+	if err != nil {
+		return fmt.Errorf("failed to get notes directory: %w", err)
+	}
+
+	outDir := positional[0]
+	if sameDir(notesDir, outDir) {
+		return fmt.Errorf("cannot export into the notes directory")
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	// Taken before reading any note, so edits made during the export are
+	// picked up by the next run
+	exportTime := time.Now()
+
+	notesList, err := LoadAllNotes(notesDir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(notesList, func(i, j int) bool {
+		return notesList[i].Filename < notesList[j].Filename
+	})
+
+	exported := 0
+	jsonNotes := []ExportNoteOutput{}
+	for _, note := range notesList {
+		filename := note.Name()
+
+		info, err := os.Stat(note.Filename)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", filename, err)
+		}
+		if !info.ModTime().After(since) {
+			continue
+		}
+
+		// Notes in subdirectories are exported into the same subdirectories
+		if dir := filepath.Dir(filepath.FromSlash(filename)); dir != "." && *formatFlag != "json" {
+			if err := os.MkdirAll(filepath.Join(outDir, dir), 0755); err != nil {
+				return fmt.Errorf("failed to create export directory: %w", err)
+			}
+		}
+
+		outName := filename
+		switch *formatFlag {
+		case "json":
+			jsonNotes = append(jsonNotes, ExportNoteOutput{
+				Filename:          filename,
+				FrontmatterOutput: newFrontmatterOutput(note.Frontmatter),
+				Content:           note.Content,
+			})
+			exported++
+			continue
+		case "html":
+			outName = exportLink(filename)
+			if err := os.WriteFile(filepath.Join(outDir, outName), []byte(renderNoteHTML(note)), 0644); err != nil {
+				return fmt.Errorf("failed to export %s: %w", filename, err)
+			}
+		case "pdf":
+			outName = strings.TrimSuffix(filename, ".md") + ".pdf"
+			if err := pdf.render(note, filepath.Join(outDir, outName)); err != nil {
+				return fmt.Errorf("failed to export %s: %w", filename, err)
+			}
+		default:
+			data, err := os.ReadFile(note.Filename)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", filename, err)
+			}
+			if err := os.WriteFile(filepath.Join(outDir, outName), data, 0644); err != nil {
+				return fmt.Errorf("failed to export %s: %w", filename, err)
+			}
+		}
+
+		fmt.Printf("Exported: %s\n", outName)
+		exported++
+	}
+
+	switch *formatFlag {
+	case "html":
+		// The index links every note, including those left over from
+		// earlier --since exports
+		if err := os.WriteFile(filepath.Join(outDir, "index.html"), []byte(renderIndexHTML(notesList)), 0644); err != nil {
+			return fmt.Errorf("failed to write index: %w", err)
+		}
+		fmt.Println("Exported: index.html")
+	case "json":
+		data, err := json.MarshalIndent(jsonNotes, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode notes: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(outDir, "notes.json"), append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write notes.json: %w", err)
+		}
+		fmt.Println("Exported: notes.json")
+	}
+
+	if *changedSinceFlag != "" {
+		stamp := exportTime.UTC().Format(exportStampFormat) + "\n"
+		if err := os.WriteFile(*changedSinceFlag, []byte(stamp), 0644); err != nil {
+			return fmt.Errorf("failed to record export time: %w", err)
+		}
+	}
+
+	fmt.Printf("\nExported %d of %d notes to %s\n", exported, len(notesList), outDir)
+	return nil
+}
+
+// parseExportSince accepts a date (midnight local time) or an RFC 3339 timestamp
+func parseExportSince(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since value: %s (expected YYYY-MM-DD or RFC 3339)", value)
+	}
+	return t, nil
+}
+
+// readExportStamp returns the time recorded by the previous export, or the
+// zero time if there was none so that everything is exported
+func readExportStamp(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	t, err := time.Parse(exportStampFormat, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid export time in %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// pdfBackend converts an HTML file to PDF
+type pdfBackend struct {
+	name    string
+	command func(htmlPath, pdfPath string) *exec.Cmd
+}
+
+// findPDFBackend picks NOTES_PDF_COMMAND if set, otherwise the first of
+// wkhtmltopdf and pandoc found on PATH
+func findPDFBackend() (*pdfBackend, error) {
+	if custom := os.Getenv("NOTES_PDF_COMMAND"); custom != "" {
+		return &pdfBackend{name: custom, command: func(htmlPath, pdfPath string) *exec.Cmd {
+			// The HTML and PDF paths are passed as $1 and $2
+			return exec.Command("sh", "-c", custom, "sh", htmlPath, pdfPath)
+		}}, nil
+	}
+	if path, err := exec.LookPath("wkhtmltopdf"); err == nil {
+		return &pdfBackend{name: path, command: func(htmlPath, pdfPath string) *exec.Cmd {
+			return exec.Command(path, "--quiet", htmlPath, pdfPath)
+		}}, nil
+	}
+	if path, err := exec.LookPath("pandoc"); err == nil {
+		return &pdfBackend{name: path, command: func(htmlPath, pdfPath string) *exec.Cmd {
+			return exec.Command(path, htmlPath, "-o", pdfPath)
+		}}, nil
+	}
+	return nil, fmt.Errorf("no PDF backend found: install wkhtmltopdf or pandoc, or set NOTES_PDF_COMMAND to a command converting the HTML file $1 to the PDF file $2")
+}
+
+// render writes note to pdfPath by way of a temporary HTML file
+func (p *pdfBackend) render(note *Note, pdfPath string) error {
+	tmp, err := os.CreateTemp("", "notes-export-*.html")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(renderNoteHTML(note))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write HTML: %w", err)
+	}
+
+	cmd := p.command(tmp.Name(), pdfPath)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", p.name, err)
+	}
+	if info, err := os.Stat(pdfPath); err != nil || info.Size() == 0 {
+		return fmt.Errorf("%s produced no PDF", p.name)
+	}
+	return nil
+}
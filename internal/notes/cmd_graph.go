@@ -1,13 +1,15 @@
 package notes
 
 import (
-	"encoding/json"
+	"encoding/csv"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"unicode"
 )
 
 // CmdGraph implements the 'notes graph [filename]' command
@@ -15,6 +17,18 @@ func CmdGraph(args []string) error {
 	fs := flag.NewFlagSet("graph", flag.ExitOnError)
 	depthFlag := fs.Int("depth", 2, "how many hops to traverse")
 	jsonFlag := fs.Bool("json", false, "output as JSON")
+	flatFlag := fs.Bool("flat", false, "output JSON as flat node and edge lists")
+	rootTagFlag := fs.String("root-tag", "", "start from all notes carrying this tag")
+	noSummariesFlag := fs.Bool("no-summaries", false, "omit summaries (avoids reading notes)")
+	compactFlag := fs.Bool("compact", false, "output single-line JSON (implies --json)")
+	formatFlag := fs.String("format", "", "export as CSV (edgelist: source,target,shared_tags; adjacency: matrix), Graphviz (dot) or mermaid")
+	edgeLabelsFlag := fs.Bool("edge-labels", false, "with --format dot or mermaid, label edges with the notes' shared tags")
+	clustersFlag := fs.Bool("clusters", false, "group notes into connected clusters")
+	clusterTagsFlag := fs.Bool("cluster-tags", false, "label clusters with their top tags and most connected note (implies --clusters)")
+	pathFlag := fs.Bool("path", false, "print the shortest chain of relations between two notes given as arguments")
+	singletonsFlag := fs.Bool("singletons", false, "with --clusters, also list the notes without relations instead of only counting them")
+	tagEdgesFlag := fs.Bool("include-tag-edges", false, "also show connections inferred from shared tags")
+	minSharedFlag := fs.Int("min-shared", 1, "shared tags needed for an inferred connection (with --include-tag-edges)")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -30,41 +44,155 @@ func CmdGraph(args []string) error {
 		return fmt.Errorf("failed to load meta file: %w", err)
 	}
 
+	// Entries synced before a note was added to .notesignore stay out too
+	ignore, err := LoadIgnore(notesDir)
+	if err != nil {
+		return err
+	}
+	for filename := range meta.Files {
+		if ignore.Excludes(filename) {
+			delete(meta.Files, filename)
+		}
+	}
+
 	remaining := fs.Args()
+	g := &graphView{notesDir: notesDir, meta: meta, summaries: !*noSummariesFlag, compact: *compactFlag}
+	asJSON := *jsonFlag || *compactFlag
+
+	if *tagEdgesFlag {
+		if *minSharedFlag < 1 {
+			return fmt.Errorf("--min-shared must be at least 1")
+		}
+		// Trees follow explicit relations only
+		if *formatFlag == "" && !*flatFlag && (*rootTagFlag != "" || len(remaining) > 0) {
+			return fmt.Errorf("--include-tag-edges needs --flat or --format when showing a neighborhood")
+		}
+		g.minShared = *minSharedFlag
+	}
+
+	if *formatFlag != "" {
+		switch *formatFlag {
+		case "edgelist", "adjacency", "dot", "mermaid":
+		default:
+			return fmt.Errorf("invalid --format value: %s (expected edgelist, adjacency, dot or mermaid)", *formatFlag)
+		}
+		include, err := graphScope(g, remaining, *rootTagFlag, *depthFlag)
+		if err != nil {
+			return err
+		}
+		switch *formatFlag {
+		case "adjacency":
+			return writeAdjacencyCSV(os.Stdout, buildFlatGraph(g, include))
+		case "dot":
+			return writeDOT(os.Stdout, buildFlatGraph(g, include), *edgeLabelsFlag)
+		case "mermaid":
+			return writeMermaid(os.Stdout, buildFlatGraph(g, include), *edgeLabelsFlag)
+		}
+		return writeEdgeListCSV(os.Stdout, buildFlatGraph(g, include), g.minShared > 0)
+	}
+	if *edgeLabelsFlag {
+		return fmt.Errorf("--edge-labels requires --format dot or mermaid")
+	}
+
+	if *pathFlag {
+		return showPath(g, remaining, asJSON)
+	}
+
+	if *clustersFlag || *clusterTagsFlag {
+		return showClusters(g, asJSON, *clusterTagsFlag, *singletonsFlag)
+	}
+	if *singletonsFlag {
+		return fmt.Errorf("--singletons requires --clusters")
+	}
+
+	if *rootTagFlag != "" {
+		return showTagNeighborhood(g, *rootTagFlag, *depthFlag, asJSON, *flatFlag)
+	}
 
 	if len(remaining) > 0 {
 		// Show specific note's neighborhood
 		filename := NormalizeFilename(remaining[0])
-		return showNeighborhood(notesDir, meta, filename, *depthFlag, *jsonFlag)
+		if *flatFlag {
+			notePath := filepath.Join(notesDir, filename)
+			if _, err := os.Stat(notePath); os.IsNotExist(err) {
+				return fmt.Errorf("note not found: %s", filename)
+			}
+			include := collectNeighborhood(meta, []string{filename}, *depthFlag)
+			return outputJSON(buildFlatGraph(g, include), g.compact)
+		}
+		return showNeighborhood(g, filename, *depthFlag, asJSON)
+	}
+
+	if *flatFlag {
+		return outputJSON(buildFlatGraph(g, nil), g.compact)
 	}
 
 	// Show all connections
-	return showAllConnections(meta, *jsonFlag)
+	return showAllConnections(g, asJSON)
 }
 
-func showAllConnections(meta *MetaFile, asJSON bool) error {
+// graphView holds what graph rendering needs to look up notes
+type graphView struct {
+	notesDir  string
+	meta      *MetaFile
+	summaries bool // When false, summaries are omitted and no notes are parsed
+	compact   bool // Single-line JSON output
+	minShared int  // Shared tags needed for an inferred edge; 0 shows explicit relations only
+}
+
+// summary returns the note's summary, or "" if summaries are disabled
+func (g *graphView) summary(filename string) string {
+	if !g.summaries {
+		return ""
+	}
+	return getSummary(g.notesDir, g.meta, filename)
+}
+
+// label formats a note for text output, with its summary if enabled
+func (g *graphView) label(filename string) string {
+	if !g.summaries {
+		return filename
+	}
+	return fmt.Sprintf("%s %q", filename, g.summary(filename))
+}
+
+func showAllConnections(g *graphView, asJSON bool) error {
+	meta := g.meta
+
+	// Inferred edges are undirected, so they're listed under both notes
+	inferred := make(map[string][]string)
+	if g.minShared > 0 {
+		for key := range inferTagEdges(meta, g.minShared, nil) {
+			inferred[key[0]] = append(inferred[key[0]], key[1])
+			inferred[key[1]] = append(inferred[key[1]], key[0])
+		}
+		for _, others := range inferred {
+			sort.Strings(others)
+		}
+	}
+
 	if asJSON {
 		type connection struct {
 			From       string   `json:"from"`
 			To         []string `json:"to"`
+			InferredTo []string `json:"inferred_to,omitempty"`
 			SharedTags []string `json:"shared_tags,omitempty"`
 		}
 		var connections []connection
 		for filename, fileMeta := range meta.Files {
-			if len(fileMeta.Related) > 0 {
+			if len(fileMeta.Related) > 0 || len(inferred[filename]) > 0 {
 				conn := connection{
-					From: filename,
-					To:   fileMeta.Related,
+					From:       filename,
+					To:         fileMeta.Related,
+					InferredTo: inferred[filename],
+				}
+				if conn.To == nil {
+					conn.To = []string{}
 				}
 				connections = append(connections, conn)
 			}
 		}
-		data, err := json.MarshalIndent(connections, "", "  ")
-		if err != nil {
-			return err
-		}
-		fmt.Println(string(data))
-		return nil
+		return outputJSON(connections, g.compact)
 	}
 
 	// Sort filenames for consistent output
@@ -76,7 +204,7 @@ func showAllConnections(meta *MetaFile, asJSON bool) error {
 
 	for _, filename := range filenames {
 		fileMeta := meta.Files[filename]
-		if len(fileMeta.Related) == 0 {
+		if len(fileMeta.Related) == 0 && len(inferred[filename]) == 0 {
 			continue
 		}
 
@@ -89,73 +217,125 @@ func showAllConnections(meta *MetaFile, asJSON bool) error {
 				fmt.Printf("  → %s\n", rel)
 			}
 		}
+		// Dashed arrow: connected by tags only
+		for _, other := range inferred[filename] {
+			fmt.Printf("  ⇢ %s (%s)\n", other, strings.Join(getSharedTags(meta, filename, other), ", "))
+		}
 	}
 
 	return nil
 }
 
-func showNeighborhood(notesDir string, meta *MetaFile, filename string, depth int, asJSON bool) error {
+func showNeighborhood(g *graphView, filename string, depth int, asJSON bool) error {
 	// Verify file exists
-	notePath := filepath.Join(notesDir, filename)
+	notePath := filepath.Join(g.notesDir, filename)
 	if _, err := os.Stat(notePath); os.IsNotExist(err) {
 		return fmt.Errorf("note not found: %s", filename)
 	}
 
-	// Get summary for the root note
-	rootSummary := getSummary(notesDir, meta, filename)
-
 	if asJSON {
-		type graphNode struct {
-			Filename string      `json:"filename"`
-			Summary  string      `json:"summary,omitempty"`
-			Related  []graphNode `json:"related,omitempty"`
-		}
+		root := buildGraphNode(g, filename, depth, make(map[string]bool))
+		return outputJSON(root, g.compact)
+	}
 
-		visited := make(map[string]bool)
-		var buildGraph func(f string, d int) graphNode
-		buildGraph = func(f string, d int) graphNode {
-			node := graphNode{
-				Filename: f,
-				Summary:  getSummary(notesDir, meta, f),
-			}
-			if d <= 0 || visited[f] {
-				return node
-			}
-			visited[f] = true
+	// Text output with tree structure
+	fmt.Println(g.label(filename))
 
-			if fileMeta := meta.GetFileMeta(f); fileMeta != nil {
-				for _, rel := range fileMeta.Related {
-					node.Related = append(node.Related, buildGraph(rel, d-1))
-				}
-			}
-			return node
+	visited := make(map[string]bool)
+	visited[filename] = true
+
+	fileMeta := g.meta.GetFileMeta(filename)
+	if fileMeta == nil {
+		return nil
+	}
+
+	printTree(g, fileMeta.Related, depth-1, "", visited)
+	return nil
+}
+
+// graphNode is a note in the nested JSON tree representation
+type graphNode struct {
+	Filename string      `json:"filename"`
+	Summary  string      `json:"summary,omitempty"`
+	Ref      bool        `json:"ref,omitempty"` // Already expanded elsewhere in the output
+	Related  []graphNode `json:"related,omitempty"`
+}
+
+// buildGraphNode builds the nested tree for f. Each note is expanded at most
+// once across the whole output (visited is shared between siblings and
+// roots); later occurrences are emitted as bare references, so the output
+// stays linear in the number of relations even for densely linked hubs.
+func buildGraphNode(g *graphView, f string, depth int, visited map[string]bool) graphNode {
+	if visited[f] {
+		return graphNode{Filename: f, Ref: true}
+	}
+
+	node := graphNode{
+		Filename: f,
+		Summary:  g.summary(f),
+	}
+	if depth <= 0 {
+		return node
+	}
+	visited[f] = true
+
+	if fileMeta := g.meta.GetFileMeta(f); fileMeta != nil {
+		for _, rel := range fileMeta.Related {
+			node.Related = append(node.Related, buildGraphNode(g, rel, depth-1, visited))
 		}
+	}
+	return node
+}
 
-		root := buildGraph(filename, depth)
-		data, err := json.MarshalIndent(root, "", "  ")
-		if err != nil {
-			return err
+// showTagNeighborhood renders the combined neighborhood of every note
+// carrying tag, expanding notes shared between roots only once
+func showTagNeighborhood(g *graphView, tag string, depth int, asJSON, flat bool) error {
+	var roots []string
+	for filename, fileMeta := range g.meta.Files {
+		if hasAnyTag(fileMeta.Tags, []string{tag}) {
+			roots = append(roots, filename)
 		}
-		fmt.Println(string(data))
-		return nil
 	}
+	sort.Strings(roots)
 
-	// Text output with tree structure
-	fmt.Printf("%s %q\n", filename, rootSummary)
+	if len(roots) == 0 {
+		return fmt.Errorf("no notes tagged %q", tag)
+	}
+
+	if flat {
+		include := collectNeighborhood(g.meta, roots, depth)
+		return outputJSON(buildFlatGraph(g, include), g.compact)
+	}
 
+	if asJSON {
+		visited := make(map[string]bool)
+		trees := make([]graphNode, 0, len(roots))
+		for _, root := range roots {
+			trees = append(trees, buildGraphNode(g, root, depth, visited))
+		}
+		return outputJSON(trees, g.compact)
+	}
+
+	// Roots are never expanded underneath each other
 	visited := make(map[string]bool)
-	visited[filename] = true
+	for _, root := range roots {
+		visited[root] = true
+	}
 
-	fileMeta := meta.GetFileMeta(filename)
-	if fileMeta == nil {
-		return nil
+	for i, root := range roots {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(g.label(root))
+		if fileMeta := g.meta.GetFileMeta(root); fileMeta != nil {
+			printTree(g, fileMeta.Related, depth-1, "", visited)
+		}
 	}
 
-	printTree(notesDir, meta, fileMeta.Related, depth-1, "", visited)
 	return nil
 }
 
-func printTree(notesDir string, meta *MetaFile, related []string, depth int, prefix string, visited map[string]bool) {
+func printTree(g *graphView, related []string, depth int, prefix string, visited map[string]bool) {
 	for i, rel := range related {
 		isLast := i == len(related)-1
 		connector := "├── "
@@ -165,12 +345,11 @@ func printTree(notesDir string, meta *MetaFile, related []string, depth int, pre
 			childPrefix = prefix + "    "
 		}
 
-		summary := getSummary(notesDir, meta, rel)
-		fmt.Printf("%s%s%s %q\n", prefix, connector, rel, summary)
+		fmt.Printf("%s%s%s\n", prefix, connector, g.label(rel))
 
 		if depth > 0 && !visited[rel] {
 			visited[rel] = true
-			if fileMeta := meta.GetFileMeta(rel); fileMeta != nil && len(fileMeta.Related) > 0 {
+			if fileMeta := g.meta.GetFileMeta(rel); fileMeta != nil && len(fileMeta.Related) > 0 {
 				// Filter out already visited nodes
 				var unvisited []string
 				for _, r := range fileMeta.Related {
@@ -179,7 +358,7 @@ func printTree(notesDir string, meta *MetaFile, related []string, depth int, pre
 					}
 				}
 				if len(unvisited) > 0 {
-					printTree(notesDir, meta, unvisited, depth-1, childPrefix, visited)
+					printTree(g, unvisited, depth-1, childPrefix, visited)
 				}
 			}
 		}
@@ -207,10 +386,14 @@ func getSharedTags(meta *MetaFile, file1, file2 string) []string {
 	if meta1 == nil || meta2 == nil {
 		return nil
 	}
+	return sharedTags(meta1.Tags, meta2.Tags)
+}
 
+// sharedTags returns the tags of tags1 that are also in tags2, ignoring case
+func sharedTags(tags1, tags2 []string) []string {
 	var shared []string
-	for _, t1 := range meta1.Tags {
-		for _, t2 := range meta2.Tags {
+	for _, t1 := range tags1 {
+		for _, t2 := range tags2 {
 			if strings.EqualFold(t1, t2) {
 				shared = append(shared, t1)
 				break
@@ -219,3 +402,577 @@ func getSharedTags(meta *MetaFile, file1, file2 string) []string {
 	}
 	return shared
 }
+
+// FlatNode is a note in the flat graph representation
+type FlatNode struct {
+	ID      string   `json:"id"`
+	Summary string   `json:"summary,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+// FlatEdge is an undirected relation between two notes. Inferred edges
+// come from shared tags rather than an explicit relation.
+type FlatEdge struct {
+	Source     string   `json:"source"`
+	Target     string   `json:"target"`
+	SharedTags []string `json:"shared_tags,omitempty"`
+	Inferred   bool     `json:"inferred,omitempty"`
+}
+
+// FlatGraph is the node/edge list format expected by graph libraries
+type FlatGraph struct {
+	Nodes []FlatNode `json:"nodes"`
+	Edges []FlatEdge `json:"edges"`
+}
+
+// graphScope returns the notes an export is limited to: the neighborhood of
+// a root note or of all notes with rootTag, or nil for the whole graph
+func graphScope(g *graphView, args []string, rootTag string, depth int) (map[string]bool, error) {
+	if rootTag != "" {
+		var roots []string
+		for filename, fileMeta := range g.meta.Files {
+			if hasAnyTag(fileMeta.Tags, []string{rootTag}) {
+				roots = append(roots, filename)
+			}
+		}
+		if len(roots) == 0 {
+			return nil, fmt.Errorf("no notes tagged %q", rootTag)
+		}
+		return collectNeighborhood(g.meta, roots, depth), nil
+	}
+
+	if len(args) > 0 {
+		filename := NormalizeFilename(args[0])
+		if _, err := os.Stat(filepath.Join(g.notesDir, filename)); os.IsNotExist(err) {
+			return nil, fmt.Errorf("note not found: %s", filename)
+		}
+		return collectNeighborhood(g.meta, []string{filename}, depth), nil
+	}
+
+	return nil, nil
+}
+
+// writeEdgeListCSV writes one row per undirected relation, with shared tags
+// separated by semicolons. withKind adds a column telling explicit
+// ("related") and inferred ("tags") edges apart.
+func writeEdgeListCSV(w io.Writer, graph FlatGraph, withKind bool) error {
+	cw := csv.NewWriter(w)
+	header := []string{"source", "target", "shared_tags"}
+	if withKind {
+		header = append(header, "kind")
+	}
+	cw.Write(header)
+	for _, edge := range graph.Edges {
+		row := []string{edge.Source, edge.Target, strings.Join(edge.SharedTags, ";")}
+		if withKind {
+			kind := "related"
+			if edge.Inferred {
+				kind = "tags"
+			}
+			row = append(row, kind)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeAdjacencyCSV writes a symmetric 0/1 matrix with note names as the
+// header row and first column
+func writeAdjacencyCSV(w io.Writer, graph FlatGraph) error {
+	index := make(map[string]int, len(graph.Nodes))
+	header := []string{""}
+	for i, node := range graph.Nodes {
+		index[node.ID] = i
+		header = append(header, node.ID)
+	}
+
+	matrix := make([][]bool, len(graph.Nodes))
+	for i := range matrix {
+		matrix[i] = make([]bool, len(graph.Nodes))
+	}
+	for _, edge := range graph.Edges {
+		s, t := index[edge.Source], index[edge.Target]
+		matrix[s][t] = true
+		matrix[t][s] = true
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for i, node := range graph.Nodes {
+		row := []string{node.ID}
+		for _, connected := range matrix[i] {
+			if connected {
+				row = append(row, "1")
+			} else {
+				row = append(row, "0")
+			}
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeDOT writes the graph as a Graphviz digraph. Nodes are labeled with
+// their summary, or their name without one. Relations are undirected, so each
+// is a single edge without arrowheads; edges inferred from tags are dashed.
+func writeDOT(w io.Writer, graph FlatGraph, edgeLabels bool) error {
+	var b strings.Builder
+	b.WriteString("digraph notes {\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, node := range graph.Nodes {
+		label := node.Summary
+		if label == "" {
+			label = node.ID
+		}
+		fmt.Fprintf(&b, "  %s [label=%s];\n", dotQuote(node.ID), dotQuote(label))
+	}
+
+	for _, edge := range graph.Edges {
+		attrs := []string{"dir=none"}
+		if edge.Inferred {
+			attrs = append(attrs, "style=dashed")
+		}
+		if edgeLabels && len(edge.SharedTags) > 0 {
+			attrs = append(attrs, "label="+dotQuote(strings.Join(edge.SharedTags, ", ")))
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", dotQuote(edge.Source), dotQuote(edge.Target), strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote renders s as a double-quoted DOT ID
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	s = strings.ReplaceAll(s, "\n", "\\n")
+	return "\"" + s + "\""
+}
+
+// writeMermaid writes the graph as a Mermaid flowchart for markdown docs.
+// Node ids are derived from filenames and the labels are the summaries, as
+// in writeDOT; edges inferred from tags are dotted.
+func writeMermaid(w io.Writer, graph FlatGraph, edgeLabels bool) error {
+	ids := mermaidIDs(graph.Nodes)
+
+	var b strings.Builder
+	b.WriteString("graph TD\n")
+	for _, node := range graph.Nodes {
+		label := node.Summary
+		if label == "" {
+			label = node.ID
+		}
+		fmt.Fprintf(&b, "  %s[%s]\n", ids[node.ID], mermaidQuote(label))
+	}
+
+	for _, edge := range graph.Edges {
+		link := "---"
+		if edge.Inferred {
+			link = "-.-"
+		}
+		if edgeLabels && len(edge.SharedTags) > 0 {
+			link += "|" + mermaidQuote(strings.Join(edge.SharedTags, ", ")) + "|"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", ids[edge.Source], link, ids[edge.Target])
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidIDs maps each note to a node id made of letters, digits and
+// underscores, which Mermaid accepts anywhere. Names that sanitize to the
+// same id are told apart by a numeric suffix.
+func mermaidIDs(nodes []FlatNode) map[string]string {
+	ids := make(map[string]string, len(nodes))
+	used := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		var b strings.Builder
+		b.WriteString("n_")
+		for _, r := range strings.TrimSuffix(node.ID, ".md") {
+			if r < 128 && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				b.WriteRune(r)
+			} else {
+				b.WriteRune('_')
+			}
+		}
+		id := b.String()
+		for i := 2; used[id]; i++ {
+			id = fmt.Sprintf("%s_%d", b.String(), i)
+		}
+		used[id] = true
+		ids[node.ID] = id
+	}
+	return ids
+}
+
+// mermaidQuote renders s as a quoted Mermaid label. Quotes can't be escaped
+// with a backslash, so they become the #quot; entity.
+func mermaidQuote(s string) string {
+	s = strings.ReplaceAll(s, "\"", "#quot;")
+	s = strings.ReplaceAll(s, "\n", " ")
+	return "\"" + s + "\""
+}
+
+// collectNeighborhood returns all notes reachable from the roots within depth hops
+func collectNeighborhood(meta *MetaFile, roots []string, depth int) map[string]bool {
+	visited := make(map[string]bool)
+	frontier := []string{}
+	for _, root := range roots {
+		if !visited[root] {
+			visited[root] = true
+			frontier = append(frontier, root)
+		}
+	}
+
+	for d := 0; d < depth && len(frontier) > 0; d++ {
+		var next []string
+		for _, f := range frontier {
+			fileMeta := meta.GetFileMeta(f)
+			if fileMeta == nil {
+				continue
+			}
+			for _, rel := range fileMeta.Related {
+				if !visited[rel] {
+					visited[rel] = true
+					next = append(next, rel)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return visited
+}
+
+// buildFlatGraph builds deduplicated node and edge lists. If include is nil,
+// every note with at least one relation is included; otherwise only the
+// given notes and the edges between them.
+func buildFlatGraph(g *graphView, include map[string]bool) FlatGraph {
+	nodeSet := make(map[string]bool)
+	edgeSet := make(map[[2]string]bool)
+
+	for filename, fileMeta := range g.meta.Files {
+		if include != nil && !include[filename] {
+			continue
+		}
+		for _, rel := range fileMeta.Related {
+			if include != nil && !include[rel] {
+				continue
+			}
+			nodeSet[filename] = true
+			nodeSet[rel] = true
+
+			key := [2]string{filename, rel}
+			if rel < filename {
+				key = [2]string{rel, filename}
+			}
+			edgeSet[key] = true
+		}
+	}
+
+	var inferred map[[2]string]bool
+	if g.minShared > 0 {
+		inferred = make(map[[2]string]bool)
+		for key := range inferTagEdges(g.meta, g.minShared, include) {
+			nodeSet[key[0]] = true
+			nodeSet[key[1]] = true
+			edgeSet[key] = true
+			inferred[key] = true
+		}
+	}
+
+	// Isolated included notes (e.g. a root without relations) are still nodes
+	for filename := range include {
+		nodeSet[filename] = true
+	}
+
+	graph := FlatGraph{
+		Nodes: []FlatNode{},
+		Edges: []FlatEdge{},
+	}
+
+	for filename := range nodeSet {
+		node := FlatNode{
+			ID:      filename,
+			Summary: g.summary(filename),
+		}
+		if fileMeta := g.meta.GetFileMeta(filename); fileMeta != nil {
+			node.Tags = fileMeta.Tags
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+
+	for key := range edgeSet {
+		graph.Edges = append(graph.Edges, FlatEdge{
+			Source:     key[0],
+			Target:     key[1],
+			SharedTags: getSharedTags(g.meta, key[0], key[1]),
+			Inferred:   inferred[key],
+		})
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].Source != graph.Edges[j].Source {
+			return graph.Edges[i].Source < graph.Edges[j].Source
+		}
+		return graph.Edges[i].Target < graph.Edges[j].Target
+	})
+
+	return graph
+}
+
+// inferTagEdges returns pairs of notes sharing at least minShared tags that
+// aren't already related, keyed with the smaller filename first. If include
+// is non-nil, only pairs of included notes are considered.
+func inferTagEdges(meta *MetaFile, minShared int, include map[string]bool) map[[2]string]bool {
+	var filenames []string
+	for filename, fileMeta := range meta.Files {
+		if len(fileMeta.Tags) > 0 && (include == nil || include[filename]) {
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+
+	edges := make(map[[2]string]bool)
+	for i, a := range filenames {
+		for _, b := range filenames[i+1:] {
+			if Contains(meta.Files[a].Related, b) || Contains(meta.Files[b].Related, a) {
+				continue
+			}
+			if len(getSharedTags(meta, a, b)) >= minShared {
+				edges[[2]string{a, b}] = true
+			}
+		}
+	}
+	return edges
+}
+
+// graphCluster is a connected group of related notes
+type graphCluster struct {
+	Size           int      `json:"size"`
+	TopTags        []string `json:"top_tags,omitempty"`
+	Representative string   `json:"representative,omitempty"` // The member with the most relations
+	Members        []string `json:"members"`
+}
+
+// relationNeighbors maps each note to the notes it relates to in either
+// direction, ignoring relations to notes without an entry
+func relationNeighbors(meta *MetaFile) map[string][]string {
+	neighbors := make(map[string][]string)
+	for filename, fileMeta := range meta.Files {
+		for _, rel := range fileMeta.Related {
+			if rel == filename || meta.GetFileMeta(rel) == nil {
+				continue
+			}
+			neighbors[filename] = append(neighbors[filename], rel)
+			neighbors[rel] = append(neighbors[rel], filename)
+		}
+	}
+	return neighbors
+}
+
+// shortestPath returns the shortest chain of related notes from one note to
+// another, including both ends, or nil if they aren't connected. Ties are
+// broken by filename so the result is stable.
+func shortestPath(meta *MetaFile, from, to string) []string {
+	neighbors := relationNeighbors(meta)
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == to {
+			var path []string
+			for f := to; f != ""; f = previous[f] {
+				path = append([]string{f}, path...)
+			}
+			return path
+		}
+
+		next := neighbors[current]
+		sort.Strings(next)
+		for _, n := range next {
+			if _, seen := previous[n]; !seen {
+				previous[n] = current
+				queue = append(queue, n)
+			}
+		}
+	}
+	return nil
+}
+
+// showPath prints the shortest chain of relations between two notes
+func showPath(g *graphView, args []string, asJSON bool) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: notes graph --path <from> <to>")
+	}
+
+	var ends [2]string
+	for i, arg := range args {
+		filename, err := ResolveNote(g.notesDir, arg)
+		if err != nil {
+			return err
+		}
+		ends[i] = filename
+	}
+
+	path := shortestPath(g.meta, ends[0], ends[1])
+	if path == nil {
+		return fmt.Errorf("%s and %s are not connected", ends[0], ends[1])
+	}
+
+	if asJSON {
+		return outputJSON(struct {
+			Hops int      `json:"hops"`
+			Path []string `json:"path"`
+		}{len(path) - 1, path}, g.compact)
+	}
+
+	fmt.Printf("%s -> %s (%d hops)\n", ends[0], ends[1], len(path)-1)
+	for _, filename := range path {
+		fmt.Printf("  %s\n", g.label(filename))
+	}
+	return nil
+}
+
+// findClusters returns the connected components of the relation graph with
+// at least two notes, largest first, and the sorted notes without any
+// relation
+func findClusters(meta *MetaFile) ([]graphCluster, []string) {
+	neighbors := relationNeighbors(meta)
+
+	var filenames []string
+	for filename := range meta.Files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	var clusters []graphCluster
+	var singletons []string
+	visited := make(map[string]bool)
+	for _, start := range filenames {
+		if visited[start] {
+			continue
+		}
+		visited[start] = true
+		members := []string{start}
+		for i := 0; i < len(members); i++ {
+			for _, next := range neighbors[members[i]] {
+				if !visited[next] {
+					visited[next] = true
+					members = append(members, next)
+				}
+			}
+		}
+
+		if len(members) == 1 {
+			singletons = append(singletons, start)
+			continue
+		}
+		sort.Strings(members)
+		clusters = append(clusters, graphCluster{Size: len(members), Members: members})
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].Size > clusters[j].Size
+	})
+	return clusters, singletons
+}
+
+// labelCluster sets the cluster's three most common tags and its most
+// connected member. Ties go to the alphabetically first tag or note.
+func labelCluster(meta *MetaFile, cluster *graphCluster) {
+	tagFiles := make(map[string][]string)
+	for _, filename := range cluster.Members {
+		addNoteTags(tagFiles, filename, meta.Files[filename].Tags)
+	}
+	tags := make([]string, 0, len(tagFiles))
+	for tag := range tagFiles {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if len(tagFiles[tags[i]]) != len(tagFiles[tags[j]]) {
+			return len(tagFiles[tags[i]]) > len(tagFiles[tags[j]])
+		}
+		return tags[i] < tags[j]
+	})
+	if len(tags) > 3 {
+		tags = tags[:3]
+	}
+	cluster.TopTags = tags
+
+	bestDegree := -1
+	for _, filename := range cluster.Members {
+		degree := 0
+		for _, rel := range meta.Files[filename].Related {
+			if rel != filename && meta.GetFileMeta(rel) != nil {
+				degree++
+			}
+		}
+		if degree > bestDegree {
+			cluster.Representative = filename
+			bestDegree = degree
+		}
+	}
+}
+
+func showClusters(g *graphView, asJSON, labels, listSingletons bool) error {
+	clusters, singletons := findClusters(g.meta)
+	if labels {
+		for i := range clusters {
+			labelCluster(g.meta, &clusters[i])
+		}
+	}
+
+	if asJSON {
+		if clusters == nil {
+			clusters = []graphCluster{}
+		}
+		output := struct {
+			Clusters       []graphCluster `json:"clusters"`
+			Unrelated      int            `json:"unrelated"`
+			UnrelatedNotes []string       `json:"unrelated_notes,omitempty"`
+		}{Clusters: clusters, Unrelated: len(singletons)}
+		if listSingletons {
+			output.UnrelatedNotes = singletons
+		}
+		return outputJSON(output, g.compact)
+	}
+
+	for i, cluster := range clusters {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Cluster %d (%d notes)", i+1, cluster.Size)
+		if len(cluster.TopTags) > 0 {
+			fmt.Printf(": %s", strings.Join(cluster.TopTags, ", "))
+		}
+		fmt.Println()
+		if cluster.Representative != "" {
+			fmt.Printf("  representative: %s\n", cluster.Representative)
+		}
+		for _, member := range cluster.Members {
+			fmt.Printf("  %s\n", g.label(member))
+		}
+	}
+
+	if len(singletons) > 0 {
+		if len(clusters) > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%d notes without relations\n", len(singletons))
+		if listSingletons {
+			for _, filename := range singletons {
+				fmt.Printf("  %s\n", g.label(filename))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CmdImport implements the 'notes import --directory <path>' command
+// Turns the .txt and .md files in a directory into notes
+func CmdImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dirFlag := fs.String("directory", "", "directory whose .txt and .md files to import")
+	tagsFlag := fs.String("tags", "", "tags to add to every imported note (comma-separated)")
+	moveFlag := fs.Bool("move", false, "delete each original after importing it")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dirFlag == "" || fs.NArg() > 0 {
+		return fmt.Errorf("usage: notes import --directory <path> [--tags <tags>] [--move]")
+	}
+
+	notesDir, err := EnsureNotesDir()
+	if err != nil {
+		return fmt.Errorf("failed to ensure notes directory: %w", err)
+	}
+	if sameDir(notesDir, *dirFlag) {
+		return fmt.Errorf("cannot import from the notes directory")
+	}
+
+	entries, err := os.ReadDir(*dirFlag)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", *dirFlag, err)
+	}
+
+	unlock, err := LockMeta(notesDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	finish := beginUndo(notesDir, "import")
+	defer finish()
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	tags := parseCSV(*tagsFlag)
+	var sources []string
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+		if entry.IsDir() || strings.HasPrefix(name, ".") || (ext != ".txt" && ext != ".md") {
+			continue
+		}
+		sources = append(sources, filepath.Join(*dirFlag, name))
+	}
+	sort.Strings(sources)
+
+	imported := 0
+	for _, source := range sources {
+		filename, err := importFile(notesDir, meta, source, tags)
+		if err != nil {
+			fmt.Printf("Skipped %s: %v\n", filepath.Base(source), err)
+			continue
+		}
+		imported++
+
+		if *moveFlag {
+			if err := os.Remove(source); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to remove %s: %v\n", source, err)
+			}
+		}
+		fmt.Printf("Imported %s -> %s\n", filepath.Base(source), filename)
+	}
+
+	if imported > 0 {
+		if err := meta.Save(notesDir); err != nil {
+			return fmt.Errorf("failed to save meta file: %w", err)
+		}
+	}
+
+	fmt.Printf("\nImported %d of %d files\n", imported, len(sources))
+	return nil
+}
+
+// importFile writes source as a new note dated by its modification time and
+// records it in meta. Frontmatter in a .md file is replaced, keeping its
+// tags and summary. Returns the new note's filename.
+func importFile(notesDir string, meta *MetaFile, source string, tags []string) (string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return "", err
+	}
+
+	parsed, err := ParseNoteContent(source, data)
+	if err != nil {
+		return "", err
+	}
+	body := strings.TrimSpace(parsed.Content)
+	if body == "" {
+		return "", fmt.Errorf("empty file")
+	}
+
+	id, err := NewNoteID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+	noteTags := append([]string{}, parsed.Frontmatter.Tags...)
+	for _, tag := range tags {
+		if !Contains(noteTags, tag) {
+			noteTags = append(noteTags, tag)
+		}
+	}
+
+	note := &Note{
+		Frontmatter: Frontmatter{
+			ID:      id,
+			Created: NoteTime{info.ModTime()},
+			Tags:    noteTags,
+			Summary: parsed.Frontmatter.Summary,
+			Related: []string{},
+			Extra:   parsed.Frontmatter.Extra,
+		},
+		Content: "\n" + body + "\n",
+	}
+
+	if existing, err := findNoteByContentHash(notesDir, note.ContentHash()); err != nil {
+		return "", err
+	} else if existing != "" {
+		return "", fmt.Errorf("identical to %s", existing)
+	}
+
+	filename, err := generateFilenameAt(notesDir, info.ModTime())
+	if err != nil {
+		return "", err
+	}
+	note.Filename = filename
+	if err := note.Save(filepath.Join(notesDir, filename)); err != nil {
+		return "", fmt.Errorf("failed to save note: %w", err)
+	}
+
+	meta.UpdateFromNote(note)
+	return filename, nil
+}
@@ -1,32 +1,124 @@
 package notes
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
+	"text/template"
 	"time"
+	"unicode/utf8"
 )
 
+// ListEntry represents a single note in the JSON output of notes list
+type ListEntry struct {
+	Filename string   `json:"filename"`
+	Created  string   `json:"created"`
+	Summary  string   `json:"summary"`
+	Tags     []string `json:"tags"`
+	Pinned   bool     `json:"pinned,omitempty"`
+
+	// Set with --reading-time
+	WordCount   int    `json:"word_count,omitempty"`
+	ReadingTime string `json:"reading_time,omitempty"`
+}
+
+// errLimitReached stops a streaming walk once enough notes were written
+var errLimitReached = errors.New("limit reached")
+
 // CmdList implements the 'notes list' command
 func CmdList(args []string) error {
 	fs := flag.NewFlagSet("list", flag.ExitOnError)
 	tagsFlag := fs.String("tags", "", "filter by tags (comma-separated)")
+	excludeTagsFlag := fs.String("exclude-tags", "", "hide notes with any of these tags (comma-separated, wins over --tags)")
+	tagLogicFlag := fs.String("tag-logic", GetTagLogic(), "with several --tags, whether notes need any (or) or all (and) of them (default from the tag_logic setting)")
 	sinceFlag := fs.String("since", "", "filter by date (YYYY-MM-DD)")
-	limitFlag := fs.Int("limit", 20, "limit results")
+	withinFlag := fs.String("within", "", "only notes created within this long before now (e.g. 24h, 7d, 2w)")
+	limitFlag := fs.Int("limit", GetListLimit(), "limit results (default from the list_limit setting)")
 	rawFlag := fs.Bool("raw", false, "show only filenames")
+	formatFlag := fs.String("format", "", "print each note with a Go template, e.g. '{{.Filename}} {{.Created}} {{join .Tags \",\"}}'")
+	jsonFlag := fs.Bool("json", false, "output as a JSON array")
+	compactFlag := fs.Bool("compact", false, "output JSON without indentation")
+	streamFlag := fs.Bool("stream", false, "output one JSON object per line (NDJSON)")
+	sortFlag := fs.String("sort", "created", "sort order (created or none)")
+	sourceFlag := fs.String("source", "frontmatter", "where to read tags and summaries from (frontmatter or meta)")
+	summarySourceFlag := fs.String("summary-source", "summary", "what to display per note (summary, firstline or both)")
+	tagCountsFlag := fs.Bool("tag-counts", false, "show each note's tags with how many notes use them")
+	tableFlag := fs.Bool("table", false, "print an aligned table on a terminal")
+	readingTimeFlag := fs.Bool("reading-time", false, "include word count and reading time")
+	wpmFlag := fs.Int("wpm", 0, "reading speed in words per minute (default $NOTES_WPM or 200)")
+	orphansFlag := fs.Bool("orphans", false, "only list notes without relations in either direction")
+	strictFlag := fs.Bool("strict", false, "with --orphans, also require the notes to have no tags")
+	pinnedFlag := fs.Bool("pinned", false, "only list pinned notes")
 
-	if err := fs.Parse(args); err != nil {
+	err := fs.Parse(args)
+	if err != nil {
 		return err
 	}
 
+	if *sortFlag != "created" && *sortFlag != "none" {
+		return fmt.Errorf("invalid --sort value: %s (expected created or none)", *sortFlag)
+	}
+	if *sourceFlag != "frontmatter" && *sourceFlag != "meta" {
+		return fmt.Errorf("invalid --source value: %s (expected frontmatter or meta)", *sourceFlag)
+	}
+	switch *summarySourceFlag {
+	case "summary", "firstline", "both":
+	default:
+		return fmt.Errorf("invalid --summary-source value: %s (expected summary, firstline or both)", *summarySourceFlag)
+	}
+
+	if err := checkTagLogic(*tagLogicFlag); err != nil {
+		return err
+	}
+	if *sinceFlag != "" && *withinFlag != "" {
+		return fmt.Errorf("--since and --within cannot be combined")
+	}
+	if *strictFlag && !*orphansFlag {
+		return fmt.Errorf("--strict requires --orphans")
+	}
+
+	var format *template.Template
+	if *formatFlag != "" {
+		if *rawFlag || *jsonFlag || *compactFlag || *streamFlag || *tableFlag {
+			return fmt.Errorf("--format cannot be combined with --raw, --json, --compact, --stream or --table")
+		}
+		if format, err = parseListFormat(*formatFlag); err != nil {
+			return err
+		}
+	}
+
+	speed, err := GetReadingSpeed(*wpmFlag)
+	if err != nil {
+		return err
+	}
+	entry := func(note *Note) ListEntry {
+		e := newListEntry(note)
+		if *readingTimeFlag {
+			count := CountText(note.Content)
+			e.WordCount = count.Words + count.CJKChars
+			e.ReadingTime = formatReadingTime(count.ReadingMinutes(speed))
+		}
+		return e
+	}
+
 	notesDir, err := GetNotesDir()
 	if err != nil {
 		return fmt.Errorf("failed to get notes directory: %w", err)
 	}
 
+	var meta *MetaFile
+	if *sourceFlag == "meta" {
+		meta, err = LoadMetaFile(notesDir)
+		if err != nil {
+			return fmt.Errorf("failed to load meta file: %w", err)
+		}
+	}
+
 	// Parse filters
 	var filterTags []string
 	if *tagsFlag != "" {
@@ -35,82 +127,332 @@ func CmdList(args []string) error {
 			filterTags[i] = strings.TrimSpace(filterTags[i])
 		}
 	}
+	excludeTags := parseCSV(*excludeTagsFlag)
 
 	var sinceDate time.Time
 	if *sinceFlag != "" {
 		var err error
-		sinceDate, err = time.Parse("2006-01-02", *sinceFlag)
+		sinceDate, err = time.ParseInLocation("2006-01-02", *sinceFlag, time.Local)
 		if err != nil {
 			return fmt.Errorf("invalid date format: %w", err)
 		}
 	}
+	if *withinFlag != "" {
+		within, err := parseRelativeDuration(*withinFlag)
+		if err != nil {
+			return err
+		}
+		sinceDate = time.Now().Add(-within)
+	}
+
+	matches := func(note *Note) bool {
+		// With --source meta, tags and summary come from .meta.json and
+		// notes without an entry are left out
+		if meta != nil && !applyFileMeta(note, meta.GetFileMeta(note.Name())) {
+			return false
+		}
 
-	// Find all .md files
-	entries, err := os.ReadDir(notesDir)
+		// Apply date filter
+		if !sinceDate.IsZero() && note.Frontmatter.Created.Before(sinceDate) {
+			return false
+		}
+
+		// Apply tag filter
+		if len(filterTags) > 0 && !matchTags(note.Frontmatter.Tags, filterTags, *tagLogicFlag) {
+			return false
+		}
+		if hasAnyTag(note.Frontmatter.Tags, excludeTags) {
+			return false
+		}
+		if *pinnedFlag && !note.Frontmatter.Pinned {
+			return false
+		}
+
+		return true
+	}
+
+	// Unsorted NDJSON is written while scanning, without buffering. Orphans
+	// need every note's relations first.
+	if *streamFlag && *sortFlag == "none" && !*orphansFlag {
+		encoder := json.NewEncoder(os.Stdout)
+		written := 0
+		err := WalkNotes(notesDir, func(note *Note) error {
+			if !matches(note) {
+				return nil
+			}
+			if err := encoder.Encode(entry(note)); err != nil {
+				return err
+			}
+			written++
+			if *limitFlag > 0 && written >= *limitFlag {
+				return errLimitReached
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errLimitReached) {
+			return err
+		}
+		return nil
+	}
+
+	allNotes, err := LoadAllNotes(notesDir)
 	if err != nil {
-		return fmt.Errorf("failed to read notes directory: %w", err)
+		return err
 	}
 
-	type noteInfo struct {
-		filename string
-		summary  string
-		created  time.Time
-		tags     []string
+	var notesList []*Note
+	for _, note := range allNotes {
+		if matches(note) {
+			notesList = append(notesList, note)
+		}
 	}
 
-	var notesList []noteInfo
+	if *orphansFlag {
+		notesList = filterOrphans(notesList, allNotes, *strictFlag)
+	}
 
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
-			continue
+	// Counts cover every note, not just the listed ones
+	var tagFiles map[string][]string
+	if *tagCountsFlag {
+		tagFiles = make(map[string][]string)
+		for _, note := range allNotes {
+			addNoteTags(tagFiles, note.Name(), note.Frontmatter.Tags)
 		}
+	}
 
-		notePath := filepath.Join(notesDir, entry.Name())
-		note, err := ParseNote(notePath)
-		if err != nil {
-			continue
+	// Sort pinned notes first, then by created date, newest first
+	if *sortFlag == "created" {
+		sort.SliceStable(notesList, func(i, j int) bool {
+			if a, b := notesList[i].Frontmatter.Pinned, notesList[j].Frontmatter.Pinned; a != b {
+				return a
+			}
+			return notesList[i].Frontmatter.Created.After(notesList[j].Frontmatter.Created.Time)
+		})
+	}
+
+	// Apply limit
+	if *limitFlag > 0 && len(notesList) > *limitFlag {
+		notesList = notesList[:*limitFlag]
+	}
+
+	// Output
+	switch {
+	case *streamFlag:
+		encoder := json.NewEncoder(os.Stdout)
+		for _, note := range notesList {
+			if err := encoder.Encode(entry(note)); err != nil {
+				return err
+			}
+		}
+	case *jsonFlag || *compactFlag:
+		entries := make([]ListEntry, 0, len(notesList))
+		for _, note := range notesList {
+			entries = append(entries, entry(note))
+		}
+		return outputJSON(entries, *compactFlag)
+	case format != nil:
+		for _, note := range notesList {
+			if err := format.Execute(os.Stdout, entry(note)); err != nil {
+				return fmt.Errorf("failed to format %s: %w", note.Name(), err)
+			}
+		}
+	case *tableFlag && !*rawFlag && stdoutIsTerminal():
+		writeListTable(os.Stdout, notesList, terminalWidth(), tagFiles)
+	default:
+		terminal := stdoutIsTerminal()
+		for _, note := range notesList {
+			filename := note.Name()
+			if *readingTimeFlag && !*rawFlag {
+				filename += "  (" + formatReadingTime(CountText(note.Content).ReadingMinutes(speed)) + ")"
+			}
+			if tagFiles != nil && !*rawFlag && len(note.Frontmatter.Tags) > 0 {
+				filename += "  " + formatTagCounts(note.Frontmatter.Tags, tagFiles, terminal)
+			}
+			switch {
+			case *rawFlag:
+				fmt.Println(filename)
+			case *summarySourceFlag == "firstline":
+				fmt.Printf("%s  %q\n", filename, note.FirstLine())
+			case *summarySourceFlag == "both" && note.Frontmatter.Summary != "":
+				fmt.Printf("%s  %q\n", filename, note.Frontmatter.Summary)
+				printPreview(note.FirstLine(), terminal)
+			default:
+				fmt.Printf("%s  %q\n", filename, note.GetSummaryOrFirstLine())
+			}
 		}
+	}
 
-		// Apply date filter
-		if !sinceDate.IsZero() && note.Frontmatter.Created.Before(sinceDate) {
+	return nil
+}
+
+// parseListFormat parses a --format template, which is executed with each
+// note's ListEntry. A newline is added unless the template ends with one.
+func parseListFormat(format string) (*template.Template, error) {
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	tmpl, err := template.New("format").Funcs(template.FuncMap{"join": strings.Join}).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --format template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// filterOrphans keeps the notes that relate to no note and that no note in
+// allNotes relates to. With strict, they must also have no tags.
+func filterOrphans(notesList, allNotes []*Note, strict bool) []*Note {
+	linked := make(map[string]bool)
+	for _, note := range allNotes {
+		if len(note.Frontmatter.Related) == 0 {
 			continue
 		}
+		linked[note.Name()] = true
+		for _, rel := range note.Frontmatter.Related {
+			linked[NormalizeFilename(rel)] = true
+		}
+	}
 
-		// Apply tag filter
-		if len(filterTags) > 0 && !hasAnyTag(note.Frontmatter.Tags, filterTags) {
+	var orphans []*Note
+	for _, note := range notesList {
+		if linked[note.Name()] {
+			continue
+		}
+		if strict && len(note.Frontmatter.Tags) > 0 {
 			continue
 		}
+		orphans = append(orphans, note)
+	}
+	return orphans
+}
+
+// printPreview prints an indented preview line, dimmed on a terminal
+func printPreview(text string, dim bool) {
+	if dim {
+		fmt.Printf("    \x1b[2m%s\x1b[0m\n", text)
+		return
+	}
+	fmt.Printf("    %s\n", text)
+}
 
-		notesList = append(notesList, noteInfo{
-			filename: entry.Name(),
-			summary:  note.GetSummaryOrFirstLine(),
-			created:  note.Frontmatter.Created.Time,
-			tags:     note.Frontmatter.Tags,
+// writeListTable prints notes as aligned columns with a header row,
+// truncating summaries so rows fit within width. If tagFiles is set, tags
+// are annotated with their counts.
+func writeListTable(w io.Writer, notesList []*Note, width int, tagFiles map[string][]string) {
+	const gap = "  "
+	rows := [][]string{{"FILENAME", "CREATED", "TAGS", "SUMMARY"}}
+	for _, note := range notesList {
+		rows = append(rows, []string{
+			note.Name(),
+			note.Frontmatter.Created.Local().Format(noteTimeFormat),
+			strings.Join(note.Frontmatter.Tags, ", "),
+			note.GetSummaryOrFirstLine(),
 		})
+		if tagFiles != nil {
+			// Uncolored, so escape codes don't skew the column widths
+			rows[len(rows)-1][2] = formatTagCounts(note.Frontmatter.Tags, tagFiles, false)
+		}
 	}
 
-	// Sort by created date, newest first
-	sort.Slice(notesList, func(i, j int) bool {
-		return notesList[i].created.After(notesList[j].created)
-	})
+	// The summary takes whatever is left after the other columns
+	widths := make([]int, 3)
+	used := 0
+	for col := range widths {
+		for _, row := range rows {
+			widths[col] = max(widths[col], utf8.RuneCountInString(row[col]))
+		}
+		used += widths[col] + len(gap)
+	}
+	summaryWidth := max(width-used, 10)
 
-	// Apply limit
-	if *limitFlag > 0 && len(notesList) > *limitFlag {
-		notesList = notesList[:*limitFlag]
+	for _, row := range rows {
+		var b strings.Builder
+		for col, cell := range row[:3] {
+			b.WriteString(cell)
+			b.WriteString(strings.Repeat(" ", widths[col]-utf8.RuneCountInString(cell)))
+			b.WriteString(gap)
+		}
+		b.WriteString(truncateRunes(row[3], summaryWidth))
+		fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
 	}
+}
 
-	// Output
-	for _, n := range notesList {
-		if *rawFlag {
-			fmt.Println(n.filename)
-		} else {
-			fmt.Printf("%s  %q\n", n.filename, n.summary)
+// formatTagCounts annotates each tag with the number of notes using it, e.g.
+// "neo(42) oneoff(1)". With color, tags used by a single note are
+// highlighted as candidates for consolidation.
+func formatTagCounts(tags []string, tagFiles map[string][]string, color bool) string {
+	labels := make([]string, len(tags))
+	for i, tag := range tags {
+		count := len(tagFiles[strings.ToLower(tag)])
+		labels[i] = fmt.Sprintf("%s(%d)", tag, count)
+		if color && count == 1 {
+			labels[i] = "\x1b[33m" + labels[i] + "\x1b[0m"
 		}
 	}
+	return strings.Join(labels, " ")
+}
+
+// truncateRunes shortens s to at most n runes, marking the cut with "..."
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n <= 3 {
+		return string(runes[:n])
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+// applyFileMeta replaces a note's tags, summary and related with the values
+// from .meta.json. Returns false if the note has no meta entry.
+func applyFileMeta(note *Note, fileMeta *FileMeta) bool {
+	if fileMeta == nil {
+		return false
+	}
+	note.Frontmatter.Tags = fileMeta.Tags
+	note.Frontmatter.Summary = fileMeta.Summary
+	note.Frontmatter.Related = fileMeta.Related
+	note.Frontmatter.Pinned = fileMeta.Pinned
+	return true
+}
+
+func newListEntry(note *Note) ListEntry {
+	tags := note.Frontmatter.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return ListEntry{
+		Filename: note.Name(),
+		Created:  note.Frontmatter.Created.UTC().Format(time.RFC3339),
+		Summary:  note.GetSummaryOrFirstLine(),
+		Tags:     tags,
+		Pinned:   note.Frontmatter.Pinned,
+	}
+}
+
+// Values of --tag-logic
+const (
+	tagLogicOr  = "or"
+	tagLogicAnd = "and"
+)
 
+// checkTagLogic validates a --tag-logic value
+func checkTagLogic(logic string) error {
+	if logic != tagLogicOr && logic != tagLogicAnd {
+		return fmt.Errorf("invalid --tag-logic value: %s (expected or or and)", logic)
+	}
 	return nil
 }
 
+// matchTags reports whether noteTags has any (tagLogicOr) or all
+// (tagLogicAnd) of filterTags
+func matchTags(noteTags, filterTags []string, logic string) bool {
+	if logic == tagLogicAnd {
+		return hasAllTags(noteTags, filterTags)
+	}
+	return hasAnyTag(noteTags, filterTags)
+}
+
 func hasAnyTag(noteTags, filterTags []string) bool {
 	for _, ft := range filterTags {
 		for _, nt := range noteTags {
@@ -121,3 +463,12 @@ func hasAnyTag(noteTags, filterTags []string) bool {
 	}
 	return false
 }
+
+func hasAllTags(noteTags, filterTags []string) bool {
+	for _, ft := range filterTags {
+		if !hasAnyTag(noteTags, []string{ft}) {
+			return false
+		}
+	}
+	return true
+}
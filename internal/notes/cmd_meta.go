@@ -2,13 +2,19 @@ package notes
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 )
 
 // MetaOutput represents the JSON output for notes meta command
 type MetaOutput struct {
+	ID          string   `json:"id,omitempty"`
 	Created     string   `json:"created"`
 	Tags        []string `json:"tags"`
 	Summary     string   `json:"summary"`
@@ -16,13 +22,28 @@ type MetaOutput struct {
 	EnrichedAt  string   `json:"enriched_at,omitempty"`
 	ContentHash string   `json:"content_hash"`
 	Unenriched  bool     `json:"unenriched,omitempty"`
+	WordCount   int      `json:"word_count"`
+	ReadingTime string   `json:"reading_time"`
+
+	// Set with --stats
+	ReadingTimeSeconds *int `json:"reading_time_seconds,omitempty"`
 }
 
 // CmdMeta implements the 'notes meta <filename>' command
 // Prints note metadata as JSON
 func CmdMeta(args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("usage: notes meta <filename>")
+	fs := flag.NewFlagSet("meta", flag.ExitOnError)
+	historyFlag := fs.Bool("history", false, "list all notes by enrichment time")
+	jsonFlag := fs.Bool("json", false, "output history or diff as JSON")
+	diffFlag := fs.String("diff", "", "compare metadata with another note")
+	compactFlag := fs.Bool("compact", false, "output single-line JSON (implies --json)")
+	wpmFlag := fs.Int("wpm", 0, "reading speed in words per minute (default $NOTES_WPM or 200)")
+	statsFlag := fs.Bool("stats", false, "include the reading time in seconds")
+	ensureFlag := fs.Bool("ensure", false, "add the note to .meta.json from its frontmatter if it isn't tracked yet")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
 	}
 
 	notesDir, err := GetNotesDir()
@@ -30,23 +51,76 @@ func CmdMeta(args []string) error {
 		return fmt.Errorf("failed to get notes directory: %w", err)
 	}
 
-	filename := NormalizeFilename(args[0])
+	asJSON := *jsonFlag || *compactFlag
+
+	if *historyFlag {
+		return showEnrichmentHistory(notesDir, asJSON, *compactFlag)
+	}
+
+	if len(positional) == 0 {
+		return fmt.Errorf("usage: notes meta <filename>")
+	}
+
+	if *ensureFlag {
+		return ensureMetaEntry(positional[0])
+	}
+
+	speed, err := GetReadingSpeed(*wpmFlag)
+	if err != nil {
+		return err
+	}
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	filename, err := ResolveFilename(notesDir, positional[0])
+	if err != nil {
+		return err
+	}
+	output, err := buildMetaOutput(notesDir, meta, filename, speed, *statsFlag)
+	if err != nil {
+		return err
+	}
+
+	if *diffFlag != "" {
+		other, err := ResolveFilename(notesDir, *diffFlag)
+		if err != nil {
+			return err
+		}
+		otherOutput, err := buildMetaOutput(notesDir, meta, other, speed, *statsFlag)
+		if err != nil {
+			return err
+		}
+		return showMetaDiff(diffMeta(filename, output, other, otherOutput), asJSON, *compactFlag)
+	}
+
+	return outputJSON(output, *compactFlag)
+}
+
+// buildMetaOutput collects a note's metadata, preferring .meta.json and
+// falling back to the frontmatter for notes that were never synced. Word
+// count and reading time always come from the note body; stats adds the
+// reading time in seconds.
+func buildMetaOutput(notesDir string, meta *MetaFile, filename string, speed ReadingSpeed, stats bool) (MetaOutput, error) {
 	notePath := filepath.Join(notesDir, filename)
 
 	// Check if file exists
 	if _, err := os.Stat(notePath); os.IsNotExist(err) {
-		return fmt.Errorf("note not found: %s", filename)
+		return MetaOutput{}, fmt.Errorf("note not found: %s", filename)
 	}
 
-	// Try to get from meta file first
-	meta, err := LoadMetaFile(notesDir)
+	note, err := ParseNote(notePath)
 	if err != nil {
-		return fmt.Errorf("failed to load meta file: %w", err)
+		return MetaOutput{}, fmt.Errorf("failed to parse note: %w", err)
 	}
 
+	var output MetaOutput
+
 	fileMeta := meta.GetFileMeta(filename)
 	if fileMeta != nil && fileMeta.ContentHash != "" {
-		output := MetaOutput{
+		output = MetaOutput{
 			Tags:        fileMeta.Tags,
 			Summary:     fileMeta.Summary,
 			Related:     fileMeta.Related,
@@ -54,52 +128,255 @@ func CmdMeta(args []string) error {
 		}
 
 		if !fileMeta.EnrichedAt.IsZero() {
-			output.EnrichedAt = fileMeta.EnrichedAt.Format("2006-01-02T15:04:05Z")
+			output.EnrichedAt = fileMeta.EnrichedAt.UTC().Format(time.RFC3339)
 		}
 
-		// Get created from frontmatter
-		note, err := ParseNote(notePath)
-		if err == nil {
-			output.Created = note.Frontmatter.Created.Format("2006-01-02T15:04:05Z")
+		output.ID = note.Frontmatter.ID
+		output.Created = note.Frontmatter.Created.UTC().Format(time.RFC3339)
+	} else {
+		// Not in meta file, use the frontmatter
+		output = MetaOutput{
+			ID:          note.Frontmatter.ID,
+			Created:     note.Frontmatter.Created.UTC().Format(time.RFC3339),
+			Tags:        note.Frontmatter.Tags,
+			Summary:     note.Frontmatter.Summary,
+			Related:     note.Frontmatter.Related,
+			ContentHash: note.ContentHash(),
+			Unenriched:  true,
 		}
+	}
+
+	count := CountText(note.Content)
+	output.WordCount = count.Words + count.CJKChars
+	output.ReadingTime = formatReadingTime(count.ReadingMinutes(speed))
+	if stats {
+		seconds := count.ReadingSeconds(speed)
+		output.ReadingTimeSeconds = &seconds
+	}
+
+	if output.Tags == nil {
+		output.Tags = []string{}
+	}
+	if output.Related == nil {
+		output.Related = []string{}
+	}
+
+	return output, nil
+}
+
+// ensureMetaEntry adds a note to .meta.json from its frontmatter, like sync
+// does for every note. Notes already tracked are left untouched.
+func ensureMetaEntry(name string) error {
+	notesDir, err := GetWritableNotesDir()
+	if err != nil {
+		return err
+	}
+
+	filename, err := ResolveFilename(notesDir, name)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := LockMeta(notesDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-		if output.Tags == nil {
-			output.Tags = []string{}
+	finish := beginUndo(notesDir, "meta")
+	defer finish()
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	if meta.GetFileMeta(filename) != nil {
+		fmt.Printf("Already tracked: %s\n", filename)
+		return nil
+	}
+
+	note, err := ParseNote(filepath.Join(notesDir, filename))
+	if err != nil {
+		return fmt.Errorf("failed to parse note: %w", err)
+	}
+
+	meta.UpdateFromNote(note)
+	if err := meta.Save(notesDir); err != nil {
+		return fmt.Errorf("failed to save meta file: %w", err)
+	}
+
+	fmt.Printf("Added to .meta.json: %s\n", filename)
+	return nil
+}
+
+// MetaDiff represents the differences between two notes' metadata
+type MetaDiff struct {
+	A              string   `json:"a"`
+	B              string   `json:"b"`
+	TagsOnlyInA    []string `json:"tags_only_in_a"`
+	TagsOnlyInB    []string `json:"tags_only_in_b"`
+	SharedTags     []string `json:"shared_tags"`
+	SummaryA       string   `json:"summary_a"`
+	SummaryB       string   `json:"summary_b"`
+	RelatedOnlyInA []string `json:"related_only_in_a"`
+	RelatedOnlyInB []string `json:"related_only_in_b"`
+	SharedRelated  []string `json:"shared_related"`
+}
+
+func diffMeta(a string, metaA MetaOutput, b string, metaB MetaOutput) MetaDiff {
+	diff := MetaDiff{
+		A:        a,
+		B:        b,
+		SummaryA: metaA.Summary,
+		SummaryB: metaB.Summary,
+	}
+	diff.TagsOnlyInA, diff.SharedTags, diff.TagsOnlyInB = splitSets(metaA.Tags, metaB.Tags, strings.EqualFold)
+	diff.RelatedOnlyInA, diff.SharedRelated, diff.RelatedOnlyInB = splitSets(metaA.Related, metaB.Related, func(x, y string) bool {
+		return x == y
+	})
+	return diff
+}
+
+// splitSets partitions two lists into items only in a, in both, and only in b
+func splitSets(a, b []string, equal func(x, y string) bool) (onlyA, both, onlyB []string) {
+	onlyA, both, onlyB = []string{}, []string{}, []string{}
+
+	containsFunc := func(list []string, item string) bool {
+		for _, s := range list {
+			if equal(s, item) {
+				return true
+			}
 		}
-		if output.Related == nil {
-			output.Related = []string{}
+		return false
+	}
+
+	for _, x := range a {
+		if containsFunc(b, x) {
+			both = append(both, x)
+		} else {
+			onlyA = append(onlyA, x)
+		}
+	}
+	for _, y := range b {
+		if !containsFunc(a, y) {
+			onlyB = append(onlyB, y)
 		}
+	}
+	return onlyA, both, onlyB
+}
 
-		return outputJSON(output)
+func showMetaDiff(diff MetaDiff, asJSON, compact bool) error {
+	if asJSON {
+		return outputJSON(diff, compact)
 	}
 
-	// Not in meta file, parse from frontmatter
-	note, err := ParseNote(notePath)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Tags only in %s:\t%s\n", diff.A, strings.Join(diff.TagsOnlyInA, ", "))
+	fmt.Fprintf(w, "Tags only in %s:\t%s\n", diff.B, strings.Join(diff.TagsOnlyInB, ", "))
+	fmt.Fprintf(w, "Shared tags:\t%s\n", strings.Join(diff.SharedTags, ", "))
+	fmt.Fprintf(w, "Summary of %s:\t%q\n", diff.A, diff.SummaryA)
+	fmt.Fprintf(w, "Summary of %s:\t%q\n", diff.B, diff.SummaryB)
+	fmt.Fprintf(w, "Related only to %s:\t%s\n", diff.A, strings.Join(diff.RelatedOnlyInA, ", "))
+	fmt.Fprintf(w, "Related only to %s:\t%s\n", diff.B, strings.Join(diff.RelatedOnlyInB, ", "))
+	fmt.Fprintf(w, "Shared related:\t%s\n", strings.Join(diff.SharedRelated, ", "))
+	return w.Flush()
+}
+
+// HistoryEntry represents a single enriched note in the enrichment history
+type HistoryEntry struct {
+	Filename   string `json:"filename"`
+	EnrichedAt string `json:"enriched_at"`
+	Summary    string `json:"summary"`
+}
+
+// HistoryOutput represents the JSON output for notes meta --history
+type HistoryOutput struct {
+	Enriched      []HistoryEntry `json:"enriched"`
+	NeverEnriched []string       `json:"never_enriched"`
+}
+
+// showEnrichmentHistory lists enriched notes oldest first, followed by notes
+// that have never been enriched
+func showEnrichmentHistory(notesDir string, asJSON, compact bool) error {
+	meta, err := LoadMetaFile(notesDir)
 	if err != nil {
-		return fmt.Errorf("failed to parse note: %w", err)
+		return fmt.Errorf("failed to load meta file: %w", err)
 	}
 
-	output := MetaOutput{
-		Created:     note.Frontmatter.Created.Format("2006-01-02T15:04:05Z"),
-		Tags:        note.Frontmatter.Tags,
-		Summary:     note.Frontmatter.Summary,
-		Related:     note.Frontmatter.Related,
-		ContentHash: note.ContentHash(),
-		Unenriched:  true,
+	notesList, err := LoadAllNotes(notesDir)
+	if err != nil {
+		return err
 	}
 
-	if output.Tags == nil {
-		output.Tags = []string{}
+	type enrichedNote struct {
+		filename   string
+		enrichedAt time.Time
+		summary    string
 	}
-	if output.Related == nil {
-		output.Related = []string{}
+
+	var enriched []enrichedNote
+	neverEnriched := []string{}
+
+	for _, note := range notesList {
+		filename := note.Name()
+		fileMeta := meta.GetFileMeta(filename)
+		if fileMeta == nil || fileMeta.EnrichedAt.IsZero() {
+			neverEnriched = append(neverEnriched, filename)
+			continue
+		}
+		enriched = append(enriched, enrichedNote{filename, fileMeta.EnrichedAt, fileMeta.Summary})
+	}
+
+	sort.Slice(enriched, func(i, j int) bool {
+		if !enriched[i].enrichedAt.Equal(enriched[j].enrichedAt) {
+			return enriched[i].enrichedAt.Before(enriched[j].enrichedAt)
+		}
+		return enriched[i].filename < enriched[j].filename
+	})
+	sort.Strings(neverEnriched)
+
+	if asJSON {
+		output := HistoryOutput{
+			Enriched:      []HistoryEntry{},
+			NeverEnriched: neverEnriched,
+		}
+		for _, e := range enriched {
+			output.Enriched = append(output.Enriched, HistoryEntry{
+				Filename:   e.filename,
+				EnrichedAt: e.enrichedAt.UTC().Format(time.RFC3339),
+				Summary:    e.summary,
+			})
+		}
+		return outputJSON(output, compact)
 	}
 
-	return outputJSON(output)
+	for _, e := range enriched {
+		fmt.Printf("%s  %s  %q\n", e.enrichedAt.Local().Format(noteTimeFormat), e.filename, e.summary)
+	}
+
+	if len(neverEnriched) > 0 {
+		if len(enriched) > 0 {
+			fmt.Println()
+		}
+		fmt.Println("Never enriched:")
+		for _, filename := range neverEnriched {
+			fmt.Printf("  %s\n", filename)
+		}
+	}
+
+	return nil
 }
 
-func outputJSON(v interface{}) error {
-	data, err := json.MarshalIndent(v, "", "  ")
+// outputJSON prints v as indented JSON, or on a single line if compact
+func outputJSON(v interface{}, compact bool) error {
+	var data []byte
+	var err error
+	if compact {
+		data, err = json.Marshal(v)
+	} else {
+		data, err = json.MarshalIndent(v, "", "  ")
+	}
 	if err != nil {
 		return err
 	}
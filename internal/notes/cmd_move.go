@@ -0,0 +1,210 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// CmdMove implements the 'notes move <file> <folder>' command
+// Moves a note into a folder of the notes directory ("." for the top level)
+// and rewrites relations pointing at it
+func CmdMove(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: notes move <file> <folder>")
+	}
+
+	notesDir, err := GetWritableNotesDir()
+	if err != nil {
+		return err
+	}
+
+	oldName, err := ResolveNote(notesDir, args[0])
+	if err != nil {
+		return err
+	}
+
+	folder := path.Clean(filepath.ToSlash(args[1]))
+	if path.IsAbs(folder) || folder == ".." || strings.HasPrefix(folder, "../") {
+		return fmt.Errorf("folder must be inside the notes directory: %s", args[1])
+	}
+	for _, part := range strings.Split(folder, "/") {
+		if part != "." && strings.HasPrefix(part, ".") {
+			return fmt.Errorf("cannot move notes into hidden folders: %s", args[1])
+		}
+	}
+
+	newName := path.Join(folder, path.Base(oldName))
+	if newName == oldName {
+		return fmt.Errorf("%s is already in %s", oldName, folder)
+	}
+
+	finish := beginUndo(notesDir, "move")
+	defer finish()
+	if err := relocateNote(notesDir, oldName, newName, ""); err != nil {
+		return err
+	}
+
+	fmt.Printf("Moved %s -> %s\n", oldName, newName)
+	return nil
+}
+
+// CmdMoveTo implements the 'notes move-to <notebook> <file>' command
+// Moves (or with --copy, copies) a note into another notebook
+func CmdMoveTo(args []string) error {
+	fs := flag.NewFlagSet("move-to", flag.ExitOnError)
+	copyFlag := fs.Bool("copy", false, "copy the note instead of moving it")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 2 {
+		return fmt.Errorf("usage: notes move-to <notebook> <file> [--copy]")
+	}
+
+	var srcDir string
+	if *copyFlag {
+		srcDir, err = GetNotesDir()
+		if err != nil {
+			return fmt.Errorf("failed to get notes directory: %w", err)
+		}
+	} else {
+		srcDir, err = GetWritableNotesDir()
+		if err != nil {
+			return err
+		}
+	}
+
+	dstDir, err := GetNotebookDir(positional[0])
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dstDir); os.IsNotExist(err) {
+		return fmt.Errorf("notebook directory %s does not exist", dstDir)
+	}
+	if err := checkWritable(dstDir); err != nil {
+		return err
+	}
+	if sameDir(srcDir, dstDir) {
+		return fmt.Errorf("%s is the current notebook", positional[0])
+	}
+
+	filename, err := ResolveNote(srcDir, positional[1])
+	if err != nil {
+		return err
+	}
+	srcPath := filepath.Join(srcDir, filename)
+	dstPath := filepath.Join(dstDir, filename)
+
+	if _, err := os.Stat(dstPath); err == nil {
+		return fmt.Errorf("note already exists in %s: %s", dstDir, filename)
+	}
+
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to read note: %w", err)
+	}
+	note, err := ParseNoteContent(srcPath, data)
+	if err != nil {
+		return fmt.Errorf("failed to parse note: %w", err)
+	}
+
+	// Both notebooks' .meta.json change, and each gets its own snapshot
+	for _, dir := range []string{srcDir, dstDir} {
+		unlock, err := LockMeta(dir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		finish := beginUndo(dir, "move-to")
+		defer finish()
+	}
+
+	srcMeta, err := LoadMetaFile(srcDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+	dstMeta, err := LoadMetaFile(dstDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file for %s: %w", dstDir, err)
+	}
+
+	// Copy the bytes unchanged so plain notes stay plain
+	if err := writeFileAtomic(dstPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write note: %w", err)
+	}
+
+	// Register in the destination, keeping enrichment state from the source
+	related := append([]string{}, note.Frontmatter.Related...)
+	dstMeta.UpdateFromNote(note)
+	if srcEntry := srcMeta.GetFileMeta(filename); srcEntry != nil {
+		dstEntry := dstMeta.GetFileMeta(filename)
+		dstEntry.ContentHash = srcEntry.ContentHash
+		dstEntry.EnrichedAt = srcEntry.EnrichedAt
+		for _, rel := range srcEntry.Related {
+			if !Contains(related, rel) {
+				related = append(related, rel)
+			}
+		}
+	}
+	if err := dstMeta.Save(dstDir); err != nil {
+		return fmt.Errorf("failed to save meta file for %s: %w", dstDir, err)
+	}
+
+	// Relations are filenames within one notebook, so links across
+	// notebooks no longer resolve
+	for _, rel := range related {
+		if _, err := os.Stat(filepath.Join(dstDir, rel)); os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Warning: related note %s is not in %s, the relation is left as-is\n", rel, dstDir)
+		}
+	}
+
+	if *copyFlag {
+		fmt.Printf("Copied %s to %s\n", filename, dstDir)
+		return nil
+	}
+
+	// Relations to the moved note no longer resolve in the source notebook,
+	// so they are removed like when the note is deleted
+	notesList, err := LoadAllNotes(srcDir)
+	if err != nil {
+		return err
+	}
+	for _, other := range notesList {
+		otherName := other.Name()
+		if otherName == filename || !relatesTo(currentRelated(srcMeta, otherName, other), filename) {
+			continue
+		}
+		if fileMeta := srcMeta.GetFileMeta(otherName); fileMeta != nil {
+			fileMeta.Related = removeRelated(fileMeta.Related, filename)
+		}
+		if err := updateRelatedInFile(srcDir, otherName, removeRelated(other.Frontmatter.Related, filename)); err != nil {
+			return fmt.Errorf("failed to update %s: %w", otherName, err)
+		}
+		fmt.Printf("Removed backlink in %s\n", otherName)
+	}
+
+	if err := removeRecorded(srcPath); err != nil {
+		return fmt.Errorf("failed to remove note: %w", err)
+	}
+	delete(srcMeta.Files, filename)
+	if err := srcMeta.Save(srcDir); err != nil {
+		return fmt.Errorf("failed to save meta file: %w", err)
+	}
+
+	fmt.Printf("Moved %s to %s\n", filename, dstDir)
+	return nil
+}
+
+// sameDir reports whether two paths refer to the same directory
+func sameDir(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
@@ -1,94 +1,489 @@
 package notes
 
 import (
+	"flag"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
 
 // CmdNew implements the 'notes new [content]' command
 func CmdNew(args []string) error {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	noFrontmatterFlag := fs.Bool("no-frontmatter", false, "write only the body, without YAML frontmatter")
+	templateFlag := fs.String("template", "", "template from .templates/ to use (default: default.md if present)")
+	editTemplateFlag := fs.Bool("edit-template", false, "edit the default template instead of creating a note")
+	idFlag := fs.String("id", "", "stable id to resolve the note by (default: generated)")
+	forceFlag := fs.Bool("force", false, "create the note even if one with identical content exists")
+	openExistingFlag := fs.Bool("open-existing", false, "edit the existing note instead when the content is a duplicate")
+	dailyFlag := fs.Bool("append-to-daily", false, "append to today's note under a timestamp heading (default $NOTES_APPEND_TO_DAILY)")
+	clipboardFlag := fs.Bool("clipboard", false, "use the text on the system clipboard as the content")
+	tagsFlag := fs.String("tags", "", "comma-separated tags to add")
+	relatedFlag := fs.String("related", "", "comma-separated related notes")
+	summaryFlag := fs.String("summary", "", "summary for the frontmatter")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+
+	tags := parseCSV(*tagsFlag)
+	related := parseCSV(*relatedFlag)
+	for i, r := range related {
+		related[i] = NormalizeFilename(r)
+	}
+	summary := strings.TrimSpace(*summaryFlag)
+	hasFrontmatterFlags := len(tags) > 0 || len(related) > 0 || summary != ""
+	if hasFrontmatterFlags && *noFrontmatterFlag {
+		return fmt.Errorf("--tags, --related and --summary cannot be used with --no-frontmatter")
+	}
+
+	if *clipboardFlag {
+		if len(args) > 0 {
+			return fmt.Errorf("content arguments cannot be combined with --clipboard")
+		}
+		text, err := readClipboard()
+		if err != nil {
+			return fmt.Errorf("failed to read clipboard: %w", err)
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return fmt.Errorf("the clipboard is empty")
+		}
+		// Handled exactly like content given on the command line
+		args = []string{text}
+	}
+
 	notesDir, err := EnsureNotesDir()
 	if err != nil {
 		return fmt.Errorf("failed to ensure notes directory: %w", err)
 	}
 
-	// Generate filename
-	filename, err := GenerateFilename(notesDir)
+	if *editTemplateFlag {
+		return editDefaultTemplate(notesDir)
+	}
+
+	finish := beginUndo(notesDir, "new")
+	defer finish()
+
+	appendDaily := GetAppendToDaily()
+	if isFlagSet(fs, "append-to-daily") {
+		appendDaily = *dailyFlag
+	}
+	if appendDaily {
+		if *templateFlag != "" || *idFlag != "" || hasFrontmatterFlags {
+			return fmt.Errorf("--template, --id, --tags, --related and --summary cannot be used with --append-to-daily")
+		}
+		return appendToDaily(notesDir, strings.Join(args, " "), time.Now(), *noFrontmatterFlag)
+	}
+
+	id := *idFlag
+	if id == "" {
+		if id, err = NewNoteID(); err != nil {
+			return fmt.Errorf("failed to generate id: %w", err)
+		}
+	} else {
+		if err := validateNoteID(id); err != nil {
+			return err
+		}
+		if existing, err := findNoteByID(notesDir, id); err != nil {
+			return err
+		} else if existing != "" {
+			return fmt.Errorf("id %s is already used by %s", id, existing)
+		}
+	}
+
+	tmpl, err := LoadTemplate(notesDir, *templateFlag)
 	if err != nil {
-		return fmt.Errorf("failed to generate filename: %w", err)
+		return err
 	}
 
-	notePath := filepath.Join(notesDir, filename)
 	now := time.Now()
 
-	// Create note with empty frontmatter
+	// Create note with empty frontmatter. Its filename is only picked when
+	// it is written, so notes created in the same minute don't collide.
 	note := &Note{
-		Filename: filename,
 		Frontmatter: Frontmatter{
+			ID:      id,
 			Created: NoteTime{now},
 			Tags:    []string{},
 			Related: []string{},
 		},
 	}
 
-	if len(args) > 0 {
+	// Plain notes are written body-only and gain frontmatter once enriched
+	render := note.ToMarkdown
+	if *noFrontmatterFlag {
+		render = func() string { return note.Content }
+	}
+	save := func(path string) error {
+		return os.WriteFile(path, []byte(render()), 0644)
+	}
+	var notePath string
+	create := func(data []byte) (string, error) {
+		path, err := createNoteFile(notesDir, now, data)
+		notePath = path
+		return path, err
+	}
+
+	// Content given on the command line is checked for duplicates before
+	// saving; notes written in the editor are not. Returns true if the note
+	// must not be created.
+	checkDuplicate := func() (bool, error) {
+		if *forceFlag {
+			return false, nil
+		}
+		existing, err := findNoteByContentHash(notesDir, note.ContentHash())
+		if err != nil {
+			return true, err
+		}
+		if existing == "" {
+			return false, nil
+		}
+		if !*openExistingFlag {
+			return true, fmt.Errorf("a note with identical content already exists: %s (use --force to create it anyway or --open-existing to edit it)", existing)
+		}
+		fmt.Fprintf(os.Stderr, "Opening existing note %s with identical content\n", existing)
+		if err := runEditor(filepath.Join(notesDir, existing), 0); err != nil {
+			return true, fmt.Errorf("editor failed: %w", err)
+		}
+		return true, nil
+	}
+
+	if tmpl != nil {
+		applyTemplate(note, tmpl, strings.Join(args, " "))
+	}
+	// Added to what the template sets; a given summary replaces its summary
+	note.Frontmatter.Tags = normalizeTagSlice(append(note.Frontmatter.Tags, tags...))
+	for _, r := range related {
+		if !Contains(note.Frontmatter.Related, r) {
+			note.Frontmatter.Related = append(note.Frontmatter.Related, r)
+		}
+	}
+	if summary != "" {
+		note.Frontmatter.Summary = summary
+	}
+
+	if tmpl != nil {
+		if len(args) > 0 {
+			if stop, err := checkDuplicate(); stop {
+				return err
+			}
+			if _, err := create([]byte(render())); err != nil {
+				return fmt.Errorf("failed to save note: %w", err)
+			}
+		} else {
+			rendered, line := stripCursor(render())
+			saveDraft := func(path string) error {
+				return os.WriteFile(path, []byte(rendered), 0644)
+			}
+
+			created, err := captureInEditor(create, saveDraft, line)
+			if err != nil || !created {
+				return err
+			}
+		}
+	} else if len(args) > 0 {
 		// Content provided as argument
 		note.Content = "\n" + strings.Join(args, " ") + "\n"
-		if err := note.Save(notePath); err != nil {
+		if *noFrontmatterFlag {
+			note.Content = strings.Join(args, " ") + "\n"
+		}
+		if stop, err := checkDuplicate(); stop {
+			return err
+		}
+		if _, err := create([]byte(render())); err != nil {
 			return fmt.Errorf("failed to save note: %w", err)
 		}
 	} else {
-		// Open editor
 		note.Content = "\n"
-		if err := note.Save(notePath); err != nil {
-			return fmt.Errorf("failed to save template: %w", err)
+		if *noFrontmatterFlag {
+			note.Content = ""
 		}
 
-		// Open editor
-		editor := GetEditor()
-		cmd := exec.Command(editor, notePath)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-
-		if err := cmd.Run(); err != nil {
-			// Clean up file on editor error
-			os.Remove(notePath)
-			return fmt.Errorf("editor failed: %w", err)
+		created, err := captureInEditor(create, save, 0)
+		if err != nil || !created {
+			return err
 		}
+	}
 
-		// Re-read the file to check if content was added
-		editedNote, err := ParseNote(notePath)
-		if err != nil {
-			os.Remove(notePath)
-			return fmt.Errorf("failed to parse edited note: %w", err)
+	// Tags and a summary given up front are what enrichment would add, so
+	// the note is tracked right away instead of being flagged
+	if len(tags) > 0 && summary != "" {
+		if err := trackNewNote(notesDir, notePath); err != nil {
+			return err
 		}
+	}
+
+	fmt.Printf("Created %s\n", notePath)
+	return nil
+}
+
+// trackNewNote adds a just-created note to .meta.json, reading it back from
+// disk since it may have been changed in the editor
+func trackNewNote(notesDir, notePath string) error {
+	note, err := ParseNote(notePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse note: %w", err)
+	}
 
-		// Check if content is empty or just whitespace
-		if strings.TrimSpace(editedNote.Content) == "" {
-			os.Remove(notePath)
-			fmt.Fprintln(os.Stderr, "Aborted: no content added")
-			return nil
+	unlock, err := LockMeta(notesDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+	meta.UpdateFromNote(note)
+	if err := meta.Save(notesDir); err != nil {
+		return fmt.Errorf("failed to save meta file: %w", err)
+	}
+	return nil
+}
+
+// dailyFilename is the name of the note collecting a day's captures
+func dailyFilename(day time.Time) string {
+	return day.Format("2006-01-02") + ".md"
+}
+
+// appendToDaily adds text to the day's note under a "## HH:MM" heading,
+// creating the note first if needed. Without text, the entry is written in
+// the editor. The note's content hash changes, so it will be flagged for
+// enrichment again.
+func appendToDaily(notesDir, text string, now time.Time, noFrontmatter bool) error {
+	if strings.TrimSpace(text) == "" {
+		entry, ok, err := captureEntryInEditor()
+		if err != nil || !ok {
+			return err
 		}
+		text = entry
 	}
 
-	fmt.Printf("Created %s\n", notePath)
+	notePath := filepath.Join(notesDir, dailyFilename(now))
+	note, err := loadDailyNote(notePath, now, noFrontmatter)
+	if err != nil {
+		return err
+	}
+
+	note.Content = strings.TrimRight(note.Content, "\n") + "\n\n## " + now.Format("15:04") + "\n\n" +
+		strings.TrimSpace(text) + "\n"
+	if err := note.SaveKeepingFormat(notePath); err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+
+	fmt.Printf("Appended to %s\n", notePath)
 	return nil
 }
 
+// loadDailyNote parses the day's note at notePath, or returns a new one
+// headed by the date if it doesn't exist yet. A new note isn't saved.
+func loadDailyNote(notePath string, day time.Time, noFrontmatter bool) (*Note, error) {
+	note, err := ParseNote(notePath)
+	if err == nil {
+		return note, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(notePath), err)
+	}
+
+	id, err := NewNoteID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate id: %w", err)
+	}
+	return &Note{
+		Filename: filepath.Base(notePath),
+		Frontmatter: Frontmatter{
+			ID:      id,
+			Created: NoteTime{day},
+			Tags:    []string{},
+			Related: []string{},
+		},
+		Content:        "\n# " + day.Format("2006-01-02") + "\n",
+		HasFrontmatter: !noFrontmatter,
+	}, nil
+}
+
+// captureEntryInEditor lets the user write a daily entry in the editor and
+// returns its text. Returns false if nothing was written.
+func captureEntryInEditor() (string, bool, error) {
+	tmp, err := os.CreateTemp("", "notes-entry-*.md")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create draft: %w", err)
+	}
+	entryPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(entryPath)
+
+	empty := func(path string) error { return os.WriteFile(path, nil, 0644) }
+	keep := func(data []byte) (string, error) { return entryPath, os.WriteFile(entryPath, data, 0644) }
+	ok, err := captureInEditor(keep, empty, 0)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	data, err := os.ReadFile(entryPath)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read entry: %w", err)
+	}
+	return string(data), true, nil
+}
+
+// findNoteByContentHash returns a note whose body has the given hash, or "".
+// .meta.json is checked first; notes it doesn't know yet are parsed.
+func findNoteByContentHash(notesDir, hash string) (string, error) {
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	var candidates []string
+	for filename, fileMeta := range meta.Files {
+		if fileMeta.ContentHash == hash {
+			candidates = append(candidates, filename)
+		}
+	}
+	sort.Strings(candidates)
+	for _, filename := range candidates {
+		if _, err := os.Stat(filepath.Join(notesDir, filename)); err == nil {
+			return filename, nil
+		}
+	}
+
+	names, err := listNoteFiles(notesDir, func(SkippedFile) {})
+	if err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		if meta.GetFileMeta(name) != nil {
+			continue
+		}
+		note, err := ParseNote(filepath.Join(notesDir, name))
+		if err == nil && note.ContentHash() == hash {
+			return name, nil
+		}
+	}
+
+	return "", nil
+}
+
+// captureInEditor lets the user write a note in a temporary draft file and
+// only hands it to save once the editor exits cleanly with content.
+// If the editor fails, the draft is kept and its path printed so no work is lost.
+func captureInEditor(save func(data []byte) (string, error), saveTemplate func(path string) error, cursorLine int) (bool, error) {
+	draft, err := os.CreateTemp("", "notes-draft-*.md")
+	if err != nil {
+		return false, fmt.Errorf("failed to create draft: %w", err)
+	}
+	draftPath := draft.Name()
+	draft.Close()
+
+	if err := saveTemplate(draftPath); err != nil {
+		os.Remove(draftPath)
+		return false, fmt.Errorf("failed to save template: %w", err)
+	}
+	initial, _ := os.ReadFile(draftPath)
+
+	for {
+		err := runEditor(draftPath, cursorLine)
+		if err == nil {
+			break
+		}
+
+		draftNote, parseErr := ParseNote(draftPath)
+		if parseErr == nil && strings.TrimSpace(draftNote.Content) == "" {
+			// Nothing written yet, nothing to preserve
+			os.Remove(draftPath)
+			return false, fmt.Errorf("editor failed: %w", err)
+		}
+
+		if confirm(fmt.Sprintf("Editor failed (%v). Retry?", err)) {
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "Draft preserved at %s\n", draftPath)
+		return false, fmt.Errorf("editor failed: %w", err)
+	}
+
+	// Re-read the draft to check if content was added
+	editedNote, err := ParseNote(draftPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Draft preserved at %s\n", draftPath)
+		return false, fmt.Errorf("failed to parse edited note: %w", err)
+	}
+
+	data, err := os.ReadFile(draftPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read draft: %w", err)
+	}
+
+	// Check if content is empty or just whitespace, or the template is untouched
+	if strings.TrimSpace(editedNote.Content) == "" || string(data) == string(initial) {
+		os.Remove(draftPath)
+		fmt.Fprintln(os.Stderr, "Aborted: no content added")
+		return false, nil
+	}
+	if _, err := save(data); err != nil {
+		fmt.Fprintf(os.Stderr, "Draft preserved at %s\n", draftPath)
+		return false, fmt.Errorf("failed to save note: %w", err)
+	}
+	os.Remove(draftPath)
+
+	return true, nil
+}
+
+// createNoteFile writes data to a new note named for t. The file is created
+// exclusively, so if another notes process took the name since it was
+// picked, the next free name is used instead of overwriting that note.
+func createNoteFile(notesDir string, t time.Time, data []byte) (string, error) {
+	for {
+		filename, err := generateFilenameAt(notesDir, t)
+		if err != nil {
+			return "", err
+		}
+
+		path := filepath.Join(notesDir, filename)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		recordCreated(path)
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			os.Remove(path)
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		return path, nil
+	}
+}
+
 // GenerateFilename creates a unique filename for the current time
 func GenerateFilename(notesDir string) (string, error) {
-	now := time.Now()
-	base := now.Format("2006-01-02-1504")
+	return generateFilenameAt(notesDir, time.Now())
+}
+
+// generateFilenameAt returns an unused filename for a note created at t.
+// Any entry counts as used, including a dangling symlink, as creating the
+// note exclusively would fail on it.
+func generateFilenameAt(notesDir string, t time.Time) (string, error) {
+	base := t.Format("2006-01-02-1504")
 
 	// Try without suffix first
 	filename := base + ".md"
 	fullPath := filepath.Join(notesDir, filename)
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+	if _, err := os.Lstat(fullPath); os.IsNotExist(err) {
 		return filename, nil
 	}
 
@@ -96,7 +491,7 @@ func GenerateFilename(notesDir string) (string, error) {
 	for i := 1; i < 100; i++ {
 		filename = fmt.Sprintf("%s-%d.md", base, i)
 		fullPath = filepath.Join(notesDir, filename)
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		if _, err := os.Lstat(fullPath); os.IsNotExist(err) {
 			return filename, nil
 		}
 	}
@@ -0,0 +1,61 @@
+package notes
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// CmdOpen implements the 'notes open [filename]' command
+// Opens the notes directory in the file browser, or a note in the default
+// app for markdown files
+func CmdOpen(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: notes open [filename]")
+	}
+
+	notesDir, err := GetNotesDir()
+	if err != nil {
+		return fmt.Errorf("failed to get notes directory: %w", err)
+	}
+
+	target := notesDir
+	if len(args) == 1 {
+		filename, err := ResolveFilename(notesDir, args[0])
+		if err != nil {
+			return err
+		}
+		target = filepath.Join(notesDir, filename)
+	}
+
+	if err := openInApp(target); err != nil {
+		return fmt.Errorf("failed to open %s: %w", target, err)
+	}
+	return nil
+}
+
+// openerCommand returns the command that opens a path with the desktop's
+// default application on this platform
+func openerCommand(goos, path string) ([]string, error) {
+	switch goos {
+	case "darwin":
+		return []string{"open", path}, nil
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly":
+		return []string{"xdg-open", path}, nil
+	case "windows":
+		// The empty argument is start's window title
+		return []string{"cmd", "/c", "start", "", path}, nil
+	}
+	return nil, fmt.Errorf("opening files is not supported on %s", goos)
+}
+
+// openInApp hands path to the desktop's default application without
+// waiting for it. It is a variable so tests can replace it.
+var openInApp = func(path string) error {
+	command, err := openerCommand(runtime.GOOS, path)
+	if err != nil {
+		return err
+	}
+	return exec.Command(command[0], command[1:]...).Start()
+}
@@ -0,0 +1,79 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CmdOrphans implements the 'notes orphans' command
+// Lists notes without relations that share no tag with any other note
+func CmdOrphans(args []string) error {
+	fs := flag.NewFlagSet("orphans", flag.ExitOnError)
+	relatedOnlyFlag := fs.Bool("related-only", false, "list every note without relations, even if it shares tags")
+	rawFlag := fs.Bool("raw", false, "show only filenames")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	notesDir, err := GetNotesDir()
+	if err != nil {
+		return fmt.Errorf("failed to get notes directory: %w", err)
+	}
+
+	notesList, err := LoadAllNotes(notesDir)
+	if err != nil {
+		return err
+	}
+
+	orphans := filterOrphans(notesList, notesList, false)
+	if !*relatedOnlyFlag {
+		orphans = filterUnsharedTags(orphans, notesList)
+	}
+
+	// Newest first, like notes list
+	sort.SliceStable(orphans, func(i, j int) bool {
+		return orphans[i].Frontmatter.Created.After(orphans[j].Frontmatter.Created.Time)
+	})
+
+	if len(orphans) == 0 && !*rawFlag {
+		fmt.Println("No orphan notes")
+		return nil
+	}
+
+	for _, note := range orphans {
+		filename := note.Name()
+		if *rawFlag {
+			fmt.Println(filename)
+		} else {
+			fmt.Printf("%s  %q\n", filename, note.GetSummaryOrFirstLine())
+		}
+	}
+	return nil
+}
+
+// filterUnsharedTags keeps the notes none of whose tags (compared case-
+// insensitively) is used by another note in allNotes
+func filterUnsharedTags(notesList, allNotes []*Note) []*Note {
+	tagFiles := make(map[string][]string)
+	for _, note := range allNotes {
+		addNoteTags(tagFiles, note.Name(), note.Frontmatter.Tags)
+	}
+
+	var unshared []*Note
+	for _, note := range notesList {
+		shared := false
+		for _, tag := range note.Frontmatter.Tags {
+			if len(tagFiles[strings.ToLower(tag)]) > 1 {
+				shared = true
+				break
+			}
+		}
+		if !shared {
+			unshared = append(unshared, note)
+		}
+	}
+	return unshared
+}
@@ -0,0 +1,82 @@
+package notes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CmdPin implements the 'notes pin <filename>' command
+// Marks a note as pinned so that list shows it first
+func CmdPin(args []string) error {
+	return setPinned(args, "pin", true)
+}
+
+// CmdUnpin implements the 'notes unpin <filename>' command
+// Removes a note's pin
+func CmdUnpin(args []string) error {
+	return setPinned(args, "unpin", false)
+}
+
+func setPinned(args []string, cmdName string, pinned bool) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: notes %s <filename>", cmdName)
+	}
+
+	notesDir, err := GetWritableNotesDir()
+	if err != nil {
+		return err
+	}
+
+	filename, err := ResolveFilename(notesDir, args[0])
+	if err != nil {
+		return err
+	}
+	notePath := filepath.Join(notesDir, filename)
+
+	note, err := ParseNote(notePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("note not found: %s", filename)
+		}
+		return fmt.Errorf("failed to parse note: %w", err)
+	}
+	if !note.HasFrontmatter {
+		return fmt.Errorf("%s has no frontmatter to store the pin in", filename)
+	}
+
+	if note.Frontmatter.Pinned != pinned {
+		finish := beginUndo(notesDir, cmdName)
+		defer finish()
+
+		note.Frontmatter.Pinned = pinned
+		if err := note.Save(notePath); err != nil {
+			return fmt.Errorf("failed to save note: %w", err)
+		}
+
+		unlock, err := LockMeta(notesDir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		// The body is unchanged, so an up-to-date entry stays up to date
+		meta, err := LoadMetaFile(notesDir)
+		if err != nil {
+			return fmt.Errorf("failed to load meta file: %w", err)
+		}
+		if fileMeta := meta.GetFileMeta(filename); fileMeta != nil {
+			fileMeta.Pinned = pinned
+			if err := meta.Save(notesDir); err != nil {
+				return fmt.Errorf("failed to save meta file: %w", err)
+			}
+		}
+	}
+
+	if pinned {
+		fmt.Printf("Pinned %s\n", filename)
+	} else {
+		fmt.Printf("Unpinned %s\n", filename)
+	}
+	return nil
+}
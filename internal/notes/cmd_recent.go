@@ -0,0 +1,50 @@
+package notes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CmdRecent implements the 'notes recent [duration]' command
+// Lists notes created within a duration such as 7d (the default), 24h or 2w;
+// other arguments are passed on to 'notes list'
+func CmdRecent(args []string) error {
+	within := "7d"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		within, args = args[0], args[1:]
+	}
+	return CmdList(append([]string{"--within", within}, args...))
+}
+
+// parseRelativeDuration accepts anything time.ParseDuration does plus whole
+// days and weeks ("3d", "2w"). The duration must be positive.
+func parseRelativeDuration(value string) (time.Duration, error) {
+	invalid := fmt.Errorf("invalid duration: %s (expected e.g. 24h, 7d or 2w)", value)
+
+	var d time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, invalid
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else if weeks, ok := strings.CutSuffix(value, "w"); ok {
+		n, err := strconv.Atoi(weeks)
+		if err != nil {
+			return 0, invalid
+		}
+		d = time.Duration(n) * 7 * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(value); err != nil {
+			return 0, invalid
+		}
+	}
+
+	if d <= 0 {
+		return 0, invalid
+	}
+	return d, nil
+}
@@ -0,0 +1,228 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// reindexStats counts the fixes made by notes reindex
+type reindexStats struct {
+	asymmetric int // Relations added because only the other side declared them
+	wikilinks  int // Relations added from [[wikilinks]] in the body
+	dangling   int // Relations dropped because the target doesn't exist
+	self       int // Relations of a note to itself
+	duplicates int // Repeated entries in a related list
+}
+
+// CmdReindex implements the 'notes reindex' command
+// Rebuilds all relations from frontmatter, .meta.json and wikilinks, then
+// writes a symmetric, de-duplicated graph back to both
+func CmdReindex(args []string) error {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	dryRunFlag := fs.Bool("dry-run", false, "show what would change without writing")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	getDir := GetWritableNotesDir
+	if *dryRunFlag {
+		getDir = GetNotesDir
+	}
+	notesDir, err := getDir()
+	if err != nil {
+		return err
+	}
+
+	if !*dryRunFlag {
+		unlock, err := LockMeta(notesDir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		finish := beginUndo(notesDir, "reindex")
+		defer finish()
+	}
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	notesList, err := LoadAllNotes(notesDir)
+	if err != nil {
+		return err
+	}
+
+	notesByName := make(map[string]*Note)
+	ids := make(map[string]string)
+	for _, note := range notesList {
+		filename := note.Name()
+		notesByName[filename] = note
+		if note.Frontmatter.ID != "" {
+			ids[note.Frontmatter.ID] = filename
+		}
+	}
+
+	var stats reindexStats
+	declared := make(map[string][]string)
+	neighbors := make(map[string]map[string]bool)
+	link := func(a, b string) {
+		for _, pair := range [][2]string{{a, b}, {b, a}} {
+			if neighbors[pair[0]] == nil {
+				neighbors[pair[0]] = make(map[string]bool)
+			}
+			neighbors[pair[0]][pair[1]] = true
+		}
+	}
+
+	for filename, note := range notesByName {
+		// Plain notes only have relations in .meta.json, so use both sources
+		var targets []string
+		for _, rel := range currentRelated(meta, filename, note) {
+			rel = NormalizeFilename(rel)
+			if Contains(targets, rel) {
+				stats.duplicates++
+				continue
+			}
+			targets = append(targets, rel)
+		}
+		declared[filename] = targets
+
+		for _, rel := range targets {
+			switch {
+			case rel == filename:
+				stats.self++
+			case notesByName[rel] == nil:
+				stats.dangling++
+			default:
+				link(filename, rel)
+			}
+		}
+	}
+
+	// Wikilinks are resolved like command-line names: filename first, then id
+	wikilinked := make(map[[2]string]bool)
+	for filename, note := range notesByName {
+		for _, target := range note.Wikilinks() {
+			rel := NormalizeFilename(target)
+			if notesByName[rel] == nil {
+				rel = ids[target]
+			}
+			if rel == "" || rel == filename {
+				continue
+			}
+			wikilinked[[2]string{filename, rel}] = true
+			wikilinked[[2]string{rel, filename}] = true
+			link(filename, rel)
+		}
+	}
+
+	var filenames []string
+	for filename := range notesByName {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	changedNotes := 0
+	for _, filename := range filenames {
+		note := notesByName[filename]
+
+		related := []string{}
+		for rel := range neighbors[filename] {
+			related = append(related, rel)
+		}
+		sort.Strings(related)
+
+		var added, removed []string
+		for _, rel := range related {
+			if Contains(declared[filename], rel) {
+				continue
+			}
+			added = append(added, rel)
+			if Contains(declared[rel], filename) {
+				stats.asymmetric++
+			} else if wikilinked[[2]string{filename, rel}] {
+				stats.wikilinks++
+			}
+		}
+		for _, rel := range declared[filename] {
+			if !Contains(related, rel) {
+				removed = append(removed, rel)
+			}
+		}
+
+		fileMeta := meta.GetFileMeta(filename)
+		frontmatterOK := !note.HasFrontmatter || stringSliceEqual(note.Frontmatter.Related, related)
+		metaOK := (fileMeta == nil && len(related) == 0) || (fileMeta != nil && stringSliceEqual(fileMeta.Related, related))
+		if frontmatterOK && metaOK {
+			continue
+		}
+		changedNotes++
+
+		var changes []string
+		for _, rel := range added {
+			changes = append(changes, "+"+rel)
+		}
+		for _, rel := range removed {
+			changes = append(changes, "-"+rel)
+		}
+		if len(changes) == 0 {
+			changes = append(changes, "reordered")
+		}
+
+		if *dryRunFlag {
+			fmt.Printf("Would update: %s (%s)\n", filename, strings.Join(changes, ", "))
+			continue
+		}
+		fmt.Printf("Updated: %s (%s)\n", filename, strings.Join(changes, ", "))
+
+		if !frontmatterOK {
+			note.Frontmatter.Related = related
+			if err := note.Save(note.Filename); err != nil {
+				return fmt.Errorf("failed to update %s: %w", filename, err)
+			}
+		}
+		if fileMeta == nil {
+			// Like relate, track unsynced notes without a hash so they
+			// still show up as needing enrichment
+			fileMeta = &FileMeta{
+				Tags:    note.Frontmatter.Tags,
+				Summary: note.Frontmatter.Summary,
+			}
+			meta.SetFileMeta(filename, fileMeta)
+		}
+		fileMeta.Related = related
+	}
+
+	if !*dryRunFlag && changedNotes > 0 {
+		if err := meta.Save(notesDir); err != nil {
+			return fmt.Errorf("failed to save meta file: %w", err)
+		}
+	}
+
+	verb := "Reindexed"
+	if *dryRunFlag {
+		verb = "Dry run: would reindex"
+	}
+	fmt.Printf("\n%s %d notes (%d changed): %d asymmetric added, %d from wikilinks, %d dangling removed, %d self-references removed, %d duplicates removed\n",
+		verb, len(notesList), changedNotes, stats.asymmetric, stats.wikilinks, stats.dangling, stats.self, stats.duplicates)
+	return nil
+}
+
+// currentRelated returns the union of a note's relations from its
+// frontmatter and its .meta.json entry, keeping duplicates within each
+func currentRelated(meta *MetaFile, filename string, note *Note) []string {
+	related := append([]string{}, note.Frontmatter.Related...)
+	if fileMeta := meta.GetFileMeta(filename); fileMeta != nil {
+		for _, rel := range fileMeta.Related {
+			if !Contains(note.Frontmatter.Related, rel) {
+				related = append(related, rel)
+			}
+		}
+	}
+	return related
+}
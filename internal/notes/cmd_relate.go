@@ -0,0 +1,118 @@
+package notes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CmdRelate implements the 'notes relate <a> <b>' command
+// Adds a single bidirectional relation, keeping existing ones
+func CmdRelate(args []string) error {
+	return changeRelation(args, "relate", true)
+}
+
+// CmdUnrelate implements the 'notes unrelate <a> <b>' command
+// Removes a single bidirectional relation
+func CmdUnrelate(args []string) error {
+	return changeRelation(args, "unrelate", false)
+}
+
+func changeRelation(args []string, cmdName string, add bool) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: notes %s <a> <b>", cmdName)
+	}
+
+	notesDir, err := GetWritableNotesDir()
+	if err != nil {
+		return err
+	}
+
+	a := NormalizeFilename(args[0])
+	b := NormalizeFilename(args[1])
+	if a == b {
+		return fmt.Errorf("cannot %s a note to itself", cmdName)
+	}
+
+	unlock, err := LockMeta(notesDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	finish := beginUndo(notesDir, cmdName)
+	defer finish()
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	for _, filename := range []string{a, b} {
+		note, err := ParseNote(filepath.Join(notesDir, filename))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("note not found: %s", filename)
+			}
+			return fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		// A link only in .meta.json would be one-sided and dropped by sync
+		if add && !note.HasFrontmatter {
+			return fmt.Errorf("%s has no frontmatter to store the relation in", filename)
+		}
+
+		// AddRelation only touches existing entries. Track notes that were
+		// never synced without a hash so they still show up as needing enrichment.
+		if meta.GetFileMeta(filename) == nil {
+			meta.SetFileMeta(filename, &FileMeta{
+				Tags:    note.Frontmatter.Tags,
+				Summary: note.Frontmatter.Summary,
+				Related: note.Frontmatter.Related,
+			})
+		}
+	}
+
+	if add {
+		meta.AddRelation(a, b)
+	} else {
+		meta.RemoveRelation(a, b)
+	}
+
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		if err := setRelatedInFile(notesDir, pair[0], pair[1], add); err != nil {
+			return fmt.Errorf("failed to update %s: %w", pair[0], err)
+		}
+	}
+
+	if err := meta.Save(notesDir); err != nil {
+		return fmt.Errorf("failed to save meta file: %w", err)
+	}
+
+	if add {
+		fmt.Printf("Related %s <-> %s\n", a, b)
+	} else {
+		fmt.Printf("Unrelated %s <-> %s\n", a, b)
+	}
+	return nil
+}
+
+// setRelatedInFile adds or removes a single entry in a note's frontmatter
+// related list. Plain notes without frontmatter are left untouched, as they
+// can only be unrelated.
+func setRelatedInFile(notesDir, filename, other string, add bool) error {
+	notePath := filepath.Join(notesDir, filename)
+	note, err := ParseNote(notePath)
+	if err != nil {
+		return err
+	}
+	if !note.HasFrontmatter || Contains(note.Frontmatter.Related, other) == add {
+		return nil
+	}
+
+	if add {
+		note.Frontmatter.Related = append(note.Frontmatter.Related, other)
+	} else {
+		note.Frontmatter.Related = RemoveString(note.Frontmatter.Related, other)
+	}
+	return note.Save(notePath)
+}
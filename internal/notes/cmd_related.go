@@ -0,0 +1,159 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// minTermLength leaves out short words, which are mostly stop words, when
+// comparing note contents
+const minTermLength = 4
+
+// relatedSuggestion is a note ranked by how similar it is to the target
+type relatedSuggestion struct {
+	note        *Note
+	sharedTags  []string
+	termOverlap float64 // Jaccard index of the notes' terms, with --content
+}
+
+// CmdRelatedSuggest implements the 'notes related <filename>' command
+// Suggests notes to relate by shared tags and, with --content, shared terms
+func CmdRelatedSuggest(args []string) error {
+	fs := flag.NewFlagSet("related", flag.ExitOnError)
+	limitFlag := fs.Int("limit", 5, "show at most this many suggestions")
+	contentFlag := fs.Bool("content", false, "also rank by words the notes have in common")
+	addFlag := fs.Bool("add", false, "relate the top suggestion to the note")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: notes related <filename> [--limit N] [--content] [--add]")
+	}
+	if *limitFlag < 1 {
+		return fmt.Errorf("--limit must be at least 1")
+	}
+
+	notesDir, err := GetNotesDir()
+	if err != nil {
+		return fmt.Errorf("failed to get notes directory: %w", err)
+	}
+
+	filename, err := ResolveFilename(notesDir, positional[0])
+	if err != nil {
+		return err
+	}
+
+	notesList, err := LoadAllNotes(notesDir)
+	if err != nil {
+		return err
+	}
+
+	var target *Note
+	for _, note := range notesList {
+		if note.Name() == filename {
+			target = note
+			break
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("note not found: %s", filename)
+	}
+
+	suggestions := suggestRelated(target, notesList, *contentFlag)
+	if len(suggestions) > *limitFlag {
+		suggestions = suggestions[:*limitFlag]
+	}
+
+	if len(suggestions) == 0 {
+		fmt.Printf("No suggestions for %s\n", filename)
+		return nil
+	}
+
+	if *addFlag {
+		return CmdRelate([]string{filename, suggestions[0].note.Name()})
+	}
+
+	for _, s := range suggestions {
+		fmt.Printf("%s  %q\n", s.note.Name(), s.note.GetSummaryOrFirstLine())
+		if len(s.sharedTags) > 0 {
+			fmt.Printf("  shared tags: %s\n", strings.Join(s.sharedTags, ", "))
+		}
+		if *contentFlag {
+			fmt.Printf("  content overlap: %.0f%%\n", s.termOverlap*100)
+		}
+	}
+	return nil
+}
+
+// suggestRelated ranks the notes that aren't target or already related to
+// it, most shared tags first, then most shared terms. Notes with nothing in
+// common are left out.
+func suggestRelated(target *Note, notesList []*Note, byContent bool) []relatedSuggestion {
+	var targetTerms map[string]bool
+	if byContent {
+		targetTerms = contentTerms(target.Content)
+	}
+
+	var suggestions []relatedSuggestion
+	for _, note := range notesList {
+		name := note.Name()
+		if name == target.Name() || Contains(target.Frontmatter.Related, name) {
+			continue
+		}
+
+		s := relatedSuggestion{note: note, sharedTags: sharedTags(target.Frontmatter.Tags, note.Frontmatter.Tags)}
+		if byContent {
+			s.termOverlap = termOverlap(targetTerms, contentTerms(note.Content))
+		}
+		if len(s.sharedTags) > 0 || s.termOverlap > 0 {
+			suggestions = append(suggestions, s)
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if len(a.sharedTags) != len(b.sharedTags) {
+			return len(a.sharedTags) > len(b.sharedTags)
+		}
+		if a.termOverlap != b.termOverlap {
+			return a.termOverlap > b.termOverlap
+		}
+		return a.note.Name() < b.note.Name()
+	})
+	return suggestions
+}
+
+// contentTerms returns the distinct lowercased words of a note body
+func contentTerms(content string) map[string]bool {
+	terms := make(map[string]bool)
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if utf8.RuneCountInString(word) >= minTermLength {
+			terms[word] = true
+		}
+	}
+	return terms
+}
+
+// termOverlap is the share of terms the two sets have in common
+func termOverlap(a, b map[string]bool) float64 {
+	common := 0
+	for term := range a {
+		if b[term] {
+			common++
+		}
+	}
+	union := len(a) + len(b) - common
+	if union == 0 {
+		return 0
+	}
+	return float64(common) / float64(union)
+}
@@ -0,0 +1,183 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CmdRename implements the 'notes rename <old> <new>' command
+// Renames a note and rewrites relations pointing at it
+func CmdRename(args []string) error {
+	fs := flag.NewFlagSet("rename", flag.ExitOnError)
+	titleFlag := fs.String("title", "", "also set the note's leading # heading")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 2 {
+		return fmt.Errorf("usage: notes rename <old> <new> [--title \"...\"]")
+	}
+
+	notesDir, err := GetWritableNotesDir()
+	if err != nil {
+		return err
+	}
+
+	oldName, err := ResolveNote(notesDir, positional[0])
+	if err != nil {
+		return err
+	}
+	newName := NormalizeFilename(positional[1])
+
+	finish := beginUndo(notesDir, "rename")
+	defer finish()
+	if err := relocateNote(notesDir, oldName, newName, *titleFlag); err != nil {
+		return err
+	}
+
+	fmt.Printf("Renamed %s -> %s\n", oldName, newName)
+	return nil
+}
+
+// relocateNote renames a note within the notes directory, creating the
+// target's directory if needed, and keeps its .meta.json entry and the
+// relations pointing at it. A non-empty title replaces the note's heading.
+func relocateNote(notesDir, oldName, newName, title string) error {
+	oldPath := filepath.Join(notesDir, oldName)
+	newPath := filepath.Join(notesDir, newName)
+
+	if oldName != newName {
+		if _, err := os.Stat(newPath); err == nil {
+			return fmt.Errorf("note already exists: %s", newName)
+		}
+	}
+
+	note, err := ParseNote(oldPath)
+	if err != nil {
+		return fmt.Errorf("failed to parse note: %w", err)
+	}
+
+	unlock, err := LockMeta(notesDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := renameRecorded(oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to rename note: %w", err)
+	}
+
+	oldHash := note.ContentHash()
+	if title != "" {
+		note.Content = setTitle(note.Content, title)
+		if err := note.SaveKeepingFormat(newPath); err != nil {
+			return fmt.Errorf("failed to save note: %w", err)
+		}
+	}
+
+	// Move the meta entry, keeping enrichment state
+	meta.RefreshHash(oldName, oldHash, note.ContentHash())
+	if fileMeta := meta.GetFileMeta(oldName); fileMeta != nil {
+		delete(meta.Files, oldName)
+		meta.SetFileMeta(newName, fileMeta)
+	}
+
+	if oldName != newName {
+		if err := rewriteRelations(notesDir, meta, oldName, newName); err != nil {
+			return err
+		}
+	}
+
+	if err := meta.Save(notesDir); err != nil {
+		return fmt.Errorf("failed to save meta file: %w", err)
+	}
+	return nil
+}
+
+// rewriteRelations replaces oldName, with or without ".md", by newName in
+// every note's related list, both in .meta.json and in the notes' frontmatter
+func rewriteRelations(notesDir string, meta *MetaFile, oldName, newName string) error {
+	for _, fileMeta := range meta.Files {
+		fileMeta.Related = replaceString(fileMeta.Related, oldName, newName)
+	}
+
+	notesList, err := LoadAllNotes(notesDir)
+	if err != nil {
+		return err
+	}
+
+	for _, note := range notesList {
+		if !relatesTo(note.Frontmatter.Related, oldName) {
+			continue
+		}
+		note.Frontmatter.Related = replaceString(note.Frontmatter.Related, oldName, newName)
+		if err := note.Save(note.Filename); err != nil {
+			return fmt.Errorf("failed to update %s: %w", note.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// replaceString replaces every entry naming the note old (with or without
+// ".md") in slice, dropping the replacement if it's already present
+func replaceString(slice []string, old, new string) []string {
+	if !relatesTo(slice, old) {
+		return slice
+	}
+	result := make([]string, 0, len(slice))
+	for _, s := range slice {
+		if NormalizeFilename(s) == old {
+			s = new
+		}
+		if !Contains(result, s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// relatesTo reports whether a related list names filename, with or without ".md"
+func relatesTo(related []string, filename string) bool {
+	for _, rel := range related {
+		if NormalizeFilename(rel) == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// setTitle replaces the leading "# " heading of the body, or inserts one
+// before the first non-empty line
+func setTitle(content, title string) string {
+	heading := "# " + title
+
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if strings.TrimSpace(line) == "" {
+			offset += len(line)
+			continue
+		}
+		if strings.HasPrefix(line, "# ") {
+			end := offset + len(strings.TrimRight(line, "\n"))
+			return content[:offset] + heading + content[end:]
+		}
+		return content[:offset] + heading + "\n\n" + content[offset:]
+	}
+
+	// Empty body
+	return "\n" + heading + "\n"
+}
@@ -0,0 +1,467 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Search scopes for --in
+const (
+	searchInBody        = "body"
+	searchInFrontmatter = "frontmatter"
+	searchInBoth        = "both"
+)
+
+// SearchMatch is a single matching line in a note body
+type SearchMatch struct {
+	Line    int    `json:"line"`
+	Text    string `json:"text"`
+	Context bool   `json:"context,omitempty"` // A line around a match, not a match itself
+}
+
+// searchOptions selects which notes and fields searchNotes matches
+type searchOptions struct {
+	scope          string   // searchInBody, searchInFrontmatter or searchInBoth
+	includeSummary bool     // Also match summaries when searching bodies
+	tags           []string // Only search notes with these tags
+	tagLogic       string   // Whether notes need any (tagLogicOr) or all (tagLogicAnd) of tags
+	context        int      // Body lines to include around each match
+}
+
+// CmdSearch implements the 'notes search <query>' command
+// Searches note bodies and/or summaries and tags, optionally replacing
+// matches in bodies
+func CmdSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	inFlag := fs.String("in", searchInBody, "where to search: body, frontmatter (summary and tags) or both")
+	regexFlag := fs.Bool("regex", false, "treat the query as a regular expression")
+	replaceFlag := fs.String("replace", "", "replace matches with this text ($1 etc. refer to groups with --regex)")
+	yesFlag := fs.Bool("yes", false, "apply --replace (default is a dry run)")
+	includeSummaryFlag := fs.Bool("include-summary", false, "also match summaries when searching bodies")
+	tagsFlag := fs.String("tags", "", "only search notes with any of these tags (comma-separated)")
+	tagLogicFlag := fs.String("tag-logic", GetTagLogic(), "with several --tags, whether notes need any (or) or all (and) of them (default from the tag_logic setting)")
+	contextFlag := fs.Int("context", 0, "show this many lines around each body match")
+	sortFlag := fs.String("sort", "created", "result order: created (newest first) or none (filename order, streamed)")
+	var limit int
+	fs.IntVar(&limit, "limit", 0, "show at most this many matching notes (0 for no limit)")
+	fs.IntVar(&limit, "max-results", 0, "same as --limit")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) == 0 {
+		return fmt.Errorf("usage: notes search <query> [--in body|frontmatter|both] [--include-summary] [--tags <tags> [--tag-logic and]] [--regex] [--context N] [--limit N] [--replace <new> [--yes]]")
+	}
+
+	switch *inFlag {
+	case searchInBody, searchInFrontmatter, searchInBoth:
+	default:
+		return fmt.Errorf("invalid --in value: %s (expected body, frontmatter or both)", *inFlag)
+	}
+	if *sortFlag != "created" && *sortFlag != "none" {
+		return fmt.Errorf("invalid --sort value: %s (expected created or none)", *sortFlag)
+	}
+	if err := checkTagLogic(*tagLogicFlag); err != nil {
+		return err
+	}
+	if *contextFlag < 0 {
+		return fmt.Errorf("--context must not be negative")
+	}
+	opts := searchOptions{
+		scope:          *inFlag,
+		includeSummary: *includeSummaryFlag,
+		tags:           parseCSV(*tagsFlag),
+		tagLogic:       *tagLogicFlag,
+		context:        *contextFlag,
+	}
+
+	query := strings.Join(positional, " ")
+	re, err := compileSearchPattern(query, *regexFlag)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	replacing := isFlagSet(fs, "replace")
+	if replacing && *inFlag != searchInBody {
+		return fmt.Errorf("--replace only rewrites note bodies and cannot be combined with --in %s", *inFlag)
+	}
+	if replacing && *includeSummaryFlag {
+		return fmt.Errorf("--replace only rewrites note bodies and cannot be combined with --include-summary")
+	}
+
+	var notesDir string
+	if replacing && *yesFlag {
+		notesDir, err = GetWritableNotesDir()
+		if err != nil {
+			return err
+		}
+	} else {
+		notesDir, err = GetNotesDir()
+		if err != nil {
+			return fmt.Errorf("failed to get notes directory: %w", err)
+		}
+	}
+
+	if replacing {
+		allNotes, err := LoadAllNotes(notesDir)
+		if err != nil {
+			return err
+		}
+		var notesList []*Note
+		for _, note := range allNotes {
+			if len(opts.tags) == 0 || matchTags(note.Frontmatter.Tags, opts.tags, opts.tagLogic) {
+				notesList = append(notesList, note)
+			}
+		}
+
+		// Newest first, like notes list
+		sort.SliceStable(notesList, func(i, j int) bool {
+			return notesList[i].Frontmatter.Created.After(notesList[j].Frontmatter.Created.Time)
+		})
+
+		return replaceInNotes(notesDir, notesList, re, *replaceFlag, *regexFlag, *yesFlag)
+	}
+
+	// Unsorted results are printed as they are found
+	if *sortFlag == "none" {
+		return searchNotes(notesDir, re, opts, limit, runtime.NumCPU(), printSearchResult)
+	}
+
+	var results []searchResult
+	err = searchNotes(notesDir, re, opts, 0, runtime.NumCPU(), func(result searchResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Newest first, like notes list
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Created.After(results[j].Created)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	for _, result := range results {
+		if err := printSearchResult(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printSearchResult prints a matching note. Frontmatter fields are named;
+// body lines show their line number, followed by ":" for matches and "-"
+// for context, with "--" between groups that aren't adjacent.
+func printSearchResult(result searchResult) error {
+	fmt.Println(result.Filename)
+	if result.Summary != "" {
+		fmt.Printf("  summary: %s\n", result.Summary)
+	}
+	if len(result.Tags) > 0 {
+		fmt.Printf("  tags: %s\n", strings.Join(result.Tags, ", "))
+	}
+	withContext := false
+	for _, m := range result.Body {
+		withContext = withContext || m.Context
+	}
+	for i, m := range result.Body {
+		if withContext && i > 0 && m.Line > result.Body[i-1].Line+1 {
+			fmt.Println("  --")
+		}
+		sep := ":"
+		if m.Context {
+			sep = "-"
+		}
+		fmt.Println(strings.TrimRight(fmt.Sprintf("  %d%s %s", m.Line, sep, strings.TrimSpace(m.Text)), " "))
+	}
+	return nil
+}
+
+// searchResult holds the matches found in one note
+type searchResult struct {
+	Filename string
+	Created  time.Time
+	Summary  string        // The summary, if it matched
+	Tags     []string      // Matching tags
+	Body     []SearchMatch // Matching body lines
+	err      error         // Set when the note could not be parsed
+}
+
+func (r searchResult) matched() bool {
+	return r.Summary != "" || len(r.Tags) > 0 || len(r.Body) > 0
+}
+
+// searchNotes reads and matches notes on a pool of workers, calling emit for
+// each matching note in filename order. Results that arrive early are held
+// until every note before them has been emitted; at most a few per worker
+// are in flight, so memory stays bounded on huge notebooks. With maxResults
+// above 0, no further notes are read once that many have matched.
+func searchNotes(notesDir string, re *regexp.Regexp, opts searchOptions, maxResults, workers int, emit func(searchResult) error) error {
+	names, err := listNoteFiles(notesDir, func(s SkippedFile) {
+		reportSkipped([]SkippedFile{s})
+	})
+	if err != nil {
+		return err
+	}
+
+	type indexedResult struct {
+		index  int
+		result searchResult
+	}
+
+	jobs := make(chan int)
+	results := make(chan indexedResult)
+	window := make(chan struct{}, workers*4) // Notes dispatched but not yet emitted
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- indexedResult{i, searchFile(notesDir, names[i], re, opts)}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := range names {
+			select {
+			case window <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]searchResult)
+	next, emitted := 0, 0
+	stopped := false
+	var emitErr error
+	stop := func() {
+		stopped = true
+		close(done)
+	}
+
+	// Keep receiving after a stop so workers can finish and exit
+	for r := range results {
+		if stopped {
+			continue
+		}
+		pending[r.index] = r.result
+
+		for !stopped {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-window
+
+			if result.err != nil {
+				reportSkipped([]SkippedFile{{
+					Name:   result.Filename,
+					Reason: fmt.Sprintf("failed to parse: %v", result.err),
+					Err:    result.err,
+				}})
+				continue
+			}
+			if !result.matched() {
+				continue
+			}
+
+			if err := emit(result); err != nil {
+				emitErr = err
+				stop()
+				break
+			}
+			emitted++
+			if maxResults > 0 && emitted >= maxResults {
+				stop()
+			}
+		}
+	}
+
+	return emitErr
+}
+
+// searchFile parses one note and matches re against the fields selected by opts
+func searchFile(notesDir, filename string, re *regexp.Regexp, opts searchOptions) searchResult {
+	result := searchResult{Filename: filename}
+
+	// Searches stream their results, so don't keep every note in the cache
+	note, err := parseNoteOnce(filepath.Join(notesDir, filename))
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.Created = note.Frontmatter.Created.Time
+
+	if len(opts.tags) > 0 && !matchTags(note.Frontmatter.Tags, opts.tags, opts.tagLogic) {
+		return result
+	}
+
+	if opts.scope != searchInBody || opts.includeSummary {
+		if note.Frontmatter.Summary != "" && re.MatchString(note.Frontmatter.Summary) {
+			result.Summary = note.Frontmatter.Summary
+		}
+	}
+	if opts.scope != searchInBody {
+		for _, tag := range note.Frontmatter.Tags {
+			if re.MatchString(tag) {
+				result.Tags = append(result.Tags, tag)
+			}
+		}
+	}
+	if opts.scope != searchInFrontmatter {
+		result.Body = findMatches(note.Content, re)
+		if opts.context > 0 {
+			result.Body = addContext(note.Content, result.Body, opts.context)
+		}
+	}
+
+	return result
+}
+
+// compileSearchPattern builds a case-insensitive pattern for a literal or regex query
+func compileSearchPattern(query string, isRegex bool) (*regexp.Regexp, error) {
+	if !isRegex {
+		query = regexp.QuoteMeta(query)
+	}
+	return regexp.Compile("(?i)" + query)
+}
+
+// findMatches returns the body lines matching re, numbered from 1
+func findMatches(content string, re *regexp.Regexp) []SearchMatch {
+	var matches []SearchMatch
+	for i, line := range strings.Split(content, "\n") {
+		if re.MatchString(line) {
+			matches = append(matches, SearchMatch{Line: i + 1, Text: line})
+		}
+	}
+	return matches
+}
+
+// addContext adds up to n lines before and after each match, in line order
+func addContext(content string, matches []SearchMatch, n int) []SearchMatch {
+	lines := strings.Split(content, "\n")
+	matched := make(map[int]bool)
+	for _, m := range matches {
+		matched[m.Line] = true
+	}
+
+	var withContext []SearchMatch
+	last := 0 // Last line added
+	for _, m := range matches {
+		for line := max(m.Line-n, last+1); line <= min(m.Line+n, len(lines)); line++ {
+			withContext = append(withContext, SearchMatch{Line: line, Text: lines[line-1], Context: !matched[line]})
+			last = line
+		}
+	}
+	return withContext
+}
+
+// replaceLines replaces matches line by line. With isRegex, the replacement
+// may reference capture groups; otherwise it is inserted literally.
+func replaceLines(content string, re *regexp.Regexp, replacement string, isRegex bool) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if isRegex {
+			lines[i] = re.ReplaceAllString(line, replacement)
+		} else {
+			lines[i] = re.ReplaceAllLiteralString(line, replacement)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// replaceInNotes previews or applies a replacement across note bodies.
+// Frontmatter is never modified; content hashes are refreshed in .meta.json.
+func replaceInNotes(notesDir string, notesList []*Note, re *regexp.Regexp, replacement string, isRegex, apply bool) error {
+	var meta *MetaFile
+	if apply {
+		unlock, err := LockMeta(notesDir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		finish := beginUndo(notesDir, "replace")
+		defer finish()
+
+		meta, err = LoadMetaFile(notesDir)
+		if err != nil {
+			return fmt.Errorf("failed to load meta file: %w", err)
+		}
+	}
+
+	changedNotes := 0
+	for _, note := range notesList {
+		newContent := replaceLines(note.Content, re, replacement, isRegex)
+		if newContent == note.Content {
+			continue
+		}
+		changedNotes++
+
+		filename := note.Name()
+		fmt.Println(filename)
+
+		oldLines := strings.Split(note.Content, "\n")
+		newLines := strings.Split(newContent, "\n")
+		for i := range oldLines {
+			if oldLines[i] != newLines[i] {
+				fmt.Printf("  %d: - %s\n", i+1, strings.TrimSpace(oldLines[i]))
+				fmt.Printf("  %d: + %s\n", i+1, strings.TrimSpace(newLines[i]))
+			}
+		}
+
+		if !apply {
+			continue
+		}
+
+		oldHash := note.ContentHash()
+		note.Content = newContent
+		if err := note.SaveKeepingFormat(note.Filename); err != nil {
+			return fmt.Errorf("failed to save %s: %w", filename, err)
+		}
+		meta.RefreshHash(filename, oldHash, note.ContentHash())
+	}
+
+	if !apply {
+		fmt.Printf("\nDry run: would change %d notes (use --yes to apply)\n", changedNotes)
+		return nil
+	}
+
+	if changedNotes > 0 {
+		if err := meta.Save(notesDir); err != nil {
+			return fmt.Errorf("failed to save meta file: %w", err)
+		}
+	}
+
+	fmt.Printf("\nChanged %d notes\n", changedNotes)
+	return nil
+}
@@ -1,16 +1,59 @@
 package notes
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 )
 
+// ANSI reverse video, used to highlight matches on a terminal
+const (
+	highlightStart = "\x1b[7m"
+	highlightEnd   = "\x1b[27m"
+)
+
+// FrontmatterOutput is a note's own frontmatter as printed by show --frontmatter-json
+type FrontmatterOutput struct {
+	ID      string   `json:"id,omitempty"`
+	Created string   `json:"created"`
+	Tags    []string `json:"tags"`
+	Summary string   `json:"summary"`
+	Related []string `json:"related"`
+	Pinned  bool     `json:"pinned,omitempty"`
+}
+
 // CmdShow implements the 'notes show <filename>' command
 // Prints note content without frontmatter
 func CmdShow(args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("usage: notes show <filename>")
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	highlightFlag := fs.String("highlight", "", "highlight occurrences of this term (on a terminal)")
+	regexFlag := fs.Bool("regex", false, "treat --highlight as a regular expression")
+	frontmatterJSONFlag := fs.Bool("frontmatter-json", false, "print only the note's frontmatter as JSON (ignores .meta.json)")
+	outlineFlag := fs.Bool("outline", false, "print only the headings, indented by level")
+	lineNumbersFlag := fs.Bool("line-numbers", false, "with --outline, prefix each heading with its line in the file (for 'notes edit --line')")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) == 0 {
+		return fmt.Errorf("usage: notes show <filename> [--highlight <term> [--regex] | --frontmatter-json | --outline [--line-numbers]]")
+	}
+	if *lineNumbersFlag && !*outlineFlag {
+		return fmt.Errorf("--line-numbers requires --outline")
+	}
+
+	var re *regexp.Regexp
+	if *highlightFlag != "" {
+		re, err = compileSearchPattern(*highlightFlag, *regexFlag)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
 	}
 
 	notesDir, err := GetNotesDir()
@@ -18,7 +61,10 @@ func CmdShow(args []string) error {
 		return fmt.Errorf("failed to get notes directory: %w", err)
 	}
 
-	filename := NormalizeFilename(args[0])
+	filename, err := ResolveFilename(notesDir, positional[0])
+	if err != nil {
+		return err
+	}
 	notePath := filepath.Join(notesDir, filename)
 
 	note, err := ParseNote(notePath)
@@ -29,12 +75,108 @@ func CmdShow(args []string) error {
 		return fmt.Errorf("failed to parse note: %w", err)
 	}
 
+	if *frontmatterJSONFlag {
+		return outputJSON(newFrontmatterOutput(note.Frontmatter), false)
+	}
+
+	if *outlineFlag {
+		return printOutline(notePath, note, *lineNumbersFlag)
+	}
+
 	// Print content without leading newline if present
 	content := note.Content
 	if len(content) > 0 && content[0] == '\n' {
 		content = content[1:]
 	}
+	if re != nil && stdoutIsTerminal() {
+		content = highlightMatches(content, re)
+	}
 	fmt.Print(content)
 
 	return nil
 }
+
+// outlineHeading is a markdown heading in a note body
+type outlineHeading struct {
+	Level int
+	Title string
+	Line  int // 1-based line within the body
+}
+
+// noteOutline returns the body's headings in order, skipping fenced code
+func noteOutline(content string) []outlineHeading {
+	var headings []outlineHeading
+	inCode := false
+	for i, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		if m := headingPattern.FindStringSubmatch(line); m != nil {
+			headings = append(headings, outlineHeading{Level: len(m[1]), Title: strings.TrimSpace(m[2]), Line: i + 1})
+		}
+	}
+	return headings
+}
+
+// printOutline prints the note's headings indented by level, prefixed with
+// their line numbers in the file if lineNumbers is set
+func printOutline(notePath string, note *Note, lineNumbers bool) error {
+	headings := noteOutline(note.Content)
+	if len(headings) == 0 {
+		fmt.Println("No headings found")
+		return nil
+	}
+
+	// Body lines are offset by the frontmatter above them
+	offset := 0
+	if lineNumbers {
+		data, err := os.ReadFile(notePath)
+		if err != nil {
+			return fmt.Errorf("failed to read note: %w", err)
+		}
+		offset = strings.Count(string(data[:len(data)-len(note.Content)]), "\n")
+	}
+	width := len(fmt.Sprint(offset + headings[len(headings)-1].Line))
+
+	for _, h := range headings {
+		indent := strings.Repeat("  ", h.Level-1)
+		if lineNumbers {
+			fmt.Printf("%*d  %s%s\n", width, offset+h.Line, indent, h.Title)
+		} else {
+			fmt.Printf("%s%s\n", indent, h.Title)
+		}
+	}
+	return nil
+}
+
+// highlightMatches wraps every non-empty match of re in reverse video
+func highlightMatches(content string, re *regexp.Regexp) string {
+	return re.ReplaceAllStringFunc(content, func(match string) string {
+		if match == "" {
+			return match
+		}
+		return highlightStart + match + highlightEnd
+	})
+}
+
+func newFrontmatterOutput(fm Frontmatter) FrontmatterOutput {
+	output := FrontmatterOutput{
+		ID:      fm.ID,
+		Created: fm.Created.UTC().Format(time.RFC3339),
+		Tags:    fm.Tags,
+		Summary: fm.Summary,
+		Related: fm.Related,
+		Pinned:  fm.Pinned,
+	}
+	if output.Tags == nil {
+		output.Tags = []string{}
+	}
+	if output.Related == nil {
+		output.Related = []string{}
+	}
+	return output
+}
@@ -0,0 +1,195 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StatsOutput is the JSON output of notes stats
+type StatsOutput struct {
+	Notes       int     `json:"notes"`
+	Enriched    int     `json:"enriched"`
+	Unenriched  int     `json:"unenriched"`
+	UniqueTags  int     `json:"unique_tags"`
+	AvgTags     float64 `json:"avg_tags_per_note"`
+	Orphans     int     `json:"orphans"`          // Notes without relations in either direction
+	Oldest      string  `json:"oldest,omitempty"` // Earliest created time
+	Newest      string  `json:"newest,omitempty"` // Latest created time
+	Words       int     `json:"words"`
+	ReadingTime string  `json:"reading_time"`
+
+	Network *NetworkHealth `json:"network,omitempty"`
+
+	oldest, newest time.Time // For the text output, which shows local dates
+}
+
+// NetworkHealth describes how well the declared relations hold together.
+// Only the metrics that were asked for are set.
+type NetworkHealth struct {
+	Relations   int      `json:"relations"` // Directed relations between existing notes
+	DeadLinks   *int     `json:"dead_links,omitempty"`
+	Asymmetric  *int     `json:"asymmetric,omitempty"`
+	Reciprocity *float64 `json:"reciprocity,omitempty"` // Share of relations declared in both directions
+}
+
+// CmdStats implements the 'notes stats' command
+// Prints notebook statistics, optionally with relation health metrics
+func CmdStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	deadLinksFlag := fs.Bool("dead-links", false, "count related links whose target doesn't exist")
+	reciprocityFlag := fs.Bool("reciprocity", false, "count relations not declared in both directions")
+	jsonFlag := fs.Bool("json", false, "output as JSON")
+	wpmFlag := fs.Int("wpm", 0, "reading speed in words per minute (default $NOTES_WPM or 200)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	speed, err := GetReadingSpeed(*wpmFlag)
+	if err != nil {
+		return err
+	}
+
+	notesDir, err := GetNotesDir()
+	if err != nil {
+		return fmt.Errorf("failed to get notes directory: %w", err)
+	}
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	notesList, err := LoadAllNotes(notesDir)
+	if err != nil {
+		return err
+	}
+
+	stats := summarizeNotes(meta, notesList, speed)
+	if *deadLinksFlag || *reciprocityFlag {
+		dead, asymmetric, relations := countRelationHealth(notesDir, meta, notesList)
+		stats.Network = &NetworkHealth{Relations: relations}
+		if *deadLinksFlag {
+			stats.Network.DeadLinks = &dead
+		}
+		if *reciprocityFlag {
+			stats.Network.Asymmetric = &asymmetric
+			if relations > 0 {
+				ratio := float64(relations-asymmetric) / float64(relations)
+				stats.Network.Reciprocity = &ratio
+			}
+		}
+	}
+
+	if *jsonFlag {
+		return outputJSON(stats, false)
+	}
+
+	fmt.Printf("Notes:    %d (%d enriched, %d need enrichment)\n", stats.Notes, stats.Enriched, stats.Unenriched)
+	fmt.Printf("Tags:     %d unique, %.1f per note\n", stats.UniqueTags, stats.AvgTags)
+	fmt.Printf("Orphans:  %d\n", stats.Orphans)
+	if stats.Notes > 0 {
+		fmt.Printf("Created:  %s to %s\n", stats.oldest.Local().Format("2006-01-02"), stats.newest.Local().Format("2006-01-02"))
+	}
+	fmt.Printf("Words:    %d (%s)\n", stats.Words, stats.ReadingTime)
+	if network := stats.Network; network != nil {
+		fmt.Println("\nNetwork health:")
+		fmt.Printf("  Relations:    %d\n", network.Relations)
+		if network.DeadLinks != nil {
+			fmt.Printf("  Dead links:   %d\n", *network.DeadLinks)
+		}
+		if network.Asymmetric != nil {
+			fmt.Printf("  Asymmetric:   %d\n", *network.Asymmetric)
+			if network.Reciprocity != nil {
+				fmt.Printf("  Reciprocity:  %.0f%% (%d of %d relations returned)\n",
+					*network.Reciprocity*100, network.Relations-*network.Asymmetric, network.Relations)
+			} else {
+				fmt.Println("  Reciprocity:  n/a (no relations)")
+			}
+		}
+		if (network.DeadLinks != nil && *network.DeadLinks > 0) || (network.Asymmetric != nil && *network.Asymmetric > 0) {
+			fmt.Println("\nRun 'notes reindex' to drop dead links and add missing back-links")
+		}
+	}
+
+	return nil
+}
+
+// summarizeNotes computes the statistics shown without any flags
+func summarizeNotes(meta *MetaFile, notesList []*Note, speed ReadingSpeed) StatsOutput {
+	stats := StatsOutput{Notes: len(notesList)}
+	tagFiles := make(map[string][]string)
+	tagCount := 0
+	var total TextCount
+	var oldest, newest time.Time
+
+	for i, note := range notesList {
+		filename := note.Name()
+		if meta.NeedsEnrichment(filename, note.ContentHash()) {
+			stats.Unenriched++
+		} else {
+			stats.Enriched++
+		}
+
+		addNoteTags(tagFiles, filename, note.Frontmatter.Tags)
+		tagCount += len(note.Frontmatter.Tags)
+
+		count := CountText(note.Content)
+		total.Words += count.Words
+		total.CJKChars += count.CJKChars
+
+		created := note.Frontmatter.Created.Time
+		if i == 0 || created.Before(oldest) {
+			oldest = created
+		}
+		if i == 0 || created.After(newest) {
+			newest = created
+		}
+	}
+
+	stats.UniqueTags = len(tagFiles)
+	if stats.Notes > 0 {
+		stats.AvgTags = float64(tagCount) / float64(stats.Notes)
+		stats.oldest, stats.newest = oldest, newest
+		stats.Oldest = oldest.UTC().Format(time.RFC3339)
+		stats.Newest = newest.UTC().Format(time.RFC3339)
+	}
+	stats.Orphans = len(filterOrphans(notesList, notesList, false))
+	stats.Words = total.Words + total.CJKChars
+	stats.ReadingTime = formatReadingTime(total.ReadingMinutes(speed))
+	return stats
+}
+
+// countRelationHealth checks every declared relation, from frontmatter and
+// .meta.json alike. It returns the links to notes that don't exist, the
+// relations whose target doesn't declare one back, and the relations
+// between existing notes. Self-references and repeats are not counted.
+func countRelationHealth(notesDir string, meta *MetaFile, notesList []*Note) (dead, asymmetric, relations int) {
+	edges := make(map[[2]string]bool)
+	for _, note := range notesList {
+		filename := note.Name()
+		seen := make(map[string]bool)
+		for _, rel := range currentRelated(meta, filename, note) {
+			rel = NormalizeFilename(rel)
+			if rel == filename || seen[rel] {
+				continue
+			}
+			seen[rel] = true
+			if _, err := os.Stat(filepath.Join(notesDir, rel)); os.IsNotExist(err) {
+				dead++
+				continue
+			}
+			edges[[2]string{filename, rel}] = true
+		}
+	}
+
+	for edge := range edges {
+		if !edges[[2]string{edge[1], edge[0]}] {
+			asymmetric++
+		}
+	}
+	return dead, asymmetric, len(edges)
+}
@@ -14,20 +14,39 @@ func CmdSync(args []string) error {
 	fs := flag.NewFlagSet("sync", flag.ExitOnError)
 	dryRunFlag := fs.Bool("dry-run", false, "show what would change without writing")
 	forceFlag := fs.Bool("force", false, "rebuild entire .meta.json from scratch")
+	checkFlag := fs.Bool("check", false, "exit nonzero if .meta.json is out of date, without writing")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	notesDir, err := GetNotesDir()
+	// --check is a dry run that fails instead of reporting success
+	dryRun := *dryRunFlag || *checkFlag
+
+	getDir := GetWritableNotesDir
+	if dryRun {
+		getDir = GetNotesDir
+	}
+	notesDir, err := getDir()
 	if err != nil {
-		return fmt.Errorf("failed to get notes directory: %w", err)
+		return err
+	}
+
+	if !dryRun {
+		unlock, err := LockMeta(notesDir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		finish := beginUndo(notesDir, "sync")
+		defer finish()
 	}
 
 	// Load existing meta or create new one
 	var meta *MetaFile
 	if *forceFlag {
-		meta = &MetaFile{Files: make(map[string]*FileMeta)}
+		meta = &MetaFile{Files: make(map[string]*FileMeta), dir: notesDir}
 	} else {
 		meta, err = LoadMetaFile(notesDir)
 		if err != nil {
@@ -35,28 +54,16 @@ func CmdSync(args []string) error {
 		}
 	}
 
-	// Find all .md files
-	entries, err := os.ReadDir(notesDir)
+	notesList, err := LoadAllNotes(notesDir)
 	if err != nil {
-		return fmt.Errorf("failed to read notes directory: %w", err)
+		return err
 	}
 
-	var totalCount, updatedCount int
-
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
-			continue
-		}
-
-		totalCount++
-		filename := entry.Name()
-		notePath := filepath.Join(notesDir, filename)
+	totalCount := len(notesList)
+	var updatedCount, removedCount int
 
-		note, err := ParseNote(notePath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to parse %s: %v\n", filename, err)
-			continue
-		}
+	for _, note := range notesList {
+		filename := note.Name()
 
 		existingMeta := meta.GetFileMeta(filename)
 		newHash := note.ContentHash()
@@ -66,48 +73,70 @@ func CmdSync(args []string) error {
 
 		if len(changes) > 0 {
 			updatedCount++
-			if *dryRunFlag {
+			if dryRun {
 				fmt.Printf("Would update: %s (%s)\n", filename, strings.Join(changes, ", "))
 			} else {
 				fmt.Printf("Updated: %s (%s)\n", filename, strings.Join(changes, ", "))
 			}
 		}
 
-		if !*dryRunFlag {
+		if !dryRun {
 			// Update meta
 			if existingMeta == nil {
 				existingMeta = &FileMeta{}
 				meta.SetFileMeta(filename, existingMeta)
 			}
 
+			existingMeta.ID = note.Frontmatter.ID
 			existingMeta.ContentHash = newHash
-			existingMeta.Tags = note.Frontmatter.Tags
+			existingMeta.Tags = normalizeTagSlice(note.Frontmatter.Tags)
 			existingMeta.Summary = note.Frontmatter.Summary
 			existingMeta.Related = note.Frontmatter.Related
+			existingMeta.Pinned = note.Frontmatter.Pinned
 			// Preserve enriched_at timestamp
 		}
 	}
 
-	// Remove entries for files that no longer exist
+	// Remove entries for files that no longer exist or are now ignored
+	ignore, err := LoadIgnore(notesDir)
+	if err != nil {
+		return err
+	}
 	for filename := range meta.Files {
+		reason := ""
 		notePath := filepath.Join(notesDir, filename)
 		if _, err := os.Stat(notePath); os.IsNotExist(err) {
-			if *dryRunFlag {
-				fmt.Printf("Would remove: %s (file deleted)\n", filename)
-			} else {
-				fmt.Printf("Removed: %s (file deleted)\n", filename)
-				delete(meta.Files, filename)
-			}
+			reason = "file deleted"
+		} else if ignore.Excludes(filename) {
+			reason = "ignored by " + IgnoreFile
+		}
+		if reason == "" {
+			continue
+		}
+
+		removedCount++
+		if dryRun {
+			fmt.Printf("Would remove: %s (%s)\n", filename, reason)
+		} else {
+			fmt.Printf("Removed: %s (%s)\n", filename, reason)
+			delete(meta.Files, filename)
 		}
 	}
 
-	if !*dryRunFlag {
+	if !dryRun {
 		if err := meta.Save(notesDir); err != nil {
 			return fmt.Errorf("failed to save meta file: %w", err)
 		}
 	}
 
 	unchangedCount := totalCount - updatedCount
+	if *checkFlag {
+		if updatedCount > 0 || removedCount > 0 {
+			return fmt.Errorf(".meta.json is out of date: %d to update, %d to remove (run 'notes sync')", updatedCount, removedCount)
+		}
+		fmt.Printf(".meta.json is up to date (%d notes)\n", totalCount)
+		return nil
+	}
 	if *dryRunFlag {
 		fmt.Printf("\nDry run: would sync %d notes (%d to update, %d unchanged)\n", totalCount, updatedCount, unchangedCount)
 	} else {
@@ -124,11 +153,15 @@ func detectChanges(existing *FileMeta, note *Note, newHash string) []string {
 		return []string{"new"}
 	}
 
+	if existing.ID != note.Frontmatter.ID {
+		changes = append(changes, "id changed")
+	}
+
 	if existing.ContentHash != newHash {
 		changes = append(changes, "content changed")
 	}
 
-	if !stringSliceEqual(existing.Tags, note.Frontmatter.Tags) {
+	if !stringSliceEqual(existing.Tags, normalizeTagSlice(note.Frontmatter.Tags)) {
 		changes = append(changes, "tags changed")
 	}
 
@@ -140,6 +173,10 @@ func detectChanges(existing *FileMeta, note *Note, newHash string) []string {
 		changes = append(changes, "related changed")
 	}
 
+	if existing.Pinned != note.Frontmatter.Pinned {
+		changes = append(changes, "pinned changed")
+	}
+
 	return changes
 }
 
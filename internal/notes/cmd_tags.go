@@ -1,71 +1,469 @@
 package notes
 
 import (
+	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 )
 
+// TagOutput represents a single tag in the JSON output of notes tags
+type TagOutput struct {
+	Tag   string   `json:"tag"`
+	Count int      `json:"count"`
+	Files []string `json:"files,omitempty"`
+}
+
 // CmdTags implements the 'notes tags' command
-// Lists all tags with counts
+// Lists all tags with counts, or merges tags with --merge
 func CmdTags(args []string) error {
+	fs := flag.NewFlagSet("tags", flag.ExitOnError)
+	fromFlag := fs.String("from", "files", "where to read tags from (files or meta)")
+	jsonFlag := fs.Bool("json", false, "output as JSON")
+	withFilesFlag := fs.Bool("with-files", false, "include the files carrying each tag (with --json)")
+	cooccurFlag := fs.String("cooccur", "", "list the tags appearing on the same notes as this tag, with how often")
+	mergeFlag := fs.String("merge", "", "tags to merge (comma-separated, or further arguments)")
+	intoFlag := fs.String("into", "", "tag that --merge replaces the others with")
+	dryRunFlag := fs.Bool("dry-run", false, "with --merge, show what would change without changing notes")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if *mergeFlag != "" || *intoFlag != "" {
+		sources := append(parseCSV(*mergeFlag), positional...)
+		if len(sources) == 0 || strings.TrimSpace(*intoFlag) == "" {
+			return fmt.Errorf("usage: notes tags --merge <tag,tag,...> --into <tag> [--dry-run]")
+		}
+		return mergeTags(sources, strings.TrimSpace(*intoFlag), *dryRunFlag)
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(positional, " "))
+	}
+
 	notesDir, err := GetNotesDir()
 	if err != nil {
 		return fmt.Errorf("failed to get notes directory: %w", err)
 	}
 
-	// Collect tags from all notes
-	tagCounts := make(map[string]int)
+	var tagFiles map[string][]string
+	switch *fromFlag {
+	case "files":
+		tagFiles, err = collectTagsFromFiles(notesDir)
+		if err != nil {
+			return err
+		}
+	case "meta":
+		meta, err := LoadMetaFile(notesDir)
+		if err != nil {
+			return fmt.Errorf("failed to load meta file: %w", err)
+		}
+		if stale, err := meta.IsStale(notesDir); err != nil {
+			return fmt.Errorf("failed to check meta file: %w", err)
+		} else if stale {
+			fmt.Fprintln(os.Stderr, "Warning: .meta.json is out of date, run 'notes sync' for accurate counts")
+		}
+		tagFiles = collectTagsFromMeta(meta)
+	default:
+		return fmt.Errorf("invalid --from value: %s (expected files or meta)", *fromFlag)
+	}
 
-	entries, err := os.ReadDir(notesDir)
+	if *cooccurFlag != "" {
+		tag := strings.ToLower(strings.TrimSpace(*cooccurFlag))
+		if len(tagFiles[tag]) == 0 {
+			return fmt.Errorf("no notes are tagged %s", tag)
+		}
+		tagFiles = cooccurringTags(tagFiles, tag)
+	}
+
+	// Sort by count (descending), then alphabetically
+	tags := make([]TagOutput, 0, len(tagFiles))
+	for tag, files := range tagFiles {
+		sort.Strings(files)
+		tags = append(tags, TagOutput{Tag: tag, Count: len(files), Files: files})
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	if *jsonFlag {
+		if !*withFilesFlag {
+			for i := range tags {
+				tags[i].Files = nil
+			}
+		}
+		return outputJSON(tags, false)
+	}
+
+	if len(tags) == 0 {
+		fmt.Println("No tags found")
+		return nil
+	}
+
+	for _, tc := range tags {
+		fmt.Printf("%s (%d)\n", tc.Tag, tc.Count)
+	}
+
+	return nil
+}
+
+// collectTagsFromFiles maps each lowercased tag to the notes carrying it by
+// parsing every note file. Counts are the length of each file list.
+func collectTagsFromFiles(notesDir string) (map[string][]string, error) {
+	tagFiles := make(map[string][]string)
+
+	notesList, err := LoadAllNotes(notesDir)
 	if err != nil {
-		return fmt.Errorf("failed to read notes directory: %w", err)
+		return nil, err
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
+	for _, note := range notesList {
+		addNoteTags(tagFiles, note.Name(), note.Frontmatter.Tags)
+	}
+
+	return tagFiles, nil
+}
+
+// collectTagsFromMeta maps each lowercased tag to the notes carrying it using
+// .meta.json, without reading any notes
+func collectTagsFromMeta(meta *MetaFile) map[string][]string {
+	tagFiles := make(map[string][]string)
+	for filename, fileMeta := range meta.Files {
+		addNoteTags(tagFiles, filename, fileMeta.Tags)
+	}
+	return tagFiles
+}
+
+// cooccurringTags maps every other tag to the notes it shares with tag
+func cooccurringTags(tagFiles map[string][]string, tag string) map[string][]string {
+	shared := make(map[string][]string)
+	for other, files := range tagFiles {
+		if other == tag {
 			continue
 		}
+		for _, file := range files {
+			if Contains(tagFiles[tag], file) {
+				shared[other] = append(shared[other], file)
+			}
+		}
+	}
+	return shared
+}
+
+// addNoteTags records filename under each of its tags, once per tag
+func addNoteTags(tagFiles map[string][]string, filename string, tags []string) {
+	for _, tag := range tags {
+		tag = strings.ToLower(tag)
+		if !Contains(tagFiles[tag], filename) {
+			tagFiles[tag] = append(tagFiles[tag], filename)
+		}
+	}
+}
+
+// CmdPruneTags implements the 'notes prune-tags --min N' command
+// Removes tags used by fewer than N notes from frontmatter and .meta.json
+func CmdPruneTags(args []string) error {
+	fs := flag.NewFlagSet("prune-tags", flag.ExitOnError)
+	minFlag := fs.Int("min", 2, "keep tags used by at least this many notes")
+	dryRunFlag := fs.Bool("dry-run", false, "show what would be pruned without changing notes")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *minFlag < 1 {
+		return fmt.Errorf("--min must be at least 1")
+	}
 
-		notePath := filepath.Join(notesDir, entry.Name())
-		note, err := ParseNote(notePath)
+	var notesDir string
+	var err error
+	if *dryRunFlag {
+		notesDir, err = GetNotesDir()
 		if err != nil {
-			continue
+			return fmt.Errorf("failed to get notes directory: %w", err)
+		}
+	} else {
+		notesDir, err = GetWritableNotesDir()
+		if err != nil {
+			return err
 		}
+	}
 
-		for _, tag := range note.Frontmatter.Tags {
-			tagCounts[strings.ToLower(tag)]++
+	// Notes are loaded under the lock, so edits made meanwhile aren't
+	// overwritten by stale copies
+	if !*dryRunFlag {
+		unlock, err := LockMeta(notesDir)
+		if err != nil {
+			return err
 		}
+		defer unlock()
+
+		finish := beginUndo(notesDir, "prune-tags")
+		defer finish()
 	}
 
-	if len(tagCounts) == 0 {
-		fmt.Println("No tags found")
+	notesList, err := LoadAllNotes(notesDir)
+	if err != nil {
+		return err
+	}
+
+	tagFiles := make(map[string][]string)
+	for _, note := range notesList {
+		addNoteTags(tagFiles, note.Name(), note.Frontmatter.Tags)
+	}
+
+	var pruned []string
+	for tag, files := range tagFiles {
+		if len(files) < *minFlag {
+			pruned = append(pruned, tag)
+		}
+	}
+	sort.Strings(pruned)
+
+	if len(pruned) == 0 {
+		fmt.Printf("No tags used by fewer than %d notes\n", *minFlag)
 		return nil
 	}
 
-	// Sort by count (descending), then alphabetically
-	type tagCount struct {
-		tag   string
-		count int
+	for _, tag := range pruned {
+		fmt.Printf("%s (%d)\n", tag, len(tagFiles[tag]))
 	}
 
-	var tags []tagCount
-	for tag, count := range tagCounts {
-		tags = append(tags, tagCount{tag, count})
+	var meta *MetaFile
+	if !*dryRunFlag {
+		meta, err = LoadMetaFile(notesDir)
+		if err != nil {
+			return fmt.Errorf("failed to load meta file: %w", err)
+		}
 	}
 
-	sort.Slice(tags, func(i, j int) bool {
-		if tags[i].count != tags[j].count {
-			return tags[i].count > tags[j].count
+	affected := 0
+	for _, note := range notesList {
+		kept := removeTags(note.Frontmatter.Tags, pruned)
+		if len(kept) == len(note.Frontmatter.Tags) {
+			continue
+		}
+		affected++
+		if *dryRunFlag {
+			continue
 		}
-		return tags[i].tag < tags[j].tag
-	})
 
-	for _, tc := range tags {
-		fmt.Printf("%s (%d)\n", tc.tag, tc.count)
+		filename := note.Name()
+		note.Frontmatter.Tags = kept
+		if err := note.Save(note.Filename); err != nil {
+			return fmt.Errorf("failed to update %s: %w", filename, err)
+		}
+		if fileMeta := meta.GetFileMeta(filename); fileMeta != nil {
+			fileMeta.Tags = removeTags(fileMeta.Tags, pruned)
+		}
+	}
+
+	if *dryRunFlag {
+		fmt.Printf("\nDry run: would prune %d tags from %d notes\n", len(pruned), affected)
+		return nil
 	}
 
+	if err := meta.Save(notesDir); err != nil {
+		return fmt.Errorf("failed to save meta file: %w", err)
+	}
+
+	fmt.Printf("\nPruned %d tags from %d notes\n", len(pruned), affected)
 	return nil
 }
+
+// mergeTags replaces the source tags with into and reports the result
+func mergeTags(sources []string, into string, dryRun bool) error {
+	affected, err := replaceTags(sources, into, dryRun)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Printf("\nDry run: would merge into %s in %d notes\n", into, affected)
+	} else {
+		fmt.Printf("\nMerged into %s in %d notes\n", into, affected)
+	}
+	return nil
+}
+
+// CmdTag implements the 'notes tag <rename|merge>' command
+// Groups operations on individual tags
+func CmdTag(args []string) error {
+	const usage = "usage: notes tag <rename <old> <new>|merge <tag>... --into <tag>>"
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+
+	switch args[0] {
+	case "rename":
+		return CmdTagRename(args[1:])
+	case "merge":
+		return CmdTagMerge(args[1:])
+	default:
+		return fmt.Errorf("unknown tag command: %s (%s)", args[0], usage)
+	}
+}
+
+// CmdTagRename implements the 'notes tag rename <old> <new>' command
+// Renames a tag in every note, matching it case-insensitively
+func CmdTagRename(args []string) error {
+	fs := flag.NewFlagSet("tag rename", flag.ExitOnError)
+	dryRunFlag := fs.Bool("dry-run", false, "show the affected notes without changing them")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 2 || strings.TrimSpace(positional[0]) == "" || strings.TrimSpace(positional[1]) == "" {
+		return fmt.Errorf("usage: notes tag rename <old> <new> [--dry-run]")
+	}
+	oldTag, newTag := strings.TrimSpace(positional[0]), strings.TrimSpace(positional[1])
+
+	affected, err := replaceTags([]string{oldTag}, newTag, *dryRunFlag)
+	if err != nil {
+		return err
+	}
+	if *dryRunFlag {
+		fmt.Printf("\nDry run: would rename %s to %s in %d notes\n", oldTag, newTag, affected)
+	} else {
+		fmt.Printf("\nRenamed %s to %s in %d notes\n", oldTag, newTag, affected)
+	}
+	return nil
+}
+
+// CmdTagMerge implements the 'notes tag merge <tag>... --into <tag>' command
+// Replaces several tags with one; the same as 'notes tags --merge'
+func CmdTagMerge(args []string) error {
+	fs := flag.NewFlagSet("tag merge", flag.ExitOnError)
+	intoFlag := fs.String("into", "", "tag to replace the others with")
+	dryRunFlag := fs.Bool("dry-run", false, "show the affected notes without changing them")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var sources []string
+	for _, arg := range positional {
+		sources = append(sources, parseCSV(arg)...)
+	}
+	into := strings.TrimSpace(*intoFlag)
+	if len(sources) == 0 || into == "" {
+		return fmt.Errorf("usage: notes tag merge <tag>... --into <tag> [--dry-run]")
+	}
+
+	return mergeTags(sources, into, *dryRunFlag)
+}
+
+// replaceTags replaces the source tags with into in every note carrying one
+// of them, in frontmatter and .meta.json, printing each change. Returns the
+// number of notes changed.
+func replaceTags(sources []string, into string, dryRun bool) (int, error) {
+	getDir := GetWritableNotesDir
+	if dryRun {
+		getDir = GetNotesDir
+	}
+	notesDir, err := getDir()
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range sources {
+		sources[i] = strings.ToLower(sources[i])
+	}
+
+	notesList, err := LoadAllNotes(notesDir)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(notesList, func(i, j int) bool {
+		return notesList[i].Filename < notesList[j].Filename
+	})
+
+	var meta *MetaFile
+	if !dryRun {
+		unlock, err := LockMeta(notesDir)
+		if err != nil {
+			return 0, err
+		}
+		defer unlock()
+
+		finish := beginUndo(notesDir, "tags")
+		defer finish()
+
+		meta, err = LoadMetaFile(notesDir)
+		if err != nil {
+			return 0, fmt.Errorf("failed to load meta file: %w", err)
+		}
+	}
+
+	affected := 0
+	for _, note := range notesList {
+		merged, changed := mergeTagList(note.Frontmatter.Tags, sources, into)
+		if !changed {
+			continue
+		}
+		affected++
+
+		filename := note.Name()
+		fmt.Printf("%s: %s -> %s\n", filename, strings.Join(note.Frontmatter.Tags, ", "), strings.Join(merged, ", "))
+		if dryRun {
+			continue
+		}
+
+		note.Frontmatter.Tags = merged
+		if err := note.Save(note.Filename); err != nil {
+			return 0, fmt.Errorf("failed to update %s: %w", filename, err)
+		}
+		if fileMeta := meta.GetFileMeta(filename); fileMeta != nil {
+			merged, _ := mergeTagList(fileMeta.Tags, sources, into)
+			fileMeta.Tags = normalizeTagSlice(merged)
+		}
+	}
+
+	if !dryRun && affected > 0 {
+		if err := meta.Save(notesDir); err != nil {
+			return 0, fmt.Errorf("failed to save meta file: %w", err)
+		}
+	}
+	return affected, nil
+}
+
+// mergeTagList replaces the (lowercased) source tags in tags with into,
+// keeping the position of the first one and dropping duplicates. Reports
+// whether anything changed.
+func mergeTagList(tags, sources []string, into string) ([]string, bool) {
+	merged := make([]string, 0, len(tags))
+	changed, seen := false, false
+	for _, tag := range tags {
+		if Contains(sources, strings.ToLower(tag)) {
+			changed = changed || tag != into
+			tag = into
+		}
+		if strings.EqualFold(tag, into) {
+			if seen {
+				changed = true
+				continue
+			}
+			seen = true
+		}
+		merged = append(merged, tag)
+	}
+	return merged, changed
+}
+
+// removeTags returns tags without any of the (lowercased) tags in drop
+func removeTags(tags, drop []string) []string {
+	kept := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if !Contains(drop, strings.ToLower(tag)) {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
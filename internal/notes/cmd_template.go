@@ -0,0 +1,153 @@
+package notes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CmdTemplate implements the 'notes template <list|show|new|apply>' command
+// Manages note templates in .templates/
+func CmdTemplate(args []string) error {
+	const usage = "usage: notes template <list|show <name>|new <name>|apply <name> <note>>"
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+
+	switch args[0] {
+	case "list":
+		return listTemplates()
+	case "show":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: notes template show <name>")
+		}
+		return showTemplate(args[1])
+	case "new":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: notes template new <name>")
+		}
+		return newTemplate(args[1])
+	case "apply":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: notes template apply <name> <note>")
+		}
+		return applyTemplateToNote(args[1], args[2])
+	default:
+		return fmt.Errorf("unknown template command: %s (%s)", args[0], usage)
+	}
+}
+
+func listTemplates() error {
+	notesDir, err := GetNotesDir()
+	if err != nil {
+		return fmt.Errorf("failed to get notes directory: %w", err)
+	}
+
+	names, err := ListTemplates(notesDir)
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		fmt.Println("No templates found")
+		return nil
+	}
+
+	for _, name := range names {
+		if name == "default" {
+			fmt.Printf("%s (used by notes new)\n", name)
+		} else {
+			fmt.Println(name)
+		}
+	}
+	return nil
+}
+
+func showTemplate(name string) error {
+	notesDir, err := GetNotesDir()
+	if err != nil {
+		return fmt.Errorf("failed to get notes directory: %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(TemplatesDir(notesDir), NormalizeFilename(name)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("template not found: %s", name)
+		}
+		return err
+	}
+
+	fmt.Print(string(data))
+	return nil
+}
+
+func newTemplate(name string) error {
+	notesDir, err := GetWritableNotesDir()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(TemplatesDir(notesDir), NormalizeFilename(name))
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("template already exists: %s", name)
+	}
+
+	if err := editTemplate(notesDir, name); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created template %s\n", path)
+	return nil
+}
+
+// applyTemplateToNote appends a template's body to an existing note. The
+// note's frontmatter is left alone; the cursor marker is dropped.
+func applyTemplateToNote(name, noteName string) error {
+	notesDir, err := GetWritableNotesDir()
+	if err != nil {
+		return err
+	}
+
+	finish := beginUndo(notesDir, "template")
+	defer finish()
+
+	tmpl, err := LoadTemplate(notesDir, name)
+	if err != nil {
+		return err
+	}
+
+	filename, err := ResolveNote(notesDir, noteName)
+	if err != nil {
+		return err
+	}
+	notePath := filepath.Join(notesDir, filename)
+
+	note, err := ParseNote(notePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse note: %w", err)
+	}
+
+	body, _ := stripCursor(strings.TrimLeft(tmpl.Content, "\n"))
+	if strings.TrimSpace(body) == "" {
+		return fmt.Errorf("template %s has no body", name)
+	}
+
+	content := strings.TrimRight(note.Content, "\n")
+	if strings.TrimSpace(content) != "" {
+		content += "\n\n"
+	} else if note.HasFrontmatter {
+		content = "\n"
+	}
+	note.Content = content + body
+	if !strings.HasSuffix(note.Content, "\n") {
+		note.Content += "\n"
+	}
+
+	if err := note.SaveKeepingFormat(notePath); err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+
+	fmt.Printf("Applied template %s to %s\n", name, filename)
+	return nil
+}
@@ -0,0 +1,72 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CmdToday implements the 'notes today [content]' command
+// Appends content as a timestamped bullet to today's note, or opens the note
+// in $EDITOR without content. The note is created on first use.
+func CmdToday(args []string) error {
+	fs := flag.NewFlagSet("today", flag.ExitOnError)
+	noFrontmatterFlag := fs.Bool("no-frontmatter", false, "create today's note without YAML frontmatter")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	notesDir, err := EnsureNotesDir()
+	if err != nil {
+		return fmt.Errorf("failed to ensure notes directory: %w", err)
+	}
+
+	finish := beginUndo(notesDir, "today")
+	defer finish()
+
+	now := time.Now()
+	notePath := filepath.Join(notesDir, dailyFilename(now))
+	note, err := loadDailyNote(notePath, now, *noFrontmatterFlag)
+	if err != nil {
+		return err
+	}
+
+	text := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if text == "" {
+		if err := note.SaveKeepingFormat(notePath); err != nil {
+			return fmt.Errorf("failed to save note: %w", err)
+		}
+		if err := runEditor(notePath, 0); err != nil {
+			return fmt.Errorf("editor failed: %w", err)
+		}
+		return nil
+	}
+
+	note.Content = appendBullet(note.Content, dailyBullet(now, text))
+	if err := note.SaveKeepingFormat(notePath); err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+
+	fmt.Printf("Appended to %s\n", notePath)
+	return nil
+}
+
+// dailyBullet formats text as a "- HH:MM text" list item, indenting any
+// further lines so they stay part of it
+func dailyBullet(now time.Time, text string) string {
+	return "- " + now.Format("15:04") + " " + strings.ReplaceAll(text, "\n", "\n  ")
+}
+
+// appendBullet adds a list item to content, continuing the list if the
+// content ends with one and starting a new paragraph otherwise
+func appendBullet(content, bullet string) string {
+	content = strings.TrimRight(content, "\n")
+	lastLine := content[strings.LastIndex(content, "\n")+1:]
+	if strings.HasPrefix(lastLine, "- ") || strings.HasPrefix(lastLine, "  ") {
+		return content + "\n" + bullet + "\n"
+	}
+	return content + "\n\n" + bullet + "\n"
+}
@@ -0,0 +1,79 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// CmdUndo implements the 'notes undo' command
+// Restores the files as they were before the last command that changed notes
+func CmdUndo(args []string) error {
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	listFlag := fs.Bool("list", false, "list the operations that can be undone, newest first")
+	dryRunFlag := fs.Bool("dry-run", false, "show what would be restored without changing anything")
+	forceFlag := fs.Bool("force", false, "undo even if the files were changed since")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: notes undo [--list] [--dry-run] [--force]")
+	}
+
+	getDir := GetWritableNotesDir
+	if *listFlag || *dryRunFlag {
+		getDir = GetNotesDir
+	}
+	notesDir, err := getDir()
+	if err != nil {
+		return err
+	}
+
+	if !*listFlag && !*dryRunFlag {
+		// .meta.json is among the restored files
+		unlock, err := LockMeta(notesDir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	snaps, err := loadSnapshots(notesDir)
+	if err != nil {
+		return err
+	}
+
+	if *listFlag {
+		for _, snap := range snaps {
+			fmt.Printf("%s  %-10s  %s\n", snap.Created.Local().Format("2006-01-02 15:04:05"), snap.Command, strings.Join(snap.fileNames(), ", "))
+		}
+		return nil
+	}
+
+	if len(snaps) == 0 {
+		return fmt.Errorf("nothing to undo")
+	}
+	last := snaps[0]
+	when := last.Created.Local().Format("2006-01-02 15:04:05")
+
+	// Restoring files changed since would silently throw those changes away
+	changed, err := last.changed(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to check for later changes: %w", err)
+	}
+	if len(changed) > 0 && !*forceFlag {
+		return fmt.Errorf("cannot undo %s from %s: %s changed since (use --force to undo anyway)", last.Command, when, strings.Join(changed, ", "))
+	}
+
+	if *dryRunFlag {
+		fmt.Printf("Would undo %s from %s, restoring %s\n", last.Command, when, strings.Join(last.fileNames(), ", "))
+		return nil
+	}
+
+	if err := last.restore(notesDir); err != nil {
+		return err
+	}
+	fmt.Printf("Undid %s from %s, restored %s\n", last.Command, when, strings.Join(last.fileNames(), ", "))
+	return nil
+}
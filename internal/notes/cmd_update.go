@@ -1,8 +1,10 @@
 package notes
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -37,17 +39,92 @@ func CmdUpdate(args []string) error {
 	tagsFlag := fs.String("tags", "", "tags (comma-separated)")
 	summaryFlag := fs.String("summary", "", "summary")
 	relatedFlag := fs.String("related", "", "related files (comma-separated)")
+	fromStdinFlag := fs.Bool("from-stdin", false, "read tags=, summary= and related= lines from stdin")
 
 	if err := fs.Parse(flagArgs); err != nil {
 		return err
 	}
 
-	notesDir, err := GetNotesDir()
+	// Explicitly empty tags or related clear them, while leaving them out
+	// keeps the current ones
+	tagsSet, relatedSet := isFlagSet(fs, "tags"), isFlagSet(fs, "related")
+	if *fromStdinFlag {
+		fields, err := readUpdateFields(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read stdin: %w", err)
+		}
+		if v, ok := fields["tags"]; ok {
+			*tagsFlag = v
+			tagsSet = true
+		}
+		if v, ok := fields["summary"]; ok {
+			*summaryFlag = v
+		}
+		if v, ok := fields["related"]; ok {
+			*relatedFlag = v
+			relatedSet = true
+		}
+	}
+
+	notesDir, err := GetWritableNotesDir()
+	if err != nil {
+		return err
+	}
+
+	var update NoteUpdate
+	if tagsSet {
+		update.Tags = parseCSV(*tagsFlag)
+	}
+	update.Summary = *summaryFlag
+	if relatedSet {
+		update.Related = parseCSV(*relatedFlag)
+	}
+
+	// Hold the lock until the meta file is saved, so concurrent commands
+	// don't overwrite each other's changes
+	unlock, err := LockMeta(notesDir)
 	if err != nil {
-		return fmt.Errorf("failed to get notes directory: %w", err)
+		return err
 	}
+	defer unlock()
 
-	filename = NormalizeFilename(filename)
+	// Load meta file
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	filename, err = ResolveFilename(notesDir, filename)
+	if err != nil {
+		return err
+	}
+
+	finish := beginUndo(notesDir, "update")
+	defer finish()
+	if err := ApplyUpdate(notesDir, meta, filename, update); err != nil {
+		return err
+	}
+
+	// Save meta file
+	if err := meta.Save(notesDir); err != nil {
+		return fmt.Errorf("failed to save meta file: %w", err)
+	}
+
+	fmt.Printf("Updated %s\n", filename)
+	return nil
+}
+
+// NoteUpdate describes metadata changes for a note. Nil tags/related and an
+// empty summary leave the current value unchanged.
+type NoteUpdate struct {
+	Tags    []string `json:"tags"`
+	Summary string   `json:"summary"`
+	Related []string `json:"related"`
+}
+
+// ApplyUpdate updates a note's frontmatter and its .meta.json entry, marks
+// it as enriched and keeps relations bidirectional. The caller saves meta.
+func ApplyUpdate(notesDir string, meta *MetaFile, filename string, update NoteUpdate) error {
 	notePath := filepath.Join(notesDir, filename)
 
 	// Check if file exists
@@ -61,12 +138,6 @@ func CmdUpdate(args []string) error {
 		return fmt.Errorf("failed to parse note: %w", err)
 	}
 
-	// Load meta file
-	meta, err := LoadMetaFile(notesDir)
-	if err != nil {
-		return fmt.Errorf("failed to load meta file: %w", err)
-	}
-
 	// Get previous related for bidirectional update
 	var prevRelated []string
 	if fileMeta := meta.GetFileMeta(filename); fileMeta != nil {
@@ -76,23 +147,22 @@ func CmdUpdate(args []string) error {
 	}
 
 	// Update tags if provided
-	if *tagsFlag != "" {
-		tags := parseCSV(*tagsFlag)
-		note.Frontmatter.Tags = tags
+	if update.Tags != nil {
+		note.Frontmatter.Tags = normalizeTagSlice(update.Tags)
 	}
 
 	// Update summary if provided
-	if *summaryFlag != "" {
-		note.Frontmatter.Summary = *summaryFlag
+	if update.Summary != "" {
+		note.Frontmatter.Summary = update.Summary
 	}
 
 	// Update related if provided
 	var newRelated []string
-	if *relatedFlag != "" {
-		newRelated = parseCSV(*relatedFlag)
+	if update.Related != nil {
+		newRelated = make([]string, len(update.Related))
 		// Normalize filenames
-		for i := range newRelated {
-			newRelated[i] = NormalizeFilename(newRelated[i])
+		for i := range update.Related {
+			newRelated[i] = NormalizeFilename(update.Related[i])
 		}
 		note.Frontmatter.Related = newRelated
 	}
@@ -109,14 +179,16 @@ func CmdUpdate(args []string) error {
 		meta.SetFileMeta(filename, fileMeta)
 	}
 
+	fileMeta.ID = note.Frontmatter.ID
 	fileMeta.ContentHash = note.ContentHash()
 	fileMeta.EnrichedAt = time.Now()
-	fileMeta.Tags = note.Frontmatter.Tags
+	fileMeta.Tags = normalizeTagSlice(note.Frontmatter.Tags)
 	fileMeta.Summary = note.Frontmatter.Summary
 	fileMeta.Related = note.Frontmatter.Related
+	fileMeta.Pinned = note.Frontmatter.Pinned
 
 	// Handle bidirectional relations
-	if *relatedFlag != "" {
+	if update.Related != nil {
 		// Remove old relations that are no longer present
 		for _, oldRel := range prevRelated {
 			if !Contains(newRelated, oldRel) {
@@ -144,7 +216,7 @@ func CmdUpdate(args []string) error {
 					relPath := filepath.Join(notesDir, newRel)
 					if _, err := os.Stat(relPath); err == nil {
 						if relNote, err := ParseNote(relPath); err == nil {
-							if !Contains(relNote.Frontmatter.Related, filename) {
+							if relNote.HasFrontmatter && !Contains(relNote.Frontmatter.Related, filename) {
 								relNote.Frontmatter.Related = append(relNote.Frontmatter.Related, filename)
 								relNote.Save(relPath)
 							}
@@ -155,13 +227,42 @@ func CmdUpdate(args []string) error {
 		}
 	}
 
-	// Save meta file
-	if err := meta.Save(notesDir); err != nil {
-		return fmt.Errorf("failed to save meta file: %w", err)
+	return nil
+}
+
+// readUpdateFields parses key=value lines for update --from-stdin.
+// Blank lines and lines starting with # are ignored.
+func readUpdateFields(r io.Reader) (map[string]string, error) {
+	fields := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key=value", lineNum)
+		}
+
+		key = strings.TrimSpace(key)
+		switch key {
+		case "tags", "summary", "related":
+			fields[key] = strings.TrimSpace(value)
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %q (expected tags, summary or related)", lineNum, key)
+		}
 	}
 
-	fmt.Printf("Updated %s\n", filename)
-	return nil
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
 }
 
 func parseCSV(s string) []string {
@@ -179,12 +280,18 @@ func parseCSV(s string) []string {
 	return result
 }
 
+// updateRelatedInFile rewrites the related list in a note's frontmatter.
+// Plain notes without frontmatter are left untouched so they only gain
+// frontmatter when enriched directly; the relation is kept in .meta.json.
 func updateRelatedInFile(notesDir, filename string, related []string) error {
 	notePath := filepath.Join(notesDir, filename)
 	note, err := ParseNote(notePath)
 	if err != nil {
 		return err
 	}
+	if !note.HasFrontmatter {
+		return nil
+	}
 	note.Frontmatter.Related = related
 	return note.Save(notePath)
 }
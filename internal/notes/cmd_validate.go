@@ -0,0 +1,200 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// CmdValidate implements the 'notes validate' command
+// Checks the notebook for broken frontmatter, broken or one-sided relations,
+// missing created dates and out-of-date .meta.json entries. Fails if any
+// problem remains, so it can run as a pre-commit hook.
+func CmdValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fixFlag := fs.Bool("fix", false, "repair what can be repaired safely: missing created dates, dead and one-sided relations, entries for missing files")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	getDir := GetNotesDir
+	if *fixFlag {
+		getDir = GetWritableNotesDir
+	}
+	notesDir, err := getDir()
+	if err != nil {
+		return err
+	}
+
+	if *fixFlag {
+		unlock, err := LockMeta(notesDir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		finish := beginUndo(notesDir, "validate")
+		defer finish()
+	}
+
+	notesList, skipped, err := scanNotesDir(notesDir)
+	if err != nil {
+		return err
+	}
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	v := &validation{fix: *fixFlag}
+
+	// Notes that failed to parse can't be checked any further
+	for _, s := range skipped {
+		if s.Err != nil {
+			v.report(false, "Invalid frontmatter: %s: %v", s.Name, s.Err)
+		}
+	}
+
+	notes := make(map[string]*Note, len(notesList))
+	var names []string
+	for _, note := range notesList {
+		notes[note.Name()] = note
+		names = append(names, note.Name())
+	}
+	sort.Strings(names)
+	dirty := make(map[string]bool)
+
+	for _, name := range names {
+		note := notes[name]
+		if !note.HasFrontmatter || !note.Frontmatter.Created.IsZero() {
+			continue
+		}
+		if v.report(true, "Missing created date: %s", name) {
+			info, err := os.Stat(note.Filename)
+			if err != nil {
+				return fmt.Errorf("failed to stat %s: %w", name, err)
+			}
+			note.Frontmatter.Created = NoteTime{info.ModTime()}
+			dirty[name] = true
+		}
+	}
+
+	for _, name := range names {
+		note := notes[name]
+		for _, rel := range append([]string(nil), note.Frontmatter.Related...) {
+			target := NormalizeFilename(rel)
+			if target == name {
+				continue
+			}
+
+			other, exists := notes[target]
+			if !exists {
+				if _, err := os.Stat(filepath.Join(notesDir, target)); err == nil {
+					// Exists but failed to parse, reported above
+					continue
+				}
+				if v.report(true, "Dead relation: %s -> %s", name, rel) {
+					note.Frontmatter.Related = RemoveString(note.Frontmatter.Related, rel)
+					if fileMeta := meta.GetFileMeta(name); fileMeta != nil {
+						fileMeta.Related = RemoveString(fileMeta.Related, rel)
+					}
+					dirty[name] = true
+				}
+				continue
+			}
+
+			if relatesTo(other.Frontmatter.Related, name) {
+				continue
+			}
+			// Plain notes have nowhere to store the reverse relation
+			if v.report(other.HasFrontmatter, "One-sided relation: %s -> %s", name, target) {
+				other.Frontmatter.Related = append(other.Frontmatter.Related, name)
+				if fileMeta := meta.GetFileMeta(target); fileMeta != nil && !Contains(fileMeta.Related, name) {
+					fileMeta.Related = append(fileMeta.Related, name)
+				}
+				dirty[target] = true
+			}
+		}
+	}
+
+	var entries []string
+	for filename := range meta.Files {
+		entries = append(entries, filename)
+	}
+	sort.Strings(entries)
+	metaChanged := false
+	for _, filename := range entries {
+		note, exists := notes[filename]
+		if !exists {
+			if _, err := os.Stat(filepath.Join(notesDir, filename)); err == nil {
+				continue
+			}
+			if v.report(true, "Entry for missing file: %s", filename) {
+				delete(meta.Files, filename)
+				metaChanged = true
+			}
+			continue
+		}
+
+		// Only enrichment can bring the entry up to date
+		if recorded := meta.Files[filename].ContentHash; recorded != note.ContentHash() {
+			v.report(false, "Stale content hash: %s (run 'notes enrich')", filename)
+		}
+	}
+
+	for _, name := range names {
+		if dirty[name] {
+			if err := notes[name].Save(notes[name].Filename); err != nil {
+				return fmt.Errorf("failed to save %s: %w", name, err)
+			}
+			metaChanged = true
+		}
+	}
+	if metaChanged {
+		if err := meta.Save(notesDir); err != nil {
+			return fmt.Errorf("failed to save meta file: %w", err)
+		}
+	}
+
+	if v.fixed > 0 {
+		fmt.Printf("\nFixed %d problems\n", v.fixed)
+	}
+	if v.remaining > 0 {
+		if !*fixFlag && v.fixable > 0 {
+			fmt.Printf("\n%d can be fixed with --fix\n", v.fixable)
+		}
+		return fmt.Errorf("%d problems found", v.remaining)
+	}
+	if v.fixed == 0 {
+		fmt.Printf("No problems in %d notes\n", len(names))
+	}
+	return nil
+}
+
+// validation counts the problems found by CmdValidate
+type validation struct {
+	fix       bool
+	fixed     int
+	fixable   int // Unfixed problems --fix would repair
+	remaining int
+}
+
+// report prints a problem and reports whether the caller should fix it
+func (v *validation) report(fixable bool, format string, args ...interface{}) bool {
+	problem := fmt.Sprintf(format, args...)
+	if fixable && v.fix {
+		fmt.Printf("%s (fixed)\n", problem)
+		v.fixed++
+		return true
+	}
+
+	fmt.Println(problem)
+	v.remaining++
+	if fixable {
+		v.fixable++
+	}
+	return false
+}
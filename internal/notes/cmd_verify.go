@@ -0,0 +1,63 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// CmdVerifyHashes implements the 'notes verify-hashes' command
+// Checks every .meta.json entry against its note without changing anything
+func CmdVerifyHashes(args []string) error {
+	fs := flag.NewFlagSet("verify-hashes", flag.ExitOnError)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	notesDir, err := GetNotesDir()
+	if err != nil {
+		return fmt.Errorf("failed to get notes directory: %w", err)
+	}
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	var filenames []string
+	for filename := range meta.Files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	failed := 0
+	for _, filename := range filenames {
+		note, err := ParseNote(filepath.Join(notesDir, filename))
+		if os.IsNotExist(err) {
+			fmt.Printf("Missing: %s\n", filename)
+			failed++
+			continue
+		}
+		if err != nil {
+			fmt.Printf("Unreadable: %s: %v\n", filename, err)
+			failed++
+			continue
+		}
+
+		recorded := meta.Files[filename].ContentHash
+		if actual := note.ContentHash(); actual != recorded {
+			fmt.Printf("Changed: %s (recorded %s, file %s)\n", filename, recorded, actual)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d entries failed verification", failed, len(filenames))
+	}
+
+	fmt.Printf("All %d hashes match\n", len(filenames))
+	return nil
+}
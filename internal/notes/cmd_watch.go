@@ -0,0 +1,219 @@
+package notes
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// CmdWatch implements the 'notes watch' command
+// Polls the notes directory and reports created or changed notes. With
+// --enrich, each batch of changes produces an enrichment prompt.
+func CmdWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	intervalFlag := fs.Duration("interval", time.Second, "how often to check for changes")
+	debounceFlag := fs.Duration("debounce", 2*time.Second, "quiet time before a batch of changes is handled")
+	enrichFlag := fs.Bool("enrich", false, "generate an enrichment prompt for changed notes")
+	promptFileFlag := fs.String("prompt-file", "", "write the prompt to this file instead of stdout (with --enrich)")
+	applyFlag := fs.String("apply", "", "pipe the prompt into this command and apply its JSON output (with --enrich)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !*enrichFlag && (*promptFileFlag != "" || *applyFlag != "") {
+		return fmt.Errorf("--prompt-file and --apply require --enrich")
+	}
+	if *promptFileFlag != "" && *applyFlag != "" {
+		return fmt.Errorf("use either --prompt-file or --apply, not both")
+	}
+
+	var notesDir string
+	var err error
+	if *applyFlag != "" {
+		notesDir, err = GetWritableNotesDir()
+		if err != nil {
+			return err
+		}
+	} else {
+		notesDir, err = GetNotesDir()
+		if err != nil {
+			return fmt.Errorf("failed to get notes directory: %w", err)
+		}
+	}
+
+	onBatch := func(filenames []string) error {
+		for _, filename := range filenames {
+			fmt.Printf("Changed: %s\n", filename)
+		}
+		return nil
+	}
+	if *enrichFlag {
+		onBatch = func(filenames []string) error {
+			return enrichChanged(notesDir, filenames, *applyFlag, *promptFileFlag)
+		}
+	}
+
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		<-interrupt
+		close(stop)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Watching %s (Ctrl-C to stop)\n", notesDir)
+	return watchNotes(notesDir, *intervalFlag, *debounceFlag, stop, onBatch)
+}
+
+// noteState is what the watcher remembers about a note between polls
+type noteState struct {
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+// watchNotes polls notesDir until stop is closed. Notes that are created or
+// whose content changes are collected and passed to onBatch once nothing
+// has changed for debounce, so a burst of saves is handled once. Errors
+// from onBatch are reported and watching continues.
+func watchNotes(notesDir string, interval, debounce time.Duration, stop <-chan struct{}, onBatch func([]string) error) error {
+	states := make(map[string]noteState)
+	if _, err := pollChanges(notesDir, states); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	pending := make(map[string]bool)
+	var lastChange time.Time
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+
+		changed, err := pollChanges(notesDir, states)
+		if err != nil {
+			return err
+		}
+		if len(changed) > 0 {
+			for _, filename := range changed {
+				pending[filename] = true
+			}
+			lastChange = time.Now()
+			continue
+		}
+
+		if len(pending) == 0 || time.Since(lastChange) < debounce {
+			continue
+		}
+
+		filenames := make([]string, 0, len(pending))
+		for filename := range pending {
+			filenames = append(filenames, filename)
+		}
+		sort.Strings(filenames)
+		pending = make(map[string]bool)
+
+		if err := onBatch(filenames); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+// pollChanges updates states and returns the notes that are new or whose
+// content changed. Notes are only re-read when their size or mtime changed,
+// and frontmatter-only edits are not reported.
+func pollChanges(notesDir string, states map[string]noteState) ([]string, error) {
+	names, err := listNoteFiles(notesDir, func(SkippedFile) {})
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	seen := make(map[string]bool)
+	for _, filename := range names {
+		seen[filename] = true
+
+		info, err := os.Stat(filepath.Join(notesDir, filename))
+		if err != nil {
+			continue
+		}
+		prev, known := states[filename]
+		if known && prev.modTime.Equal(info.ModTime()) && prev.size == info.Size() {
+			continue
+		}
+
+		note, err := ParseNote(filepath.Join(notesDir, filename))
+		if err != nil {
+			// Probably mid-save; try again on the next poll
+			continue
+		}
+
+		state := noteState{modTime: info.ModTime(), size: info.Size(), hash: note.ContentHash()}
+		states[filename] = state
+		if !known || prev.hash != state.hash {
+			changed = append(changed, filename)
+		}
+	}
+
+	for filename := range states {
+		if !seen[filename] {
+			delete(states, filename)
+		}
+	}
+
+	return changed, nil
+}
+
+// enrichChanged builds an enrichment prompt for the changed notes that still
+// need enrichment, writing it to promptFile (or stdout) or applying it
+func enrichChanged(notesDir string, filenames []string, command, promptFile string) error {
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load meta file: %w", err)
+	}
+
+	var notesList []*Note
+	for _, filename := range filenames {
+		note, err := ParseNote(filepath.Join(notesDir, filename))
+		if err != nil {
+			continue
+		}
+		if meta.NeedsEnrichment(filename, note.ContentHash()) {
+			notesList = append(notesList, note)
+		}
+	}
+	if len(notesList) == 0 {
+		return nil
+	}
+
+	var w io.Writer = os.Stdout
+	if promptFile != "" {
+		f, err := os.Create(promptFile)
+		if err != nil {
+			return fmt.Errorf("failed to write prompt: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := enrichNotes(notesDir, meta, notesList, command, w); err != nil {
+		return err
+	}
+
+	if promptFile != "" {
+		fmt.Fprintf(os.Stderr, "Wrote enrichment prompt for %d notes to %s\n", len(notesList), promptFile)
+	}
+	return nil
+}
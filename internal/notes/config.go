@@ -1,23 +1,51 @@
 package notes
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // GetNotesDir returns the notes directory path
 // Uses NOTES_DIR env var if set, otherwise defaults to ~/notes
+// Returns an error if the path exists but is not a directory
 func GetNotesDir() (string, error) {
-	if dir := os.Getenv("NOTES_DIR"); dir != "" {
-		return dir, nil
+	dir := os.Getenv("NOTES_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, "notes")
+	}
+
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		return "", fmt.Errorf("notes directory %s is a file, not a directory (check NOTES_DIR)", dir)
 	}
 
-	home, err := os.UserHomeDir()
+	return dir, nil
+}
+
+// GetWritableNotesDir returns the notes directory path for commands that
+// modify notes, verifying that the directory exists and is writable
+func GetWritableNotesDir() (string, error) {
+	dir, err := GetNotesDir()
 	if err != nil {
 		return "", err
 	}
 
-	return filepath.Join(home, "notes"), nil
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return "", fmt.Errorf("notes directory %s does not exist (check NOTES_DIR or create a note first)", dir)
+	}
+
+	if err := checkWritable(dir); err != nil {
+		return "", err
+	}
+
+	return dir, nil
 }
 
 // EnsureNotesDir creates the notes directory if it doesn't exist
@@ -28,20 +56,161 @@ func EnsureNotesDir() (string, error) {
 	}
 
 	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("cannot create notes directory %s: %w", dir, err)
+	}
+
+	if err := checkWritable(dir); err != nil {
 		return "", err
 	}
 
 	return dir, nil
 }
 
-// GetEditor returns the editor to use
+// checkWritable verifies that files can be created in dir
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("notes directory %s is not writable (check its permissions)", dir)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
+
+// GetEditor returns the editor to use ($EDITOR or the editor setting)
 func GetEditor() string {
-	if editor := os.Getenv("EDITOR"); editor != "" {
+	if editor, _ := lookupSetting("editor"); editor != "" {
 		return editor
 	}
 	return "vim"
 }
 
+// GetEditorWait reports whether to wait for confirmation after the editor
+// exits, for editors that fork and return immediately
+func GetEditorWait() bool {
+	value, _ := lookupSetting("editor_wait")
+	wait, _ := parseBoolSetting(value)
+	return wait
+}
+
+// GetAppendToDaily reports whether 'notes new' appends to today's note by
+// default (NOTES_APPEND_TO_DAILY or the append_to_daily setting)
+func GetAppendToDaily() bool {
+	value, _ := lookupSetting("append_to_daily")
+	appendDaily, _ := parseBoolSetting(value)
+	return appendDaily
+}
+
+// GetListLimit returns the default number of notes 'notes list' shows
+func GetListLimit() int {
+	value, source := lookupSetting("list_limit")
+	if value == "" {
+		return 20
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring invalid list_limit %q from %s\n", value, source)
+		return 20
+	}
+	return limit
+}
+
+// GetTagLogic returns the default --tag-logic for list and search: and if
+// NOTES_TAG_LOGIC or the tag_logic setting says so, otherwise or
+func GetTagLogic() string {
+	value, _ := lookupSetting("tag_logic")
+	if value == tagLogicAnd {
+		return tagLogicAnd
+	}
+	return tagLogicOr
+}
+
+// GetColorMode returns when to use colors and terminal formatting: auto,
+// always or never (NOTES_COLOR or the color setting)
+func GetColorMode() string {
+	value, _ := lookupSetting("color")
+	if value == "always" || value == "never" {
+		return value
+	}
+	return "auto"
+}
+
+// GetTimeFormat returns the layout for writing created times: the legacy
+// minute format unless NOTES_TIME_FORMAT or the time_format setting is rfc3339
+func GetTimeFormat() string {
+	value, _ := lookupSetting("time_format")
+	if value == "rfc3339" {
+		return time.RFC3339
+	}
+	return noteTimeFormat
+}
+
+// GetReadingSpeed returns the reading speed for reading time estimates
+// from NOTES_WPM and NOTES_CJK_CPM or the wpm and cjk_cpm settings. A wpm
+// above 0 overrides both.
+func GetReadingSpeed(wpm int) (ReadingSpeed, error) {
+	speed := ReadingSpeed{
+		WordsPerMinute:    defaultWordsPerMinute,
+		CJKCharsPerMinute: defaultCJKCharsPerMinute,
+	}
+
+	for _, s := range []struct {
+		key   string
+		value *int
+	}{
+		{"wpm", &speed.WordsPerMinute},
+		{"cjk_cpm", &speed.CJKCharsPerMinute},
+	} {
+		raw, source := lookupSetting(s.key)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			name := findSetting(s.key).Env
+			if source == "config" {
+				name = s.key
+			}
+			return ReadingSpeed{}, fmt.Errorf("invalid %s: %q (expected a positive number)", name, raw)
+		}
+		*s.value = n
+	}
+
+	if wpm < 0 {
+		return ReadingSpeed{}, fmt.Errorf("invalid --wpm: %d", wpm)
+	}
+	if wpm > 0 {
+		speed.WordsPerMinute = wpm
+	}
+	return speed, nil
+}
+
+// GetNotebookDir returns the directory of another notebook. Names are looked
+// up in NOTES_NOTEBOOKS ("work=~/work-notes,personal=~/notes"); anything else
+// is taken as a path to an existing directory.
+func GetNotebookDir(name string) (string, error) {
+	for _, entry := range strings.Split(os.Getenv("NOTES_NOTEBOOKS"), ",") {
+		key, dir, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || strings.TrimSpace(key) != name {
+			continue
+		}
+		dir = strings.TrimSpace(dir)
+		if strings.HasPrefix(dir, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			dir = filepath.Join(home, dir[2:])
+		}
+		return dir, nil
+	}
+
+	if info, err := os.Stat(name); err == nil && info.IsDir() {
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown notebook: %s (add it to NOTES_NOTEBOOKS or pass a directory)", name)
+}
+
 // NormalizeFilename ensures a filename has .md extension
 func NormalizeFilename(filename string) string {
 	if filepath.Ext(filename) != ".md" {
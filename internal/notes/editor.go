@@ -0,0 +1,89 @@
+package notes
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// runEditor opens path in the configured editor and waits for it to exit.
+// If line is positive and the editor understands +N, the cursor is placed there.
+// For editors that detach from the terminal (e.g. GUI editors without a
+// --wait flag), set NOTES_EDITOR_WAIT=1 to wait for Enter before returning.
+func runEditor(path string, line int) error {
+	editor := GetEditor()
+	args := []string{path}
+	if line > 0 && supportsLineArg(editor) {
+		args = []string{fmt.Sprintf("+%d", line), path}
+	}
+	cmd := exec.Command(editor, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	if GetEditorWait() {
+		fmt.Fprint(os.Stderr, "Press Enter when you're done editing...")
+		bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+
+	return nil
+}
+
+// supportsLineArg reports whether the editor accepts a +N line argument
+func supportsLineArg(editor string) bool {
+	switch filepath.Base(editor) {
+	case "vi", "vim", "nvim", "nano", "emacs", "micro", "kak":
+		return true
+	}
+	return false
+}
+
+// confirm asks a yes/no question on stderr. Non-interactive sessions
+// always answer no.
+func confirm(question string) bool {
+	if !isTerminal(os.Stdin) {
+		return false
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// stdoutIsTerminal reports whether ANSI styling may be written to stdout,
+// honoring the color setting. It's a variable so tests can simulate a
+// terminal.
+var stdoutIsTerminal = func() bool {
+	switch GetColorMode() {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// terminalWidth returns the width to fit output to, from $COLUMNS
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 80
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
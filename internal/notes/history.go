@@ -0,0 +1,305 @@
+package notes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryDir is the hidden directory in the notes directory holding the
+// snapshots 'notes undo' restores
+const HistoryDir = ".notes-history"
+
+// maxSnapshots is how many snapshots are kept; older ones are removed
+var maxSnapshots = 20
+
+// snapshot records the files a command changed, as they were before it ran
+type snapshot struct {
+	Command string         `json:"command"`
+	Created time.Time      `json:"created"`
+	Files   []snapshotFile `json:"files"`
+
+	path string // Snapshot file, set when loaded
+}
+
+type snapshotFile struct {
+	Name    string `json:"name"` // Relative to the notes directory
+	Content []byte `json:"content,omitempty"`
+	Absent  bool   `json:"absent,omitempty"` // Created by the command, so undo removes it
+	After   string `json:"after,omitempty"`  // Hash of the file the command left, empty if it removed it
+}
+
+// undoLog collects the files the running command changes in one notes
+// directory. Writes through writeFileAtomic, such as Note.Save and
+// MetaFile.Save, and removals through removeRecorded add each file the first
+// time it is touched.
+type undoLog struct {
+	notesDir string
+	snap     snapshot
+	seen     map[string]bool
+}
+
+// activeUndo holds the logs of the running command, one per notes directory
+// it changes
+var activeUndo []*undoLog
+
+// beginUndo starts recording the files command changes in notesDir. Call
+// the returned function when the command is done: if anything was changed,
+// even by a command that then failed, the snapshot is saved so the changes
+// can be undone. Only the newest maxSnapshots snapshots are kept. Files
+// already recorded for notesDir, by a command calling another one, stay in
+// the outer command's snapshot.
+func beginUndo(notesDir, command string) func() {
+	if findUndoLog(notesDir) != nil {
+		return func() {}
+	}
+
+	log := &undoLog{
+		notesDir: notesDir,
+		snap:     snapshot{Command: command, Created: time.Now()},
+		seen:     make(map[string]bool),
+	}
+	activeUndo = append(activeUndo, log)
+
+	return func() {
+		for i, l := range activeUndo {
+			if l == log {
+				activeUndo = append(activeUndo[:i], activeUndo[i+1:]...)
+				break
+			}
+		}
+		if len(log.snap.Files) == 0 {
+			return
+		}
+		if err := log.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save undo snapshot: %v\n", err)
+		}
+	}
+}
+
+// findUndoLog returns the active log whose notes directory holds path
+func findUndoLog(path string) *undoLog {
+	for _, log := range activeUndo {
+		rel, err := filepath.Rel(log.notesDir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return log
+		}
+	}
+	return nil
+}
+
+// recordUndo saves the current contents of path in the running command's
+// undo log before the file is changed. Files outside the notes directories
+// being recorded are ignored.
+func recordUndo(path string) error {
+	log := findUndoLog(path)
+	if log == nil {
+		return nil
+	}
+	rel, _ := filepath.Rel(log.notesDir, path)
+	name := filepath.ToSlash(rel)
+	if name == "." || log.seen[name] {
+		return nil
+	}
+
+	file := snapshotFile{Name: name}
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		file.Absent = true
+	case err != nil:
+		return fmt.Errorf("failed to record %s for undo: %w", name, err)
+	default:
+		file.Content = data
+	}
+	log.seen[name] = true
+	log.snap.Files = append(log.snap.Files, file)
+	return nil
+}
+
+// recordCreated records path as created by the running command, for files
+// that are created exclusively rather than through writeFileAtomic
+func recordCreated(path string) {
+	log := findUndoLog(path)
+	if log == nil {
+		return
+	}
+	rel, _ := filepath.Rel(log.notesDir, path)
+	name := filepath.ToSlash(rel)
+	if log.seen[name] {
+		return
+	}
+	log.seen[name] = true
+	log.snap.Files = append(log.snap.Files, snapshotFile{Name: name, Absent: true})
+}
+
+// fileHash returns the SHA256 hash of the file at path, or "" if it doesn't
+// exist
+func fileHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// removeRecorded removes a file like os.Remove, recording it for undo first
+func removeRecorded(path string) error {
+	if err := recordUndo(path); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// renameRecorded renames a file like os.Rename, recording both names for
+// undo first
+func renameRecorded(oldPath, newPath string) error {
+	for _, path := range []string{oldPath, newPath} {
+		if err := recordUndo(path); err != nil {
+			return err
+		}
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// save writes the snapshot to the history directory and prunes old ones
+func (l *undoLog) save() error {
+	// Remember what the command left, so undo can tell later changes apart
+	for i := range l.snap.Files {
+		file := &l.snap.Files[i]
+		hash, err := fileHash(filepath.Join(l.notesDir, file.Name))
+		if err != nil {
+			return err
+		}
+		file.After = hash
+	}
+
+	dir := filepath.Join(l.notesDir, HistoryDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(l.snap)
+	if err != nil {
+		return err
+	}
+	// UTC timestamps sort chronologically by name
+	name := l.snap.Created.UTC().Format("20060102T150405.000000000Z") + ".json"
+	if err := writeFileAtomic(filepath.Join(dir, name), data, 0644); err != nil {
+		return err
+	}
+
+	return pruneSnapshots(dir)
+}
+
+// snapshotFiles returns the snapshot files in dir, oldest first
+func snapshotFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// pruneSnapshots removes all but the newest maxSnapshots snapshots
+func pruneSnapshots(dir string) error {
+	paths, err := snapshotFiles(dir)
+	if err != nil {
+		return err
+	}
+	for len(paths) > maxSnapshots {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
+
+// loadSnapshots returns the saved snapshots, newest first
+func loadSnapshots(notesDir string) ([]*snapshot, error) {
+	paths, err := snapshotFiles(filepath.Join(notesDir, HistoryDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", HistoryDir, err)
+	}
+
+	var snaps []*snapshot
+	for i := len(paths) - 1; i >= 0; i-- {
+		data, err := os.ReadFile(paths[i])
+		if err != nil {
+			return nil, err
+		}
+		snap := &snapshot{path: paths[i]}
+		if err := json.Unmarshal(data, snap); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", paths[i], err)
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps, nil
+}
+
+// changed returns the files in the snapshot that were changed since the
+// command ran, so restoring them would lose those changes
+func (s *snapshot) changed(notesDir string) ([]string, error) {
+	var changed []string
+	for _, file := range s.Files {
+		hash, err := fileHash(filepath.Join(notesDir, file.Name))
+		if err != nil {
+			return nil, err
+		}
+		if hash != file.After {
+			changed = append(changed, file.Name)
+		}
+	}
+	return changed, nil
+}
+
+// restore writes every file in the snapshot back and removes the snapshot
+func (s *snapshot) restore(notesDir string) error {
+	for _, file := range s.Files {
+		path := filepath.Join(notesDir, file.Name)
+		forgetParsed(path)
+		if file.Absent {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", file.Name, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(path, file.Content, 0644); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", file.Name, err)
+		}
+	}
+	return os.Remove(s.path)
+}
+
+// fileNames returns the names of the files in the snapshot
+func (s *snapshot) fileNames() []string {
+	names := make([]string, len(s.Files))
+	for i, file := range s.Files {
+		names[i] = file.Name
+	}
+	return names
+}
@@ -0,0 +1,147 @@
+package notes
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile is the name of the file listing notes to leave out of scans
+const IgnoreFile = ".notesignore"
+
+// IgnoreMatcher decides which files and directories in the notes directory
+// are ignored. Patterns use the gitignore syntax: '#' starts a comment, '!'
+// re-includes, a trailing '/' only matches directories, a pattern with a
+// '/' other than at the end is anchored to the notes directory, and '**'
+// matches any number of directories. The last matching pattern wins.
+type IgnoreMatcher struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	segments []string // Pattern split at '/'
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// LoadIgnore reads the .notesignore file in the notes directory. A missing
+// file gives a matcher that only ignores dotfiles.
+func LoadIgnore(notesDir string) (*IgnoreMatcher, error) {
+	file, err := os.Open(filepath.Join(notesDir, IgnoreFile))
+	if os.IsNotExist(err) {
+		return &IgnoreMatcher{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", IgnoreFile, err)
+	}
+	defer file.Close()
+
+	m := &IgnoreMatcher{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		m.add(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", IgnoreFile, err)
+	}
+	return m, nil
+}
+
+// add parses one line of a .notesignore file
+func (m *IgnoreMatcher) add(line string) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+
+	var p ignorePattern
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:] // Escaped leading '#' or '!'
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		p.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return
+	}
+
+	p.segments = strings.Split(line, "/")
+	m.patterns = append(m.patterns, p)
+}
+
+// Match reports whether name, a slash-separated path relative to the notes
+// directory, is ignored. Dotfiles such as .meta.json and hidden directories
+// are always ignored. A file inside an ignored directory is only reported
+// by matching the directory itself, which callers skip.
+func (m *IgnoreMatcher) Match(name string, isDir bool) bool {
+	if strings.HasPrefix(path.Base(name), ".") {
+		return true
+	}
+
+	ignored := false
+	parts := strings.Split(name, "/")
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.matches(parts) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// Excludes reports whether the note name is ignored, either itself or
+// because one of the directories it is in is
+func (m *IgnoreMatcher) Excludes(name string) bool {
+	parts := strings.Split(name, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.Match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.Match(name, false)
+}
+
+func (p ignorePattern) matches(parts []string) bool {
+	if p.anchored {
+		return matchSegments(p.segments, parts)
+	}
+	// Unanchored patterns match the name at any depth
+	return matchSegments(p.segments, parts[len(parts)-1:])
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" segment matches zero or more path segments
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], parts[1:])
+}
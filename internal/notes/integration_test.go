@@ -1,8 +1,15 @@
 package notes
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -18,14 +25,59 @@ func setupTestDir(t *testing.T) (string, func()) {
 	oldNotesDir := os.Getenv("NOTES_DIR")
 	os.Setenv("NOTES_DIR", tmpDir)
 
+	// Keep the user's config file out of tests
+	oldConfig := os.Getenv("NOTES_CONFIG")
+	configPath := tmpDir + "-config.json"
+	os.Setenv("NOTES_CONFIG", configPath)
+
 	cleanup := func() {
 		os.Setenv("NOTES_DIR", oldNotesDir)
+		os.Setenv("NOTES_CONFIG", oldConfig)
 		os.RemoveAll(tmpDir)
+		os.Remove(configPath)
 	}
 
 	return tmpDir, cleanup
 }
 
+// readNotesDir lists the notes directory like os.ReadDir, leaving out hidden
+// entries such as .meta.json and the undo history
+func readNotesDir(dir string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	var visible []os.DirEntry
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), ".") {
+			visible = append(visible, entry)
+		}
+	}
+	return visible, err
+}
+
+// captureStdout runs fn and returns everything it printed to stdout
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldStdout := os.Stdout
+	os.Stdout = w
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+
+	fnErr := fn()
+	w.Close()
+	os.Stdout = oldStdout
+
+	return <-done, fnErr
+}
+
 func createTestNote(t *testing.T, dir, filename, content string) {
 	created, _ := time.Parse("2006-01-02 15:04", "2025-01-11 14:23")
 	note := &Note{
@@ -76,7 +128,7 @@ func TestCmdNewWithContent(t *testing.T) {
 	}
 
 	// Check file was created
-	entries, _ := os.ReadDir(tmpDir)
+	entries, _ := readNotesDir(tmpDir)
 	if len(entries) != 1 {
 		t.Fatalf("Expected 1 file, got %d", len(entries))
 	}
@@ -106,6 +158,55 @@ func TestCmdDiff(t *testing.T) {
 	}
 }
 
+func TestCmdDiffAll(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	// messy.md is new, has no summary or tags, and links to a missing note
+	createTestNote(t, tmpDir, "messy.md", "Needs work")
+	messyPath := filepath.Join(tmpDir, "messy.md")
+	note, _ := ParseNote(messyPath)
+	note.Frontmatter.Related = []string{"gone.md"}
+	note.Save(messyPath)
+
+	createEnrichedTestNote(t, tmpDir, "clean.md", "All good", []string{"tag1"}, "Summary")
+
+	output, err := captureStdout(t, func() error { return CmdDiff([]string{"--all", "--json"}) })
+	if err != nil {
+		t.Fatalf("CmdDiff(--all --json) error = %v", err)
+	}
+
+	var report DiffReport
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, output)
+	}
+
+	sections := map[string][]DiffEntry{
+		"needs_enrichment": report.NeedsEnrichment,
+		"no_summary":       report.NoSummary,
+		"no_tags":          report.NoTags,
+		"dangling_related": report.DanglingRelated,
+	}
+	for name, entries := range sections {
+		if len(entries) != 1 || entries[0].Filename != "messy.md" {
+			t.Errorf("%s = %+v, want only messy.md", name, entries)
+		}
+	}
+	if report.NeedsEnrichment[0].Detail != "new" || report.DanglingRelated[0].Detail != "gone.md" {
+		t.Errorf("unexpected details: %+v / %+v", report.NeedsEnrichment[0], report.DanglingRelated[0])
+	}
+
+	output, err = captureStdout(t, func() error { return CmdDiff([]string{"--all"}) })
+	if err != nil {
+		t.Fatalf("CmdDiff(--all) error = %v", err)
+	}
+	for _, heading := range []string{"Needs enrichment (1):", "No summary (1):", "No tags (1):", "Dangling related links (1):\n  messy.md (gone.md)"} {
+		if !strings.Contains(output, heading) {
+			t.Errorf("output missing %q:\n%s", heading, output)
+		}
+	}
+}
+
 func TestCmdList(t *testing.T) {
 	tmpDir, cleanup := setupTestDir(t)
 	defer cleanup()
@@ -145,245 +246,5212 @@ func TestCmdListWithFilters(t *testing.T) {
 	}
 }
 
-func TestCmdShow(t *testing.T) {
+func TestCmdListTagLogic(t *testing.T) {
 	tmpDir, cleanup := setupTestDir(t)
 	defer cleanup()
 
-	createTestNote(t, tmpDir, "2025-01-11-1423.md", "This is the note content")
+	createEnrichedTestNote(t, tmpDir, "2025-01-11-1423.md", "Both", []string{"urgent", "work"}, "Both")
+	createEnrichedTestNote(t, tmpDir, "2025-01-11-1424.md", "Work only", []string{"work"}, "Work only")
+	createEnrichedTestNote(t, tmpDir, "2025-01-11-1425.md", "Neither", []string{"home"}, "Neither")
 
-	// With .md extension
-	err := CmdShow([]string{"2025-01-11-1423.md"})
-	if err != nil {
-		t.Fatalf("CmdShow() error = %v", err)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--tags", "work,urgent"}, "2025-01-11-1423.md\n2025-01-11-1424.md\n"},
+		{[]string{"--tags", "work,urgent", "--tag-logic", "or"}, "2025-01-11-1423.md\n2025-01-11-1424.md\n"},
+		{[]string{"--tags", "Work,URGENT", "--tag-logic", "and"}, "2025-01-11-1423.md\n"},
+	}
+	for _, tt := range tests {
+		output, err := captureStdout(t, func() error { return CmdList(append(tt.args, "--raw")) })
+		if err != nil {
+			t.Fatalf("CmdList(%v) error = %v", tt.args, err)
+		}
+		if output != tt.want {
+			t.Errorf("CmdList(%v) = %q, want %q", tt.args, output, tt.want)
+		}
 	}
 
-	// Without .md extension
-	err = CmdShow([]string{"2025-01-11-1423"})
+	output, err := captureStdout(t, func() error {
+		return CmdSearch([]string{"o", "--tags", "work,urgent", "--tag-logic", "and"})
+	})
 	if err != nil {
-		t.Fatalf("CmdShow() without extension error = %v", err)
+		t.Fatalf("CmdSearch() error = %v", err)
+	}
+	if !strings.Contains(output, "2025-01-11-1423.md") || strings.Contains(output, "2025-01-11-1424.md") {
+		t.Errorf("search --tag-logic and should only match the note with both tags, got:\n%s", output)
+	}
+
+	if err := CmdList([]string{"--tag-logic", "xor"}); err == nil {
+		t.Error("expected an error for an invalid --tag-logic")
 	}
 }
 
-func TestCmdShowNotFound(t *testing.T) {
-	_, cleanup := setupTestDir(t)
+func TestCmdListExcludeTags(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
 	defer cleanup()
 
-	err := CmdShow([]string{"nonexistent.md"})
-	if err == nil {
-		t.Error("CmdShow() should error for nonexistent file")
+	createEnrichedTestNote(t, tmpDir, "2025-01-11-1423.md", "Both", []string{"archived", "work"}, "Both")
+	createEnrichedTestNote(t, tmpDir, "2025-01-11-1424.md", "Work only", []string{"work"}, "Work only")
+	createEnrichedTestNote(t, tmpDir, "2025-01-11-1425.md", "Home", []string{"home"}, "Home")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--exclude-tags", "archived"}, "2025-01-11-1424.md\n2025-01-11-1425.md\n"},
+		{[]string{"--exclude-tags", "Archived, home"}, "2025-01-11-1424.md\n"},
+		// Exclusion wins over a matching --tags
+		{[]string{"--tags", "work", "--exclude-tags", "archived"}, "2025-01-11-1424.md\n"},
+	}
+	for _, tt := range tests {
+		output, err := captureStdout(t, func() error { return CmdList(append(tt.args, "--raw", "--sort", "none")) })
+		if err != nil {
+			t.Fatalf("CmdList(%v) error = %v", tt.args, err)
+		}
+		if output != tt.want {
+			t.Errorf("CmdList(%v) = %q, want %q", tt.args, output, tt.want)
+		}
 	}
 }
 
-func TestCmdMeta(t *testing.T) {
+func TestCmdListFormat(t *testing.T) {
 	tmpDir, cleanup := setupTestDir(t)
 	defer cleanup()
 
-	createEnrichedTestNote(t, tmpDir, "2025-01-11-1423.md", "Content", []string{"tag1", "tag2"}, "Test summary")
+	createEnrichedTestNote(t, tmpDir, "2025-01-11-1423.md", "Content", []string{"neo", "eval"}, "A summary")
 
-	err := CmdMeta([]string{"2025-01-11-1423"})
+	output, err := captureStdout(t, func() error {
+		return CmdList([]string{"--format", `{{.Filename}} | {{.Created}} | {{join .Tags ","}} | {{.Summary}}`})
+	})
 	if err != nil {
-		t.Fatalf("CmdMeta() error = %v", err)
+		t.Fatalf("CmdList(--format) error = %v", err)
+	}
+	if want := "2025-01-11-1423.md | 2025-01-11T14:23:00Z | eval,neo | A summary\n"; output != want {
+		t.Errorf("CmdList(--format) = %q, want %q", output, want)
+	}
+
+	output, err = captureStdout(t, func() error {
+		return CmdList([]string{"--format", "{{.Filename}}: {{.ReadingTime}}", "--reading-time"})
+	})
+	if err != nil {
+		t.Fatalf("CmdList(--format --reading-time) error = %v", err)
+	}
+	if want := "2025-01-11-1423.md: 1 min\n"; output != want {
+		t.Errorf("CmdList(--format --reading-time) = %q, want %q", output, want)
+	}
+
+	if err := CmdList([]string{"--format", "{{.Filename"}); err == nil {
+		t.Error("expected an error for an invalid template")
+	}
+	if err := CmdList([]string{"--format", "{{.Filename}}", "--json"}); err == nil {
+		t.Error("expected an error combining --format and --json")
 	}
 }
 
-func TestCmdUpdate(t *testing.T) {
+func TestCmdPin(t *testing.T) {
 	tmpDir, cleanup := setupTestDir(t)
 	defer cleanup()
 
-	createTestNote(t, tmpDir, "2025-01-11-1423.md", "Content")
+	createTestNote(t, tmpDir, "2025-01-10-0900.md", "Reference")
+	createTestNote(t, tmpDir, "2025-01-11-0900.md", "Other")
+	createTestNote(t, tmpDir, "2025-01-09-0900.md", "Another")
+	createEnrichedTestNote(t, tmpDir, "2025-01-08-0900.md", "Enriched", []string{"neo"}, "Summary")
 
-	err := CmdUpdate([]string{
-		"2025-01-11-1423.md",
-		"--tags", "neo,eval,idea",
-		"--summary", "Test summary here",
-		"--related", "other.md",
-	})
-	if err != nil {
-		t.Fatalf("CmdUpdate() error = %v", err)
+	for _, name := range []string{"2025-01-10-0900", "2025-01-08-0900"} {
+		if _, err := captureStdout(t, func() error { return CmdPin([]string{name}) }); err != nil {
+			t.Fatalf("CmdPin(%s) error = %v", name, err)
+		}
 	}
 
-	// Verify note was updated
-	note, err := ParseNote(filepath.Join(tmpDir, "2025-01-11-1423.md"))
+	output, err := captureStdout(t, func() error { return CmdList([]string{"--raw"}) })
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("CmdList() error = %v", err)
 	}
-
-	if len(note.Frontmatter.Tags) != 3 {
-		t.Errorf("Expected 3 tags, got %d", len(note.Frontmatter.Tags))
+	// Test notes share a created time, so ties keep filename order
+	want := "2025-01-08-0900.md\n2025-01-10-0900.md\n2025-01-09-0900.md\n2025-01-11-0900.md\n"
+	if output != want {
+		t.Errorf("CmdList() = %q, want pinned notes first %q", output, want)
 	}
-	if note.Frontmatter.Summary != "Test summary here" {
-		t.Errorf("Summary = %q, want %q", note.Frontmatter.Summary, "Test summary here")
+
+	output, _ = captureStdout(t, func() error { return CmdList([]string{"--raw", "--pinned"}) })
+	if output != "2025-01-08-0900.md\n2025-01-10-0900.md\n" {
+		t.Errorf("CmdList(--pinned) = %q", output)
 	}
 
-	// Verify meta was updated
+	// Pinning keeps the enriched note up to date in .meta.json
 	meta, _ := LoadMetaFile(tmpDir)
-	fileMeta := meta.GetFileMeta("2025-01-11-1423.md")
-	if fileMeta == nil {
-		t.Fatal("Meta should have entry")
+	note, _ := ParseNote(filepath.Join(tmpDir, "2025-01-08-0900.md"))
+	if !meta.GetFileMeta("2025-01-08-0900.md").Pinned || meta.NeedsEnrichment("2025-01-08-0900.md", note.ContentHash()) {
+		t.Errorf("expected the meta entry to be pinned and still enriched, got %+v", meta.GetFileMeta("2025-01-08-0900.md"))
 	}
-	if fileMeta.Summary != "Test summary here" {
-		t.Errorf("Meta summary = %q, want %q", fileMeta.Summary, "Test summary here")
+
+	if _, err := captureStdout(t, func() error { return CmdUnpin([]string{"2025-01-10-0900.md"}) }); err != nil {
+		t.Fatalf("CmdUnpin() error = %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(tmpDir, "2025-01-10-0900.md"))
+	if strings.Contains(string(data), "pinned") {
+		t.Errorf("unpinned note should not mention pinned:\n%s", data)
+	}
+
+	// A note pinned by hand is picked up by sync, which then stays clean,
+	// and update keeps the flag in .meta.json
+	os.WriteFile(filepath.Join(tmpDir, "hand.md"), []byte("---\ncreated: 2025-01-09 09:00\ntags: []\nsummary: \"\"\nrelated: []\npinned: true\n---\nBody\n"), 0644)
+	if _, err := captureStdout(t, func() error { return CmdSync(nil) }); err != nil {
+		t.Fatalf("CmdSync() error = %v", err)
+	}
+	if _, err := captureStdout(t, func() error { return CmdSync([]string{"--check"}) }); err != nil {
+		t.Errorf("CmdSync(--check) after sync error = %v", err)
+	}
+	meta, _ = LoadMetaFile(tmpDir)
+	if !meta.GetFileMeta("hand.md").Pinned {
+		t.Error("sync should record the pinned flag in .meta.json")
+	}
+	meta.GetFileMeta("hand.md").Pinned = false
+	meta.Save(tmpDir)
+	if _, err := captureStdout(t, func() error { return CmdUpdate([]string{"hand.md", "--tags", "x"}) }); err != nil {
+		t.Fatalf("CmdUpdate() error = %v", err)
+	}
+	meta, _ = LoadMetaFile(tmpDir)
+	if !meta.GetFileMeta("hand.md").Pinned {
+		t.Error("update should record the pinned flag in .meta.json")
 	}
 }
 
-func TestCmdUpdateBidirectional(t *testing.T) {
+func TestCmdShow(t *testing.T) {
 	tmpDir, cleanup := setupTestDir(t)
 	defer cleanup()
 
-	createTestNote(t, tmpDir, "a.md", "Content A")
-	createTestNote(t, tmpDir, "b.md", "Content B")
-
-	// Initialize meta for b.md
-	meta, _ := LoadMetaFile(tmpDir)
-	note, _ := ParseNote(filepath.Join(tmpDir, "b.md"))
-	meta.UpdateFromNote(note)
-	meta.Save(tmpDir)
+	createTestNote(t, tmpDir, "2025-01-11-1423.md", "This is the note content")
 
-	// Update a.md to relate to b.md
-	err := CmdUpdate([]string{
-		"a.md",
-		"--tags", "test",
-		"--summary", "Summary A",
-		"--related", "b.md",
-	})
+	// With .md extension
+	err := CmdShow([]string{"2025-01-11-1423.md"})
 	if err != nil {
-		t.Fatalf("CmdUpdate() error = %v", err)
+		t.Fatalf("CmdShow() error = %v", err)
 	}
 
-	// Check that b.md now relates back to a.md
-	meta, _ = LoadMetaFile(tmpDir)
-	bMeta := meta.GetFileMeta("b.md")
-	if bMeta == nil || !Contains(bMeta.Related, "a.md") {
-		t.Error("b.md should have bidirectional relation to a.md")
+	// Without .md extension
+	err = CmdShow([]string{"2025-01-11-1423"})
+	if err != nil {
+		t.Fatalf("CmdShow() without extension error = %v", err)
 	}
 }
 
-func TestCmdSync(t *testing.T) {
+func TestCmdCat(t *testing.T) {
 	tmpDir, cleanup := setupTestDir(t)
 	defer cleanup()
 
-	// Create notes with enriched frontmatter (but no meta)
-	created, _ := time.Parse("2006-01-02 15:04", "2025-01-11 14:23")
-	note := &Note{
-		Filename: "test.md",
-		Frontmatter: Frontmatter{
-			Created: NoteTime{created},
-			Tags:    []string{"tag1", "tag2"},
-			Summary: "Manual summary",
-			Related: []string{},
-		},
-		Content: "\nContent here\n",
+	createTestNote(t, tmpDir, "2025-01-11-1423.md", "First note")
+	createTestNote(t, tmpDir, "2025-01-12-0900.md", "Second note")
+	raw, err := os.ReadFile(filepath.Join(tmpDir, "2025-01-11-1423.md"))
+	if err != nil {
+		t.Fatal(err)
 	}
-	note.Save(filepath.Join(tmpDir, "test.md"))
 
-	// Run sync
-	err := CmdSync([]string{})
+	output, err := captureStdout(t, func() error { return CmdCat([]string{"2025-01-11-1423"}) })
 	if err != nil {
-		t.Fatalf("CmdSync() error = %v", err)
+		t.Fatalf("CmdCat() error = %v", err)
+	}
+	if output != string(raw) {
+		t.Errorf("CmdCat() = %q, want the file verbatim %q", output, raw)
 	}
 
-	// Verify meta was created
-	meta, _ := LoadMetaFile(tmpDir)
-	fileMeta := meta.GetFileMeta("test.md")
-	if fileMeta == nil {
-		t.Fatal("Meta should have entry after sync")
+	output, err = captureStdout(t, func() error {
+		return CmdCat([]string{"2025-01-11-1423.md", "2025-01-12-0900.md"})
+	})
+	if err != nil {
+		t.Fatalf("CmdCat() with two notes error = %v", err)
 	}
-	if fileMeta.Summary != "Manual summary" {
-		t.Errorf("Summary = %q, want %q", fileMeta.Summary, "Manual summary")
+	if !strings.HasPrefix(output, "==> 2025-01-11-1423.md <==\n---\n") {
+		t.Errorf("expected a header before the first note, got:\n%s", output)
 	}
-	if len(fileMeta.Tags) != 2 {
-		t.Errorf("Tags length = %d, want 2", len(fileMeta.Tags))
+	if !strings.Contains(output, "\n\n==> 2025-01-12-0900.md <==\n") || !strings.Contains(output, "Second note") {
+		t.Errorf("expected the second note after a separator, got:\n%s", output)
+	}
+
+	if _, err := captureStdout(t, func() error {
+		return CmdCat([]string{"2025-01-11-1423.md", "missing"})
+	}); err == nil {
+		t.Error("expected an error for a missing note")
 	}
 }
 
-func TestCmdSyncDryRun(t *testing.T) {
+func TestCmdShowHighlight(t *testing.T) {
 	tmpDir, cleanup := setupTestDir(t)
 	defer cleanup()
 
-	createTestNote(t, tmpDir, "test.md", "Content")
+	createTestNote(t, tmpDir, "a.md", "Widget and widgets, v2 and v3")
 
-	err := CmdSync([]string{"--dry-run"})
-	if err != nil {
-		t.Fatalf("CmdSync() dry run error = %v", err)
+	show := func(args ...string) string {
+		t.Helper()
+		output, err := captureStdout(t, func() error { return CmdShow(args) })
+		if err != nil {
+			t.Fatalf("CmdShow(%v) error = %v", args, err)
+		}
+		return output
 	}
 
-	// Meta should NOT be created in dry run
-	metaPath := filepath.Join(tmpDir, ".meta.json")
-	if _, err := os.Stat(metaPath); !os.IsNotExist(err) {
-		// File might exist but be empty
-		data, _ := os.ReadFile(metaPath)
-		if len(data) > 5 { // more than just "{}"
-			t.Error("Meta should not be created in dry run")
-		}
+	// Not a terminal: plain output
+	if output := show("a.md", "--highlight", "widget"); strings.Contains(output, "\x1b[") {
+		t.Errorf("Output should be plain when not on a terminal, got %q", output)
+	}
+
+	orig := stdoutIsTerminal
+	stdoutIsTerminal = func() bool { return true }
+	defer func() { stdoutIsTerminal = orig }()
+
+	want := "\x1b[7mWidget\x1b[27m and \x1b[7mwidget\x1b[27ms, v2 and v3\n"
+	if output := show("a.md", "--highlight", "widget"); output != want {
+		t.Errorf("Highlighted output = %q, want %q", output, want)
+	}
+
+	want = "Widget and widgets, \x1b[7mv2\x1b[27m and \x1b[7mv3\x1b[27m\n"
+	if output := show("--highlight", `v\d`, "--regex", "a.md"); output != want {
+		t.Errorf("Regex highlighted output = %q, want %q", output, want)
 	}
 }
 
-func TestCmdGraph(t *testing.T) {
+func TestCmdShowOutline(t *testing.T) {
 	tmpDir, cleanup := setupTestDir(t)
 	defer cleanup()
 
-	// Create related notes
-	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"neo"}, "Summary A")
-	createEnrichedTestNote(t, tmpDir, "b.md", "Content B", []string{"neo"}, "Summary B")
+	body := strings.Join([]string{
+		"# Project",
+		"Intro",
+		"## Goals",
+		"### Short term",
+		"```sh",
+		"# a comment, not a heading",
+		"```",
+		"### Long term",
+		"## Risks",
+		"#hashtag is not a heading either",
+	}, "\n")
+	createTestNote(t, tmpDir, "a.md", body)
 
-	// Add relation
-	meta, _ := LoadMetaFile(tmpDir)
-	meta.AddRelation("a.md", "b.md")
-	meta.Save(tmpDir)
+	show := func(args ...string) string {
+		output, err := captureStdout(t, func() error { return CmdShow(args) })
+		if err != nil {
+			t.Fatalf("CmdShow(%v) error = %v", args, err)
+		}
+		return output
+	}
 
-	// Test all connections view
-	err := CmdGraph([]string{})
-	if err != nil {
-		t.Fatalf("CmdGraph() error = %v", err)
+	want := "Project\n  Goals\n    Short term\n    Long term\n  Risks\n"
+	if output := show("a.md", "--outline"); output != want {
+		t.Errorf("outline = %q, want %q", output, want)
 	}
 
-	// Test specific note view
-	err = CmdGraph([]string{"a.md"})
-	if err != nil {
-		t.Fatalf("CmdGraph(a.md) error = %v", err)
+	// Line numbers count the frontmatter, so they can be passed to edit --line
+	output := show("a.md", "--outline", "--line-numbers")
+	data, _ := os.ReadFile(filepath.Join(tmpDir, "a.md"))
+	lines := strings.Split(string(data), "\n")
+	for _, entry := range strings.Split(strings.TrimSpace(output), "\n") {
+		var line int
+		fmt.Sscanf(strings.TrimSpace(entry), "%d", &line)
+		title := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(entry), "0123456789"))
+		if line < 1 || line > len(lines) || !strings.HasSuffix(lines[line-1], " "+title) {
+			t.Errorf("entry %q does not point at its heading", entry)
+		}
 	}
 
-	// Test with JSON output
-	err = CmdGraph([]string{"--json"})
-	if err != nil {
-		t.Fatalf("CmdGraph(--json) error = %v", err)
+	if err := CmdShow([]string{"a.md", "--line-numbers"}); err == nil {
+		t.Error("expected an error for --line-numbers without --outline")
 	}
 }
 
-func TestCmdTags(t *testing.T) {
+func TestCmdShowFrontmatterJSON(t *testing.T) {
 	tmpDir, cleanup := setupTestDir(t)
 	defer cleanup()
 
-	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"neo", "eval"}, "Summary A")
-	createEnrichedTestNote(t, tmpDir, "b.md", "Content B", []string{"neo", "meeting"}, "Summary B")
-	createEnrichedTestNote(t, tmpDir, "c.md", "Content C", []string{"idea"}, "Summary C")
+	createEnrichedTestNote(t, tmpDir, "a.md", "Body", []string{"neo"}, "File summary")
 
-	err := CmdTags([]string{})
+	// .meta.json disagrees with the file
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.GetFileMeta("a.md").Summary = "Meta summary"
+	meta.GetFileMeta("a.md").Tags = []string{"stale"}
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error {
+		return CmdShow([]string{"a.md", "--frontmatter-json"})
+	})
 	if err != nil {
-		t.Fatalf("CmdTags() error = %v", err)
+		t.Fatalf("CmdShow(--frontmatter-json) error = %v", err)
+	}
+
+	var fm FrontmatterOutput
+	if err := json.Unmarshal([]byte(output), &fm); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, output)
+	}
+	if fm.Summary != "File summary" || fmt.Sprint(fm.Tags) != "[neo]" {
+		t.Errorf("frontmatter = %+v, want the file's own summary and tags", fm)
+	}
+	if fm.Created != "2025-01-11T14:23:00Z" {
+		t.Errorf("Created = %q", fm.Created)
+	}
+	if strings.Contains(output, "Body") {
+		t.Errorf("output should not include the body:\n%s", output)
 	}
 }
 
-func TestCmdEnrich(t *testing.T) {
+func TestCmdShowNotFound(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	err := CmdShow([]string{"nonexistent.md"})
+	if err == nil {
+		t.Error("CmdShow() should error for nonexistent file")
+	}
+}
+
+func TestCmdMeta(t *testing.T) {
 	tmpDir, cleanup := setupTestDir(t)
 	defer cleanup()
 
-	createTestNote(t, tmpDir, "unenriched.md", "Content needing enrichment")
+	createEnrichedTestNote(t, tmpDir, "2025-01-11-1423.md", "Content", []string{"tag1", "tag2"}, "Test summary")
 
-	err := CmdEnrich([]string{})
+	err := CmdMeta([]string{"2025-01-11-1423"})
 	if err != nil {
-		t.Fatalf("CmdEnrich() error = %v", err)
+		t.Fatalf("CmdMeta() error = %v", err)
 	}
 }
 
-func TestCmdEnrichAllUpToDate(t *testing.T) {
+func TestCmdMetaStats(t *testing.T) {
 	tmpDir, cleanup := setupTestDir(t)
 	defer cleanup()
+	t.Setenv("NOTES_WPM", "")
 
-	createEnrichedTestNote(t, tmpDir, "enriched.md", "Already enriched", []string{"tag"}, "Summary")
+	createEnrichedTestNote(t, tmpDir, "a.md", strings.Repeat("word ", 250), []string{"tag1"}, "Summary")
+
+	var plain, stats MetaOutput
+	for _, c := range []struct {
+		args []string
+		out  *MetaOutput
+	}{{[]string{"a.md"}, &plain}, {[]string{"a.md", "--stats"}, &stats}} {
+		output, err := captureStdout(t, func() error { return CmdMeta(c.args) })
+		if err != nil {
+			t.Fatalf("CmdMeta(%v) error = %v", c.args, err)
+		}
+		if err := json.Unmarshal([]byte(output), c.out); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, output)
+		}
+	}
+
+	if plain.ReadingTimeSeconds != nil {
+		t.Error("reading_time_seconds should only be included with --stats")
+	}
+	if stats.WordCount != 250 || stats.ReadingTimeSeconds == nil || *stats.ReadingTimeSeconds != 75 {
+		t.Errorf("--stats = %+v, want 250 words read in 75 seconds", stats)
+	}
+}
+
+func TestCmdMetaEnsure(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "hand-written.md", "Added by hand")
+
+	if _, err := captureStdout(t, func() error {
+		return CmdMeta([]string{"hand-written", "--ensure"})
+	}); err != nil {
+		t.Fatalf("CmdMeta(--ensure) error = %v", err)
+	}
+
+	meta, _ := LoadMetaFile(tmpDir)
+	fileMeta := meta.GetFileMeta("hand-written.md")
+	if fileMeta == nil {
+		t.Fatal("--ensure should create a meta entry")
+	}
+	note, _ := ParseNote(filepath.Join(tmpDir, "hand-written.md"))
+	if fileMeta.ContentHash != note.ContentHash() {
+		t.Errorf("ContentHash = %q, want %q", fileMeta.ContentHash, note.ContentHash())
+	}
+
+	// An existing entry is left alone
+	fileMeta.Summary = "Kept"
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error {
+		return CmdMeta([]string{"hand-written", "--ensure"})
+	})
+	if err != nil {
+		t.Fatalf("CmdMeta(--ensure) second run error = %v", err)
+	}
+	if !strings.Contains(output, "Already tracked") {
+		t.Errorf("second run should report the note as tracked, got %q", output)
+	}
+	meta, _ = LoadMetaFile(tmpDir)
+	if summary := meta.GetFileMeta("hand-written.md").Summary; summary != "Kept" {
+		t.Errorf("Summary = %q, existing entry should not change", summary)
+	}
+}
+
+func TestCompactJSONOutput(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Content", []string{"tag1", "tag2"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "Content", []string{"tag1"}, "Summary B")
+	if err := CmdRelate([]string{"a.md", "b.md"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]func() error{
+		"meta":         func() error { return CmdMeta([]string{"a.md", "--compact"}) },
+		"meta --diff":  func() error { return CmdMeta([]string{"a.md", "--diff", "b.md", "--compact"}) },
+		"graph":        func() error { return CmdGraph([]string{"--compact"}) },
+		"graph <file>": func() error { return CmdGraph([]string{"--compact", "a.md"}) },
+		"list":         func() error { return CmdList([]string{"--json", "--compact"}) },
+	}
+
+	for name, fn := range cases {
+		output, err := captureStdout(t, fn)
+		if err != nil {
+			t.Fatalf("%s --compact error = %v", name, err)
+		}
+		output = strings.TrimSuffix(output, "\n")
+		if strings.Contains(output, "\n") {
+			t.Errorf("%s --compact output spans multiple lines:\n%s", name, output)
+		}
+		if !json.Valid([]byte(output)) {
+			t.Errorf("%s --compact output is not valid JSON: %s", name, output)
+		}
+	}
+}
+
+func TestCmdUpdate(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "2025-01-11-1423.md", "Content")
+
+	err := CmdUpdate([]string{
+		"2025-01-11-1423.md",
+		"--tags", "neo,eval,idea",
+		"--summary", "Test summary here",
+		"--related", "other.md",
+	})
+	if err != nil {
+		t.Fatalf("CmdUpdate() error = %v", err)
+	}
+
+	// Verify note was updated
+	note, err := ParseNote(filepath.Join(tmpDir, "2025-01-11-1423.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(note.Frontmatter.Tags) != 3 {
+		t.Errorf("Expected 3 tags, got %d", len(note.Frontmatter.Tags))
+	}
+	if note.Frontmatter.Summary != "Test summary here" {
+		t.Errorf("Summary = %q, want %q", note.Frontmatter.Summary, "Test summary here")
+	}
+
+	// Verify meta was updated
+	meta, _ := LoadMetaFile(tmpDir)
+	fileMeta := meta.GetFileMeta("2025-01-11-1423.md")
+	if fileMeta == nil {
+		t.Fatal("Meta should have entry")
+	}
+	if fileMeta.Summary != "Test summary here" {
+		t.Errorf("Meta summary = %q, want %q", fileMeta.Summary, "Test summary here")
+	}
+}
+
+func TestCmdUpdateNormalizesTags(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "a.md", "Content")
+
+	if err := CmdUpdate([]string{"a.md", "--tags", "Neo,neo,eval"}); err != nil {
+		t.Fatalf("CmdUpdate() error = %v", err)
+	}
+
+	note, _ := ParseNote(filepath.Join(tmpDir, "a.md"))
+	if !stringSliceEqual(note.Frontmatter.Tags, []string{"eval", "Neo"}) {
+		t.Errorf("frontmatter tags = %v, want [eval Neo]", note.Frontmatter.Tags)
+	}
+	meta, _ := LoadMetaFile(tmpDir)
+	if fm := meta.GetFileMeta("a.md"); !stringSliceEqual(fm.Tags, []string{"eval", "Neo"}) {
+		t.Errorf("meta tags = %v, want [eval Neo]", fm.Tags)
+	}
+
+	// A hand-edited duplicate is normalized by sync and not flagged again
+	data, _ := os.ReadFile(filepath.Join(tmpDir, "a.md"))
+	os.WriteFile(filepath.Join(tmpDir, "a.md"), []byte(strings.Replace(string(data), "tags: [eval, Neo]", "tags: [neo, eval, NEO]", 1)), 0644)
+	if _, err := captureStdout(t, func() error { return CmdSync(nil) }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := captureStdout(t, func() error { return CmdSync([]string{"--check"}) }); err != nil {
+		t.Errorf("sync should be stable after normalizing tags: %v", err)
+	}
+}
+
+func TestCmdUpdateKeepsExtraFrontmatter(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	path := filepath.Join(tmpDir, "a.md")
+	os.WriteFile(path, []byte("---\ncreated: 2025-01-11 14:23\nmood: calm\ntags: []\n---\n\nContent\n"), 0644)
+
+	if err := CmdUpdate([]string{"a.md", "--tags", "neo", "--summary", "Calm day"}); err != nil {
+		t.Fatalf("CmdUpdate() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "mood: calm\n") || !strings.Contains(string(data), "tags: [neo]") {
+		t.Errorf("update should keep mood:, got:\n%s", data)
+	}
+}
+
+func TestTimeFormatSetting(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	zone := time.FixedZone("UTC+2", 2*60*60)
+	created := time.Date(2025, 3, 30, 9, 15, 42, 0, zone)
+	note := &Note{Frontmatter: Frontmatter{Created: NoteTime{created}}, Content: "\nZoned\n"}
+
+	t.Setenv("NOTES_TIME_FORMAT", "rfc3339")
+	note.Save(filepath.Join(tmpDir, "zoned.md"))
+	data, _ := os.ReadFile(filepath.Join(tmpDir, "zoned.md"))
+	if !strings.Contains(string(data), "created: 2025-03-30T09:15:42+02:00\n") {
+		t.Errorf("want an RFC 3339 created time, got:\n%s", data)
+	}
+	if parsed, _ := ParseNote(filepath.Join(tmpDir, "zoned.md")); !parsed.Frontmatter.Created.Equal(created) {
+		t.Errorf("created = %v, want %v", parsed.Frontmatter.Created.Time, created)
+	}
+
+	// Legacy notes sort by the instant they were written, whatever the format
+	t.Setenv("NOTES_TIME_FORMAT", "")
+	earlier := created.Add(-time.Minute).In(time.Local).Format("2006-01-02 15:04")
+	later := created.Add(time.Minute).In(time.Local).Format("2006-01-02 15:04")
+	os.WriteFile(filepath.Join(tmpDir, "earlier.md"), []byte("---\ncreated: "+earlier+"\n---\nEarlier\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "later.md"), []byte("---\ncreated: "+later+"\n---\nLater\n"), 0644)
+
+	output, err := captureStdout(t, func() error { return CmdList([]string{"--raw"}) })
+	if err != nil {
+		t.Fatalf("CmdList() error = %v", err)
+	}
+	if output != "later.md\nzoned.md\nearlier.md\n" {
+		t.Errorf("CmdList() order = %q", output)
+	}
+
+	if err := CmdConfig([]string{"--set", "time_format=iso"}); err == nil {
+		t.Error("expected an error for an unknown time format")
+	}
+}
+
+func TestCmdUpdateBidirectional(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "a.md", "Content A")
+	createTestNote(t, tmpDir, "b.md", "Content B")
+
+	// Initialize meta for b.md
+	meta, _ := LoadMetaFile(tmpDir)
+	note, _ := ParseNote(filepath.Join(tmpDir, "b.md"))
+	meta.UpdateFromNote(note)
+	meta.Save(tmpDir)
+
+	// Update a.md to relate to b.md
+	err := CmdUpdate([]string{
+		"a.md",
+		"--tags", "test",
+		"--summary", "Summary A",
+		"--related", "b.md",
+	})
+	if err != nil {
+		t.Fatalf("CmdUpdate() error = %v", err)
+	}
+
+	// Check that b.md now relates back to a.md
+	meta, _ = LoadMetaFile(tmpDir)
+	bMeta := meta.GetFileMeta("b.md")
+	if bMeta == nil || !Contains(bMeta.Related, "a.md") {
+		t.Error("b.md should have bidirectional relation to a.md")
+	}
+}
+
+func TestCmdSync(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	// Create notes with enriched frontmatter (but no meta)
+	created, _ := time.Parse("2006-01-02 15:04", "2025-01-11 14:23")
+	note := &Note{
+		Filename: "test.md",
+		Frontmatter: Frontmatter{
+			Created: NoteTime{created},
+			Tags:    []string{"tag1", "tag2"},
+			Summary: "Manual summary",
+			Related: []string{},
+		},
+		Content: "\nContent here\n",
+	}
+	note.Save(filepath.Join(tmpDir, "test.md"))
+
+	// Run sync
+	err := CmdSync([]string{})
+	if err != nil {
+		t.Fatalf("CmdSync() error = %v", err)
+	}
+
+	// Verify meta was created
+	meta, _ := LoadMetaFile(tmpDir)
+	fileMeta := meta.GetFileMeta("test.md")
+	if fileMeta == nil {
+		t.Fatal("Meta should have entry after sync")
+	}
+	if fileMeta.Summary != "Manual summary" {
+		t.Errorf("Summary = %q, want %q", fileMeta.Summary, "Manual summary")
+	}
+	if len(fileMeta.Tags) != 2 {
+		t.Errorf("Tags length = %d, want 2", len(fileMeta.Tags))
+	}
+}
+
+func TestCmdSyncDryRun(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "test.md", "Content")
+
+	err := CmdSync([]string{"--dry-run"})
+	if err != nil {
+		t.Fatalf("CmdSync() dry run error = %v", err)
+	}
+
+	// Meta should NOT be created in dry run
+	metaPath := filepath.Join(tmpDir, ".meta.json")
+	if _, err := os.Stat(metaPath); !os.IsNotExist(err) {
+		// File might exist but be empty
+		data, _ := os.ReadFile(metaPath)
+		if len(data) > 5 { // more than just "{}"
+			t.Error("Meta should not be created in dry run")
+		}
+	}
+}
+
+func TestCmdSyncCheck(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "test.md", "Content", []string{"old"}, "Summary")
+	notePath := filepath.Join(tmpDir, "test.md")
+
+	if _, err := captureStdout(t, func() error { return CmdSync([]string{"--check"}) }); err != nil {
+		t.Fatalf("CmdSync(--check) on synced notes error = %v", err)
+	}
+
+	// Change the frontmatter behind sync's back
+	note, _ := ParseNote(notePath)
+	note.Frontmatter.Tags = []string{"new"}
+	note.Save(notePath)
+	before, _ := os.ReadFile(filepath.Join(tmpDir, ".meta.json"))
+
+	output, err := captureStdout(t, func() error { return CmdSync([]string{"--check"}) })
+	if err == nil {
+		t.Fatal("CmdSync(--check) should fail when frontmatter changed")
+	}
+	if !strings.Contains(output, "Would update: test.md (tags changed)") {
+		t.Errorf("expected changed note in output, got:\n%s", output)
+	}
+
+	after, _ := os.ReadFile(filepath.Join(tmpDir, ".meta.json"))
+	if string(before) != string(after) {
+		t.Error("--check should not modify .meta.json")
+	}
+}
+
+func TestCmdGraph(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	// Create related notes
+	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"neo"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "Content B", []string{"neo"}, "Summary B")
+
+	// Add relation
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.AddRelation("a.md", "b.md")
+	meta.Save(tmpDir)
+
+	// Test all connections view
+	err := CmdGraph([]string{})
+	if err != nil {
+		t.Fatalf("CmdGraph() error = %v", err)
+	}
+
+	// Test specific note view
+	err = CmdGraph([]string{"a.md"})
+	if err != nil {
+		t.Fatalf("CmdGraph(a.md) error = %v", err)
+	}
+
+	// Test with JSON output
+	err = CmdGraph([]string{"--json"})
+	if err != nil {
+		t.Fatalf("CmdGraph(--json) error = %v", err)
+	}
+}
+
+func TestCmdTags(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"neo", "eval"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "Content B", []string{"neo", "meeting"}, "Summary B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "Content C", []string{"idea"}, "Summary C")
+
+	err := CmdTags([]string{})
+	if err != nil {
+		t.Fatalf("CmdTags() error = %v", err)
+	}
+}
+
+func TestCmdEnrich(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "unenriched.md", "Content needing enrichment")
 
 	err := CmdEnrich([]string{})
 	if err != nil {
-		t.Fatalf("CmdEnrich() error = %v", err)
+		t.Fatalf("CmdEnrich() error = %v", err)
+	}
+}
+
+func TestCmdEnrichAllUpToDate(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "enriched.md", "Already enriched", []string{"tag"}, "Summary")
+
+	err := CmdEnrich([]string{})
+	if err != nil {
+		t.Fatalf("CmdEnrich() error = %v", err)
+	}
+}
+
+func TestCmdEnrichApply(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("--apply requires a POSIX shell")
+	}
+
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "a.md", "First note")
+	createTestNote(t, tmpDir, "b.md", "Second note")
+
+	// The fake model echoes the prompt to a file and answers with fenced JSON
+	promptPath := filepath.Join(t.TempDir(), "prompt.txt")
+	answer := "```json\n" + `{"a.md": {"tags": ["go"], "summary": "First", "related": ["b.md"]}, "ghost.md": {"summary": "x"}}` + "\n```"
+	command := fmt.Sprintf("cat > %s; printf '%%s\\n' '%s'", promptPath, answer)
+
+	output, err := captureStdout(t, func() error {
+		return CmdEnrich([]string{"--apply", command})
+	})
+	if err != nil {
+		t.Fatalf("CmdEnrich(--apply) error = %v", err)
+	}
+
+	prompt, _ := os.ReadFile(promptPath)
+	if !strings.Contains(string(prompt), "First note") {
+		t.Errorf("prompt should include note bodies, got:\n%s", prompt)
+	}
+
+	for _, want := range []string{"Updated a.md", "Skipped ghost.md", "Missing b.md", "Enriched 1 of 2 notes"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+
+	meta, _ := LoadMetaFile(tmpDir)
+	if fm := meta.GetFileMeta("a.md"); fm == nil || fm.Summary != "First" || !stringSliceEqual(fm.Tags, []string{"go"}) {
+		t.Errorf("a.md meta = %+v, want enriched", fm)
+	}
+	note, _ := ParseNote(filepath.Join(tmpDir, "b.md"))
+	if !Contains(note.Frontmatter.Related, "a.md") {
+		t.Errorf("b.md should be related back to a.md, got %v", note.Frontmatter.Related)
+	}
+}
+
+func TestCmdEnrichApplyKeepsConcurrentMetaChanges(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("--apply requires a POSIX shell")
+	}
+
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "a.md", "First note")
+
+	// Another command records c.md while the model is still running
+	metaPath := filepath.Join(tmpDir, ".meta.json")
+	concurrent := `{"files": {"c.md": {"content_hash": "abc", "summary": "C", "tags": [], "related": []}}}`
+	answer := `{"a.md": {"tags": ["go"], "summary": "First"}}`
+	command := fmt.Sprintf("cat >/dev/null; printf '%%s' '%s' > %s; printf '%%s\\n' '%s'", concurrent, metaPath, answer)
+
+	if _, err := captureStdout(t, func() error { return CmdEnrich([]string{"--apply", command}) }); err != nil {
+		t.Fatalf("CmdEnrich(--apply) error = %v", err)
+	}
+
+	meta, _ := LoadMetaFile(tmpDir)
+	if fm := meta.GetFileMeta("c.md"); fm == nil || fm.Summary != "C" {
+		t.Errorf("c.md meta = %+v, want the entry written during enrichment kept", fm)
+	}
+	if fm := meta.GetFileMeta("a.md"); fm == nil || fm.Summary != "First" {
+		t.Errorf("a.md meta = %+v, want enriched", fm)
+	}
+}
+
+func TestCmdEnrichBatchTokens(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	// About 100 tokens each
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		createTestNote(t, tmpDir, name, strings.Repeat("word ", 80))
+	}
+
+	meta, _ := LoadMetaFile(tmpDir)
+	var base strings.Builder
+	writeEnrichPrompt(&base, meta, nil, false)
+	overhead := estimateTokens(base.String())
+
+	// Room for one note per prompt
+	batchDir := filepath.Join(t.TempDir(), "batches")
+	budget := fmt.Sprint(overhead + 150)
+	if err := CmdEnrich([]string{"--batch-tokens", budget, "--batch-dir", batchDir}); err != nil {
+		t.Fatalf("CmdEnrich(--batch-tokens) error = %v", err)
+	}
+
+	entries, _ := os.ReadDir(batchDir)
+	if len(entries) != 3 {
+		t.Fatalf("want 3 batch files, got %d", len(entries))
+	}
+	seen := make(map[string]bool)
+	for i, entry := range entries {
+		if want := fmt.Sprintf("batch-%03d.md", i+1); entry.Name() != want {
+			t.Errorf("batch file %d = %s, want %s", i, entry.Name(), want)
+		}
+		data, _ := os.ReadFile(filepath.Join(batchDir, entry.Name()))
+		for _, name := range []string{"a.md", "b.md", "c.md"} {
+			if strings.Contains(string(data), "- "+name) {
+				if seen[name] {
+					t.Errorf("%s appears in more than one batch", name)
+				}
+				seen[name] = true
+			}
+		}
+		if tokens := estimateTokens(string(data)); tokens > overhead+150 {
+			t.Errorf("%s is about %d tokens, over the budget", entry.Name(), tokens)
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf("every note should be in a batch, got %v", seen)
+	}
+
+	// Printed batches are separated by a delimiter
+	output, err := captureStdout(t, func() error {
+		return CmdEnrich([]string{"--batch-tokens", fmt.Sprint(overhead + 250)})
+	})
+	if err != nil {
+		t.Fatalf("CmdEnrich(--batch-tokens) error = %v", err)
+	}
+	if !strings.Contains(output, "<!-- batch 1 of 2 -->") || !strings.Contains(output, "<!-- batch 2 of 2 -->") {
+		t.Errorf("want 2 delimited batches, got:\n%s", output)
+	}
+
+	if err := CmdEnrich([]string{"--batch-tokens", "10"}); err == nil || !strings.Contains(err.Error(), "too small") {
+		t.Errorf("expected a budget error, got %v", err)
+	}
+}
+
+func TestCmdEnrichApplyInvalidJSON(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("--apply requires a POSIX shell")
+	}
+
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "a.md", "First note")
+
+	_, err := captureStdout(t, func() error {
+		return CmdEnrich([]string{"--apply", "cat >/dev/null; echo '{\"a.md\": {\"tags\": \"go\"}}'"})
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("expected invalid JSON error, got %v", err)
+	}
+}
+
+func TestWatchNotesDebounces(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "existing.md", "Old", []string{"tag"}, "Summary")
+
+	batches := make(chan []string, 10)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- watchNotes(tmpDir, 5*time.Millisecond, 100*time.Millisecond, stop, func(filenames []string) error {
+			batches <- filenames
+			return nil
+		})
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// A burst of saves: two new notes and a content change
+	createTestNote(t, tmpDir, "a.md", "First draft")
+	time.Sleep(10 * time.Millisecond)
+	createTestNote(t, tmpDir, "a.md", "Second draft, longer")
+	createTestNote(t, tmpDir, "b.md", "Another")
+	os.WriteFile(filepath.Join(tmpDir, "existing.md"), []byte("---\ntags: [tag]\n---\nNew body\n"), 0644)
+
+	select {
+	case batch := <-batches:
+		if !stringSliceEqual(batch, []string{"a.md", "b.md", "existing.md"}) {
+			t.Errorf("batch = %v, want [a.md b.md existing.md]", batch)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no batch reported")
+	}
+
+	// Frontmatter-only edits are not content changes
+	note, _ := ParseNote(filepath.Join(tmpDir, "b.md"))
+	note.Frontmatter.Summary = "Enriched"
+	note.Save(filepath.Join(tmpDir, "b.md"))
+
+	select {
+	case batch := <-batches:
+		t.Errorf("unexpected batch after frontmatter edit: %v", batch)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Errorf("watchNotes() error = %v", err)
+	}
+}
+
+func TestEnrichChangedWritesPromptFile(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "new.md", "Fresh idea")
+	createEnrichedTestNote(t, tmpDir, "done.md", "Already enriched", []string{"tag"}, "Summary")
+
+	promptPath := filepath.Join(t.TempDir(), "prompt.md")
+	if err := enrichChanged(tmpDir, []string{"new.md", "done.md"}, "", promptPath); err != nil {
+		t.Fatalf("enrichChanged() error = %v", err)
+	}
+
+	prompt, err := os.ReadFile(promptPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(prompt), "- new.md") || strings.Contains(string(prompt), "- done.md (created") {
+		t.Errorf("prompt should list only notes needing enrichment:\n%s", prompt)
+	}
+}
+
+func TestLoadAllNotesSkipsMalformed(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "good.md", "Valid note")
+	os.WriteFile(filepath.Join(tmpDir, "broken.md"), []byte("---\ntags: [unclosed\n---\nBody\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "readme.txt"), []byte("not a note"), 0644)
+
+	notesList, skipped, err := scanNotesDir(tmpDir)
+	if err != nil {
+		t.Fatalf("scanNotesDir() error = %v", err)
+	}
+
+	if len(notesList) != 1 || filepath.Base(notesList[0].Filename) != "good.md" {
+		t.Errorf("Expected only good.md to be loaded, got %d notes", len(notesList))
+	}
+
+	reasons := make(map[string]SkippedFile)
+	for _, s := range skipped {
+		reasons[s.Name] = s
+	}
+	if s, ok := reasons["broken.md"]; !ok || s.Err == nil || !strings.Contains(s.Reason, "failed to parse") {
+		t.Errorf("broken.md should be skipped with a parse error, got %+v", s)
+	}
+	if s, ok := reasons["readme.txt"]; !ok || s.Err != nil {
+		t.Errorf("readme.txt should be skipped as a non-note, got %+v", s)
+	}
+
+	// Commands keep working around the malformed note
+	Verbose = true
+	defer func() { Verbose = false }()
+
+	output, err := captureStdout(t, func() error {
+		return CmdList([]string{"--raw"})
+	})
+	if err != nil {
+		t.Fatalf("CmdList() error = %v", err)
+	}
+	if strings.TrimSpace(output) != "good.md" {
+		t.Errorf("CmdList() output = %q, want only good.md", output)
+	}
+}
+
+func TestLoadAllNotesKeepsOrder(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	var want []string
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("note-%02d.md", i)
+		if i%10 == 3 {
+			os.WriteFile(filepath.Join(tmpDir, name), []byte("---\ntags: [unclosed\n---\nBody\n"), 0644)
+			continue
+		}
+		createTestNote(t, tmpDir, name, "Content")
+		want = append(want, name)
+	}
+	os.MkdirAll(filepath.Join(tmpDir, "sub"), 0755)
+	createTestNote(t, tmpDir, "sub/a.md", "Content")
+	want = append(want, "sub/a.md")
+
+	notesList, skipped, err := scanNotesDir(tmpDir)
+	if err != nil {
+		t.Fatalf("scanNotesDir() error = %v", err)
+	}
+
+	var got []string
+	for _, note := range notesList {
+		got = append(got, note.Name())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("notes = %v, want %v", got, want)
+	}
+
+	var broken []string
+	for _, s := range skipped {
+		if s.Err != nil {
+			broken = append(broken, s.Name)
+		}
+	}
+	if want := []string{"note-03.md", "note-13.md", "note-23.md", "note-33.md", "note-43.md"}; !reflect.DeepEqual(broken, want) {
+		t.Errorf("broken notes = %v, want %v", broken, want)
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	m := &IgnoreMatcher{}
+	for _, line := range []string{
+		"# scratch files",
+		"scratch-*.md",
+		"drafts/",
+		"/todo.md",
+		"archive/**/old.md",
+		"!scratch-keep.md",
+	} {
+		m.add(line)
+	}
+
+	tests := []struct {
+		name  string
+		isDir bool
+		want  bool
+	}{
+		{"scratch-1.md", false, true},
+		{"work/scratch-2.md", false, true},
+		{"scratch-keep.md", false, false},
+		{"drafts", true, true},
+		{"drafts", false, false},
+		{"todo.md", false, true},
+		{"work/todo.md", false, false},
+		{"archive/old.md", false, true},
+		{"archive/2024/q1/old.md", false, true},
+		{"note.md", false, false},
+		{".meta.json", false, true},
+		{"work/.hidden.md", false, true},
+	}
+	for _, tt := range tests {
+		if got := m.Match(tt.name, tt.isDir); got != tt.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tt.name, tt.isDir, got, tt.want)
+		}
+	}
+
+	if !m.Excludes("drafts/idea.md") {
+		t.Error("Excludes() should ignore notes in an ignored directory")
+	}
+	if m.Excludes("work/plan.md") {
+		t.Error("Excludes() should keep notes that match nothing")
+	}
+}
+
+func TestNotesignore(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "keep.md", "Content", []string{"shared"}, "Keep")
+	createEnrichedTestNote(t, tmpDir, "other.md", "Content", []string{"shared"}, "Other")
+	createEnrichedTestNote(t, tmpDir, "scratch.md", "Content", []string{"shared"}, "Scratch")
+	os.MkdirAll(filepath.Join(tmpDir, "drafts"), 0755)
+	createTestNote(t, tmpDir, "drafts/idea.md", "Content")
+	os.WriteFile(filepath.Join(tmpDir, ".hidden.md"), []byte("Hidden\n"), 0644)
+
+	// Without .notesignore only the dotfile is left out
+	output, _ := captureStdout(t, func() error { return CmdList([]string{"--raw"}) })
+	if output != "drafts/idea.md\nkeep.md\nother.md\nscratch.md\n" {
+		t.Errorf("list without .notesignore = %q", output)
+	}
+
+	os.WriteFile(filepath.Join(tmpDir, IgnoreFile), []byte("scratch.md\ndrafts/\n"), 0644)
+
+	output, _ = captureStdout(t, func() error { return CmdList([]string{"--raw"}) })
+	if output != "keep.md\nother.md\n" {
+		t.Errorf("list should skip ignored notes, got %q", output)
+	}
+
+	// The graph drops entries synced before the note was ignored
+	output, err := captureStdout(t, func() error {
+		return CmdGraph([]string{"--flat", "--json", "--include-tag-edges"})
+	})
+	if err != nil {
+		t.Fatalf("CmdGraph() error = %v", err)
+	}
+	if !strings.Contains(output, "keep.md") || strings.Contains(output, "scratch.md") {
+		t.Errorf("graph should leave out ignored notes:\n%s", output)
+	}
+
+	// Sync removes them from .meta.json
+	output, err = captureStdout(t, func() error { return CmdSync(nil) })
+	if err != nil {
+		t.Fatalf("CmdSync() error = %v", err)
+	}
+	if !strings.Contains(output, "Removed: scratch.md (ignored by .notesignore)") {
+		t.Errorf("sync should remove the ignored note:\n%s", output)
+	}
+	meta, _ := LoadMetaFile(tmpDir)
+	if _, ok := meta.Files["scratch.md"]; ok {
+		t.Error("scratch.md should no longer be in .meta.json")
+	}
+	if _, ok := meta.Files["drafts/idea.md"]; ok {
+		t.Error("notes in an ignored directory should not be synced")
+	}
+}
+
+func TestCmdTagsFromMeta(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"neo", "eval"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "Content B", []string{"Neo"}, "Summary B")
+
+	output, err := captureStdout(t, func() error {
+		return CmdTags([]string{"--from", "meta"})
+	})
+	if err != nil {
+		t.Fatalf("CmdTags(--from meta) error = %v", err)
+	}
+
+	if !strings.Contains(output, "neo (2)") {
+		t.Errorf("Output should count neo twice, got:\n%s", output)
+	}
+	if !strings.Contains(output, "eval (1)") {
+		t.Errorf("Output should count eval once, got:\n%s", output)
+	}
+}
+
+func TestCmdPruneTags(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"common", "oneoff"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"Common"}, "B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "C", []string{"common"}, "C")
+
+	output, err := captureStdout(t, func() error {
+		return CmdPruneTags([]string{"--min", "2", "--dry-run"})
+	})
+	if err != nil {
+		t.Fatalf("CmdPruneTags(--dry-run) error = %v", err)
+	}
+	if !strings.Contains(output, "would prune 1 tags from 1 notes") {
+		t.Errorf("dry run output = %q", output)
+	}
+	if note, _ := ParseNote(filepath.Join(tmpDir, "a.md")); len(note.Frontmatter.Tags) != 2 {
+		t.Fatalf("dry run should not change notes, tags = %v", note.Frontmatter.Tags)
+	}
+
+	if _, err := captureStdout(t, func() error {
+		return CmdPruneTags([]string{"--min", "2"})
+	}); err != nil {
+		t.Fatalf("CmdPruneTags() error = %v", err)
+	}
+
+	note, _ := ParseNote(filepath.Join(tmpDir, "a.md"))
+	if fmt.Sprint(note.Frontmatter.Tags) != "[common]" {
+		t.Errorf("a.md tags = %v, want [common]", note.Frontmatter.Tags)
+	}
+	meta, _ := LoadMetaFile(tmpDir)
+	if tags := meta.GetFileMeta("a.md").Tags; fmt.Sprint(tags) != "[common]" {
+		t.Errorf("a.md meta tags = %v, want [common]", tags)
+	}
+	if note, _ := ParseNote(filepath.Join(tmpDir, "b.md")); fmt.Sprint(note.Frontmatter.Tags) != "[Common]" {
+		t.Errorf("b.md tags = %v, a tag used three times should survive", note.Frontmatter.Tags)
+	}
+}
+
+func TestCmdTagsMerge(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"ml", "machine-learning"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"go", "AI"}, "B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "C", []string{"machine-learning"}, "C")
+	createEnrichedTestNote(t, tmpDir, "d.md", "D", []string{"go"}, "D")
+
+	output, err := captureStdout(t, func() error {
+		return CmdTags([]string{"--merge", "ml,machine-learning", "ai", "--into", "ml", "--dry-run"})
+	})
+	if err != nil {
+		t.Fatalf("CmdTags(--merge --dry-run) error = %v", err)
+	}
+	if !strings.Contains(output, "would merge into ml in 3 notes") {
+		t.Errorf("dry run output = %q", output)
+	}
+	if note, _ := ParseNote(filepath.Join(tmpDir, "c.md")); fmt.Sprint(note.Frontmatter.Tags) != "[machine-learning]" {
+		t.Fatalf("dry run should not change notes, tags = %v", note.Frontmatter.Tags)
+	}
+
+	output, err = captureStdout(t, func() error {
+		return CmdTags([]string{"--merge", "ml,machine-learning,ai", "--into", "ml"})
+	})
+	if err != nil {
+		t.Fatalf("CmdTags(--merge) error = %v", err)
+	}
+	if !strings.Contains(output, "Merged into ml in 3 notes") {
+		t.Errorf("output = %q", output)
+	}
+
+	for file, want := range map[string]string{"a.md": "[ml]", "b.md": "[go ml]", "c.md": "[ml]", "d.md": "[go]"} {
+		if note, _ := ParseNote(filepath.Join(tmpDir, file)); fmt.Sprint(note.Frontmatter.Tags) != want {
+			t.Errorf("%s tags = %v, want %s", file, note.Frontmatter.Tags, want)
+		}
+	}
+
+	// The histogram collapses in both files and meta
+	tagFiles, _ := collectTagsFromFiles(tmpDir)
+	meta, _ := LoadMetaFile(tmpDir)
+	for source, counts := range map[string]map[string][]string{"files": tagFiles, "meta": collectTagsFromMeta(meta)} {
+		if len(counts) != 2 || len(counts["ml"]) != 3 || len(counts["go"]) != 2 {
+			t.Errorf("tags from %s = %v, want ml(3) go(2)", source, counts)
+		}
+	}
+
+	if err := CmdTags([]string{"--merge", "ai"}); err == nil {
+		t.Error("expected an error for --merge without --into")
+	}
+}
+
+func TestCmdTagRename(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"machne-learning", "go"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"Machne-Learning", "machine-learning"}, "B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "C", []string{"go"}, "C")
+
+	output, err := captureStdout(t, func() error {
+		return CmdTag([]string{"rename", "machne-learning", "machine-learning", "--dry-run"})
+	})
+	if err != nil {
+		t.Fatalf("CmdTag(rename --dry-run) error = %v", err)
+	}
+	want := "a.md: go, machne-learning -> go, machine-learning\n" +
+		"b.md: machine-learning, Machne-Learning -> machine-learning\n" +
+		"\nDry run: would rename machne-learning to machine-learning in 2 notes\n"
+	if output != want {
+		t.Errorf("dry run output = %q, want %q", output, want)
+	}
+	if note, _ := ParseNote(filepath.Join(tmpDir, "a.md")); !Contains(note.Frontmatter.Tags, "machne-learning") {
+		t.Fatal("dry run should not change notes")
+	}
+
+	if _, err := captureStdout(t, func() error {
+		return CmdTag([]string{"rename", "MACHNE-LEARNING", "machine-learning"})
+	}); err != nil {
+		t.Fatalf("CmdTag(rename) error = %v", err)
+	}
+
+	meta, _ := LoadMetaFile(tmpDir)
+	for file, want := range map[string]string{"a.md": "[go machine-learning]", "b.md": "[machine-learning]", "c.md": "[go]"} {
+		note, _ := ParseNote(filepath.Join(tmpDir, file))
+		if fmt.Sprint(note.Frontmatter.Tags) != want || fmt.Sprint(meta.GetFileMeta(file).Tags) != want {
+			t.Errorf("%s tags = %v (meta %v), want %s", file, note.Frontmatter.Tags, meta.GetFileMeta(file).Tags, want)
+		}
+	}
+
+	// A rename that changes the sort order keeps .meta.json in sync
+	if _, err := captureStdout(t, func() error { return CmdTag([]string{"rename", "go", "zz"}) }); err != nil {
+		t.Fatalf("CmdTag(rename) error = %v", err)
+	}
+	meta, _ = LoadMetaFile(tmpDir)
+	if got := fmt.Sprint(meta.GetFileMeta("a.md").Tags); got != "[machine-learning zz]" {
+		t.Errorf("a.md meta tags = %s, want them sorted like the frontmatter", got)
+	}
+	if _, err := captureStdout(t, func() error { return CmdSync([]string{"--check"}) }); err != nil {
+		t.Errorf("CmdSync(--check) after rename error = %v", err)
+	}
+
+	if err := CmdTag([]string{"rename", "only-one"}); err == nil {
+		t.Error("expected a usage error")
+	}
+}
+
+func TestCmdTagMerge(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"ML"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"machine-learning"}, "B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "C", []string{"go"}, "C")
+
+	// Both sources on one note collapse into a single target tag
+	note, _ := ParseNote(filepath.Join(tmpDir, "b.md"))
+	note.Frontmatter.Tags = []string{"zeta", "machine-learning", "alpha", "ml"}
+	note.Save(filepath.Join(tmpDir, "b.md"))
+	cPath := filepath.Join(tmpDir, "c.md")
+	before, _ := os.ReadFile(cPath)
+	beforeInfo, _ := os.Stat(cPath)
+
+	output, err := captureStdout(t, func() error {
+		return CmdTag([]string{"merge", "ml", "machine-learning", "--into", "ml"})
+	})
+	if err != nil {
+		t.Fatalf("CmdTag(merge) error = %v", err)
+	}
+	if !strings.Contains(output, "Merged into ml in 2 notes") {
+		t.Errorf("output = %q", output)
+	}
+
+	for file, want := range map[string]string{"a.md": "[ml]", "b.md": "[alpha ml zeta]"} {
+		if note, _ := ParseNote(filepath.Join(tmpDir, file)); fmt.Sprint(note.Frontmatter.Tags) != want {
+			t.Errorf("%s tags = %v, want %s", file, note.Frontmatter.Tags, want)
+		}
+	}
+
+	// Notes without a source tag are left alone
+	after, _ := os.ReadFile(cPath)
+	afterInfo, _ := os.Stat(cPath)
+	if string(after) != string(before) || !afterInfo.ModTime().Equal(beforeInfo.ModTime()) {
+		t.Error("c.md should not be touched")
+	}
+
+	if err := CmdTag([]string{"merge", "ml"}); err == nil {
+		t.Error("expected an error without --into")
+	}
+}
+
+func TestCmdTagsInvalidSource(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if err := CmdTags([]string{"--from", "nowhere"}); err == nil {
+		t.Error("CmdTags() should error for invalid --from value")
+	}
+}
+
+func setupTagBenchmark(b *testing.B, count int) (string, *MetaFile) {
+	tmpDir := b.TempDir()
+	created, _ := time.Parse("2006-01-02 15:04", "2025-01-11 14:23")
+
+	meta := &MetaFile{Files: make(map[string]*FileMeta)}
+	for i := 0; i < count; i++ {
+		note := &Note{
+			Filename: fmt.Sprintf("note-%04d.md", i),
+			Frontmatter: Frontmatter{
+				Created: NoteTime{created},
+				Tags:    []string{"common", fmt.Sprintf("tag-%d", i%10)},
+				Summary: "Benchmark note",
+				Related: []string{},
+			},
+			Content: "\n" + strings.Repeat("Some note content. ", 50) + "\n",
+		}
+		if err := note.Save(filepath.Join(tmpDir, note.Filename)); err != nil {
+			b.Fatal(err)
+		}
+		meta.UpdateFromNote(note)
+	}
+	if err := meta.Save(tmpDir); err != nil {
+		b.Fatal(err)
+	}
+
+	return tmpDir, meta
+}
+
+func BenchmarkCollectTagsFromFiles(b *testing.B) {
+	tmpDir, _ := setupTagBenchmark(b, 500)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resetParseCache()
+		if _, err := collectTagsFromFiles(tmpDir); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCollectTagsFromMeta(b *testing.B) {
+	tmpDir, _ := setupTagBenchmark(b, 500)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		meta, err := LoadMetaFile(tmpDir)
+		if err != nil {
+			b.Fatal(err)
+		}
+		collectTagsFromMeta(meta)
+	}
+}
+
+func TestCmdNewNoFrontmatter(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if err := CmdNew([]string{"--no-frontmatter", "Raw", "fragment"}); err != nil {
+		t.Fatalf("CmdNew(--no-frontmatter) error = %v", err)
+	}
+
+	entries, _ := readNotesDir(tmpDir)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 file, got %d", len(entries))
+	}
+	plain := entries[0].Name()
+	plainPath := filepath.Join(tmpDir, plain)
+
+	data, _ := os.ReadFile(plainPath)
+	if string(data) != "Raw fragment\n" {
+		t.Errorf("File content = %q, want body only", string(data))
+	}
+
+	// Relating another note to it must not add frontmatter as a side effect
+	createTestNote(t, tmpDir, "other.md", "Other content")
+	meta, _ := LoadMetaFile(tmpDir)
+	note, _ := ParseNote(plainPath)
+	meta.UpdateFromNote(note)
+	meta.Save(tmpDir)
+
+	if err := CmdUpdate([]string{"other.md", "--related", plain}); err != nil {
+		t.Fatalf("CmdUpdate() error = %v", err)
+	}
+	data, _ = os.ReadFile(plainPath)
+	if strings.HasPrefix(string(data), "---\n") {
+		t.Error("Plain note should not gain frontmatter from a reverse relation")
+	}
+
+	meta, _ = LoadMetaFile(tmpDir)
+	if !Contains(meta.GetFileMeta(plain).Related, "other.md") {
+		t.Error("Reverse relation should still be recorded in meta")
+	}
+
+	// Enriching the note directly adds frontmatter without changing its hash
+	hash := note.ContentHash()
+	if err := CmdUpdate([]string{plain, "--tags", "fragment"}); err != nil {
+		t.Fatalf("CmdUpdate() error = %v", err)
+	}
+	enriched, _ := ParseNote(plainPath)
+	if !enriched.HasFrontmatter {
+		t.Error("Enriched note should have frontmatter")
+	}
+	if enriched.ContentHash() != hash {
+		t.Error("Adding frontmatter should not change the content hash")
+	}
+}
+
+func TestCmdMetaHistory(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "late.md", "Late", []string{"tag"}, "Late summary")
+	createEnrichedTestNote(t, tmpDir, "early.md", "Early", []string{"tag"}, "Early summary")
+	createTestNote(t, tmpDir, "raw.md", "Not enriched")
+
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.GetFileMeta("early.md").EnrichedAt = time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	meta.GetFileMeta("late.md").EnrichedAt = time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error {
+		return CmdMeta([]string{"--history", "--json"})
+	})
+	if err != nil {
+		t.Fatalf("CmdMeta(--history) error = %v", err)
+	}
+
+	var history HistoryOutput
+	if err := json.Unmarshal([]byte(output), &history); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, output)
+	}
+
+	if len(history.Enriched) != 2 || history.Enriched[0].Filename != "early.md" || history.Enriched[1].Filename != "late.md" {
+		t.Errorf("Enriched = %+v, want early.md then late.md", history.Enriched)
+	}
+	if len(history.NeverEnriched) != 1 || history.NeverEnriched[0] != "raw.md" {
+		t.Errorf("NeverEnriched = %v, want [raw.md]", history.NeverEnriched)
+	}
+
+	// Text output
+	if err := CmdMeta([]string{"--history"}); err != nil {
+		t.Fatalf("CmdMeta(--history) text error = %v", err)
+	}
+}
+
+func TestCmdGraphFlat(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"neo", "eval"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "Content B", []string{"neo"}, "Summary B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "Content C", []string{"idea"}, "Summary C")
+
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.AddRelation("a.md", "b.md")
+	meta.AddRelation("b.md", "c.md")
+	meta.AddRelation("a.md", "c.md")
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error {
+		return CmdGraph([]string{"--flat"})
+	})
+	if err != nil {
+		t.Fatalf("CmdGraph(--flat) error = %v", err)
+	}
+
+	var graph FlatGraph
+	if err := json.Unmarshal([]byte(output), &graph); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, output)
+	}
+
+	seen := make(map[string]bool)
+	for _, node := range graph.Nodes {
+		if seen[node.ID] {
+			t.Errorf("Duplicate node %s", node.ID)
+		}
+		seen[node.ID] = true
+	}
+	if len(graph.Nodes) != 3 {
+		t.Errorf("Expected 3 nodes, got %d", len(graph.Nodes))
+	}
+
+	// Bidirectional relations are a single undirected edge
+	if len(graph.Edges) != 3 {
+		t.Errorf("Expected 3 edges, got %d: %+v", len(graph.Edges), graph.Edges)
+	}
+	for _, edge := range graph.Edges {
+		if edge.Source == "a.md" && edge.Target == "b.md" {
+			if len(edge.SharedTags) != 1 || edge.SharedTags[0] != "neo" {
+				t.Errorf("a.md-b.md shared tags = %v, want [neo]", edge.SharedTags)
+			}
+		}
+	}
+
+	// Depth 0 only includes the root note
+	output, err = captureStdout(t, func() error {
+		return CmdGraph([]string{"--flat", "--depth", "0", "c.md"})
+	})
+	if err != nil {
+		t.Fatalf("CmdGraph(--flat c.md) error = %v", err)
+	}
+	json.Unmarshal([]byte(output), &graph)
+	if len(graph.Nodes) != 1 || len(graph.Edges) != 0 {
+		t.Errorf("Depth 0 should only include the root, got %+v", graph)
+	}
+}
+
+func TestCmdReindex(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	writeNote := func(name, related, body string) {
+		content := fmt.Sprintf("---\ncreated: 2025-01-11 14:23\ntags: []\nsummary: \"\"\nrelated: [%s]\n---\n%s\n", related, body)
+		os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644)
+	}
+	writeNote("a.md", "b.md, gone.md, a.md", "Asymmetric, dangling and self")
+	writeNote("b.md", "", "Declares nothing")
+	writeNote("c.md", "a.md, a.md", "Duplicate, and a link to [[d|the d note]]")
+	writeNote("d.md", "", "Linked only from a body")
+
+	before, _ := os.ReadFile(filepath.Join(tmpDir, "a.md"))
+	output, err := captureStdout(t, func() error { return CmdReindex([]string{"--dry-run"}) })
+	if err != nil {
+		t.Fatalf("CmdReindex(--dry-run) error = %v", err)
+	}
+	after, _ := os.ReadFile(filepath.Join(tmpDir, "a.md"))
+	if string(before) != string(after) {
+		t.Error("--dry-run should not modify notes")
+	}
+	if !strings.Contains(output, "Would update: a.md (+c.md, -gone.md, -a.md)") {
+		t.Errorf("dry run output:\n%s", output)
+	}
+
+	output, err = captureStdout(t, func() error { return CmdReindex(nil) })
+	if err != nil {
+		t.Fatalf("CmdReindex() error = %v", err)
+	}
+	if !strings.Contains(output, "2 asymmetric added, 2 from wikilinks, 1 dangling removed, 1 self-references removed, 1 duplicates removed") {
+		t.Errorf("summary missing from output:\n%s", output)
+	}
+
+	meta, _ := LoadMetaFile(tmpDir)
+	want := map[string][]string{
+		"a.md": {"b.md", "c.md"},
+		"b.md": {"a.md"},
+		"c.md": {"a.md", "d.md"},
+		"d.md": {"c.md"},
+	}
+	for filename, related := range want {
+		note, _ := ParseNote(filepath.Join(tmpDir, filename))
+		if !stringSliceEqual(note.Frontmatter.Related, related) {
+			t.Errorf("%s frontmatter related = %v, want %v", filename, note.Frontmatter.Related, related)
+		}
+		if fm := meta.GetFileMeta(filename); fm == nil || !stringSliceEqual(fm.Related, related) {
+			t.Errorf("%s meta related = %+v, want %v", filename, fm, related)
+		}
+	}
+
+	// A second run has nothing left to fix
+	output, _ = captureStdout(t, func() error { return CmdReindex(nil) })
+	if !strings.Contains(output, "(0 changed)") {
+		t.Errorf("reindex should be idempotent, got:\n%s", output)
+	}
+}
+
+func TestCmdRenameWithTitle(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "2025-01-11-1423.md", "# Old heading\n\nBody text", []string{"neo"}, "Summary")
+	createEnrichedTestNote(t, tmpDir, "other.md", "Other", []string{"neo"}, "Other summary")
+	if err := CmdUpdate([]string{"other.md", "--related", "2025-01-11-1423.md"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := CmdRename([]string{"2025-01-11-1423", "project-plan", "--title", "Project plan"})
+	if err != nil {
+		t.Fatalf("CmdRename() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "2025-01-11-1423.md")); !os.IsNotExist(err) {
+		t.Error("Old file should no longer exist")
+	}
+
+	note, err := ParseNote(filepath.Join(tmpDir, "project-plan.md"))
+	if err != nil {
+		t.Fatalf("Renamed note should exist: %v", err)
+	}
+	if note.Content != "\n# Project plan\n\nBody text\n" {
+		t.Errorf("Content = %q, want replaced heading", note.Content)
+	}
+
+	meta, _ := LoadMetaFile(tmpDir)
+	if meta.GetFileMeta("2025-01-11-1423.md") != nil {
+		t.Error("Old meta entry should be removed")
+	}
+	fileMeta := meta.GetFileMeta("project-plan.md")
+	if fileMeta == nil {
+		t.Fatal("Meta entry should move to the new name")
+	}
+	if fileMeta.ContentHash != note.ContentHash() {
+		t.Error("Content hash should be recomputed after the heading change")
+	}
+
+	other, _ := ParseNote(filepath.Join(tmpDir, "other.md"))
+	if !Contains(other.Frontmatter.Related, "project-plan.md") || Contains(other.Frontmatter.Related, "2025-01-11-1423.md") {
+		t.Errorf("other.md related = %v, want rewritten to project-plan.md", other.Frontmatter.Related)
+	}
+	if !Contains(meta.GetFileMeta("other.md").Related, "project-plan.md") {
+		t.Error("other.md meta should point at the new name")
+	}
+}
+
+func TestResolveFilenameFuzzy(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "2025-01-11-1423.md", "Morning")
+	createTestNote(t, tmpDir, "2025-01-11-1830.md", "Evening")
+	createTestNote(t, tmpDir, "project-plan.md", "Plan")
+
+	for _, c := range []struct{ name, want string }{
+		{"2025-01-11-1423", "2025-01-11-1423.md"}, // exact
+		{"2025-01-11-14", "2025-01-11-1423.md"},   // unique prefix
+		{"PLAN", "project-plan.md"},               // substring, any case
+	} {
+		if got, err := ResolveFilename(tmpDir, c.name); err != nil || got != c.want {
+			t.Errorf("ResolveFilename(%q) = %q, %v; want %q", c.name, got, err, c.want)
+		}
+	}
+
+	if _, err := ResolveFilename(tmpDir, "2025-01-11"); err == nil || !strings.Contains(err.Error(), "matches 2 notes") {
+		t.Errorf("want an ambiguity error, got %v", err)
+	}
+	if _, err := ResolveFilename(tmpDir, "missing"); err == nil || !strings.Contains(err.Error(), "note not found") {
+		t.Errorf("want a not found error, got %v", err)
+	}
+
+	// Commands taking a note accept the short form
+	output, err := captureStdout(t, func() error { return CmdShow([]string{"1830"}) })
+	if err != nil || !strings.Contains(output, "Evening") {
+		t.Errorf("CmdShow(1830) = %q, %v", output, err)
+	}
+	if _, err := captureStdout(t, func() error { return CmdUpdate([]string{"plan", "--tags", "work"}) }); err != nil {
+		t.Fatalf("CmdUpdate(plan) error = %v", err)
+	}
+	if note, _ := ParseNote(filepath.Join(tmpDir, "project-plan.md")); !Contains(note.Frontmatter.Tags, "work") {
+		t.Errorf("project-plan.md tags = %v", note.Frontmatter.Tags)
+	}
+}
+
+func TestSubdirectories(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	for _, dir := range []string{"work", ".templates"} {
+		os.MkdirAll(filepath.Join(tmpDir, dir), 0755)
+	}
+	createEnrichedTestNote(t, tmpDir, "top.md", "Top", []string{"home"}, "Top note")
+	createEnrichedTestNote(t, tmpDir, "work/plan.md", "Plan", []string{"work"}, "Plan")
+	createTestNote(t, tmpDir, ".templates/default.md", "Template")
+	note, _ := ParseNote(filepath.Join(tmpDir, "work/plan.md"))
+	note.Frontmatter.Related = []string{"top.md"}
+	note.Save(filepath.Join(tmpDir, "work/plan.md"))
+
+	output, err := captureStdout(t, func() error { return CmdList([]string{"--raw", "--sort", "none"}) })
+	if err != nil || output != "top.md\nwork/plan.md\n" {
+		t.Errorf("CmdList() = %q, %v; want notes in subdirectories but not hidden ones", output, err)
+	}
+
+	if _, err := captureStdout(t, func() error { return CmdSync(nil) }); err != nil {
+		t.Fatalf("CmdSync() error = %v", err)
+	}
+	meta, _ := LoadMetaFile(tmpDir)
+	if meta.GetFileMeta("work/plan.md") == nil || meta.GetFileMeta("plan.md") != nil {
+		t.Errorf("meta should be keyed by relative path, got %v", meta.Files)
+	}
+	if output, _ := captureStdout(t, func() error { return CmdDiff(nil) }); strings.Contains(output, "plan.md") {
+		t.Errorf("synced note should not be listed by diff:\n%s", output)
+	}
+	if output, _ := captureStdout(t, func() error { return CmdTags(nil) }); !strings.Contains(output, "work") {
+		t.Errorf("CmdTags() should count tags in subdirectories:\n%s", output)
+	}
+
+	// Moving a note rewrites relations to it, in frontmatter and meta
+	if _, err := captureStdout(t, func() error { return CmdMove([]string{"top", "personal"}) }); err != nil {
+		t.Fatalf("CmdMove() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "personal", "top.md")); err != nil {
+		t.Fatalf("top.md should be in personal/: %v", err)
+	}
+	note, _ = ParseNote(filepath.Join(tmpDir, "work/plan.md"))
+	meta, _ = LoadMetaFile(tmpDir)
+	if fmt.Sprint(note.Frontmatter.Related) != "[personal/top.md]" || fmt.Sprint(meta.GetFileMeta("work/plan.md").Related) != "[personal/top.md]" {
+		t.Errorf("related = %v / %v, want [personal/top.md]", note.Frontmatter.Related, meta.GetFileMeta("work/plan.md").Related)
+	}
+	if meta.GetFileMeta("personal/top.md") == nil || meta.GetFileMeta("top.md") != nil {
+		t.Error("the meta entry should move with the note")
+	}
+
+	// Back to the top level
+	if _, err := captureStdout(t, func() error { return CmdMove([]string{"work/plan", "."}) }); err != nil {
+		t.Fatalf("CmdMove(.) error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "plan.md")); err != nil {
+		t.Errorf("plan.md should be at the top level: %v", err)
+	}
+
+	for _, folder := range []string{"..", "../elsewhere", ".hidden", "."} {
+		if err := CmdMove([]string{"plan.md", folder}); err == nil {
+			t.Errorf("CmdMove(plan.md, %s) should fail", folder)
+		}
+	}
+}
+
+func TestResolveNoteByIDAcrossRename(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if _, err := captureStdout(t, func() error { return CmdNew([]string{"--id", "proj-42", "Stable note"}) }); err != nil {
+		t.Fatalf("CmdNew(--id) error = %v", err)
+	}
+	entries, _ := readNotesDir(tmpDir)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 file, got %d", len(entries))
+	}
+	original := entries[0].Name()
+
+	filename, err := ResolveNote(tmpDir, "proj-42")
+	if err != nil || filename != original {
+		t.Fatalf("ResolveNote(proj-42) = %q, %v; want %q", filename, err, original)
+	}
+
+	if _, err := captureStdout(t, func() error { return CmdRename([]string{original, "renamed"}) }); err != nil {
+		t.Fatalf("CmdRename() error = %v", err)
+	}
+
+	filename, err = ResolveNote(tmpDir, "proj-42")
+	if err != nil || filename != "renamed.md" {
+		t.Errorf("ResolveNote(proj-42) after rename = %q, %v; want renamed.md", filename, err)
+	}
+
+	output, err := captureStdout(t, func() error { return CmdShow([]string{"proj-42"}) })
+	if err != nil || !strings.Contains(output, "Stable note") {
+		t.Errorf("CmdShow(proj-42) = %q, %v", output, err)
+	}
+
+	// Ids are unique
+	if err := CmdNew([]string{"--id", "proj-42", "Another"}); err == nil {
+		t.Error("CmdNew should refuse an id that is already in use")
+	}
+
+	// Ids YAML would read as something else are kept too
+	if _, err := captureStdout(t, func() error { return CmdNew([]string{"--id", "null", "Null note"}) }); err != nil {
+		t.Fatalf("CmdNew(--id null) error = %v", err)
+	}
+	if _, err := ResolveNote(tmpDir, "null"); err != nil {
+		t.Errorf("ResolveNote(null) error = %v", err)
+	}
+	if err := CmdNew([]string{"--id", "null", "Another"}); err == nil {
+		t.Error("CmdNew should refuse a second note with id null")
+	}
+}
+
+func TestCmdNewWarnsOnDuplicateContent(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if _, err := captureStdout(t, func() error { return CmdNew([]string{"Pasted", "twice"}) }); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := readNotesDir(tmpDir)
+	original := entries[0].Name()
+
+	err := CmdNew([]string{"Pasted", "twice"})
+	if err == nil || !strings.Contains(err.Error(), "identical content already exists: "+original) {
+		t.Fatalf("CmdNew() with duplicate content error = %v, want warning naming %s", err, original)
+	}
+	if entries, _ := readNotesDir(tmpDir); len(entries) != 1 {
+		t.Errorf("duplicate should not be created, got %d files", len(entries))
+	}
+
+	// Also found through .meta.json once synced
+	captureStdout(t, func() error { return CmdSync(nil) })
+	if err := CmdNew([]string{"Pasted", "twice"}); err == nil {
+		t.Error("CmdNew() should detect duplicates recorded in .meta.json")
+	}
+
+	t.Setenv("EDITOR", writeFakeEditor(t, "", 0))
+	if err := CmdNew([]string{"--open-existing", "Pasted", "twice"}); err != nil {
+		t.Errorf("CmdNew(--open-existing) error = %v", err)
+	}
+
+	if _, err := captureStdout(t, func() error { return CmdNew([]string{"--force", "Pasted", "twice"}) }); err != nil {
+		t.Fatalf("CmdNew(--force) error = %v", err)
+	}
+	notesList, _ := LoadAllNotes(tmpDir)
+	if len(notesList) != 2 {
+		t.Errorf("--force should create the duplicate, got %d notes", len(notesList))
+	}
+}
+
+func TestAppendToDaily(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	morning := time.Date(2025, 1, 11, 9, 5, 0, 0, time.Local)
+	afternoon := time.Date(2025, 1, 11, 13, 30, 0, 0, time.Local)
+
+	captureStdout(t, func() error { return appendToDaily(tmpDir, "First capture", morning, false) })
+	captureStdout(t, func() error { return appendToDaily(tmpDir, "Second capture", afternoon, false) })
+
+	entries, _ := readNotesDir(tmpDir)
+	if len(entries) != 1 || entries[0].Name() != "2025-01-11.md" {
+		t.Fatalf("want a single daily note, got %v", entries)
+	}
+
+	note, err := ParseNote(filepath.Join(tmpDir, "2025-01-11.md"))
+	if err != nil {
+		t.Fatalf("failed to parse daily note: %v", err)
+	}
+	want := "\n# 2025-01-11\n\n## 09:05\n\nFirst capture\n\n## 13:30\n\nSecond capture\n"
+	if note.Content != want {
+		t.Errorf("Content = %q, want %q", note.Content, want)
+	}
+	if note.Frontmatter.ID == "" {
+		t.Error("daily note should get an id when created")
+	}
+
+	// The env default routes plain 'notes new' to today's note
+	t.Setenv("NOTES_APPEND_TO_DAILY", "1")
+	output, err := captureStdout(t, func() error { return CmdNew([]string{"Third capture"}) })
+	if err != nil {
+		t.Fatalf("CmdNew() error = %v", err)
+	}
+	if !strings.Contains(output, "Appended to") {
+		t.Errorf("output = %q, want an append", output)
+	}
+}
+
+func TestCmdToday(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	for _, text := range []string{"First entry", "Second entry\nwith two lines"} {
+		if _, err := captureStdout(t, func() error { return CmdToday([]string{text}) }); err != nil {
+			t.Fatalf("CmdToday(%q) error = %v", text, err)
+		}
+	}
+
+	notePath := filepath.Join(tmpDir, dailyFilename(time.Now()))
+	note, err := ParseNote(notePath)
+	if err != nil {
+		t.Fatalf("failed to parse today's note: %v", err)
+	}
+	want := regexp.MustCompile(`^\n# \d{4}-\d{2}-\d{2}\n\n- \d{2}:\d{2} First entry\n- \d{2}:\d{2} Second entry\n  with two lines\n$`)
+	if !want.MatchString(note.Content) {
+		t.Errorf("Content = %q", note.Content)
+	}
+	if note.Frontmatter.ID == "" {
+		t.Error("today's note should get an id when created")
+	}
+
+	// Without content, the note is opened in the editor
+	t.Setenv("EDITOR", writeFakeEditor(t, "Written in the editor", 0))
+	if err := CmdToday(nil); err != nil {
+		t.Fatalf("CmdToday() error = %v", err)
+	}
+	data, _ := os.ReadFile(notePath)
+	if !strings.HasSuffix(string(data), "with two lines\nWritten in the editor\n") {
+		t.Errorf("expected the editor to edit today's note, got:\n%s", data)
+	}
+}
+
+func TestAppendBullet(t *testing.T) {
+	tests := []struct {
+		content, want string
+	}{
+		{"\n# Day\n", "\n# Day\n\n- x\n"},
+		{"\n# Day\n\n- a\n\n", "\n# Day\n\n- a\n- x\n"},
+		{"\n- a\n  more\n", "\n- a\n  more\n- x\n"},
+		{"\n## 09:05\n\nCapture\n", "\n## 09:05\n\nCapture\n\n- x\n"},
+	}
+	for _, tt := range tests {
+		if got := appendBullet(tt.content, "- x"); got != tt.want {
+			t.Errorf("appendBullet(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestCmdAppend(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "First line", []string{"neo"}, "Summary")
+	notePath := filepath.Join(tmpDir, "a.md")
+
+	if _, err := captureStdout(t, func() error { return CmdAppend([]string{"a", "Second", "line"}) }); err != nil {
+		t.Fatalf("CmdAppend() error = %v", err)
+	}
+
+	// Content piped to stdin
+	stdin, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin.WriteString("Third line\nFourth line\n")
+	stdin.Seek(0, io.SeekStart)
+	origStdin := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = origStdin }()
+	if _, err := captureStdout(t, func() error { return CmdAppend([]string{"a.md"}) }); err != nil {
+		t.Fatalf("CmdAppend() from stdin error = %v", err)
+	}
+
+	note, err := ParseNote(notePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\nFirst line\nSecond line\nThird line\nFourth line\n"; note.Content != want {
+		t.Errorf("Content = %q, want %q", note.Content, want)
+	}
+	if !stringSliceEqual(note.Frontmatter.Tags, []string{"neo"}) || note.Frontmatter.Summary != "Summary" {
+		t.Errorf("frontmatter changed: %+v", note.Frontmatter)
+	}
+
+	meta, _ := LoadMetaFile(tmpDir)
+	if !meta.NeedsEnrichment("a.md", note.ContentHash()) {
+		t.Error("an appended note should need enrichment again")
+	}
+
+	if err := CmdAppend([]string{"a.md", "  "}); err == nil {
+		t.Error("expected an error for empty content")
+	}
+}
+
+func TestCmdNewGeneratesID(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if _, err := captureStdout(t, func() error { return CmdNew([]string{"Note"}) }); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := readNotesDir(tmpDir)
+	note, err := ParseNote(filepath.Join(tmpDir, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.Frontmatter.ID == "" {
+		t.Error("new notes should get a generated id")
+	}
+}
+
+func TestCmdMoveTo(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	workDir := t.TempDir()
+	t.Setenv("NOTES_NOTEBOOKS", "work="+workDir)
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Wrong notebook", []string{"neo"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "Stays", []string{"neo"}, "Summary B")
+	if _, err := captureStdout(t, func() error { return CmdRelate([]string{"a.md", "b.md"}) }); err != nil {
+		t.Fatal(err)
+	}
+
+	// Copy keeps the source
+	if _, err := captureStdout(t, func() error { return CmdMoveTo([]string{"--copy", "work", "a"}) }); err != nil {
+		t.Fatalf("CmdMoveTo(--copy) error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "a.md")); err != nil {
+		t.Error("--copy should keep the source note")
+	}
+	os.Remove(filepath.Join(workDir, "a.md"))
+	os.Remove(filepath.Join(workDir, ".meta.json"))
+
+	output, err := captureStdout(t, func() error { return CmdMoveTo([]string{"work", "a.md"}) })
+	if err != nil {
+		t.Fatalf("CmdMoveTo() error = %v", err)
+	}
+	if !strings.Contains(output, "Removed backlink in b.md\n") || !strings.Contains(output, "Moved a.md") {
+		t.Errorf("unexpected output: %s", output)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "a.md")); !os.IsNotExist(err) {
+		t.Error("source note should be removed")
+	}
+	moved, err := ParseNote(filepath.Join(workDir, "a.md"))
+	if err != nil || !strings.Contains(moved.Content, "Wrong notebook") {
+		t.Fatalf("destination note = %v, %v", moved, err)
+	}
+
+	srcMeta, _ := LoadMetaFile(tmpDir)
+	if srcMeta.GetFileMeta("a.md") != nil {
+		t.Error("source .meta.json should no longer list a.md")
+	}
+	dstMeta, _ := LoadMetaFile(workDir)
+	fm := dstMeta.GetFileMeta("a.md")
+	if fm == nil || fm.Summary != "Summary A" || fm.EnrichedAt.IsZero() {
+		t.Errorf("destination meta = %+v, want enriched entry", fm)
+	}
+
+	// Relations to the moved note are dropped in the source notebook
+	b, _ := ParseNote(filepath.Join(tmpDir, "b.md"))
+	if Contains(b.Frontmatter.Related, "a.md") || Contains(srcMeta.GetFileMeta("b.md").Related, "a.md") {
+		t.Errorf("b.md should no longer relate to a.md: frontmatter %v, meta %v", b.Frontmatter.Related, srcMeta.GetFileMeta("b.md").Related)
+	}
+
+	// The move can be undone in the source notebook
+	if _, err := captureStdout(t, func() error { return CmdUndo(nil) }); err != nil {
+		t.Fatalf("CmdUndo() after move-to error = %v", err)
+	}
+	b, _ = ParseNote(filepath.Join(tmpDir, "b.md"))
+	if _, err := os.Stat(filepath.Join(tmpDir, "a.md")); err != nil || !Contains(b.Frontmatter.Related, "a.md") {
+		t.Errorf("undo should bring back a.md and the backlink in b.md: %v, %v", err, b.Frontmatter.Related)
+	}
+	snaps, _ := loadSnapshots(workDir)
+	if len(snaps) == 0 || snaps[0].Command != "move-to" {
+		t.Error("the destination notebook should have its own move-to snapshot")
+	}
+	os.Remove(filepath.Join(workDir, "a.md"))
+	os.Remove(filepath.Join(workDir, ".meta.json"))
+
+	if err := CmdMoveTo([]string{"work", "b.md", "--copy"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CmdMoveTo([]string{"work", "b.md"}); err == nil {
+		t.Error("CmdMoveTo should refuse to overwrite a note in the destination")
+	}
+	if err := CmdMoveTo([]string{"nowhere", "b.md"}); err == nil {
+		t.Error("CmdMoveTo should error for an unknown notebook")
+	}
+}
+
+func TestCmdRenameRewritesRelations(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "2025-01-11-1423.md", "Plan", []string{"neo"}, "Plan")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"neo"}, "B")
+	captureStdout(t, func() error { return CmdRelate([]string{"2025-01-11-1423.md", "b.md"}) })
+
+	// A hand-written relation without the extension
+	note, _ := ParseNote(filepath.Join(tmpDir, "b.md"))
+	note.Frontmatter.Related = []string{"2025-01-11-1423"}
+	note.Save(filepath.Join(tmpDir, "b.md"))
+
+	before, _ := LoadMetaFile(tmpDir)
+	before.GetFileMeta("2025-01-11-1423.md").EnrichedAt = time.Date(2025, 1, 12, 8, 0, 0, 0, time.UTC)
+	before.Save(tmpDir)
+	entry := *before.GetFileMeta("2025-01-11-1423.md")
+
+	if _, err := captureStdout(t, func() error { return CmdRename([]string{"2025-01-11-1423", "project-plan"}) }); err != nil {
+		t.Fatalf("CmdRename() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "2025-01-11-1423.md")); !os.IsNotExist(err) {
+		t.Error("old file should be gone")
+	}
+	meta, _ := LoadMetaFile(tmpDir)
+	if meta.GetFileMeta("2025-01-11-1423.md") != nil {
+		t.Error("old meta entry should be gone")
+	}
+	moved := meta.GetFileMeta("project-plan.md")
+	if moved == nil || moved.ContentHash != entry.ContentHash || !moved.EnrichedAt.Equal(entry.EnrichedAt) {
+		t.Errorf("moved entry = %+v, want hash and enrichment time kept from %+v", moved, entry)
+	}
+
+	if related := meta.GetFileMeta("b.md").Related; fmt.Sprint(related) != "[project-plan.md]" {
+		t.Errorf("b.md meta related = %v", related)
+	}
+	note, _ = ParseNote(filepath.Join(tmpDir, "b.md"))
+	if fmt.Sprint(note.Frontmatter.Related) != "[project-plan.md]" {
+		t.Errorf("b.md frontmatter related = %v", note.Frontmatter.Related)
+	}
+}
+
+func TestCmdRenameRefusesOverwrite(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "a.md", "A")
+	createTestNote(t, tmpDir, "b.md", "B")
+
+	if err := CmdRename([]string{"a.md", "b.md"}); err == nil {
+		t.Error("CmdRename() should refuse to overwrite an existing note")
+	}
+}
+
+func TestCmdClean(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "messy.md", "Line one   \n\n\n\n\nLine two\t\n\n", []string{"tag"}, "Summary")
+	createEnrichedTestNote(t, tmpDir, "tidy.md", "Already tidy", []string{"tag"}, "Summary")
+	messyPath := filepath.Join(tmpDir, "messy.md")
+	before, _ := os.ReadFile(messyPath)
+
+	// Dry run leaves the file untouched
+	if err := CmdClean([]string{"--all", "--dry-run"}); err != nil {
+		t.Fatalf("CmdClean(--dry-run) error = %v", err)
+	}
+	after, _ := os.ReadFile(messyPath)
+	if string(before) != string(after) {
+		t.Error("Dry run should not modify notes")
+	}
+
+	if err := CmdClean([]string{"--all"}); err != nil {
+		t.Fatalf("CmdClean(--all) error = %v", err)
+	}
+
+	note, _ := ParseNote(messyPath)
+	if note.Content != "\nLine one\n\nLine two\n" {
+		t.Errorf("Content = %q, want normalized body", note.Content)
+	}
+
+	meta, _ := LoadMetaFile(tmpDir)
+	if meta.NeedsEnrichment("messy.md", note.ContentHash()) {
+		t.Error("Cleaning an enriched note should refresh its content hash")
+	}
+}
+
+func TestCmdMetaDiff(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"neo", "eval"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "Content B", []string{"NEO", "meeting"}, "Summary B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "Content C", []string{}, "Summary C")
+
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.AddRelation("a.md", "c.md")
+	meta.AddRelation("b.md", "c.md")
+	meta.AddRelation("a.md", "b.md")
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error {
+		return CmdMeta([]string{"a.md", "--diff", "b", "--json"})
+	})
+	if err != nil {
+		t.Fatalf("CmdMeta(--diff) error = %v", err)
+	}
+
+	var diff MetaDiff
+	if err := json.Unmarshal([]byte(output), &diff); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, output)
+	}
+
+	if !stringSliceEqual(diff.TagsOnlyInA, []string{"eval"}) {
+		t.Errorf("TagsOnlyInA = %v, want [eval]", diff.TagsOnlyInA)
+	}
+	if !stringSliceEqual(diff.TagsOnlyInB, []string{"meeting"}) {
+		t.Errorf("TagsOnlyInB = %v, want [meeting]", diff.TagsOnlyInB)
+	}
+	if !stringSliceEqual(diff.SharedTags, []string{"neo"}) {
+		t.Errorf("SharedTags = %v, want [neo]", diff.SharedTags)
+	}
+	if diff.SummaryA != "Summary A" || diff.SummaryB != "Summary B" {
+		t.Errorf("Summaries = %q, %q", diff.SummaryA, diff.SummaryB)
+	}
+	if !stringSliceEqual(diff.SharedRelated, []string{"c.md"}) {
+		t.Errorf("SharedRelated = %v, want [c.md]", diff.SharedRelated)
+	}
+	if !stringSliceEqual(diff.RelatedOnlyInA, []string{"b.md"}) || !stringSliceEqual(diff.RelatedOnlyInB, []string{"a.md"}) {
+		t.Errorf("Related only = %v / %v", diff.RelatedOnlyInA, diff.RelatedOnlyInB)
+	}
+
+	// Text output
+	output, err = captureStdout(t, func() error {
+		return CmdMeta([]string{"a.md", "--diff", "b.md"})
+	})
+	if err != nil {
+		t.Fatalf("CmdMeta(--diff) text error = %v", err)
+	}
+	if !strings.Contains(output, "Tags only in a.md:") || !strings.Contains(output, "eval") {
+		t.Errorf("Unexpected text output:\n%s", output)
+	}
+}
+
+// writeFakeEditor creates an editor script that appends text to the file it
+// is given and exits with the given code
+func writeFakeEditor(t *testing.T, text string, exitCode int) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake editor script requires a POSIX shell")
+	}
+
+	script := filepath.Join(t.TempDir(), "editor.sh")
+	body := "#!/bin/sh\n"
+	if text != "" {
+		body += fmt.Sprintf("printf '%%s\\n' %q >> \"$1\"\n", text)
+	}
+	body += fmt.Sprintf("exit %d\n", exitCode)
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestCmdEditDiffAfter(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Line one\nLine two", []string{"tag"}, "Summary")
+	t.Setenv("EDITOR", writeFakeEditor(t, "Added line", 0))
+
+	output, err := captureStdout(t, func() error { return CmdEdit([]string{"a.md", "--diff-after"}) })
+	if err != nil {
+		t.Fatalf("CmdEdit(--diff-after) error = %v", err)
+	}
+
+	for _, want := range []string{"--- a/a.md\n+++ b/a.md\n", " Line two\n+Added line\n", "Content hash changed", "will now flag this note"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+
+	// An editor that saves without changes
+	t.Setenv("EDITOR", writeFakeEditor(t, "", 0))
+	output, err = captureStdout(t, func() error { return CmdEdit([]string{"a.md", "--diff-after"}) })
+	if err != nil {
+		t.Fatalf("CmdEdit(--diff-after) error = %v", err)
+	}
+	if !strings.HasPrefix(output, "Body unchanged") {
+		t.Errorf("output = %q, want body unchanged", output)
+	}
+}
+
+func TestCmdNewEditorFailurePreservesDraft(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	draftDir := t.TempDir()
+	t.Setenv("TMPDIR", draftDir)
+	t.Setenv("EDITOR", writeFakeEditor(t, "Important thoughts", 1))
+
+	if err := CmdNew([]string{}); err == nil {
+		t.Fatal("CmdNew() should report the editor failure")
+	}
+
+	entries, _ := readNotesDir(tmpDir)
+	if len(entries) != 0 {
+		t.Errorf("No note should be created on editor failure, got %d files", len(entries))
+	}
+
+	drafts, _ := filepath.Glob(filepath.Join(draftDir, "notes-draft-*.md"))
+	if len(drafts) != 1 {
+		t.Fatalf("Expected the draft to be preserved, got %v", drafts)
+	}
+	data, _ := os.ReadFile(drafts[0])
+	if !strings.Contains(string(data), "Important thoughts") {
+		t.Errorf("Draft should keep the written content, got:\n%s", data)
+	}
+}
+
+func TestCmdNewEditorFailureWithoutContent(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	draftDir := t.TempDir()
+	t.Setenv("TMPDIR", draftDir)
+	t.Setenv("EDITOR", writeFakeEditor(t, "", 1))
+
+	if err := CmdNew([]string{}); err == nil {
+		t.Fatal("CmdNew() should report the editor failure")
+	}
+
+	entries, _ := readNotesDir(tmpDir)
+	drafts, _ := filepath.Glob(filepath.Join(draftDir, "notes-draft-*.md"))
+	if len(entries) != 0 || len(drafts) != 0 {
+		t.Errorf("Empty drafts should be cleaned up, got %d notes and %v", len(entries), drafts)
+	}
+}
+
+func TestCmdNewEditorSuccess(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	draftDir := t.TempDir()
+	t.Setenv("TMPDIR", draftDir)
+	t.Setenv("EDITOR", writeFakeEditor(t, "Written in editor", 0))
+
+	if err := CmdNew([]string{}); err != nil {
+		t.Fatalf("CmdNew() error = %v", err)
+	}
+
+	entries, _ := readNotesDir(tmpDir)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 note, got %d", len(entries))
+	}
+	note, err := ParseNote(filepath.Join(tmpDir, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(note.Content) != "Written in editor" {
+		t.Errorf("Content = %q, want editor text", note.Content)
+	}
+
+	drafts, _ := filepath.Glob(filepath.Join(draftDir, "notes-draft-*.md"))
+	if len(drafts) != 0 {
+		t.Errorf("Draft should be removed after success, got %v", drafts)
+	}
+}
+
+func TestCreateNoteFileSkipsDanglingSymlink(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 1, 11, 14, 23, 0, 0, time.Local)
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "2025-01-11-1423.md")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	path, err := createNoteFile(dir, now, []byte("Body\n"))
+	if err != nil {
+		t.Fatalf("createNoteFile() error = %v", err)
+	}
+	if filepath.Base(path) != "2025-01-11-1423-1.md" {
+		t.Errorf("createNoteFile() = %s, want the next free name", path)
+	}
+}
+
+func TestCmdNewEditorKeepsConcurrentNote(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake editor script requires a POSIX shell")
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+
+	// While the editor is open, another session saves a note under the
+	// name this one would pick (either minute, in case the clock ticks over)
+	start := time.Now()
+	var others []string
+	for _, at := range []time.Time{start, start.Add(time.Minute)} {
+		others = append(others, filepath.Join(tmpDir, at.Format("2006-01-02-1504")+".md"))
+	}
+	script := filepath.Join(t.TempDir(), "editor.sh")
+	body := "#!/bin/sh\nprintf 'Mine\\n' >> \"$1\"\n"
+	for _, other := range others {
+		body += fmt.Sprintf("printf 'Other session\\n' > %q\n", other)
+	}
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EDITOR", script)
+
+	output, err := captureStdout(t, func() error { return CmdNew(nil) })
+	if err != nil {
+		t.Fatalf("CmdNew() error = %v", err)
+	}
+
+	for _, other := range others {
+		if data, _ := os.ReadFile(other); string(data) != "Other session\n" {
+			t.Errorf("%s was overwritten: %q", filepath.Base(other), data)
+		}
+	}
+	created := strings.TrimSpace(strings.TrimPrefix(output, "Created "))
+	if !strings.HasSuffix(created, "-1.md") {
+		t.Errorf("CmdNew() output = %q, want the next free name", output)
+	}
+	if note, err := ParseNote(created); err != nil || strings.TrimSpace(note.Content) != "Mine" {
+		t.Errorf("new note = %+v, %v", note, err)
+	}
+}
+
+func TestCmdGraphRootTag(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"theme"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "Content B", []string{"Theme"}, "Summary B")
+	createEnrichedTestNote(t, tmpDir, "shared.md", "Shared", []string{"other"}, "Shared neighbor")
+	createEnrichedTestNote(t, tmpDir, "far.md", "Far", []string{"other"}, "Two hops away")
+	createEnrichedTestNote(t, tmpDir, "unrelated.md", "Unrelated", []string{"other"}, "Not connected")
+
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.AddRelation("a.md", "shared.md")
+	meta.AddRelation("b.md", "shared.md")
+	meta.AddRelation("shared.md", "far.md")
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error {
+		return CmdGraph([]string{"--root-tag", "theme", "--depth", "1", "--flat"})
+	})
+	if err != nil {
+		t.Fatalf("CmdGraph(--root-tag) error = %v", err)
+	}
+
+	var graph FlatGraph
+	if err := json.Unmarshal([]byte(output), &graph); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, output)
+	}
+
+	var ids []string
+	for _, node := range graph.Nodes {
+		ids = append(ids, node.ID)
+	}
+	if !stringSliceEqual(ids, []string{"a.md", "b.md", "shared.md"}) {
+		t.Errorf("Nodes = %v, want [a.md b.md shared.md]", ids)
+	}
+	if len(graph.Edges) != 2 {
+		t.Errorf("Expected 2 edges, got %+v", graph.Edges)
+	}
+
+	// Text output expands the shared neighbor only once
+	output, err = captureStdout(t, func() error {
+		return CmdGraph([]string{"--root-tag", "theme"})
+	})
+	if err != nil {
+		t.Fatalf("CmdGraph(--root-tag) text error = %v", err)
+	}
+	if strings.Count(output, "far.md") != 1 {
+		t.Errorf("far.md should appear once, got:\n%s", output)
+	}
+	if strings.Contains(output, "unrelated.md") {
+		t.Errorf("unrelated.md should not appear, got:\n%s", output)
+	}
+
+	if err := CmdGraph([]string{"--root-tag", "missing"}); err == nil {
+		t.Error("CmdGraph() should error when no notes carry the tag")
+	}
+}
+
+func TestCmdUpdateFromStdin(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "a.md", "Content A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "Content B", []string{"tag"}, "Summary B")
+
+	input := `# piped from a tool
+tags=neo, eval
+summary=Quotes "and" commas, even = signs
+
+related=b.md
+`
+	stdinFile := filepath.Join(t.TempDir(), "stdin")
+	os.WriteFile(stdinFile, []byte(input), 0644)
+	f, err := os.Open(stdinFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	oldStdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = oldStdin }()
+
+	if err := CmdUpdate([]string{"a.md", "--from-stdin"}); err != nil {
+		t.Fatalf("CmdUpdate(--from-stdin) error = %v", err)
+	}
+
+	note, _ := ParseNote(filepath.Join(tmpDir, "a.md"))
+	if !stringSliceEqual(note.Frontmatter.Tags, []string{"eval", "neo"}) {
+		t.Errorf("Tags = %v, want [eval neo]", note.Frontmatter.Tags)
+	}
+	if note.Frontmatter.Summary != `Quotes "and" commas, even = signs` {
+		t.Errorf("Summary = %q", note.Frontmatter.Summary)
+	}
+
+	meta, _ := LoadMetaFile(tmpDir)
+	if !Contains(meta.GetFileMeta("b.md").Related, "a.md") {
+		t.Error("Relation from stdin should be bidirectional")
+	}
+
+	// Empty values clear the field; missing ones keep it
+	os.WriteFile(stdinFile, []byte("tags=\nrelated=\n"), 0644)
+	f.Seek(0, io.SeekStart)
+	if err := CmdUpdate([]string{"a.md", "--from-stdin"}); err != nil {
+		t.Fatalf("CmdUpdate(--from-stdin) clearing error = %v", err)
+	}
+	note, _ = ParseNote(filepath.Join(tmpDir, "a.md"))
+	if len(note.Frontmatter.Tags) != 0 || len(note.Frontmatter.Related) != 0 {
+		t.Errorf("tags = %v, related = %v, want both cleared", note.Frontmatter.Tags, note.Frontmatter.Related)
+	}
+	if note.Frontmatter.Summary != `Quotes "and" commas, even = signs` {
+		t.Errorf("Summary = %q, want it kept", note.Frontmatter.Summary)
+	}
+	meta, _ = LoadMetaFile(tmpDir)
+	if len(meta.GetFileMeta("a.md").Tags) != 0 || Contains(meta.GetFileMeta("b.md").Related, "a.md") {
+		t.Errorf("meta tags = %v, b.md related = %v, want cleared", meta.GetFileMeta("a.md").Tags, meta.GetFileMeta("b.md").Related)
+	}
+
+	if err := CmdUpdate([]string{"b.md", "--tags", ""}); err != nil {
+		t.Fatalf("CmdUpdate(--tags \"\") error = %v", err)
+	}
+	if note, _ := ParseNote(filepath.Join(tmpDir, "b.md")); len(note.Frontmatter.Tags) != 0 {
+		t.Errorf("--tags \"\" left tags %v", note.Frontmatter.Tags)
+	}
+}
+
+func TestReadUpdateFieldsRejectsUnknownKey(t *testing.T) {
+	if _, err := readUpdateFields(strings.NewReader("title=nope\n")); err == nil {
+		t.Error("readUpdateFields() should reject unknown keys")
+	}
+	if _, err := readUpdateFields(strings.NewReader("no separator\n")); err == nil {
+		t.Error("readUpdateFields() should reject lines without =")
+	}
+}
+
+func TestCmdListJSON(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	for i, created := range []string{"2025-01-10 09:00", "2025-01-12 09:00", "2025-01-11 09:00"} {
+		at, _ := time.Parse("2006-01-02 15:04", created)
+		note := &Note{
+			Filename:    fmt.Sprintf("n%d.md", i),
+			Frontmatter: Frontmatter{Created: NoteTime{at}, Summary: fmt.Sprintf("Note %d", i)},
+			Content:     "\nBody\n",
+		}
+		if i > 0 {
+			note.Frontmatter.Tags = []string{"neo"}
+		}
+		note.Save(filepath.Join(tmpDir, note.Filename))
+	}
+
+	list := func(args ...string) string {
+		output, err := captureStdout(t, func() error { return CmdList(append([]string{"--json"}, args...)) })
+		if err != nil {
+			t.Fatalf("CmdList(--json %v) error = %v", args, err)
+		}
+		return output
+	}
+
+	// Untagged notes have "tags": [], not null
+	output := list()
+	if !strings.Contains(output, `"tags": []`) || strings.Contains(output, "null") {
+		t.Errorf("tags should serialize as an empty array:\n%s", output)
+	}
+
+	var entries []ListEntry
+	json.Unmarshal([]byte(list("--tags", "neo", "--since", "2025-01-11", "--limit", "1")), &entries)
+	if len(entries) != 1 || entries[0].Filename != "n1.md" || entries[0].Summary != "Note 1" {
+		t.Fatalf("filtered entries = %+v, want only the newest tagged note", entries)
+	}
+	if created, err := time.Parse(time.RFC3339, entries[0].Created); err != nil || created.Format("2006-01-02 15:04") != "2025-01-12 09:00" {
+		t.Errorf("created = %q, want RFC 3339 (%v)", entries[0].Created, err)
+	}
+
+	json.Unmarshal([]byte(list()), &entries)
+	var order []string
+	for _, e := range entries {
+		order = append(order, e.Filename)
+	}
+	if fmt.Sprint(order) != "[n1.md n2.md n0.md]" {
+		t.Errorf("order = %v, want newest first", order)
+	}
+}
+
+func TestCmdListJSONShapes(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"neo"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "Content B", []string{"eval"}, "Summary B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "Content C", []string{"neo"}, "Summary C")
+
+	// Compact output is a single-line array
+	output, err := captureStdout(t, func() error {
+		return CmdList([]string{"--json", "--compact"})
+	})
+	if err != nil {
+		t.Fatalf("CmdList(--compact) error = %v", err)
+	}
+	if strings.Count(strings.TrimSpace(output), "\n") != 0 {
+		t.Errorf("Compact output should be a single line, got:\n%s", output)
+	}
+	var entries []ListEntry
+	if err := json.Unmarshal([]byte(output), &entries); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, output)
+	}
+	if len(entries) != 3 {
+		t.Errorf("Expected 3 entries, got %d", len(entries))
+	}
+
+	// Streaming output is one object per line, for sorted and unsorted scans
+	for _, sortOrder := range []string{"created", "none"} {
+		output, err = captureStdout(t, func() error {
+			return CmdList([]string{"--stream", "--sort", sortOrder, "--tags", "neo"})
+		})
+		if err != nil {
+			t.Fatalf("CmdList(--stream --sort %s) error = %v", sortOrder, err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(output), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("Expected 2 NDJSON lines with --sort %s, got:\n%s", sortOrder, output)
+		}
+		for _, line := range lines {
+			var entry ListEntry
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Errorf("Invalid NDJSON line %q: %v", line, err)
+			}
+			if !Contains(entry.Tags, "neo") {
+				t.Errorf("Entry %s should match the tag filter", entry.Filename)
+			}
+		}
+	}
+
+	// Streaming stops at the limit
+	output, _ = captureStdout(t, func() error {
+		return CmdList([]string{"--stream", "--sort", "none", "--limit", "1"})
+	})
+	if strings.Count(output, "\n") != 1 {
+		t.Errorf("Expected a single streamed line, got:\n%s", output)
+	}
+
+	// Streaming and searching don't keep every note in the parse cache
+	resetParseCache()
+	captureStdout(t, func() error { return CmdList([]string{"--stream", "--sort", "none"}) })
+	captureStdout(t, func() error { return CmdSearch([]string{"Content"}) })
+	if n := len(parseCache.entries); n != 0 {
+		t.Errorf("parse cache holds %d notes after streaming, want none", n)
+	}
+}
+
+func TestCmdRelatedSuggest(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "target.md", "Terraform modules for the staging cluster", []string{"aws", "infra", "terraform"}, "Target")
+	createEnrichedTestNote(t, tmpDir, "two.md", "Unrelated words entirely", []string{"aws", "infra"}, "Two tags")
+	createEnrichedTestNote(t, tmpDir, "one.md", "Something else", []string{"terraform"}, "One tag")
+	createEnrichedTestNote(t, tmpDir, "content.md", "Upgrading the staging cluster", []string{"k8s"}, "Content only")
+	createEnrichedTestNote(t, tmpDir, "none.md", "Nothing here", []string{"home"}, "Nothing shared")
+
+	output, err := captureStdout(t, func() error { return CmdRelatedSuggest([]string{"target.md"}) })
+	if err != nil {
+		t.Fatalf("CmdRelatedSuggest() error = %v", err)
+	}
+	if !strings.HasPrefix(output, "two.md") || !strings.Contains(output, "shared tags: aws, infra") {
+		t.Errorf("expected two.md first with its shared tags, got:\n%s", output)
+	}
+	if strings.Index(output, "one.md") < strings.Index(output, "two.md") {
+		t.Errorf("expected notes sharing more tags first, got:\n%s", output)
+	}
+	if strings.Contains(output, "content.md") || strings.Contains(output, "none.md") {
+		t.Errorf("expected only notes sharing tags without --content, got:\n%s", output)
+	}
+
+	output, err = captureStdout(t, func() error {
+		return CmdRelatedSuggest([]string{"target.md", "--content", "--limit", "3"})
+	})
+	if err != nil {
+		t.Fatalf("CmdRelatedSuggest(--content) error = %v", err)
+	}
+	if !strings.Contains(output, "content.md") || strings.Contains(output, "none.md") {
+		t.Errorf("expected content.md to be suggested by its terms, got:\n%s", output)
+	}
+
+	// --add relates the top suggestion, which is then no longer suggested
+	if _, err := captureStdout(t, func() error { return CmdRelatedSuggest([]string{"target.md", "--add"}) }); err != nil {
+		t.Fatalf("CmdRelatedSuggest(--add) error = %v", err)
+	}
+	note, err := ParseNote(filepath.Join(tmpDir, "target.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Contains(note.Frontmatter.Related, "two.md") {
+		t.Errorf("expected two.md to be related, got %v", note.Frontmatter.Related)
+	}
+	output, _ = captureStdout(t, func() error { return CmdRelatedSuggest([]string{"target.md"}) })
+	if strings.Contains(output, "two.md") {
+		t.Errorf("expected related notes to be excluded, got:\n%s", output)
+	}
+}
+
+func TestCmdDupes(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	body := "Meeting notes about the quarterly planning session and budget review"
+	createEnrichedTestNote(t, tmpDir, "a.md", body, []string{"work"}, "Planning")
+	createEnrichedTestNote(t, tmpDir, "b.md", body, []string{"meeting"}, "")
+	createEnrichedTestNote(t, tmpDir, "c.md", body+" tomorrow", []string{"work"}, "Almost the same")
+	createEnrichedTestNote(t, tmpDir, "d.md", "Something different entirely", []string{"home"}, "Other")
+	createTestNote(t, tmpDir, "e.md", "")
+	createTestNote(t, tmpDir, "f.md", "")
+
+	output, err := captureStdout(t, func() error { return CmdDupes(nil) })
+	if err != nil {
+		t.Fatalf("CmdDupes() error = %v", err)
+	}
+	if output != "2 notes: \"Planning\"\n  a.md\n  b.md\n" {
+		t.Errorf("CmdDupes() = %q", output)
+	}
+
+	output, err = captureStdout(t, func() error { return CmdDupes([]string{"--similarity", "0.8", "--raw"}) })
+	if err != nil {
+		t.Fatalf("CmdDupes(--similarity) error = %v", err)
+	}
+	if output != "a.md b.md c.md\n" {
+		t.Errorf("CmdDupes(--similarity 0.8 --raw) = %q", output)
+	}
+
+	if err := CmdDupes([]string{"--similarity", "1.5"}); err == nil {
+		t.Error("expected an error for a similarity above 1")
+	}
+}
+
+func TestCmdRelateAndUnrelate(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"neo"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "c.md", "Content C", []string{"neo"}, "Summary C")
+	createTestNote(t, tmpDir, "b.md", "Content B")
+	if err := CmdUpdate([]string{"a.md", "--related", "c.md"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Relating twice is idempotent and keeps the existing relation
+	for i := 0; i < 2; i++ {
+		if err := CmdRelate([]string{"a", "b"}); err != nil {
+			t.Fatalf("CmdRelate() error = %v", err)
+		}
+	}
+
+	a, _ := ParseNote(filepath.Join(tmpDir, "a.md"))
+	if !stringSliceEqual(a.Frontmatter.Related, []string{"c.md", "b.md"}) {
+		t.Errorf("a.md related = %v, want [c.md b.md]", a.Frontmatter.Related)
+	}
+	b, _ := ParseNote(filepath.Join(tmpDir, "b.md"))
+	if !stringSliceEqual(b.Frontmatter.Related, []string{"a.md"}) {
+		t.Errorf("b.md related = %v, want [a.md]", b.Frontmatter.Related)
+	}
+
+	meta, _ := LoadMetaFile(tmpDir)
+	if !stringSliceEqual(meta.GetFileMeta("a.md").Related, []string{"c.md", "b.md"}) {
+		t.Errorf("a.md meta related = %v", meta.GetFileMeta("a.md").Related)
+	}
+	if !meta.NeedsEnrichment("b.md", b.ContentHash()) {
+		t.Error("Relating an unenriched note should not mark it as enriched")
+	}
+
+	// Unrelating twice is idempotent and keeps the other relation
+	for i := 0; i < 2; i++ {
+		if err := CmdUnrelate([]string{"b.md", "a.md"}); err != nil {
+			t.Fatalf("CmdUnrelate() error = %v", err)
+		}
+	}
+
+	a, _ = ParseNote(filepath.Join(tmpDir, "a.md"))
+	if !stringSliceEqual(a.Frontmatter.Related, []string{"c.md"}) {
+		t.Errorf("a.md related = %v, want [c.md]", a.Frontmatter.Related)
+	}
+	meta, _ = LoadMetaFile(tmpDir)
+	if Contains(meta.GetFileMeta("b.md").Related, "a.md") {
+		t.Error("b.md meta should no longer relate to a.md")
+	}
+
+	if err := CmdRelate([]string{"a.md", "missing.md"}); err == nil {
+		t.Error("CmdRelate() should error for a missing note")
+	}
+
+	// Plain notes have nowhere to store the relation, so it would be one-sided
+	os.WriteFile(filepath.Join(tmpDir, "plain.md"), []byte("Just a body\n"), 0644)
+	before, _ := os.ReadFile(filepath.Join(tmpDir, ".meta.json"))
+	for _, args := range [][]string{{"a.md", "plain.md"}, {"plain", "a"}} {
+		err := CmdRelate(args)
+		if err == nil || !strings.Contains(err.Error(), "plain.md has no frontmatter") {
+			t.Errorf("CmdRelate(%v) error = %v, want plain.md has no frontmatter", args, err)
+		}
+	}
+	if after, _ := os.ReadFile(filepath.Join(tmpDir, ".meta.json")); string(after) != string(before) {
+		t.Error("refused relate should leave .meta.json alone")
+	}
+}
+
+func TestCmdContext(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "root.md", "Root body", []string{"neo"}, "Root summary")
+	createEnrichedTestNote(t, tmpDir, "near.md", "Near body", []string{"neo"}, "Near summary")
+	createEnrichedTestNote(t, tmpDir, "far.md", "Far body", []string{"eval"}, "Far summary")
+
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.AddRelation("root.md", "near.md")
+	meta.AddRelation("near.md", "far.md")
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error {
+		return CmdContext([]string{"root.md"})
+	})
+	if err != nil {
+		t.Fatalf("CmdContext() error = %v", err)
+	}
+	if !strings.Contains(output, "===== root.md =====\ntags: neo\nsummary: Root summary\n\nRoot body") {
+		t.Errorf("Missing root section, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Near body") || strings.Contains(output, "Far body") {
+		t.Errorf("Depth 1 should include only the direct neighbor, got:\n%s", output)
+	}
+
+	// Budget stops the traversal after the root
+	bundle, omitted := buildContextBundle(tmpDir, meta, "root.md", 2, 10)
+	if !strings.Contains(bundle, "Root body") || strings.Contains(bundle, "Near body") {
+		t.Errorf("Budget should keep only the root, got:\n%s", bundle)
+	}
+	if omitted != 1 {
+		t.Errorf("Expected 1 omitted note, got %d", omitted)
+	}
+
+	bundle, omitted = buildContextBundle(tmpDir, meta, "root.md", 2, 0)
+	if !strings.Contains(bundle, "Far body") || omitted != 0 {
+		t.Errorf("Unlimited depth 2 bundle should include far.md, got:\n%s", bundle)
+	}
+}
+
+func TestNotesDirIsFile(t *testing.T) {
+	notesFile := filepath.Join(t.TempDir(), "notes")
+	os.WriteFile(notesFile, []byte("oops"), 0644)
+	t.Setenv("NOTES_DIR", notesFile)
+
+	for name, err := range map[string]error{
+		"new":  CmdNew([]string{"content"}),
+		"list": CmdList([]string{}),
+	} {
+		if err == nil || !strings.Contains(err.Error(), "is a file, not a directory") {
+			t.Errorf("%s: error = %v, want a clear not-a-directory error", name, err)
+		}
+	}
+}
+
+func TestNotesDirReadOnly(t *testing.T) {
+	notesDir := t.TempDir()
+	createTestNote(t, notesDir, "a.md", "Content")
+	if err := os.Chmod(notesDir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(notesDir, 0755)
+
+	// Privileged users can still write, so there is nothing to detect
+	if f, err := os.CreateTemp(notesDir, "probe-*"); err == nil {
+		f.Close()
+		os.Remove(f.Name())
+		t.Skip("directory permissions are not enforced for this user")
+	}
+
+	t.Setenv("NOTES_DIR", notesDir)
+
+	err := CmdUpdate([]string{"a.md", "--tags", "x"})
+	if err == nil || !strings.Contains(err.Error(), "is not writable") {
+		t.Errorf("CmdUpdate() error = %v, want a not-writable error", err)
+	}
+
+	err = CmdNew([]string{"content"})
+	if err == nil || !strings.Contains(err.Error(), "is not writable") {
+		t.Errorf("CmdNew() error = %v, want a not-writable error", err)
+	}
+
+	// Read-only commands keep working
+	if err := CmdList([]string{}); err != nil {
+		t.Errorf("CmdList() error = %v", err)
+	}
+}
+
+func TestCmdTagsCooccur(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"neo", "eval"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "Content B", []string{"neo", "eval", "meeting"}, "Summary B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "Content C", []string{"meeting", "home"}, "Summary C")
+
+	output, err := captureStdout(t, func() error { return CmdTags([]string{"--cooccur", "Neo"}) })
+	if err != nil {
+		t.Fatalf("CmdTags(--cooccur) error = %v", err)
+	}
+	if output != "eval (2)\nmeeting (1)\n" {
+		t.Errorf("CmdTags(--cooccur neo) = %q", output)
+	}
+
+	output, err = captureStdout(t, func() error {
+		return CmdTags([]string{"--cooccur", "meeting", "--json", "--with-files"})
+	})
+	if err != nil {
+		t.Fatalf("CmdTags(--cooccur --json) error = %v", err)
+	}
+	var tags []TagOutput
+	if err := json.Unmarshal([]byte(output), &tags); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, output)
+	}
+	want := []TagOutput{
+		{Tag: "eval", Count: 1, Files: []string{"b.md"}},
+		{Tag: "home", Count: 1, Files: []string{"c.md"}},
+		{Tag: "neo", Count: 1, Files: []string{"b.md"}},
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("CmdTags(--cooccur meeting --json) = %+v, want %+v", tags, want)
+	}
+
+	if err := CmdTags([]string{"--cooccur", "missing"}); err == nil {
+		t.Error("expected an error for an unused tag")
+	}
+}
+
+func TestCmdTagsWithFiles(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"neo", "eval"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "Content B", []string{"neo", "meeting"}, "Summary B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "Content C", []string{"Neo", "neo"}, "Summary C")
+
+	output, err := captureStdout(t, func() error {
+		return CmdTags([]string{"--json", "--with-files"})
+	})
+	if err != nil {
+		t.Fatalf("CmdTags(--json --with-files) error = %v", err)
+	}
+
+	var tags []TagOutput
+	if err := json.Unmarshal([]byte(output), &tags); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, output)
+	}
+
+	want := map[string][]string{
+		"neo":     {"a.md", "b.md", "c.md"},
+		"eval":    {"a.md"},
+		"meeting": {"b.md"},
+	}
+	if len(tags) != len(want) {
+		t.Fatalf("Expected %d tags, got %+v", len(want), tags)
+	}
+	for _, tag := range tags {
+		if !stringSliceEqual(tag.Files, want[tag.Tag]) {
+			t.Errorf("Tag %s files = %v, want %v", tag.Tag, tag.Files, want[tag.Tag])
+		}
+		if tag.Count != len(want[tag.Tag]) {
+			t.Errorf("Tag %s count = %d, want %d", tag.Tag, tag.Count, len(want[tag.Tag]))
+		}
+	}
+	if tags[0].Tag != "neo" {
+		t.Errorf("Most used tag should come first, got %s", tags[0].Tag)
+	}
+
+	// Plain JSON histogram omits files
+	output, _ = captureStdout(t, func() error {
+		return CmdTags([]string{"--json"})
+	})
+	if strings.Contains(output, "files") {
+		t.Errorf("Files should only be listed with --with-files, got:\n%s", output)
+	}
+}
+
+func writeTestTemplate(t *testing.T, dir, name, content string) {
+	t.Helper()
+	templatesDir := TemplatesDir(dir)
+	if err := os.MkdirAll(templatesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(templatesDir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCmdNewUsesDefaultTemplate(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	writeTestTemplate(t, tmpDir, "default.md", "---\ntags: [journal]\n---\n\n## Log\n\n{{cursor}}\n\n## Next\n")
+
+	if err := CmdNew([]string{"Shipped", "the", "release"}); err != nil {
+		t.Fatalf("CmdNew() error = %v", err)
+	}
+
+	notesList, _ := LoadAllNotes(tmpDir)
+	if len(notesList) != 1 {
+		t.Fatalf("Expected 1 note, got %d", len(notesList))
+	}
+	note := notesList[0]
+	if note.Content != "\n## Log\n\nShipped the release\n\n## Next\n" {
+		t.Errorf("Content = %q, want template body with text at the cursor", note.Content)
+	}
+	if !stringSliceEqual(note.Frontmatter.Tags, []string{"journal"}) {
+		t.Errorf("Tags = %v, want template tags", note.Frontmatter.Tags)
+	}
+	if note.Frontmatter.Created.IsZero() {
+		t.Error("Created should be set to the current time")
+	}
+}
+
+func TestCmdNewTemplateInEditor(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("EDITOR", writeFakeEditor(t, "Typed text", 0))
+	writeTestTemplate(t, tmpDir, "meeting.md", "\n# Meeting\n\n{{cursor}}\n")
+
+	if err := CmdNew([]string{"--template", "meeting"}); err != nil {
+		t.Fatalf("CmdNew(--template) error = %v", err)
+	}
+
+	notesList, _ := LoadAllNotes(tmpDir)
+	if len(notesList) != 1 {
+		t.Fatalf("Expected 1 note, got %d", len(notesList))
+	}
+	content := notesList[0].Content
+	if strings.Contains(content, cursorMarker) {
+		t.Errorf("Cursor marker should be stripped, got %q", content)
+	}
+	if !strings.Contains(content, "# Meeting") || !strings.Contains(content, "Typed text") {
+		t.Errorf("Content = %q, want template and typed text", content)
+	}
+
+	if err := CmdNew([]string{"--template", "missing", "text"}); err == nil {
+		t.Error("CmdNew() should error for a missing named template")
+	}
+}
+
+func TestCmdNewTemplateDate(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	writeTestTemplate(t, tmpDir, "meeting.md", "---\nsummary: \"Meeting {{.Date}}\"\n---\n\n# Meeting {{.Date}}\n\n{{cursor}}\n")
+
+	if err := CmdNew([]string{"--template", "meeting", "Agenda"}); err != nil {
+		t.Fatalf("CmdNew(--template) error = %v", err)
+	}
+
+	notesList, _ := LoadAllNotes(tmpDir)
+	if len(notesList) != 1 {
+		t.Fatalf("Expected 1 note, got %d", len(notesList))
+	}
+	note := notesList[0]
+	today := note.Frontmatter.Created.Format("2006-01-02")
+	if note.Content != "\n# Meeting "+today+"\n\nAgenda\n" {
+		t.Errorf("Content = %q, want the date filled in and text at the cursor", note.Content)
+	}
+	if note.Frontmatter.Summary != "Meeting "+today {
+		t.Errorf("Summary = %q, want the date filled in", note.Frontmatter.Summary)
+	}
+
+	writeTestTemplate(t, tmpDir, "broken.md", "# {{.Author}}\n")
+	if err := CmdNew([]string{"--template", "broken", "text"}); err == nil || !strings.Contains(err.Error(), "invalid template broken") {
+		t.Errorf("CmdNew() with an unknown placeholder error = %v", err)
+	}
+}
+
+func TestCmdTemplateList(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	output, err := captureStdout(t, func() error { return CmdTemplate([]string{"list"}) })
+	if err != nil || output != "No templates found\n" {
+		t.Errorf("CmdTemplate(list) without templates = %q, %v", output, err)
+	}
+
+	writeTestTemplate(t, tmpDir, "meeting.md", "\n# Meeting\n")
+	writeTestTemplate(t, tmpDir, "default.md", "\n{{cursor}}\n")
+	writeTestTemplate(t, tmpDir, "notes.txt", "not a template")
+
+	output, err = captureStdout(t, func() error { return CmdTemplate([]string{"list"}) })
+	if err != nil {
+		t.Fatalf("CmdTemplate(list) error = %v", err)
+	}
+	if want := "default (used by notes new)\nmeeting\n"; output != want {
+		t.Errorf("CmdTemplate(list) = %q, want %q", output, want)
+	}
+}
+
+func TestCmdTemplateApply(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Existing text", []string{"neo"}, "Summary")
+	writeTestTemplate(t, tmpDir, "meeting.md", "---\ntags: [meeting]\n---\n\n## Attendees\n\n{{cursor}}\n")
+
+	if _, err := captureStdout(t, func() error { return CmdTemplate([]string{"apply", "meeting", "a"}) }); err != nil {
+		t.Fatalf("CmdTemplate(apply) error = %v", err)
+	}
+
+	note, _ := ParseNote(filepath.Join(tmpDir, "a.md"))
+	if !strings.Contains(note.Content, "Existing text\n\n## Attendees\n") {
+		t.Errorf("Template body should follow existing content, got %q", note.Content)
+	}
+	if strings.Contains(note.Content, cursorMarker) {
+		t.Errorf("Cursor marker should be dropped, got %q", note.Content)
+	}
+	if !stringSliceEqual(note.Frontmatter.Tags, []string{"neo"}) || note.Frontmatter.Summary != "Summary" {
+		t.Errorf("Frontmatter should be unchanged, got %+v", note.Frontmatter)
+	}
+
+	if err := CmdTemplate([]string{"apply", "missing", "a"}); err == nil {
+		t.Error("CmdTemplate(apply) should error for a missing template")
+	}
+}
+
+func TestCmdSearchReplaceLiteral(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "We use Widget here.\nAnother widget line.", []string{"widget"}, "About widget")
+	createTestNote(t, tmpDir, "b.md", "Nothing to see")
+	aPath := filepath.Join(tmpDir, "a.md")
+	before, _ := os.ReadFile(aPath)
+
+	// Dry run by default
+	output, err := captureStdout(t, func() error {
+		return CmdSearch([]string{"widget", "--replace", "gadget"})
+	})
+	if err != nil {
+		t.Fatalf("CmdSearch(--replace) error = %v", err)
+	}
+	after, _ := os.ReadFile(aPath)
+	if string(before) != string(after) {
+		t.Error("Replace without --yes should not modify notes")
+	}
+	if !strings.Contains(output, "- We use Widget here.") || !strings.Contains(output, "+ We use gadget here.") {
+		t.Errorf("Dry run should show before and after, got:\n%s", output)
+	}
+
+	if err := CmdSearch([]string{"widget", "--replace", "gadget", "--yes"}); err != nil {
+		t.Fatalf("CmdSearch(--replace --yes) error = %v", err)
+	}
+
+	note, _ := ParseNote(aPath)
+	if note.Content != "\nWe use gadget here.\nAnother gadget line.\n" {
+		t.Errorf("Content = %q", note.Content)
+	}
+	if note.Frontmatter.Summary != "About widget" || !stringSliceEqual(note.Frontmatter.Tags, []string{"widget"}) {
+		t.Error("Frontmatter must not be touched by replace")
+	}
+
+	meta, _ := LoadMetaFile(tmpDir)
+	if meta.NeedsEnrichment("a.md", note.ContentHash()) {
+		t.Error("Content hash should be refreshed after replace")
+	}
+}
+
+func TestCmdSearchReplaceRegex(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "a.md", "See ticket-42 and ticket-7.\nPrice $5.")
+
+	err := CmdSearch([]string{"--regex", `ticket-(\d+)`, "--replace", "TICKET#$1", "--yes"})
+	if err != nil {
+		t.Fatalf("CmdSearch(--regex --replace) error = %v", err)
+	}
+
+	note, _ := ParseNote(filepath.Join(tmpDir, "a.md"))
+	if note.Content != "\nSee TICKET#42 and TICKET#7.\nPrice $5.\n" {
+		t.Errorf("Content = %q, want capture groups expanded", note.Content)
+	}
+
+	// Literal mode inserts $1 verbatim
+	if err := CmdSearch([]string{"price", "--replace", "$1", "--yes"}); err != nil {
+		t.Fatal(err)
+	}
+	note, _ = ParseNote(filepath.Join(tmpDir, "a.md"))
+	if !strings.Contains(note.Content, "$1 $5.") {
+		t.Errorf("Literal replacement should not expand groups, got %q", note.Content)
+	}
+}
+
+func TestCmdSearch(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "First line\nMentions Kubernetes", []string{"kubernetes"}, "Summary")
+	createTestNote(t, tmpDir, "b.md", "Unrelated")
+
+	output, err := captureStdout(t, func() error {
+		return CmdSearch([]string{"kubernetes"})
+	})
+	if err != nil {
+		t.Fatalf("CmdSearch() error = %v", err)
+	}
+	if output != "a.md\n  3: Mentions Kubernetes\n" {
+		t.Errorf("Output = %q", output)
+	}
+}
+
+func TestCmdSearchOrderAndMaxResults(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	for i := 0; i < 50; i++ {
+		createTestNote(t, tmpDir, fmt.Sprintf("note-%02d.md", i), fmt.Sprintf("match %d", i))
+	}
+	createTestNote(t, tmpDir, "other.md", "nothing")
+
+	var got []string
+	err := searchNotes(tmpDir, regexp.MustCompile("match"), searchOptions{scope: searchInBody}, 0, 8, func(r searchResult) error {
+		got = append(got, r.Filename)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("searchNotes() error = %v", err)
+	}
+	if len(got) != 50 || !sort.StringsAreSorted(got) {
+		t.Errorf("results should cover all 50 notes in filename order, got %v", got)
+	}
+
+	output, err := captureStdout(t, func() error {
+		return CmdSearch([]string{"match", "--max-results", "3"})
+	})
+	if err != nil {
+		t.Fatalf("CmdSearch(--max-results) error = %v", err)
+	}
+	want := "note-00.md\n  2: match 0\nnote-01.md\n  2: match 1\nnote-02.md\n  2: match 2\n"
+	if output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+}
+
+func TestCmdSearchFullText(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	write := func(name, created string, tags []string, summary, body string) {
+		at, _ := time.Parse("2006-01-02 15:04", created)
+		note := &Note{
+			Filename:    name,
+			Frontmatter: Frontmatter{Created: NoteTime{at}, Tags: tags, Summary: summary, Related: []string{}},
+			Content:     "\n" + body + "\n",
+		}
+		if err := note.Save(filepath.Join(tmpDir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.md", "2025-01-01 09:00", []string{"work"}, "Kickoff", "one\ntwo\nWidget here\nthree\nfour\nfive\nwidget again")
+	write("b.md", "2025-03-01 09:00", []string{"home"}, "Widget shopping", "nothing relevant")
+	write("c.md", "2025-02-01 09:00", []string{"work"}, "", "A widget")
+
+	search := func(args ...string) string {
+		output, err := captureStdout(t, func() error { return CmdSearch(args) })
+		if err != nil {
+			t.Fatalf("CmdSearch(%v) error = %v", args, err)
+		}
+		return output
+	}
+
+	// Newest first by created; summaries only with --include-summary
+	if got := search("widget"); got != "c.md\n  2: A widget\na.md\n  4: Widget here\n  8: widget again\n" {
+		t.Errorf("output = %q", got)
+	}
+	if got := search("widget", "--include-summary", "--limit", "2"); got != "b.md\n  summary: Widget shopping\nc.md\n  2: A widget\n" {
+		t.Errorf("--include-summary --limit output = %q", got)
+	}
+
+	if got := search("widget", "--tags", "home,work", "--include-summary", "--sort", "none"); !strings.HasPrefix(got, "a.md\n") || !strings.Contains(got, "b.md\n") {
+		t.Errorf("--sort none should list in filename order, got %q", got)
+	}
+	if got := search("widget", "--tags", "work"); strings.Contains(got, "b.md") {
+		t.Errorf("--tags work should skip b.md, got %q", got)
+	}
+
+	want := "a.md\n  3- two\n  4: Widget here\n  5- three\n  --\n  7- five\n  8: widget again\n  9-\n"
+	if got := search("widget", "--tags", "work", "--context", "1", "--limit", "1", "--sort", "none"); got != want {
+		t.Errorf("--context output = %q, want %q", got, want)
+	}
+}
+
+func BenchmarkSearchNotes(b *testing.B) {
+	tmpDir, _ := setupTagBenchmark(b, 2000)
+	re := regexp.MustCompile("(?i)note content")
+
+	for _, workers := range []int{1, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				resetParseCache()
+				err := searchNotes(tmpDir, re, searchOptions{scope: searchInBody}, 0, workers, func(searchResult) error { return nil })
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkLoadAllNotes compares scanning a notebook that was not parsed yet
+// with scanning it again later in the same run
+func BenchmarkLoadAllNotes(b *testing.B) {
+	tmpDir, _ := setupTagBenchmark(b, 2000)
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			resetParseCache()
+			if _, err := LoadAllNotes(tmpDir); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		if _, err := LoadAllNotes(tmpDir); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := LoadAllNotes(tmpDir); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestCmdSearchIn(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "summary.md", "Nothing here", []string{"infra"}, "Notes on Terraform state")
+	createEnrichedTestNote(t, tmpDir, "body.md", "Ran terraform apply", []string{"ops"}, "Deploy log")
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"body", "body.md\n  2: Ran terraform apply\n"},
+		{"frontmatter", "summary.md\n  summary: Notes on Terraform state\n"},
+		{"both", "body.md\n  2: Ran terraform apply\nsummary.md\n  summary: Notes on Terraform state\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			output, err := captureStdout(t, func() error {
+				return CmdSearch([]string{"terraform", "--in", tt.in})
+			})
+			if err != nil {
+				t.Fatalf("CmdSearch(--in %s) error = %v", tt.in, err)
+			}
+			if output != tt.want {
+				t.Errorf("output = %q, want %q", output, tt.want)
+			}
+		})
+	}
+
+	output, _ := captureStdout(t, func() error {
+		return CmdSearch([]string{"infra", "--in", "frontmatter"})
+	})
+	if output != "summary.md\n  tags: infra\n" {
+		t.Errorf("tag match output = %q", output)
+	}
+
+	if err := CmdSearch([]string{"terraform", "--in", "frontmatter", "--replace", "x"}); err == nil {
+		t.Error("--replace should be rejected outside --in body")
+	}
+}
+
+func TestCmdGraphNoSummaries(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"neo"}, "Summary A")
+	// b.md has no meta entry, so its summary can only come from parsing the file
+	createTestNote(t, tmpDir, "b.md", "Parsed first line")
+
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.AddRelation("a.md", "b.md")
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error {
+		return CmdGraph([]string{"a.md"})
+	})
+	if err != nil {
+		t.Fatalf("CmdGraph() error = %v", err)
+	}
+	if !strings.Contains(output, "Parsed first line") {
+		t.Fatalf("Default output should include summaries parsed from files, got:\n%s", output)
+	}
+
+	output, err = captureStdout(t, func() error {
+		return CmdGraph([]string{"--no-summaries", "a.md"})
+	})
+	if err != nil {
+		t.Fatalf("CmdGraph(--no-summaries) error = %v", err)
+	}
+	if output != "a.md\n└── b.md\n" {
+		t.Errorf("Output = %q, want bare topology", output)
+	}
+
+	output, _ = captureStdout(t, func() error {
+		return CmdGraph([]string{"--no-summaries", "--flat"})
+	})
+	if strings.Contains(output, "summary") {
+		t.Errorf("Flat output should omit summaries, got:\n%s", output)
+	}
+}
+
+func TestCmdGraphEdgeList(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"neo", "eval"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"neo", "eval"}, "Summary B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "C", []string{"other"}, "Summary C")
+	createEnrichedTestNote(t, tmpDir, "d.md", "D", []string{"other"}, "Summary D")
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.AddRelation("a.md", "b.md")
+	meta.AddRelation("b.md", "c.md")
+	meta.AddRelation("c.md", "d.md")
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error { return CmdGraph([]string{"--format", "edgelist"}) })
+	if err != nil {
+		t.Fatalf("CmdGraph(--format edgelist) error = %v", err)
+	}
+	want := "source,target,shared_tags\na.md,b.md,eval;neo\nb.md,c.md,\nc.md,d.md,other\n"
+	if output != want {
+		t.Errorf("edgelist = %q, want %q", output, want)
+	}
+
+	// Scoped to one hop around a.md
+	output, _ = captureStdout(t, func() error { return CmdGraph([]string{"--format", "edgelist", "--depth", "1", "a.md"}) })
+	if output != "source,target,shared_tags\na.md,b.md,eval;neo\n" {
+		t.Errorf("scoped edgelist = %q", output)
+	}
+
+	output, _ = captureStdout(t, func() error { return CmdGraph([]string{"--format", "adjacency", "--depth", "1", "b.md"}) })
+	want = ",a.md,b.md,c.md\na.md,0,1,0\nb.md,1,0,1\nc.md,0,1,0\n"
+	if output != want {
+		t.Errorf("adjacency = %q, want %q", output, want)
+	}
+}
+
+func TestCmdGraphDOT(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"neo", "eval"}, `Summary "A"`)
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"neo", "eval"}, "Summary B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "C", []string{"other"}, "")
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.AddRelation("a.md", "b.md")
+	meta.AddRelation("b.md", "c.md")
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error { return CmdGraph([]string{"--format", "dot"}) })
+	if err != nil {
+		t.Fatalf("CmdGraph(--format dot) error = %v", err)
+	}
+	want := `digraph notes {
+  node [shape=box];
+  "a.md" [label="Summary \"A\""];
+  "b.md" [label="Summary B"];
+  "c.md" [label="C"];
+  "a.md" -> "b.md" [dir=none];
+  "b.md" -> "c.md" [dir=none];
+}
+`
+	if output != want {
+		t.Errorf("dot = %q, want %q", output, want)
+	}
+
+	output, err = captureStdout(t, func() error {
+		return CmdGraph([]string{"--format", "dot", "--edge-labels", "--include-tag-edges", "--no-summaries"})
+	})
+	if err != nil {
+		t.Fatalf("CmdGraph(--format dot --edge-labels) error = %v", err)
+	}
+	if !strings.Contains(output, `"a.md" [label="a.md"];`) || !strings.Contains(output, `"a.md" -> "b.md" [dir=none, label="eval, neo"];`) {
+		t.Errorf("expected file labels and shared tags on edges, got:\n%s", output)
+	}
+
+	if err := CmdGraph([]string{"--edge-labels"}); err == nil {
+		t.Error("expected an error for --edge-labels without --format dot")
+	}
+}
+
+func TestCmdGraphMermaid(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "2025-01-11-1423.md", "A", []string{"neo"}, `Summary "A"`)
+	createEnrichedTestNote(t, tmpDir, "my note.md", "B", []string{"neo"}, "Summary B")
+	createEnrichedTestNote(t, tmpDir, "my-note.md", "C", []string{"other"}, "Summary C")
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.AddRelation("2025-01-11-1423.md", "my note.md")
+	meta.AddRelation("my note.md", "my-note.md")
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error { return CmdGraph([]string{"--format", "mermaid", "--edge-labels"}) })
+	if err != nil {
+		t.Fatalf("CmdGraph(--format mermaid) error = %v", err)
+	}
+	want := `graph TD
+  n_2025_01_11_1423["Summary #quot;A#quot;"]
+  n_my_note["Summary B"]
+  n_my_note_2["Summary C"]
+  n_2025_01_11_1423 ---|"neo"| n_my_note
+  n_my_note --- n_my_note_2
+`
+	if output != want {
+		t.Errorf("mermaid = %q, want %q", output, want)
+	}
+}
+
+func TestCmdGraphJSONDenseGraph(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	// Fully connected graph: without shared expansion a depth-5 tree would
+	// have n^5 entries
+	const n = 30
+	for i := 0; i < n; i++ {
+		createEnrichedTestNote(t, tmpDir, fmt.Sprintf("n%02d.md", i), "Content", []string{"hub"}, "Summary")
+	}
+	meta, _ := LoadMetaFile(tmpDir)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			meta.AddRelation(fmt.Sprintf("n%02d.md", i), fmt.Sprintf("n%02d.md", j))
+		}
+	}
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error {
+		return CmdGraph([]string{"--json", "--depth", "5", "n00.md"})
+	})
+	if err != nil {
+		t.Fatalf("CmdGraph(--json) error = %v", err)
+	}
+
+	var root graphNode
+	if err := json.Unmarshal([]byte(output), &root); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	total := 0
+	seen := make(map[string]bool)
+	expanded := make(map[string]int)
+	var walk func(node graphNode)
+	walk = func(node graphNode) {
+		total++
+		seen[node.Filename] = true
+		if len(node.Related) > 0 {
+			expanded[node.Filename]++
+		}
+		if node.Ref && (node.Summary != "" || len(node.Related) > 0) {
+			t.Errorf("reference to %s should be bare", node.Filename)
+		}
+		for _, child := range node.Related {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	// One entry for the root plus at most one per directed relation
+	if max := 1 + n*(n-1); total > max {
+		t.Errorf("output has %d nodes, want at most %d", total, max)
+	}
+	for filename, count := range expanded {
+		if count != 1 {
+			t.Errorf("%s expanded %d times, want once", filename, count)
+		}
+	}
+	if len(seen) != n {
+		t.Errorf("output covers %d notes, want all %d", len(seen), n)
+	}
+}
+
+func TestCmdListSummarySource(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Actual first line\nMore text", []string{"neo"}, "Enriched summary")
+
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"summary", "a.md  \"Enriched summary\"\n"},
+		{"firstline", "a.md  \"Actual first line\"\n"},
+		{"both", "a.md  \"Enriched summary\"\n    Actual first line\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			output, err := captureStdout(t, func() error {
+				return CmdList([]string{"--summary-source", tt.mode})
+			})
+			if err != nil {
+				t.Fatalf("CmdList(--summary-source %s) error = %v", tt.mode, err)
+			}
+			if output != tt.want {
+				t.Errorf("output = %q, want %q", output, tt.want)
+			}
+		})
+	}
+
+	if err := CmdList([]string{"--summary-source", "title"}); err == nil {
+		t.Error("CmdList should reject an unknown --summary-source")
+	}
+}
+
+func TestCmdListTagCounts(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"neo", "oneoff"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"Neo"}, "Summary B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "C", []string{"neo", "eval"}, "Summary C")
+
+	// Counts are global even when the listing is filtered
+	output, err := captureStdout(t, func() error {
+		return CmdList([]string{"--tag-counts", "--tags", "oneoff"})
+	})
+	if err != nil {
+		t.Fatalf("CmdList(--tag-counts) error = %v", err)
+	}
+	if output != "a.md  neo(3) oneoff(1)  \"Summary A\"\n" {
+		t.Errorf("output = %q", output)
+	}
+
+	tagFiles, _ := collectTagsFromFiles(tmpDir)
+	if got := formatTagCounts([]string{"eval", "Neo"}, tagFiles, false); got != "eval(1) Neo(3)" {
+		t.Errorf("formatTagCounts() = %q", got)
+	}
+	if got := formatTagCounts([]string{"eval"}, tagFiles, true); got != "\x1b[33meval(1)\x1b[0m" {
+		t.Errorf("formatTagCounts() should highlight rare tags on a terminal, got %q", got)
+	}
+}
+
+func TestCmdListSource(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Content A", []string{"neo"}, "Meta summary")
+	createTestNote(t, tmpDir, "unsynced.md", "Never synced")
+
+	// Edit the frontmatter without syncing
+	note, _ := ParseNote(filepath.Join(tmpDir, "a.md"))
+	note.Frontmatter.Summary = "Frontmatter summary"
+	note.Save(filepath.Join(tmpDir, "a.md"))
+
+	output, err := captureStdout(t, func() error {
+		return CmdList([]string{"--tags", "neo"})
+	})
+	if err != nil {
+		t.Fatalf("CmdList() error = %v", err)
+	}
+	if !strings.Contains(output, "Frontmatter summary") {
+		t.Errorf("Default source should be frontmatter, got:\n%s", output)
+	}
+
+	output, err = captureStdout(t, func() error {
+		return CmdList([]string{"--source", "meta"})
+	})
+	if err != nil {
+		t.Fatalf("CmdList(--source meta) error = %v", err)
+	}
+	if !strings.Contains(output, "Meta summary") || strings.Contains(output, "Frontmatter summary") {
+		t.Errorf("Meta source should use the meta summary, got:\n%s", output)
+	}
+	if strings.Contains(output, "unsynced.md") {
+		t.Errorf("Notes without meta entry should be left out, got:\n%s", output)
+	}
+
+	if err := CmdList([]string{"--source", "cache"}); err == nil {
+		t.Error("CmdList() should reject an unknown source")
+	}
+}
+
+func TestCmdExportChangedSince(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "a.md", "First note")
+	createTestNote(t, tmpDir, "b.md", "Second note")
+
+	stampFile := filepath.Join(t.TempDir(), "last-export")
+	firstDir := t.TempDir()
+	if _, err := captureStdout(t, func() error {
+		return CmdExport([]string{firstDir, "--changed-since", stampFile})
+	}); err != nil {
+		t.Fatalf("CmdExport() first run error = %v", err)
+	}
+	for _, name := range []string{"a.md", "b.md"} {
+		if _, err := os.Stat(filepath.Join(firstDir, name)); err != nil {
+			t.Errorf("first run should export %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(stampFile); err != nil {
+		t.Fatalf("export time was not recorded: %v", err)
+	}
+
+	// Change b.md after the first export
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(tmpDir, "b.md"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	secondDir := t.TempDir()
+	output, err := captureStdout(t, func() error {
+		return CmdExport([]string{secondDir, "--changed-since", stampFile})
+	})
+	if err != nil {
+		t.Fatalf("CmdExport() second run error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(secondDir, "a.md")); !os.IsNotExist(err) {
+		t.Error("second run should not export the unchanged a.md")
+	}
+	data, err := os.ReadFile(filepath.Join(secondDir, "b.md"))
+	if err != nil {
+		t.Fatalf("second run should export the changed b.md: %v", err)
+	}
+	if !strings.Contains(string(data), "Second note") {
+		t.Errorf("exported b.md = %q, want the note's content", data)
+	}
+	if !strings.Contains(output, "Exported 1 of 2 notes") {
+		t.Errorf("output should report 1 of 2 notes exported, got:\n%s", output)
+	}
+}
+
+func TestCmdListTable(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Short", []string{"neo"}, "Short name")
+	createEnrichedTestNote(t, tmpDir, "a-much-longer-filename.md", "Long", []string{"eval", "neo"},
+		"A summary far too long to fit on one line of a narrow terminal")
+
+	orig := stdoutIsTerminal
+	stdoutIsTerminal = func() bool { return true }
+	defer func() { stdoutIsTerminal = orig }()
+	t.Setenv("COLUMNS", "80")
+
+	output, err := captureStdout(t, func() error {
+		return CmdList([]string{"--table", "--sort", "none"})
+	})
+	if err != nil {
+		t.Fatalf("CmdList(--table) error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("want a header and 2 rows, got:\n%s", output)
+	}
+
+	// Every column starts at the same offset on every line
+	for _, column := range []string{"CREATED", "TAGS", "SUMMARY"} {
+		offset := strings.Index(lines[0], column)
+		for _, line := range lines[1:] {
+			if offset < 1 || line[offset-2:offset] != "  " || line[offset] == ' ' {
+				t.Errorf("column %s is not aligned at %d in %q", column, offset, line)
+			}
+		}
+	}
+	for _, line := range lines {
+		if len(line) > 80 {
+			t.Errorf("line exceeds terminal width (%d): %q", len(line), line)
+		}
+	}
+	if !strings.Contains(output, "...") {
+		t.Errorf("long summary should be truncated, got:\n%s", output)
+	}
+
+	// Piped output keeps the simple format
+	stdoutIsTerminal = func() bool { return false }
+	output, _ = captureStdout(t, func() error {
+		return CmdList([]string{"--table"})
+	})
+	if strings.Contains(output, "FILENAME") {
+		t.Errorf("non-terminal output should not be a table, got:\n%s", output)
+	}
+}
+
+func TestCmdGraphIncludeTagEdges(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"neo", "eval"}, "Summary A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"neo", "eval"}, "Summary B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "C", []string{"neo"}, "Summary C")
+	createEnrichedTestNote(t, tmpDir, "d.md", "D", []string{"other"}, "Summary D")
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.AddRelation("a.md", "b.md")
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error {
+		return CmdGraph([]string{"--flat", "--include-tag-edges", "--no-summaries"})
+	})
+	if err != nil {
+		t.Fatalf("CmdGraph(--include-tag-edges) error = %v", err)
+	}
+	var graph FlatGraph
+	if err := json.Unmarshal([]byte(output), &graph); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, output)
+	}
+
+	// a-b is explicit and must not reappear as inferred
+	want := []FlatEdge{
+		{Source: "a.md", Target: "b.md", SharedTags: []string{"eval", "neo"}},
+		{Source: "a.md", Target: "c.md", SharedTags: []string{"neo"}, Inferred: true},
+		{Source: "b.md", Target: "c.md", SharedTags: []string{"neo"}, Inferred: true},
+	}
+	if fmt.Sprint(graph.Edges) != fmt.Sprint(want) {
+		t.Errorf("edges = %+v, want %+v", graph.Edges, want)
+	}
+
+	// Requiring two shared tags leaves only the explicit edge
+	output, _ = captureStdout(t, func() error {
+		return CmdGraph([]string{"--format", "edgelist", "--include-tag-edges", "--min-shared", "2"})
+	})
+	if output != "source,target,shared_tags,kind\na.md,b.md,eval;neo,related\n" {
+		t.Errorf("edgelist = %q", output)
+	}
+
+	output, _ = captureStdout(t, func() error {
+		return CmdGraph([]string{"--include-tag-edges", "--no-summaries"})
+	})
+	if !strings.Contains(output, "a.md\n  → b.md (eval, neo)\n  ⇢ c.md (neo)\n") {
+		t.Errorf("text output should mark inferred edges, got:\n%s", output)
+	}
+	if n := strings.Count(output, "⇢"); n != 4 {
+		t.Errorf("want 2 inferred edges listed under both ends, got %d:\n%s", n, output)
+	}
+}
+
+func TestCmdExportHTML(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "old.md", "# Old plan\n\nFirst draft", []string{"work"}, "Old plan")
+	createEnrichedTestNote(t, tmpDir, "new.md", "# New plan\n\nSome **bold** text", []string{"work"}, "New plan")
+	note, _ := ParseNote(filepath.Join(tmpDir, "new.md"))
+	note.Frontmatter.Created.Time = note.Frontmatter.Created.AddDate(0, 0, 1)
+	note.Frontmatter.Related = []string{"old.md"}
+	note.Save(filepath.Join(tmpDir, "new.md"))
+
+	outDir := t.TempDir()
+	if _, err := captureStdout(t, func() error {
+		return CmdExport([]string{"--format", "html", "--out", outDir})
+	}); err != nil {
+		t.Fatalf("CmdExport(--format html) error = %v", err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(outDir, "new.html"))
+	if err != nil {
+		t.Fatalf("new.html not written: %v", err)
+	}
+	for _, want := range []string{"<h1>New plan</h1>", "<strong>bold</strong>", `<a href="old.html">old</a>`} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("new.html should contain %q:\n%s", want, page)
+		}
+	}
+
+	index, err := os.ReadFile(filepath.Join(outDir, "index.html"))
+	if err != nil {
+		t.Fatalf("index.html not written: %v", err)
+	}
+	newAt := strings.Index(string(index), `href="new.html"`)
+	oldAt := strings.Index(string(index), `href="old.html"`)
+	if newAt < 0 || oldAt < 0 || newAt > oldAt {
+		t.Errorf("index should list new.html before old.html:\n%s", index)
+	}
+}
+
+func TestCmdExportJSON(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "First note", []string{"work"}, "First")
+	createTestNote(t, tmpDir, "b.md", "Second note")
+
+	outDir := t.TempDir()
+	if _, err := captureStdout(t, func() error {
+		return CmdExport([]string{outDir, "--format", "json"})
+	}); err != nil {
+		t.Fatalf("CmdExport(--format json) error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "notes.json"))
+	if err != nil {
+		t.Fatalf("notes.json not written: %v", err)
+	}
+	var exported []ExportNoteOutput
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	if len(exported) != 2 || exported[0].Filename != "a.md" || exported[1].Filename != "b.md" {
+		t.Fatalf("exported = %+v", exported)
+	}
+	if exported[0].Summary != "First" || fmt.Sprint(exported[0].Tags) != "[work]" || !strings.Contains(exported[0].Content, "First note") {
+		t.Errorf("a.md = %+v", exported[0])
+	}
+	if exported[1].Tags == nil || exported[1].Related == nil {
+		t.Error("empty tags and related should be arrays")
+	}
+}
+
+func TestCmdExportPDF(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "# Release plan\n\nShip it", []string{"work"}, "Plan")
+
+	if _, err := findPDFBackend(); err != nil {
+		t.Skip("no PDF backend available")
+	}
+
+	outDir := t.TempDir()
+	if _, err := captureStdout(t, func() error {
+		return CmdExport([]string{outDir, "--format", "pdf"})
+	}); err != nil {
+		t.Fatalf("CmdExport(--format pdf) error = %v", err)
+	}
+	info, err := os.Stat(filepath.Join(outDir, "a.pdf"))
+	if err != nil || info.Size() == 0 {
+		t.Errorf("want a non-empty a.pdf, got %v", err)
+	}
+}
+
+func TestCmdExportPDFBackend(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "# Release plan\n\nShip it", []string{"work"}, "Plan")
+
+	// No backend: a helpful error and nothing exported
+	path := os.Getenv("PATH")
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("NOTES_PDF_COMMAND", "")
+	outDir := t.TempDir()
+	err := CmdExport([]string{outDir, "--format", "pdf"})
+	if err == nil || !strings.Contains(err.Error(), "NOTES_PDF_COMMAND") {
+		t.Errorf("want an error explaining how to configure a backend, got %v", err)
+	}
+
+	// A custom command gets the rendered HTML as $1
+	t.Setenv("PATH", path)
+	t.Setenv("NOTES_PDF_COMMAND", `/bin/cp "$1" "$2"`)
+	if _, err := captureStdout(t, func() error {
+		return CmdExport([]string{outDir, "--format", "pdf"})
+	}); err != nil {
+		t.Fatalf("CmdExport(--format pdf) error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "a.pdf"))
+	if err != nil {
+		t.Fatalf("a.pdf not written: %v", err)
+	}
+	for _, want := range []string{"<h1>Release plan</h1>", `<span class="tag">work</span>`, "<p>Ship it</p>"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("rendered HTML missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestCmdGraphClusterTags(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"infra", "k8s"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"infra", "k8s", "Oncall"}, "B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "C", []string{"infra", "oncall"}, "C")
+	createEnrichedTestNote(t, tmpDir, "d.md", "D", []string{"infra", "zeta"}, "D")
+	createEnrichedTestNote(t, tmpDir, "e.md", "E", []string{"books"}, "E")
+	createEnrichedTestNote(t, tmpDir, "f.md", "F", []string{"books"}, "F")
+	createEnrichedTestNote(t, tmpDir, "lonely.md", "G", []string{"infra"}, "G")
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.AddRelation("b.md", "a.md")
+	meta.AddRelation("b.md", "c.md")
+	meta.AddRelation("b.md", "d.md")
+	meta.AddRelation("e.md", "f.md")
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error {
+		return CmdGraph([]string{"--cluster-tags", "--json"})
+	})
+	if err != nil {
+		t.Fatalf("CmdGraph(--cluster-tags) error = %v", err)
+	}
+
+	var result struct {
+		Clusters  []graphCluster `json:"clusters"`
+		Unrelated int            `json:"unrelated"`
+	}
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, output)
+	}
+	if len(result.Clusters) != 2 || result.Unrelated != 1 {
+		t.Fatalf("want 2 clusters and 1 unrelated note, got %+v", result)
+	}
+
+	// infra on 4 members, k8s and oncall on 2 each, zeta on 1
+	big := result.Clusters[0]
+	if big.Size != 4 || fmt.Sprint(big.TopTags) != "[infra k8s oncall]" || big.Representative != "b.md" {
+		t.Errorf("largest cluster = %+v", big)
+	}
+	small := result.Clusters[1]
+	if fmt.Sprint(small.Members) != "[e.md f.md]" || fmt.Sprint(small.TopTags) != "[books]" {
+		t.Errorf("second cluster = %+v", small)
+	}
+
+	output, _ = captureStdout(t, func() error {
+		return CmdGraph([]string{"--cluster-tags", "--no-summaries"})
+	})
+	if !strings.HasPrefix(output, "Cluster 1 (4 notes): infra, k8s, oncall\n  representative: b.md\n  a.md\n") {
+		t.Errorf("text output = %q", output)
+	}
+	if !strings.HasSuffix(output, "1 notes without relations\n") {
+		t.Errorf("text output should count unrelated notes, got %q", output)
+	}
+}
+
+func TestCmdGraphPath(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	for _, name := range []string{"a.md", "b.md", "c.md", "d.md", "e.md", "island.md"} {
+		createEnrichedTestNote(t, tmpDir, name, strings.ToUpper(name[:1]), []string{"tag"}, "")
+	}
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.AddRelation("a.md", "b.md")
+	meta.AddRelation("b.md", "c.md")
+	meta.AddRelation("c.md", "d.md")
+	meta.AddRelation("a.md", "e.md")
+	meta.AddRelation("e.md", "d.md")
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error {
+		return CmdGraph([]string{"--path", "--no-summaries", "a.md", "d"})
+	})
+	if err != nil {
+		t.Fatalf("CmdGraph(--path) error = %v", err)
+	}
+	if want := "a.md -> d.md (2 hops)\n  a.md\n  e.md\n  d.md\n"; output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+
+	output, err = captureStdout(t, func() error { return CmdGraph([]string{"--path", "--json", "b.md", "e.md"}) })
+	if err != nil {
+		t.Fatalf("CmdGraph(--path --json) error = %v", err)
+	}
+	var result struct {
+		Hops int      `json:"hops"`
+		Path []string `json:"path"`
+	}
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, output)
+	}
+	if result.Hops != 2 || fmt.Sprint(result.Path) != "[b.md a.md e.md]" {
+		t.Errorf("path = %+v", result)
+	}
+
+	if err := CmdGraph([]string{"--path", "a.md", "island.md"}); err == nil || !strings.Contains(err.Error(), "not connected") {
+		t.Errorf("expected a not connected error, got %v", err)
+	}
+	if err := CmdGraph([]string{"--path", "a.md", "missing.md"}); err == nil || !strings.Contains(err.Error(), "note not found: missing.md") {
+		t.Errorf("expected a note not found error, got %v", err)
+	}
+}
+
+func TestCmdGraphClustersSingletons(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"infra"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"infra"}, "B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "C", []string{"books"}, "C")
+	createEnrichedTestNote(t, tmpDir, "d.md", "D", []string{"books"}, "D")
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.AddRelation("a.md", "b.md")
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error {
+		return CmdGraph([]string{"--clusters", "--singletons", "--no-summaries"})
+	})
+	if err != nil {
+		t.Fatalf("CmdGraph(--clusters --singletons) error = %v", err)
+	}
+	want := "Cluster 1 (2 notes)\n  a.md\n  b.md\n\n2 notes without relations\n  c.md\n  d.md\n"
+	if output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+
+	output, _ = captureStdout(t, func() error { return CmdGraph([]string{"--clusters", "--singletons", "--json"}) })
+	if !strings.Contains(output, `"unrelated": 2`) || !strings.Contains(output, `"unrelated_notes": [`) {
+		t.Errorf("JSON should count and list unrelated notes, got:\n%s", output)
+	}
+
+	if err := CmdGraph([]string{"--singletons"}); err == nil {
+		t.Error("expected an error for --singletons without --clusters")
+	}
+}
+
+func TestCmdValidate(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"neo"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"neo"}, "B")
+	createEnrichedTestNote(t, tmpDir, "gone.md", "Gone", []string{"neo"}, "Gone")
+	os.Remove(filepath.Join(tmpDir, "gone.md"))
+	os.WriteFile(filepath.Join(tmpDir, "broken.md"), []byte("---\ntags: [unclosed\n---\nBody\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "undated.md"), []byte("---\ntags: []\nsummary: \"\"\nrelated: []\n---\n\nNo date\n"), 0644)
+
+	note, _ := ParseNote(filepath.Join(tmpDir, "a.md"))
+	note.Frontmatter.Related = []string{"b.md", "missing.md"}
+	note.Content = "\nA edited\n"
+	note.Save(filepath.Join(tmpDir, "a.md"))
+
+	output, err := captureStdout(t, func() error { return CmdValidate(nil) })
+	if err == nil || err.Error() != "6 problems found" {
+		t.Errorf("CmdValidate() error = %v, want 6 problems found", err)
+	}
+	for _, want := range []string{
+		"Invalid frontmatter: broken.md",
+		"Missing created date: undated.md\n",
+		"Dead relation: a.md -> missing.md\n",
+		"One-sided relation: a.md -> b.md\n",
+		"Entry for missing file: gone.md\n",
+		"Stale content hash: a.md",
+		"4 can be fixed with --fix",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+
+	output, err = captureStdout(t, func() error { return CmdValidate([]string{"--fix"}) })
+	if err == nil || err.Error() != "2 problems found" {
+		t.Errorf("CmdValidate(--fix) error = %v, want the 2 unfixable problems", err)
+	}
+	if !strings.Contains(output, "Fixed 4 problems") {
+		t.Errorf("output = %q", output)
+	}
+
+	a, _ := ParseNote(filepath.Join(tmpDir, "a.md"))
+	b, _ := ParseNote(filepath.Join(tmpDir, "b.md"))
+	undated, _ := ParseNote(filepath.Join(tmpDir, "undated.md"))
+	if fmt.Sprint(a.Frontmatter.Related) != "[b.md]" || fmt.Sprint(b.Frontmatter.Related) != "[a.md]" {
+		t.Errorf("relations after --fix: a=%v b=%v", a.Frontmatter.Related, b.Frontmatter.Related)
+	}
+	if undated.Frontmatter.Created.IsZero() {
+		t.Error("expected --fix to set a created date")
+	}
+	meta, _ := LoadMetaFile(tmpDir)
+	if meta.GetFileMeta("gone.md") != nil {
+		t.Error("expected --fix to drop the entry for the missing file")
+	}
+
+	// Once the remaining problems are gone, validate passes
+	os.Remove(filepath.Join(tmpDir, "broken.md"))
+	meta.UpdateFromNote(a)
+	meta.Save(tmpDir)
+	output, err = captureStdout(t, func() error { return CmdValidate(nil) })
+	if err != nil || !strings.Contains(output, "No problems in 3 notes") {
+		t.Errorf("CmdValidate() = %q, %v", output, err)
+	}
+}
+
+func TestCmdUpdateWaitsForMetaLock(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "a.md", "A")
+	orig := metaLockTimeout
+	metaLockTimeout = 50 * time.Millisecond
+	defer func() { metaLockTimeout = orig }()
+
+	unlock, err := LockMeta(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CmdUpdate([]string{"a.md", "--summary", "Blocked"})
+	if err == nil || !strings.Contains(err.Error(), ".meta.lock") {
+		t.Errorf("CmdUpdate() while locked: error = %v, want a lock timeout", err)
+	}
+	if err := CmdSync(nil); err == nil {
+		t.Error("CmdSync() while locked should time out")
+	}
+	if _, err := captureStdout(t, func() error { return CmdSync([]string{"--dry-run"}) }); err != nil {
+		t.Errorf("CmdSync(--dry-run) doesn't write and shouldn't need the lock: %v", err)
+	}
+	unlock()
+
+	if _, err := captureStdout(t, func() error { return CmdUpdate([]string{"a.md", "--summary", "Free"}) }); err != nil {
+		t.Fatalf("CmdUpdate() after unlock error = %v", err)
+	}
+}
+
+func TestMetaWritersWaitForMetaLock(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"go"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"go"}, "B")
+	orig := metaLockTimeout
+	metaLockTimeout = 50 * time.Millisecond
+	defer func() { metaLockTimeout = orig }()
+
+	unlock, err := LockMeta(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	commands := map[string]func() error{
+		"relate":      func() error { return CmdRelate([]string{"a.md", "b.md"}) },
+		"delete":      func() error { return CmdDelete([]string{"a.md"}) },
+		"rename":      func() error { return CmdRename([]string{"a.md", "c.md"}) },
+		"pin":         func() error { return CmdPin([]string{"a.md"}) },
+		"tag rename":  func() error { return CmdTag([]string{"rename", "go", "golang"}) },
+		"prune-tags":  func() error { return CmdPruneTags([]string{"--min", "5"}) },
+		"reindex":     func() error { return CmdReindex(nil) },
+		"validate":    func() error { return CmdValidate([]string{"--fix"}) },
+		"meta ensure": func() error { return CmdMeta([]string{"--ensure", "a.md"}) },
+	}
+	for name, run := range commands {
+		_, err := captureStdout(t, run)
+		if err == nil || !strings.Contains(err.Error(), ".meta.lock") {
+			t.Errorf("%s while locked: error = %v, want a lock timeout", name, err)
+		}
+	}
+}
+
+func TestCmdPruneTagsKeepsEditsMadeWhileWaiting(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "Before", []string{"common", "rare"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"common"}, "B")
+
+	unlock, err := LockMeta(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- CmdPruneTags(nil) }()
+
+	// Another process edits a.md while prune-tags waits for the lock
+	time.Sleep(100 * time.Millisecond)
+	data, _ := os.ReadFile(filepath.Join(tmpDir, "a.md"))
+	edited := strings.Replace(string(data), "Before", "Edited meanwhile", 1)
+	os.WriteFile(filepath.Join(tmpDir, "a.md"), []byte(edited), 0644)
+	unlock()
+
+	if err := <-done; err != nil {
+		t.Fatalf("CmdPruneTags() error = %v", err)
+	}
+	note, _ := ParseNote(filepath.Join(tmpDir, "a.md"))
+	if note.Content != "\nEdited meanwhile\n" || Contains(note.Frontmatter.Tags, "rare") {
+		t.Errorf("a.md = %q with tags %v, want the edit kept and rare pruned", note.Content, note.Frontmatter.Tags)
+	}
+}
+
+func TestCmdConfigListLimit(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	for i := 0; i < 5; i++ {
+		createTestNote(t, tmpDir, fmt.Sprintf("note-%d.md", i), "Content")
+	}
+
+	if _, err := captureStdout(t, func() error {
+		return CmdConfig([]string{"--set", "list_limit=2"})
+	}); err != nil {
+		t.Fatalf("CmdConfig(--set) error = %v", err)
+	}
+
+	output, err := captureStdout(t, func() error { return CmdConfig([]string{"--get", "list_limit"}) })
+	if err != nil || output != "2\n" {
+		t.Errorf("CmdConfig(--get) = %q, %v", output, err)
+	}
+
+	output, _ = captureStdout(t, func() error { return CmdList([]string{"--raw"}) })
+	if n := strings.Count(output, "\n"); n != 2 {
+		t.Errorf("list should honor list_limit, got %d notes:\n%s", n, output)
+	}
+
+	// An explicit flag still wins
+	output, _ = captureStdout(t, func() error { return CmdList([]string{"--raw", "--limit", "4"}) })
+	if n := strings.Count(output, "\n"); n != 4 {
+		t.Errorf("--limit should override list_limit, got %d notes", n)
+	}
+
+	// Large numbers are read back as written, not in exponent notation
+	captureStdout(t, func() error { return CmdConfig([]string{"--set", "list_limit=1000000"}) })
+	output, err = captureStdout(t, func() error { return CmdConfig([]string{"--get", "list_limit"}) })
+	if err != nil || output != "1000000\n" {
+		t.Errorf("CmdConfig(--get) = %q, %v", output, err)
+	}
+	output, err = captureStdout(t, func() error { return CmdList([]string{"--raw"}) })
+	if err != nil || strings.Count(output, "\n") != 5 {
+		t.Errorf("list with list_limit=1000000 = %q, %v", output, err)
+	}
+
+	// Unknown keys and ill-typed values are rejected
+	if err := CmdConfig([]string{"--set", "list_limit=many"}); err == nil {
+		t.Error("non-numeric list_limit should be rejected")
+	}
+	if err := CmdConfig([]string{"--set", "colour=always"}); err == nil {
+		t.Error("unknown key should be rejected")
+	}
+}
+
+func TestCmdConfigTagLogic(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+	t.Setenv("NOTES_TAG_LOGIC", "")
+
+	createEnrichedTestNote(t, tmpDir, "both.md", "Content", []string{"go", "cli"}, "Both")
+	createEnrichedTestNote(t, tmpDir, "go.md", "Content", []string{"go"}, "Go only")
+
+	if _, err := captureStdout(t, func() error {
+		return CmdConfig([]string{"set", "tag_logic", "and"})
+	}); err != nil {
+		t.Fatalf("CmdConfig(set) error = %v", err)
+	}
+	output, err := captureStdout(t, func() error { return CmdConfig([]string{"get", "tag_logic"}) })
+	if err != nil || output != "and\n" {
+		t.Errorf("CmdConfig(get) = %q, %v", output, err)
+	}
+
+	output, _ = captureStdout(t, func() error { return CmdList([]string{"--raw", "--tags", "go,cli"}) })
+	if output != "both.md\n" {
+		t.Errorf("list should default to tag_logic and, got:\n%s", output)
+	}
+
+	// The flag and then the environment variable override the config file
+	output, _ = captureStdout(t, func() error {
+		return CmdList([]string{"--raw", "--tags", "go,cli", "--tag-logic", "or"})
+	})
+	if output != "both.md\ngo.md\n" {
+		t.Errorf("--tag-logic or should override the setting, got:\n%s", output)
+	}
+	t.Setenv("NOTES_TAG_LOGIC", "or")
+	output, _ = captureStdout(t, func() error { return CmdList([]string{"--raw", "--tags", "go,cli"}) })
+	if output != "both.md\ngo.md\n" {
+		t.Errorf("NOTES_TAG_LOGIC should override the config file, got:\n%s", output)
+	}
+
+	if err := CmdConfig([]string{"set", "tag_logic", "xor"}); err == nil {
+		t.Error("invalid tag_logic should be rejected")
+	}
+	if err := CmdConfig([]string{"get"}); err == nil {
+		t.Error("get without a key should fail")
+	}
+}
+
+func TestSettingsLayering(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	t.Setenv("EDITOR", "")
+	if editor := GetEditor(); editor != "vim" {
+		t.Errorf("GetEditor() default = %q, want vim", editor)
+	}
+
+	captureStdout(t, func() error { return CmdConfig([]string{"--set", "editor=nano"}) })
+	if editor := GetEditor(); editor != "nano" {
+		t.Errorf("GetEditor() from config = %q, want nano", editor)
+	}
+
+	t.Setenv("EDITOR", "emacs")
+	if editor := GetEditor(); editor != "emacs" {
+		t.Errorf("GetEditor() should prefer $EDITOR over the config file, got %q", editor)
+	}
+}
+
+func TestSettingsReadConfigOnce(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	t.Setenv("NOTES_TIME_FORMAT", "")
+	t.Setenv("EDITOR", "")
+	path, _ := GetConfigPath()
+	os.WriteFile(path, []byte("{broken"), 0644)
+
+	r, w, _ := os.Pipe()
+	stderr := os.Stderr
+	os.Stderr = w
+	for i := 0; i < 3; i++ {
+		GetTimeFormat()
+		GetEditor()
+	}
+	os.Stderr = stderr
+	w.Close()
+	output, _ := io.ReadAll(r)
+
+	if n := strings.Count(string(output), "Warning: ignoring config file"); n != 1 {
+		t.Errorf("broken config file reported %d times, want once:\n%s", n, output)
+	}
+
+	// Saving the config file is picked up right away
+	os.Remove(path)
+	captureStdout(t, func() error { return CmdConfig([]string{"--set", "time_format=rfc3339"}) })
+	if format := GetTimeFormat(); format != time.RFC3339 {
+		t.Errorf("GetTimeFormat() after --set = %q, want RFC 3339", format)
+	}
+}
+
+func TestCmdVerifyHashes(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "ok.md", "Unchanged", []string{"neo"}, "OK")
+	createEnrichedTestNote(t, tmpDir, "drifted.md", "Original", []string{"neo"}, "Drifted")
+	createEnrichedTestNote(t, tmpDir, "gone.md", "Deleted", []string{"neo"}, "Gone")
+
+	output, err := captureStdout(t, func() error { return CmdVerifyHashes(nil) })
+	if err != nil || output != "All 3 hashes match\n" {
+		t.Fatalf("clean notebook: output = %q, err = %v", output, err)
+	}
+
+	// Edited and deleted behind the tool's back
+	note, _ := ParseNote(filepath.Join(tmpDir, "drifted.md"))
+	note.Content = "\nEdited elsewhere\n"
+	note.Save(filepath.Join(tmpDir, "drifted.md"))
+	os.Remove(filepath.Join(tmpDir, "gone.md"))
+	metaBefore, _ := os.ReadFile(filepath.Join(tmpDir, ".meta.json"))
+
+	output, err = captureStdout(t, func() error { return CmdVerifyHashes(nil) })
+	if err == nil || !strings.Contains(err.Error(), "2 of 3") {
+		t.Errorf("want an error for 2 of 3 entries, got %v", err)
+	}
+	if !strings.Contains(output, "Changed: drifted.md") || !strings.Contains(output, "Missing: gone.md") {
+		t.Errorf("output should report both problems, got:\n%s", output)
+	}
+	if strings.Contains(output, "ok.md") {
+		t.Errorf("intact note reported:\n%s", output)
+	}
+
+	metaAfter, _ := os.ReadFile(filepath.Join(tmpDir, ".meta.json"))
+	if string(metaAfter) != string(metaBefore) {
+		t.Error("verify-hashes must not modify .meta.json")
+	}
+}
+
+func TestCmdNewClipboard(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	clipboard := "  Copied from the browser\n\nwith a second paragraph\n\n"
+	orig := readClipboard
+	readClipboard = func() (string, error) { return clipboard, nil }
+	defer func() { readClipboard = orig }()
+
+	if _, err := captureStdout(t, func() error { return CmdNew([]string{"--clipboard"}) }); err != nil {
+		t.Fatalf("CmdNew --clipboard failed: %v", err)
+	}
+
+	notesList, _ := LoadAllNotes(tmpDir)
+	if len(notesList) != 1 {
+		t.Fatalf("want 1 note, got %d", len(notesList))
+	}
+	want := "\nCopied from the browser\n\nwith a second paragraph\n"
+	if notesList[0].Content != want {
+		t.Errorf("Content = %q, want %q", notesList[0].Content, want)
+	}
+
+	// Same clipboard again is caught as a duplicate
+	if _, err := captureStdout(t, func() error { return CmdNew([]string{"--clipboard"}) }); err == nil {
+		t.Error("expected a duplicate error for unchanged clipboard")
+	}
+
+	if err := CmdNew([]string{"--clipboard", "extra"}); err == nil {
+		t.Error("expected an error when combining --clipboard with content")
+	}
+
+	clipboard = " \n"
+	if err := CmdNew([]string{"--clipboard"}); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Errorf("expected an empty clipboard error, got %v", err)
+	}
+
+	readClipboard = func() (string, error) { return "", fmt.Errorf("no clipboard tool found") }
+	if err := CmdNew([]string{"--clipboard"}); err == nil || !strings.Contains(err.Error(), "no clipboard tool") {
+		t.Errorf("expected the reader's error, got %v", err)
+	}
+}
+
+func TestCmdNewWithFrontmatterFlags(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if _, err := captureStdout(t, func() error {
+		return CmdNew([]string{"--tags", "ml, go", "--related", "other", "--summary", "Training notes", "Tuning", "the", "model"})
+	}); err != nil {
+		t.Fatalf("CmdNew() error = %v", err)
+	}
+	notesList, _ := LoadAllNotes(tmpDir)
+	if len(notesList) != 1 {
+		t.Fatalf("want 1 note, got %d", len(notesList))
+	}
+	note := notesList[0]
+	fm := note.Frontmatter
+	if fmt.Sprint(fm.Tags) != "[go ml]" || fmt.Sprint(fm.Related) != "[other.md]" || fm.Summary != "Training notes" {
+		t.Errorf("frontmatter = %+v", fm)
+	}
+	if note.Content != "\nTuning the model\n" {
+		t.Errorf("Content = %q", note.Content)
+	}
+
+	// Tags and summary together are tracked so the note isn't flagged
+	meta, _ := LoadMetaFile(tmpDir)
+	filename := filepath.Base(note.Filename)
+	if meta.NeedsEnrichment(filename, note.ContentHash()) {
+		t.Error("note with tags and a summary should not need enrichment")
+	}
+	if fileMeta := meta.GetFileMeta(filename); fileMeta == nil || fileMeta.Summary != "Training notes" {
+		t.Errorf("meta entry = %+v", fileMeta)
+	}
+
+	// Tags alone still leave the summary to enrichment
+	os.Remove(note.Filename)
+	os.Remove(filepath.Join(tmpDir, ".meta.json"))
+	if _, err := captureStdout(t, func() error { return CmdNew([]string{"--tags", "go", "Tags only"}) }); err != nil {
+		t.Fatalf("CmdNew() error = %v", err)
+	}
+	notesList, _ = LoadAllNotes(tmpDir)
+	meta, _ = LoadMetaFile(tmpDir)
+	if len(notesList) != 1 || meta.GetFileMeta(filepath.Base(notesList[0].Filename)) != nil {
+		t.Error("a note without a summary should not be tracked yet")
+	}
+
+	if err := CmdNew([]string{"--tags", "go", "--no-frontmatter", "Plain"}); err == nil {
+		t.Error("expected an error combining --tags with --no-frontmatter")
+	}
+}
+
+func TestCmdRecent(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	now := time.Now()
+	for name, age := range map[string]time.Duration{"today.md": time.Hour, "last-week.md": 5 * 24 * time.Hour, "old.md": 30 * 24 * time.Hour} {
+		note := &Note{Frontmatter: Frontmatter{Created: NoteTime{now.Add(-age)}}, Content: "\n" + name + "\n"}
+		if err := note.Save(filepath.Join(tmpDir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "today.md\nlast-week.md\n"},
+		{[]string{"24h"}, "today.md\n"},
+		{[]string{"2w"}, "today.md\nlast-week.md\n"},
+	} {
+		output, err := captureStdout(t, func() error { return CmdRecent(append(c.args, "--raw")) })
+		if err != nil {
+			t.Fatalf("CmdRecent(%v) error = %v", c.args, err)
+		}
+		if output != c.want {
+			t.Errorf("CmdRecent(%v) = %q, want %q", c.args, output, c.want)
+		}
+	}
+
+	output, err := captureStdout(t, func() error { return CmdList([]string{"--within", "1w", "--raw"}) })
+	if err != nil || output != "today.md\nlast-week.md\n" {
+		t.Errorf("CmdList(--within 1w) = %q, %v", output, err)
+	}
+	if err := CmdList([]string{"--within", "7d", "--since", "2025-01-01"}); err == nil {
+		t.Error("expected an error combining --within and --since")
+	}
+	if err := CmdRecent([]string{"soon"}); err == nil {
+		t.Error("expected an error for an invalid duration")
+	}
+}
+
+func TestCmdOpen(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createTestNote(t, tmpDir, "2025-01-11-1423.md", "Content")
+
+	var opened []string
+	orig := openInApp
+	openInApp = func(path string) error { opened = append(opened, path); return nil }
+	defer func() { openInApp = orig }()
+
+	if err := CmdOpen(nil); err != nil {
+		t.Fatalf("CmdOpen() error = %v", err)
+	}
+	if err := CmdOpen([]string{"1423"}); err != nil {
+		t.Fatalf("CmdOpen(1423) error = %v", err)
+	}
+	want := []string{tmpDir, filepath.Join(tmpDir, "2025-01-11-1423.md")}
+	if !stringSliceEqual(opened, want) {
+		t.Errorf("opened %v, want %v", opened, want)
+	}
+	if err := CmdOpen([]string{"missing"}); err == nil {
+		t.Error("expected an error for a missing note")
+	}
+
+	for goos, opener := range map[string]string{"darwin": "open", "linux": "xdg-open", "windows": "cmd"} {
+		if command, err := openerCommand(goos, "x.md"); err != nil || command[0] != opener || command[len(command)-1] != "x.md" {
+			t.Errorf("openerCommand(%s) = %v, %v", goos, command, err)
+		}
+	}
+	if _, err := openerCommand("plan9", "x.md"); err == nil {
+		t.Error("expected an error on an unsupported OS")
+	}
+}
+
+func TestCmdListOrphans(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "hub.md", "Hub", []string{"neo"}, "Hub")
+	createEnrichedTestNote(t, tmpDir, "linked.md", "Linked", []string{"neo"}, "Linked")
+	createEnrichedTestNote(t, tmpDir, "tagged.md", "Tagged only", []string{"neo"}, "Tagged")
+	createEnrichedTestNote(t, tmpDir, "bare.md", "Nothing at all", []string{}, "Bare")
+
+	// Only hub.md lists the relation; linked.md is related through it
+	note, _ := ParseNote(filepath.Join(tmpDir, "hub.md"))
+	note.Frontmatter.Related = []string{"linked.md"}
+	note.Save(filepath.Join(tmpDir, "hub.md"))
+
+	list := func(args ...string) string {
+		output, err := captureStdout(t, func() error {
+			return CmdList(append([]string{"--raw", "--sort", "none"}, args...))
+		})
+		if err != nil {
+			t.Fatalf("CmdList(%v) error = %v", args, err)
+		}
+		lines := strings.Fields(output)
+		sort.Strings(lines)
+		return strings.Join(lines, " ")
+	}
+
+	if got := list("--orphans"); got != "bare.md tagged.md" {
+		t.Errorf("--orphans listed %q, want notes without relations", got)
+	}
+	if got := list("--orphans", "--strict"); got != "bare.md" {
+		t.Errorf("--orphans --strict listed %q, want notes without relations or tags", got)
+	}
+	// Combines with the usual filters
+	if got := list("--orphans", "--tags", "neo"); got != "tagged.md" {
+		t.Errorf("--orphans --tags neo listed %q", got)
+	}
+
+	if err := CmdList([]string{"--strict"}); err == nil {
+		t.Error("expected an error for --strict without --orphans")
+	}
+}
+
+func TestCmdStats(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "one two three", []string{"neo", "go"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "four five", []string{"Neo"}, "B")
+	createTestNote(t, tmpDir, "c.md", "six")
+	captureStdout(t, func() error { return CmdRelate([]string{"a.md", "b.md"}) })
+
+	// Created later than the others
+	note, _ := ParseNote(filepath.Join(tmpDir, "c.md"))
+	note.Frontmatter.Created = NoteTime{time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)}
+	note.Save(filepath.Join(tmpDir, "c.md"))
+
+	output, err := captureStdout(t, func() error { return CmdStats([]string{"--json"}) })
+	if err != nil {
+		t.Fatalf("CmdStats(--json) error = %v", err)
+	}
+	var stats StatsOutput
+	if err := json.Unmarshal([]byte(output), &stats); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, output)
+	}
+	want := StatsOutput{
+		Notes:       3,
+		Enriched:    2,
+		Unenriched:  1,
+		UniqueTags:  2,
+		AvgTags:     1,
+		Orphans:     1,
+		Oldest:      "2025-01-11T14:23:00Z",
+		Newest:      "2025-02-01T10:00:00Z",
+		Words:       6,
+		ReadingTime: "1 min",
+	}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	output, _ = captureStdout(t, func() error { return CmdStats(nil) })
+	for _, line := range []string{"Notes:    3 (2 enriched, 1 need enrichment)", "Tags:     2 unique, 1.0 per note", "Created:  2025-01-11 to 2025-02-01"} {
+		if !strings.Contains(output, line) {
+			t.Errorf("output missing %q:\n%s", line, output)
+		}
+	}
+
+	// The text output shows local dates: late in the evening west of UTC is
+	// already the next day in UTC
+	orig := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	defer func() { time.Local = orig }()
+	note.Frontmatter.Created = NoteTime{time.Date(2025, 2, 1, 23, 30, 0, 0, time.Local)}
+	note.Save(filepath.Join(tmpDir, "c.md"))
+	output, _ = captureStdout(t, func() error { return CmdStats(nil) })
+	if !strings.Contains(output, "to 2025-02-01\n") {
+		t.Errorf("output should show the local creation date:\n%s", output)
+	}
+}
+
+func TestCmdStatsNetworkHealth(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	related := map[string][]string{
+		"a.md": {"b.md", "c.md", "ghost.md"}, // a <-> b, a -> c, one dead link
+		"b.md": {"a", "b.md"},                // self-reference is ignored
+		"c.md": {},
+		"d.md": {"c.md", "gone.md", "gone"}, // d -> c, one dead link listed twice
+	}
+	for name, rels := range related {
+		createEnrichedTestNote(t, tmpDir, name, "Body of "+name, []string{"neo"}, "Summary")
+		note, _ := ParseNote(filepath.Join(tmpDir, name))
+		note.Frontmatter.Related = rels
+		note.Save(filepath.Join(tmpDir, name))
+	}
+
+	output, err := captureStdout(t, func() error {
+		return CmdStats([]string{"--dead-links", "--reciprocity", "--json"})
+	})
+	if err != nil {
+		t.Fatalf("CmdStats() error = %v", err)
+	}
+
+	var stats StatsOutput
+	if err := json.Unmarshal([]byte(output), &stats); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, output)
+	}
+	network := stats.Network
+	if stats.Notes != 4 || network == nil || network.Relations != 4 {
+		t.Fatalf("stats = %+v, want 4 notes and 4 relations", stats)
+	}
+	if network.DeadLinks == nil || *network.DeadLinks != 2 {
+		t.Errorf("dead links = %v, want 2", network.DeadLinks)
+	}
+	if network.Asymmetric == nil || *network.Asymmetric != 2 {
+		t.Errorf("asymmetric = %v, want 2 (a -> c and d -> c)", network.Asymmetric)
+	}
+	if network.Reciprocity == nil || *network.Reciprocity != 0.5 {
+		t.Errorf("reciprocity = %v, want 0.5", network.Reciprocity)
+	}
+
+	// Only the requested metrics are reported
+	output, _ = captureStdout(t, func() error { return CmdStats([]string{"--dead-links"}) })
+	if !strings.Contains(output, "Dead links:   2") || strings.Contains(output, "Reciprocity") {
+		t.Errorf("--dead-links output:\n%s", output)
+	}
+	output, _ = captureStdout(t, func() error { return CmdStats([]string{"--reciprocity"}) })
+	if !strings.Contains(output, "Reciprocity:  50% (2 of 4 relations returned)") || strings.Contains(output, "Dead links") {
+		t.Errorf("--reciprocity output:\n%s", output)
+	}
+}
+
+func TestCmdImportDirectory(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	srcDir := t.TempDir()
+	mtime := time.Date(2024, 3, 5, 8, 15, 0, 0, time.Local)
+	files := map[string]string{
+		"groceries.txt": "eggs\nmilk\n",
+		"idea.md":       "---\ntags: [ideas]\nsummary: An idea\n---\n\n# Idea\n\nBody\n",
+		"image.png":     "not text",
+	}
+	for name, content := range files {
+		path := filepath.Join(srcDir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		os.Chtimes(path, mtime, mtime)
+	}
+
+	output, err := captureStdout(t, func() error {
+		return CmdImport([]string{"--directory", srcDir, "--tags", "imported", "--move"})
+	})
+	if err != nil {
+		t.Fatalf("CmdImport() error = %v", err)
+	}
+	if !strings.Contains(output, "Imported 2 of 2 files") {
+		t.Errorf("output = %q", output)
+	}
+
+	notesList, _ := LoadAllNotes(tmpDir)
+	if len(notesList) != 2 {
+		t.Fatalf("want 2 notes, got %d", len(notesList))
+	}
+	meta, _ := LoadMetaFile(tmpDir)
+	bodies := make(map[string]*Note)
+	for _, note := range notesList {
+		filename := filepath.Base(note.Filename)
+		if !strings.HasPrefix(filename, "2024-03-05-0815") {
+			t.Errorf("filename %s should come from the file's mtime", filename)
+		}
+		if !note.Frontmatter.Created.Equal(mtime) || note.Frontmatter.ID == "" {
+			t.Errorf("%s frontmatter = %+v", filename, note.Frontmatter)
+		}
+		if fm := meta.GetFileMeta(filename); fm == nil || fm.ContentHash != note.ContentHash() {
+			t.Errorf("%s should be in .meta.json, got %+v", filename, fm)
+		}
+		bodies[strings.TrimSpace(note.Content)] = note
+	}
+
+	if note := bodies["eggs\nmilk"]; note == nil || fmt.Sprint(note.Frontmatter.Tags) != "[imported]" {
+		t.Errorf("text file not imported as expected: %+v", bodies)
+	}
+	// Existing frontmatter is replaced, keeping tags and summary
+	if note := bodies["# Idea\n\nBody"]; note == nil || fmt.Sprint(note.Frontmatter.Tags) != "[ideas imported]" || note.Frontmatter.Summary != "An idea" {
+		t.Errorf("markdown file not imported as expected: %+v", bodies)
+	}
+
+	// --move removed the imported originals only
+	remaining, _ := os.ReadDir(srcDir)
+	if len(remaining) != 1 || remaining[0].Name() != "image.png" {
+		t.Errorf("remaining files = %v, want only image.png", remaining)
+	}
+}
+
+func TestCmdDelete(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"neo"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"neo"}, "B")
+	createEnrichedTestNote(t, tmpDir, "c.md", "C", []string{"neo"}, "C")
+	if _, err := captureStdout(t, func() error { return CmdRelate([]string{"a.md", "b.md"}) }); err != nil {
+		t.Fatal(err)
+	}
+	captureStdout(t, func() error { return CmdRelate([]string{"a.md", "c.md"}) })
+
+	output, err := captureStdout(t, func() error { return CmdDelete([]string{"a.md", "--dry-run"}) })
+	if err != nil {
+		t.Fatalf("CmdDelete(--dry-run) error = %v", err)
+	}
+	if output != "Would remove backlink in b.md\nWould remove backlink in c.md\nWould delete a.md\n" {
+		t.Errorf("dry run output = %q", output)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "a.md")); err != nil {
+		t.Fatal("dry run should not delete the note")
+	}
+
+	if _, err := captureStdout(t, func() error { return CmdDelete([]string{"a"}) }); err != nil {
+		t.Fatalf("CmdDelete() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "a.md")); !os.IsNotExist(err) {
+		t.Error("a.md should be deleted")
+	}
+	meta, _ := LoadMetaFile(tmpDir)
+	if meta.GetFileMeta("a.md") != nil {
+		t.Error("a.md should be removed from .meta.json")
+	}
+	for _, other := range []string{"b.md", "c.md"} {
+		note, _ := ParseNote(filepath.Join(tmpDir, other))
+		if len(note.Frontmatter.Related) != 0 || len(meta.GetFileMeta(other).Related) != 0 {
+			t.Errorf("%s still relates to the deleted note: %v / %v", other, note.Frontmatter.Related, meta.GetFileMeta(other).Related)
+		}
+	}
+
+	if err := CmdDelete([]string{"missing.md"}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestCmdDeleteKeepBacklinks(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"neo"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"neo"}, "B")
+	captureStdout(t, func() error { return CmdRelate([]string{"a.md", "b.md"}) })
+
+	if _, err := captureStdout(t, func() error { return CmdDelete([]string{"a.md", "--keep-backlinks"}) }); err != nil {
+		t.Fatalf("CmdDelete(--keep-backlinks) error = %v", err)
+	}
+
+	note, _ := ParseNote(filepath.Join(tmpDir, "b.md"))
+	if fmt.Sprint(note.Frontmatter.Related) != "[a.md]" {
+		t.Errorf("b.md related = %v, want the backlink kept", note.Frontmatter.Related)
+	}
+}
+
+func TestCmdUndo(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"neo"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"neo"}, "B")
+	captureStdout(t, func() error { return CmdRelate([]string{"a.md", "b.md"}) })
+
+	read := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(tmpDir, name))
+		return string(data)
+	}
+	beforeA, beforeB, beforeMeta := read("a.md"), read("b.md"), read(".meta.json")
+
+	// Start without the snapshot of the relate above
+	os.RemoveAll(filepath.Join(tmpDir, HistoryDir))
+	if err := CmdUndo(nil); err == nil || err.Error() != "nothing to undo" {
+		t.Errorf("CmdUndo() without history error = %v", err)
+	}
+
+	// Undo an update that replaced the tags
+	if _, err := captureStdout(t, func() error { return CmdUpdate([]string{"a.md", "--tags", "clobbered"}) }); err != nil {
+		t.Fatalf("CmdUpdate() error = %v", err)
+	}
+	output, err := captureStdout(t, func() error { return CmdUndo(nil) })
+	if err != nil {
+		t.Fatalf("CmdUndo() error = %v", err)
+	}
+	if !strings.HasPrefix(output, "Undid update from ") || !strings.HasSuffix(output, "restored a.md, .meta.json\n") {
+		t.Errorf("CmdUndo() output = %q", output)
+	}
+	if read("a.md") != beforeA || read(".meta.json") != beforeMeta {
+		t.Error("undo should restore a.md and .meta.json as they were before the update")
+	}
+
+	// Undo a delete, including the removed backlink
+	if _, err := captureStdout(t, func() error { return CmdDelete([]string{"a.md"}) }); err != nil {
+		t.Fatalf("CmdDelete() error = %v", err)
+	}
+	output, _ = captureStdout(t, func() error { return CmdUndo([]string{"--list"}) })
+	if !strings.Contains(output, "delete      b.md, a.md, .meta.json") {
+		t.Errorf("CmdUndo(--list) output = %q", output)
+	}
+	if _, err := captureStdout(t, func() error { return CmdUndo(nil) }); err != nil {
+		t.Fatalf("CmdUndo() after delete error = %v", err)
+	}
+	if read("a.md") != beforeA || read("b.md") != beforeB || read(".meta.json") != beforeMeta {
+		t.Error("undo should bring back the deleted note, its backlink and its meta entry")
+	}
+
+	// Each snapshot is used once
+	if err := CmdUndo(nil); err == nil {
+		t.Error("second undo should have nothing left to undo")
+	}
+
+	// A failed update changes nothing, so there is nothing to undo
+	if err := CmdUpdate([]string{"missing.md", "--tags", "x"}); err == nil {
+		t.Fatal("CmdUpdate() of a missing note should fail")
+	}
+	if snaps, _ := loadSnapshots(tmpDir); len(snaps) != 0 {
+		t.Errorf("failed update left %d snapshots", len(snaps))
+	}
+
+	// Commands rewriting several notes can be undone too
+	if _, err := captureStdout(t, func() error { return CmdTagRename([]string{"neo", "trinity"}) }); err != nil {
+		t.Fatalf("CmdTagRename() error = %v", err)
+	}
+	if _, err := captureStdout(t, func() error { return CmdRename([]string{"a.md", "c.md"}) }); err != nil {
+		t.Fatalf("CmdRename() error = %v", err)
+	}
+	for _, command := range []string{"rename", "tags"} {
+		output, err := captureStdout(t, func() error { return CmdUndo(nil) })
+		if err != nil || !strings.HasPrefix(output, "Undid "+command+" from ") {
+			t.Fatalf("CmdUndo() output = %q, error = %v", output, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "c.md")); !os.IsNotExist(err) {
+		t.Error("undoing the rename should remove c.md")
+	}
+	if read("a.md") != beforeA || read("b.md") != beforeB || read(".meta.json") != beforeMeta {
+		t.Error("undo should restore the notes as they were before the rename and tag rename")
+	}
+
+	// Only the newest snapshots are kept
+	orig := maxSnapshots
+	maxSnapshots = 2
+	defer func() { maxSnapshots = orig }()
+	for _, tags := range []string{"one", "two", "three"} {
+		captureStdout(t, func() error { return CmdUpdate([]string{"b.md", "--tags", tags}) })
+	}
+	snaps, err := loadSnapshots(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 2 {
+		t.Errorf("kept %d snapshots, want 2", len(snaps))
+	}
+
+	// Snapshots don't show up as notes
+	output, _ = captureStdout(t, func() error { return CmdList([]string{"--raw"}) })
+	if output != "a.md\nb.md\n" {
+		t.Errorf("list output = %q", output)
+	}
+}
+
+func TestCmdUndoKeepsLaterChanges(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"neo"}, "A")
+	before, _ := os.ReadFile(filepath.Join(tmpDir, "a.md"))
+
+	// Every command that writes leaves its own snapshot, so undo steps back
+	// through them instead of rolling back later commands' changes
+	captureStdout(t, func() error { return CmdUpdate([]string{"a.md", "--tags", "changed"}) })
+	captureStdout(t, func() error { return CmdNew([]string{"gamma"}) })
+	captureStdout(t, func() error { return CmdSync(nil) })
+	for _, command := range []string{"sync", "new", "update"} {
+		output, err := captureStdout(t, func() error { return CmdUndo(nil) })
+		if err != nil || !strings.HasPrefix(output, "Undid "+command+" from ") {
+			t.Fatalf("CmdUndo() output = %q, error = %v, want to undo %s", output, err, command)
+		}
+	}
+	output, _ := captureStdout(t, func() error { return CmdList([]string{"--raw"}) })
+	if output != "a.md\n" {
+		t.Errorf("list after undoing new = %q", output)
+	}
+
+	// Files changed since the snapshot are only restored with --force
+	captureStdout(t, func() error { return CmdUpdate([]string{"a.md", "--tags", "changed"}) })
+	edited := "---\ntags: [edited]\n---\nEdited by hand\n"
+	os.WriteFile(filepath.Join(tmpDir, "a.md"), []byte(edited), 0644)
+	for _, args := range [][]string{nil, {"--dry-run"}} {
+		err := CmdUndo(args)
+		if err == nil || !strings.Contains(err.Error(), "a.md changed since") {
+			t.Errorf("CmdUndo(%v) error = %v, want a.md changed since", args, err)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "a.md")); string(data) != edited {
+		t.Error("refused undo should leave the edited note alone")
+	}
+	if _, err := captureStdout(t, func() error { return CmdUndo([]string{"--force"}) }); err != nil {
+		t.Fatalf("CmdUndo(--force) error = %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "a.md")); string(data) != string(before) {
+		t.Errorf("a.md after undo --force = %q, want %q", data, before)
+	}
+}
+
+func TestCmdOrphans(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	createEnrichedTestNote(t, tmpDir, "a.md", "A", []string{"neo"}, "A")
+	createEnrichedTestNote(t, tmpDir, "b.md", "B", []string{"neo"}, "B")
+	createEnrichedTestNote(t, tmpDir, "shared.md", "Shares a tag", []string{"Neo"}, "Shared")
+	createEnrichedTestNote(t, tmpDir, "lonely.md", "Own tag", []string{"oneoff"}, "Lonely")
+	createTestNote(t, tmpDir, "bare.md", "No tags")
+	captureStdout(t, func() error { return CmdRelate([]string{"a.md", "b.md"}) })
+
+	orphans := func(args ...string) string {
+		output, err := captureStdout(t, func() error { return CmdOrphans(append([]string{"--raw"}, args...)) })
+		if err != nil {
+			t.Fatalf("CmdOrphans(%v) error = %v", args, err)
+		}
+		lines := strings.Fields(output)
+		sort.Strings(lines)
+		return strings.Join(lines, " ")
+	}
+
+	if got := orphans(); got != "bare.md lonely.md" {
+		t.Errorf("orphans = %q, want notes without relations or shared tags", got)
+	}
+	if got := orphans("--related-only"); got != "bare.md lonely.md shared.md" {
+		t.Errorf("orphans --related-only = %q, want every note without relations", got)
+	}
+}
+
+func TestCmdBacklinks(t *testing.T) {
+	tmpDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	for _, name := range []string{"target.md", "a.md", "b.md", "c.md", "d.md"} {
+		createEnrichedTestNote(t, tmpDir, name, "Body of "+name, []string{"neo"}, "Summary")
+	}
+	captureStdout(t, func() error { return CmdRelate([]string{"target.md", "b.md"}) })
+
+	// a.md relates in its frontmatter only, c.md in .meta.json only
+	note, _ := ParseNote(filepath.Join(tmpDir, "a.md"))
+	note.Frontmatter.Related = []string{"target"}
+	note.Save(filepath.Join(tmpDir, "a.md"))
+	meta, _ := LoadMetaFile(tmpDir)
+	meta.GetFileMeta("c.md").Related = []string{"target.md"}
+	meta.Save(tmpDir)
+
+	output, err := captureStdout(t, func() error { return CmdBacklinks([]string{"target.md"}) })
+	if err != nil {
+		t.Fatalf("CmdBacklinks() error = %v", err)
+	}
+	want := "a.md  (not listed back)\nb.md\nc.md  (not listed back)\n\n2 of 3 backlinks are not listed back (use --fix to add them)\n"
+	if output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+
+	if _, err := captureStdout(t, func() error { return CmdBacklinks([]string{"target", "--fix"}) }); err != nil {
+		t.Fatalf("CmdBacklinks(--fix) error = %v", err)
+	}
+	note, _ = ParseNote(filepath.Join(tmpDir, "target.md"))
+	meta, _ = LoadMetaFile(tmpDir)
+	for _, other := range []string{"a.md", "b.md", "c.md"} {
+		if !Contains(note.Frontmatter.Related, other) || !Contains(meta.GetFileMeta("target.md").Related, other) {
+			t.Errorf("target.md should relate to %s: frontmatter %v, meta %v", other, note.Frontmatter.Related, meta.GetFileMeta("target.md").Related)
+		}
+	}
+
+	output, _ = captureStdout(t, func() error { return CmdBacklinks([]string{"target.md"}) })
+	if strings.Contains(output, "not listed back") {
+		t.Errorf("all backlinks should be reciprocated after --fix:\n%s", output)
 	}
 }
@@ -2,6 +2,7 @@ package notes
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
@@ -9,16 +10,20 @@ import (
 
 // FileMeta represents metadata for a single note in .meta.json
 type FileMeta struct {
+	ID          string    `json:"id,omitempty"`
 	ContentHash string    `json:"content_hash"`
 	EnrichedAt  time.Time `json:"enriched_at,omitempty"`
 	Tags        []string  `json:"tags"`
 	Summary     string    `json:"summary"`
 	Related     []string  `json:"related"`
+	Pinned      bool      `json:"pinned,omitempty"`
 }
 
 // MetaFile represents the .meta.json file structure
 type MetaFile struct {
 	Files map[string]*FileMeta `json:"files"`
+
+	dir string // Notes directory the entries are relative to
 }
 
 // LoadMetaFile loads .meta.json from the notes directory
@@ -31,6 +36,7 @@ func LoadMetaFile(notesDir string) (*MetaFile, error) {
 			// Return empty meta file
 			return &MetaFile{
 				Files: make(map[string]*FileMeta),
+				dir:   notesDir,
 			}, nil
 		}
 		return nil, err
@@ -44,10 +50,39 @@ func LoadMetaFile(notesDir string) (*MetaFile, error) {
 	if meta.Files == nil {
 		meta.Files = make(map[string]*FileMeta)
 	}
+	meta.dir = notesDir
 
 	return &meta, nil
 }
 
+// metaLockTimeout is how long LockMeta waits for another process to release
+// the lock. It is a variable so tests can shorten it.
+var metaLockTimeout = 10 * time.Second
+
+// LockMeta takes an advisory lock on .meta.json for a load-modify-save
+// sequence, waiting while another notes process holds it. The lock is a
+// .meta.lock file created exclusively, which works the same on every
+// platform. Call the returned function to release it.
+func LockMeta(notesDir string) (func(), error) {
+	lockPath := filepath.Join(notesDir, ".meta.lock")
+	deadline := time.Now().Add(metaLockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock .meta.json: %w", err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for another notes command to release %s (delete it if none is running)", metaLockTimeout, lockPath)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
 // Save writes the meta file to disk
 func (m *MetaFile) Save(notesDir string) error {
 	metaPath := filepath.Join(notesDir, ".meta.json")
@@ -57,7 +92,41 @@ func (m *MetaFile) Save(notesDir string) error {
 		return err
 	}
 
-	return os.WriteFile(metaPath, data, 0644)
+	// Written atomically, as an interrupted write would lose every entry
+	return writeFileAtomic(metaPath, data, 0644)
+}
+
+// IsStale reports whether .meta.json may no longer reflect the note files,
+// either because the set of notes differs or a note was modified after the
+// meta file was last written. Only file stats are used, no notes are parsed.
+func (m *MetaFile) IsStale(notesDir string) (bool, error) {
+	names, err := listNoteFiles(notesDir, func(SkippedFile) {})
+	if err != nil {
+		return false, err
+	}
+
+	var metaModTime time.Time
+	if info, err := os.Stat(filepath.Join(notesDir, ".meta.json")); err == nil {
+		metaModTime = info.ModTime()
+	}
+
+	seen := 0
+	for _, name := range names {
+		if m.Files[name] == nil {
+			return true, nil
+		}
+		seen++
+
+		info, err := os.Stat(filepath.Join(notesDir, name))
+		if err != nil {
+			return false, err
+		}
+		if info.ModTime().After(metaModTime) {
+			return true, nil
+		}
+	}
+
+	return seen != len(m.Files), nil
 }
 
 // GetFileMeta returns metadata for a specific file
@@ -79,25 +148,43 @@ func (m *MetaFile) NeedsEnrichment(filename, currentHash string) bool {
 	return meta.ContentHash != currentHash
 }
 
+// RefreshHash stores a note's new content hash after a mechanical edit, but
+// only if the entry was up to date so pending enrichment isn't hidden
+func (m *MetaFile) RefreshHash(filename, oldHash, newHash string) {
+	if meta := m.Files[filename]; meta != nil && meta.ContentHash == oldHash {
+		meta.ContentHash = newHash
+	}
+}
+
+// nameOf returns the key of a note's entry
+func (m *MetaFile) nameOf(note *Note) string {
+	if note.name != "" {
+		return note.name
+	}
+	return noteName(m.dir, note.Filename)
+}
+
 // UpdateFromNote updates the meta file entry from a note
 func (m *MetaFile) UpdateFromNote(note *Note) {
-	filename := filepath.Base(note.Filename)
+	filename := m.nameOf(note)
 	meta := m.Files[filename]
 	if meta == nil {
 		meta = &FileMeta{}
 		m.Files[filename] = meta
 	}
 
+	meta.ID = note.Frontmatter.ID
 	meta.ContentHash = note.ContentHash()
-	meta.Tags = note.Frontmatter.Tags
+	meta.Tags = normalizeTagSlice(note.Frontmatter.Tags)
 	meta.Summary = note.Frontmatter.Summary
 	meta.Related = note.Frontmatter.Related
+	meta.Pinned = note.Frontmatter.Pinned
 }
 
 // UpdateFromNoteWithEnrichment updates and marks as enriched
 func (m *MetaFile) UpdateFromNoteWithEnrichment(note *Note) {
 	m.UpdateFromNote(note)
-	filename := filepath.Base(note.Filename)
+	filename := m.nameOf(note)
 	m.Files[filename].EnrichedAt = time.Now()
 }
 
@@ -3,6 +3,7 @@ package notes
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -79,6 +80,88 @@ func TestMetaFileSaveAndLoad(t *testing.T) {
 	}
 }
 
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".meta.json")
+
+	if err := writeFileAtomic(path, []byte("first"), 0644); err != nil {
+		t.Fatalf("writeFileAtomic() error = %v", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("second"), 0644); err != nil {
+		t.Fatalf("writeFileAtomic() over an existing file error = %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "second" {
+		t.Errorf("content = %q, want %q", data, "second")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want the existing 0600 kept", info.Mode().Perm())
+	}
+
+	// A symlinked file is replaced behind the link
+	target := filepath.Join(dir, "target.md")
+	link := filepath.Join(dir, "link.md")
+	os.WriteFile(target, []byte("old"), 0644)
+	if err := os.Symlink(target, link); err == nil {
+		if err := writeFileAtomic(link, []byte("new"), 0644); err != nil {
+			t.Fatalf("writeFileAtomic() through a symlink error = %v", err)
+		}
+		if data, _ := os.ReadFile(target); string(data) != "new" {
+			t.Errorf("symlink target = %q, want %q", data, "new")
+		}
+		if info, _ := os.Lstat(link); info.Mode()&os.ModeSymlink == 0 {
+			t.Error("the symlink should be kept")
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("temp file left behind: %s", entry.Name())
+		}
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "x.md"), []byte("x"), 0644); !os.IsNotExist(err) {
+		t.Errorf("writing into a missing directory: error = %v, want not exist", err)
+	}
+}
+
+func TestLockMeta(t *testing.T) {
+	dir := t.TempDir()
+	orig := metaLockTimeout
+	metaLockTimeout = 100 * time.Millisecond
+	defer func() { metaLockTimeout = orig }()
+
+	unlock, err := LockMeta(dir)
+	if err != nil {
+		t.Fatalf("LockMeta() error = %v", err)
+	}
+	if _, err := LockMeta(dir); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("LockMeta() while locked: error = %v, want a timeout", err)
+	}
+
+	// A waiting caller gets the lock once it is released
+	// The goroutine gets its own copy, as unlock is reassigned below
+	release := unlock
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		release()
+	}()
+	unlock, err = LockMeta(dir)
+	if err != nil {
+		t.Fatalf("LockMeta() after release error = %v", err)
+	}
+	unlock()
+
+	if _, err := os.Stat(filepath.Join(dir, ".meta.lock")); !os.IsNotExist(err) {
+		t.Error("unlocking should remove the lock file")
+	}
+}
+
 func TestNeedsEnrichment(t *testing.T) {
 	meta := &MetaFile{
 		Files: map[string]*FileMeta{
@@ -167,3 +250,48 @@ func TestUpdateFromNote(t *testing.T) {
 		t.Error("ContentHash should be set")
 	}
 }
+
+func TestMetaIsStale(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "notes-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	note := &Note{Filename: "a.md", Content: "\nContent\n"}
+	if err := note.Save(filepath.Join(tmpDir, "a.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := &MetaFile{Files: make(map[string]*FileMeta)}
+
+	// Note missing from meta
+	if stale, _ := meta.IsStale(tmpDir); !stale {
+		t.Error("Meta without entry for a.md should be stale")
+	}
+
+	// Meta written after the note
+	meta.UpdateFromNote(note)
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(tmpDir, "a.md"), past, past)
+	if err := meta.Save(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	if stale, _ := meta.IsStale(tmpDir); stale {
+		t.Error("Meta covering all notes should not be stale")
+	}
+
+	// Note modified after meta
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(tmpDir, "a.md"), future, future)
+	if stale, _ := meta.IsStale(tmpDir); !stale {
+		t.Error("Meta older than a note should be stale")
+	}
+
+	// Entry for a deleted note
+	os.Chtimes(filepath.Join(tmpDir, "a.md"), past, past)
+	meta.SetFileMeta("gone.md", &FileMeta{})
+	if stale, _ := meta.IsStale(tmpDir); !stale {
+		t.Error("Meta with entry for missing note should be stale")
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -14,6 +17,7 @@ import (
 )
 
 // NoteTime is a custom time type that handles the "2006-01-02 15:04" format
+// as well as RFC 3339 timestamps with a time zone
 type NoteTime struct {
 	time.Time
 }
@@ -27,8 +31,9 @@ func (t *NoteTime) UnmarshalYAML(node *yaml.Node) error {
 		return nil
 	}
 
-	// Try custom format first
-	parsed, err := time.Parse(noteTimeFormat, value)
+	// Try custom format first. It has no zone and is written in local
+	// time, so it sorts correctly against RFC 3339 times.
+	parsed, err := time.ParseInLocation(noteTimeFormat, value, time.Local)
 	if err == nil {
 		t.Time = parsed
 		return nil
@@ -43,12 +48,11 @@ func (t *NoteTime) UnmarshalYAML(node *yaml.Node) error {
 
 	// Try other common formats
 	formats := []string{
-		"2006-01-02T15:04:05Z",
 		"2006-01-02T15:04:05",
 		"2006-01-02",
 	}
 	for _, f := range formats {
-		parsed, err = time.Parse(f, value)
+		parsed, err = time.ParseInLocation(f, value, time.Local)
 		if err == nil {
 			t.Time = parsed
 			return nil
@@ -59,32 +63,174 @@ func (t *NoteTime) UnmarshalYAML(node *yaml.Node) error {
 }
 
 func (t NoteTime) MarshalYAML() (interface{}, error) {
-	return t.Format(noteTimeFormat), nil
+	return t.encode(), nil
+}
+
+// encode formats the time for frontmatter in the configured layout. The
+// minute layout has no zone and is read back as local time, so the time is
+// converted to local time first rather than written in the zone it was
+// parsed in.
+func (t NoteTime) encode() string {
+	layout := GetTimeFormat()
+	if layout == noteTimeFormat {
+		return t.In(time.Local).Format(layout)
+	}
+	return t.Format(layout)
 }
 
 // Frontmatter represents the YAML frontmatter of a note
 type Frontmatter struct {
+	ID      string   `yaml:"id,omitempty"`
 	Created NoteTime `yaml:"created"`
 	Tags    []string `yaml:"tags"`
 	Summary string   `yaml:"summary"`
 	Related []string `yaml:"related"`
+	Pinned  bool     `yaml:"pinned,omitempty"` // Listed before other notes
+
+	// Extra holds any other keys in file order, so that rewriting a note
+	// keeps fields such as mood: or location: along with their comments
+	Extra []FrontmatterField `yaml:"-"`
+}
+
+// FrontmatterField is a frontmatter key not known to notes, kept as parsed
+type FrontmatterField struct {
+	Key   *yaml.Node
+	Value *yaml.Node
+}
+
+// knownFrontmatterKeys are the keys Frontmatter has fields for
+var knownFrontmatterKeys = []string{"id", "created", "tags", "summary", "related", "pinned"}
+
+// extraFrontmatterFields returns the fields of a frontmatter block that
+// aren't among knownFrontmatterKeys
+func extraFrontmatterFields(data []byte) ([]FrontmatterField, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	var extra []FrontmatterField
+	mapping := doc.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		if !Contains(knownFrontmatterKeys, key.Value) {
+			extra = append(extra, FrontmatterField{Key: key, Value: value})
+		}
+	}
+	return extra, nil
+}
+
+// writeExtraFrontmatter renders extra fields as YAML, in order
+func writeExtraFrontmatter(buf *bytes.Buffer, extra []FrontmatterField) error {
+	if len(extra) == 0 {
+		return nil
+	}
+
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	for _, field := range extra {
+		mapping.Content = append(mapping.Content, field.Key, field.Value)
+	}
+
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(mapping); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// normalizeTagSlice trims tags, drops empty ones, removes case-insensitive
+// duplicates (keeping the first casing) and sorts them, so every write path
+// stores tags the same way
+func normalizeTagSlice(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, tag)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return strings.ToLower(result[i]) < strings.ToLower(result[j])
+	})
+	return result
 }
 
 // Note represents a complete note with frontmatter and content
 type Note struct {
-	Filename    string
-	Frontmatter Frontmatter
-	Content     string // Body content without frontmatter
+	Filename       string
+	Frontmatter    Frontmatter
+	Content        string // Body content without frontmatter
+	HasFrontmatter bool   // Set by ParseNote; false for plain notes without a YAML block
+
+	name string // Path relative to the notes directory, set when scanning
+}
+
+// Name returns the name the note is known by in relations and .meta.json:
+// its path relative to the notes directory, e.g. "work/plan.md", for notes
+// found by LoadAllNotes and WalkNotes, otherwise its filename
+func (n *Note) Name() string {
+	if n.name != "" {
+		return n.name
+	}
+	return filepath.Base(n.Filename)
 }
 
-// ParseNote reads a note file and parses its frontmatter and content
+// ParseNote reads a note file and parses its frontmatter and content. Notes
+// are cached for the rest of the run and only parsed again once the file
+// changes.
 func ParseNote(filepath string) (*Note, error) {
+	return parseNote(filepath, true)
+}
+
+// parseNoteOnce parses a note like ParseNote but doesn't add it to the
+// cache, for streaming paths that visit every note once and must not keep
+// them all in memory
+func parseNoteOnce(filepath string) (*Note, error) {
+	return parseNote(filepath, false)
+}
+
+func parseNote(filepath string, store bool) (*Note, error) {
+	info, err := os.Stat(filepath)
+	if err != nil {
+		forgetParsed(filepath)
+		return nil, err
+	}
+	if note, ok := cachedParse(filepath, info); ok {
+		return note, nil
+	}
+
 	data, err := os.ReadFile(filepath)
 	if err != nil {
 		return nil, err
 	}
 
-	return ParseNoteContent(filepath, data)
+	note, err := ParseNoteContent(filepath, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Plain notes have no created timestamp, fall back to the file's mtime
+	if !note.HasFrontmatter {
+		note.Frontmatter.Created = NoteTime{info.ModTime()}
+	}
+
+	if store {
+		storeParsed(filepath, info, note)
+	}
+	return note, nil
 }
 
 // ParseNoteContent parses note content from bytes
@@ -129,11 +275,17 @@ func ParseNoteContent(filename string, data []byte) (*Note, error) {
 	if err := yaml.Unmarshal([]byte(fmContent), &fm); err != nil {
 		return nil, fmt.Errorf("invalid frontmatter: %w", err)
 	}
+	extra, err := extraFrontmatterFields([]byte(fmContent))
+	if err != nil {
+		return nil, fmt.Errorf("invalid frontmatter: %w", err)
+	}
+	fm.Extra = extra
 
 	return &Note{
-		Filename:    filename,
-		Frontmatter: fm,
-		Content:     body,
+		Filename:       filename,
+		Frontmatter:    fm,
+		Content:        body,
+		HasFrontmatter: true,
 	}, nil
 }
 
@@ -150,46 +302,32 @@ func (n *Note) ToMarkdown() string {
 
 	buf.WriteString("---\n")
 
+	// Stable id, only written when set. Quoted where it would otherwise read
+	// back as something else, such as null or true.
+	if n.Frontmatter.ID != "" {
+		buf.WriteString(fmt.Sprintf("id: %s\n", yamlString(n.Frontmatter.ID)))
+	}
+
 	// Format created time
-	created := n.Frontmatter.Created.Format(noteTimeFormat)
+	created := n.Frontmatter.Created.encode()
 
 	// Build YAML manually to control formatting
 	buf.WriteString(fmt.Sprintf("created: %s\n", created))
 
-	// Tags
-	if len(n.Frontmatter.Tags) == 0 {
-		buf.WriteString("tags: []\n")
-	} else {
-		buf.WriteString("tags: [")
-		for i, tag := range n.Frontmatter.Tags {
-			if i > 0 {
-				buf.WriteString(", ")
-			}
-			buf.WriteString(tag)
-		}
-		buf.WriteString("]\n")
-	}
+	// Lists stay inline and the summary double-quoted; values are quoted
+	// or escaped where YAML requires it
+	buf.WriteString(fmt.Sprintf("tags: %s\n", yamlFlowList(normalizeTagSlice(n.Frontmatter.Tags))))
+	buf.WriteString(fmt.Sprintf("summary: %s\n", yamlQuoted(n.Frontmatter.Summary)))
+	buf.WriteString(fmt.Sprintf("related: %s\n", yamlFlowList(n.Frontmatter.Related)))
 
-	// Summary
-	if n.Frontmatter.Summary == "" {
-		buf.WriteString("summary: \"\"\n")
-	} else {
-		buf.WriteString(fmt.Sprintf("summary: %q\n", n.Frontmatter.Summary))
+	// Only pinned notes get the field, so existing notes stay unchanged
+	if n.Frontmatter.Pinned {
+		buf.WriteString("pinned: true\n")
 	}
 
-	// Related
-	if len(n.Frontmatter.Related) == 0 {
-		buf.WriteString("related: []\n")
-	} else {
-		buf.WriteString("related: [")
-		for i, rel := range n.Frontmatter.Related {
-			if i > 0 {
-				buf.WriteString(", ")
-			}
-			buf.WriteString(rel)
-		}
-		buf.WriteString("]\n")
-	}
+	// Unknown fields after the known ones. They were parsed from YAML, so
+	// encoding them again can't fail.
+	writeExtraFrontmatter(&buf, n.Frontmatter.Extra)
 
 	buf.WriteString("---\n")
 	buf.WriteString(n.Content)
@@ -197,9 +335,47 @@ func (n *Note) ToMarkdown() string {
 	return buf.String()
 }
 
-// Save writes a note to the specified path
+// yamlFlowList renders strings as an inline YAML list such as [a, 'c,d']
+func yamlFlowList(items []string) string {
+	list := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+	for _, item := range items {
+		list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+	}
+	return marshalYAMLNode(list)
+}
+
+// yamlString renders a string as a YAML scalar, quoted only if needed
+func yamlString(s string) string {
+	return marshalYAMLNode(&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s})
+}
+
+// yamlQuoted renders a string as a double-quoted YAML scalar
+func yamlQuoted(s string) string {
+	return marshalYAMLNode(&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s, Style: yaml.DoubleQuotedStyle})
+}
+
+// marshalYAMLNode encodes a node built from strings, which can't fail
+func marshalYAMLNode(node *yaml.Node) string {
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode YAML: %v", err))
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+// Save writes a note to the specified path, atomically replacing any existing file
 func (n *Note) Save(filepath string) error {
-	return os.WriteFile(filepath, []byte(n.ToMarkdown()), 0644)
+	forgetParsed(filepath)
+	return writeFileAtomic(filepath, []byte(n.ToMarkdown()), 0644)
+}
+
+// SaveKeepingFormat writes a note like Save, but keeps plain notes without frontmatter
+func (n *Note) SaveKeepingFormat(filepath string) error {
+	if !n.HasFrontmatter {
+		forgetParsed(filepath)
+		return writeFileAtomic(filepath, []byte(n.Content), 0644)
+	}
+	return n.Save(filepath)
 }
 
 // UpdateFrontmatter updates the frontmatter of a note file in place
@@ -222,13 +398,32 @@ func UpdateFrontmatter(filepath string, tags []string, summary string, related [
 	return note.Save(filepath)
 }
 
+// wikilinkPattern matches [[target]], [[target|label]] and [[target#heading]]
+var wikilinkPattern = regexp.MustCompile(`\[\[([^\]|#]+)(?:[|#][^\]]*)?\]\]`)
+
+// Wikilinks returns the targets of [[...]] links in the body, in order of
+// appearance and without duplicates. Targets may be filenames or note ids.
+func (n *Note) Wikilinks() []string {
+	var links []string
+	for _, match := range wikilinkPattern.FindAllStringSubmatch(n.Content, -1) {
+		target := strings.TrimSpace(match[1])
+		if target != "" && !Contains(links, target) {
+			links = append(links, target)
+		}
+	}
+	return links
+}
+
 // GetSummaryOrFirstLine returns the summary if available, or the first line truncated
 func (n *Note) GetSummaryOrFirstLine() string {
 	if n.Frontmatter.Summary != "" {
 		return n.Frontmatter.Summary
 	}
+	return n.FirstLine()
+}
 
-	// Get first non-empty line
+// FirstLine returns the first non-empty body line, truncated
+func (n *Note) FirstLine() string {
 	scanner := bufio.NewScanner(strings.NewReader(n.Content))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -93,6 +93,86 @@ related: []
 	}
 }
 
+func TestNoteTimeFormats(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		// Legacy times have no zone and are read as local time
+		{"2025-01-11 14:23", time.Date(2025, 1, 11, 14, 23, 0, 0, time.Local)},
+		{"2025-01-11T14:23:05+02:00", time.Date(2025, 1, 11, 12, 23, 5, 0, time.UTC)},
+		{"2025-01-11T14:23:05Z", time.Date(2025, 1, 11, 14, 23, 5, 0, time.UTC)},
+		{"2025-01-11", time.Date(2025, 1, 11, 0, 0, 0, 0, time.Local)},
+	}
+
+	for _, tt := range tests {
+		note, err := ParseNoteContent("test.md", []byte("---\ncreated: "+tt.input+"\n---\nBody\n"))
+		if err != nil {
+			t.Fatalf("ParseNoteContent(created: %s) error = %v", tt.input, err)
+		}
+		if !note.Frontmatter.Created.Equal(tt.expected) {
+			t.Errorf("created: %s = %v, want %v", tt.input, note.Frontmatter.Created.Time, tt.expected)
+		}
+	}
+}
+
+func TestParseNoteCache(t *testing.T) {
+	resetParseCache()
+	path := filepath.Join(t.TempDir(), "note.md")
+	if err := os.WriteFile(path, []byte("---\ntags: [a]\nsummary: \"First\"\n---\nBody\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	note, err := ParseNote(path)
+	if err != nil {
+		t.Fatalf("ParseNote() error = %v", err)
+	}
+
+	// Changes to a returned note must not leak into the cache
+	note.Frontmatter.Tags[0] = "changed"
+	note.Content = "changed"
+
+	again, err := ParseNote(path)
+	if err != nil {
+		t.Fatalf("ParseNote() again error = %v", err)
+	}
+	if again.Frontmatter.Tags[0] != "a" || again.Content != "Body\n" {
+		t.Errorf("cached note was modified: tags %v, content %q", again.Frontmatter.Tags, again.Content)
+	}
+
+	// A changed file is parsed again
+	if err := os.WriteFile(path, []byte("---\ntags: [b]\nsummary: \"Second one\"\n---\nBody\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := ParseNote(path)
+	if err != nil {
+		t.Fatalf("ParseNote() after change error = %v", err)
+	}
+	if changed.Frontmatter.Summary != "Second one" {
+		t.Errorf("Summary = %q, want the changed file's summary", changed.Frontmatter.Summary)
+	}
+
+	// Saving through the note drops the cached copy even if mtime and size match
+	changed.Frontmatter.Summary = "Other one!"
+	if err := changed.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := ParseNote(path)
+	if err != nil {
+		t.Fatalf("ParseNote() after save error = %v", err)
+	}
+	if saved.Frontmatter.Summary != "Other one!" {
+		t.Errorf("Summary = %q, want the saved summary", saved.Frontmatter.Summary)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseNote(path); !os.IsNotExist(err) {
+		t.Errorf("ParseNote() of a removed note error = %v, want not exist", err)
+	}
+}
+
 func TestContentHash(t *testing.T) {
 	note1 := &Note{
 		Content: "Some content here",
@@ -143,7 +223,7 @@ func TestContentHashIgnoresFrontmatter(t *testing.T) {
 }
 
 func TestToMarkdown(t *testing.T) {
-	created, _ := time.Parse("2006-01-02 15:04", "2025-01-11 14:23")
+	created, _ := time.ParseInLocation("2006-01-02 15:04", "2025-01-11 14:23", time.Local)
 	note := &Note{
 		Frontmatter: Frontmatter{
 			Created: NoteTime{created},
@@ -160,7 +240,8 @@ func TestToMarkdown(t *testing.T) {
 	if !strings.Contains(markdown, "created: 2025-01-11 14:23") {
 		t.Errorf("Markdown should contain created date, got:\n%s", markdown)
 	}
-	if !strings.Contains(markdown, "tags: [neo, eval]") {
+	// Tags are written sorted
+	if !strings.Contains(markdown, "tags: [eval, neo]") {
 		t.Errorf("Markdown should contain tags, got:\n%s", markdown)
 	}
 	if !strings.Contains(markdown, `summary: "Test summary"`) {
@@ -174,6 +255,190 @@ func TestToMarkdown(t *testing.T) {
 	}
 }
 
+func TestToMarkdownQuotesYAML(t *testing.T) {
+	created, _ := time.Parse("2006-01-02 15:04", "2025-01-11 14:23")
+	note := &Note{
+		Frontmatter: Frontmatter{
+			Created: NoteTime{created},
+			Tags:    []string{"a b", "c,d", "[x]", "true", "plain"},
+			Summary: "Say \"hi\"\nthen leave: # now",
+			Related: []string{"other.md", "with space.md"},
+		},
+		Content: "\nBody\n",
+	}
+
+	markdown := note.ToMarkdown()
+	if !strings.Contains(markdown, `tags: ['[x]', a b, 'c,d', plain, "true"]`) {
+		t.Errorf("tags should stay inline and be quoted where needed, got:\n%s", markdown)
+	}
+	if !strings.Contains(markdown, `summary: "Say \"hi\"\nthen leave: # now"`) {
+		t.Errorf("summary should be escaped, got:\n%s", markdown)
+	}
+
+	parsed, err := ParseNoteContent("test.md", []byte(markdown))
+	if err != nil {
+		t.Fatalf("ToMarkdown() output doesn't parse: %v\n%s", err, markdown)
+	}
+	fm := parsed.Frontmatter
+	if !stringSliceEqual(fm.Tags, normalizeTagSlice(note.Frontmatter.Tags)) {
+		t.Errorf("tags = %q", fm.Tags)
+	}
+	if fm.Summary != note.Frontmatter.Summary {
+		t.Errorf("summary = %q", fm.Summary)
+	}
+	if !stringSliceEqual(fm.Related, note.Frontmatter.Related) {
+		t.Errorf("related = %q", fm.Related)
+	}
+}
+
+func TestToMarkdownIDRoundTrip(t *testing.T) {
+	for _, id := range []string{"null", "Null", "~", "true", "no", "123", "1.5", "0x1F", "proj-42"} {
+		note := &Note{Frontmatter: Frontmatter{ID: id, Created: NoteTime{time.Now()}}, Content: "\nBody\n"}
+
+		parsed, err := ParseNoteContent("test.md", []byte(note.ToMarkdown()))
+		if err != nil {
+			t.Fatalf("ToMarkdown() with id %q doesn't parse: %v", id, err)
+		}
+		if parsed.Frontmatter.ID != id {
+			t.Errorf("id %q read back as %q from:\n%s", id, parsed.Frontmatter.ID, note.ToMarkdown())
+		}
+	}
+
+	note := &Note{Frontmatter: Frontmatter{ID: "proj-42", Created: NoteTime{time.Now()}}}
+	if !strings.Contains(note.ToMarkdown(), "id: proj-42\n") {
+		t.Errorf("plain ids should stay unquoted, got:\n%s", note.ToMarkdown())
+	}
+}
+
+func TestToMarkdownKeepsExtraFields(t *testing.T) {
+	input := `---
+created: 2025-01-11 14:23
+mood: happy
+tags: [neo]
+# Where it was written
+location: Berlin
+summary: "Test summary"
+related: []
+weather:
+  temp: 21
+  sky: clear
+---
+
+Body
+`
+	note, err := ParseNoteContent("test.md", []byte(input))
+	if err != nil {
+		t.Fatalf("ParseNoteContent() error = %v", err)
+	}
+	if len(note.Frontmatter.Extra) != 3 {
+		t.Fatalf("Extra has %d fields, want 3", len(note.Frontmatter.Extra))
+	}
+
+	note.Frontmatter.Tags = append(note.Frontmatter.Tags, "eval")
+	want := `---
+created: 2025-01-11 14:23
+tags: [eval, neo]
+summary: "Test summary"
+related: []
+mood: happy
+# Where it was written
+location: Berlin
+weather:
+  temp: 21
+  sky: clear
+---
+
+Body
+`
+	if got := note.ToMarkdown(); got != want {
+		t.Errorf("ToMarkdown() =\n%s\nwant:\n%s", got, want)
+	}
+
+	// Writing it again changes nothing
+	reparsed, err := ParseNoteContent("test.md", []byte(want))
+	if err != nil {
+		t.Fatalf("ParseNoteContent() error = %v", err)
+	}
+	if got := reparsed.ToMarkdown(); got != want {
+		t.Errorf("second round trip =\n%s", got)
+	}
+}
+
+func TestToMarkdownCreatedInLocalTime(t *testing.T) {
+	orig := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	defer func() { time.Local = orig }()
+	t.Setenv("NOTES_TIME_FORMAT", "")
+
+	note, err := ParseNoteContent("z.md", []byte("---\ncreated: 2025-01-11T14:23:05+02:00\ntags: []\nsummary: \"\"\nrelated: []\n---\nBody\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The minute layout is read back as local time, so it must be written in it
+	md := note.ToMarkdown()
+	if !strings.Contains(md, "created: 2025-01-11 07:23\n") {
+		t.Errorf("ToMarkdown() should write created in local time, got:\n%s", md)
+	}
+	reparsed, err := ParseNoteContent("z.md", []byte(md))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := note.Frontmatter.Created.Truncate(time.Minute); !reparsed.Frontmatter.Created.Equal(want) {
+		t.Errorf("created read back as %v, want %v", reparsed.Frontmatter.Created, want)
+	}
+
+	// RFC 3339 keeps the zone
+	t.Setenv("NOTES_TIME_FORMAT", "rfc3339")
+	if md := note.ToMarkdown(); !strings.Contains(md, "created: 2025-01-11T14:23:05+02:00\n") {
+		t.Errorf("ToMarkdown() with rfc3339 should keep the zone, got:\n%s", md)
+	}
+}
+
+func TestToMarkdownPinned(t *testing.T) {
+	input := "---\ncreated: 2025-01-11 14:23\ntags: []\nsummary: \"\"\nrelated: []\n---\n\nBody\n"
+	note, err := ParseNoteContent("test.md", []byte(input))
+	if err != nil {
+		t.Fatalf("ParseNoteContent() error = %v", err)
+	}
+	if got := note.ToMarkdown(); got != input {
+		t.Errorf("unpinned note changed:\n%s", got)
+	}
+
+	note.Frontmatter.Pinned = true
+	pinned := note.ToMarkdown()
+	if !strings.Contains(pinned, "related: []\npinned: true\n---") {
+		t.Errorf("ToMarkdown() should write pinned: true, got:\n%s", pinned)
+	}
+
+	reparsed, err := ParseNoteContent("test.md", []byte(pinned))
+	if err != nil {
+		t.Fatalf("ParseNoteContent() error = %v", err)
+	}
+	if !reparsed.Frontmatter.Pinned || len(reparsed.Frontmatter.Extra) != 0 {
+		t.Errorf("pinned should parse into Frontmatter.Pinned, got %+v", reparsed.Frontmatter)
+	}
+}
+
+func TestNormalizeTagSlice(t *testing.T) {
+	tests := []struct {
+		input    []string
+		expected []string
+	}{
+		{[]string{"Neo", "neo", "eval"}, []string{"eval", "Neo"}},
+		{[]string{" b ", "", "A", "a", "c"}, []string{"A", "b", "c"}},
+		{[]string{}, []string{}},
+		{nil, nil},
+	}
+
+	for _, tt := range tests {
+		result := normalizeTagSlice(tt.input)
+		if !stringSliceEqual(result, tt.expected) || (result == nil) != (tt.expected == nil) {
+			t.Errorf("normalizeTagSlice(%q) = %q, want %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
 func TestGetSummaryOrFirstLine(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -247,6 +512,31 @@ func TestNormalizeFilename(t *testing.T) {
 	}
 }
 
+func TestParseRelativeDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"24h", 24 * time.Hour},
+		{"90m", 90 * time.Minute},
+		{"7d", 7 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		result, err := parseRelativeDuration(tt.input)
+		if err != nil || result != tt.expected {
+			t.Errorf("parseRelativeDuration(%q) = %v, %v; want %v", tt.input, result, err, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"", "d", "1.5d", "-2d", "0h", "week"} {
+		if _, err := parseRelativeDuration(input); err == nil {
+			t.Errorf("parseRelativeDuration(%q) should fail", input)
+		}
+	}
+}
+
 func TestGenerateFilename(t *testing.T) {
 	// Create temp directory
 	tmpDir, err := os.MkdirTemp("", "notes-test-*")
@@ -276,3 +566,162 @@ func TestGenerateFilename(t *testing.T) {
 		t.Errorf("Second filename should be different from first")
 	}
 }
+
+func TestSetTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"replaces heading", "\n# Old\n\nBody\n", "\n# New\n\nBody\n"},
+		{"inserts heading", "\nBody\n", "\n# New\n\nBody\n"},
+		{"subheading is not replaced", "## Section\nBody\n", "# New\n\n## Section\nBody\n"},
+		{"empty body", "", "\n# New\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := setTitle(tt.content, "New"); result != tt.expected {
+				t.Errorf("setTitle() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCleanContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"already clean", "\nBody\n", "\nBody\n"},
+		{"trailing whitespace", "\nLine one  \nLine two\t\n", "\nLine one\nLine two\n"},
+		{"long blank run", "\nA\n\n\n\n\nB\n", "\nA\n\nB\n"},
+		{"two blank lines", "\nA\n\n\nB\n", "\nA\n\nB\n"},
+		{"single blank line kept", "\nA\n\nB\n", "\nA\n\nB\n"},
+		{"trailing newlines", "\nBody\n\n\n", "\nBody\n"},
+		{"missing newline", "\nBody", "\nBody\n"},
+		{"whitespace only", "  \n \n", "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := CleanContent(tt.content); result != tt.expected {
+				t.Errorf("CleanContent() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStripCursor(t *testing.T) {
+	text, line := stripCursor("---\ntags: []\n---\n\n{{cursor}}\n")
+	if text != "---\ntags: []\n---\n\n\n" {
+		t.Errorf("stripCursor() text = %q", text)
+	}
+	if line != 5 {
+		t.Errorf("stripCursor() line = %d, want 5", line)
+	}
+
+	if _, line := stripCursor("no marker"); line != 0 {
+		t.Errorf("stripCursor() line = %d, want 0 without marker", line)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+
+	expected := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -8,3 +8,4 @@
+ h
+ i
+ j
++k
+`
+	if result := unifiedDiff("old", "new", old, new); result != expected {
+		t.Errorf("unifiedDiff() =\n%s\nwant\n%s", result, expected)
+	}
+
+	if result := unifiedDiff("old", "new", old, old); result != "" {
+		t.Errorf("unifiedDiff() of equal texts = %q, want empty", result)
+	}
+
+	if result := unifiedDiff("old", "new", "", "x\n"); result != "--- old\n+++ new\n@@ -0,0 +1,1 @@\n+x\n" {
+		t.Errorf("unifiedDiff() from empty = %q", result)
+	}
+}
+
+func TestCountText(t *testing.T) {
+	speed := ReadingSpeed{WordsPerMinute: 200, CJKCharsPerMinute: 500}
+
+	prose := "# Notes\n\n" + strings.Repeat("The quick brown fox jumps over the lazy dog. ", 50)
+	count := CountText(prose)
+	if count.Words != 451 || count.CJKChars != 0 {
+		t.Errorf("CountText(prose) = %+v, want 451 words", count)
+	}
+	if minutes := count.ReadingMinutes(speed); minutes != 3 {
+		t.Errorf("ReadingMinutes(prose) = %d, want 3", minutes)
+	}
+	if minutes := count.ReadingMinutes(ReadingSpeed{WordsPerMinute: 500, CJKCharsPerMinute: 500}); minutes != 1 {
+		t.Errorf("ReadingMinutes(prose) at 500 wpm = %d, want 1", minutes)
+	}
+	if seconds := count.ReadingSeconds(speed); seconds != 136 {
+		t.Errorf("ReadingSeconds(prose) = %d, want 136", seconds)
+	}
+
+	// No spaces between words, so characters are counted instead
+	cjk := strings.Repeat("今日は良い天気です。", 60)
+	count = CountText(cjk)
+	if count.Words != 0 || count.CJKChars != 540 {
+		t.Errorf("CountText(cjk) = %+v, want 540 CJK characters", count)
+	}
+	if minutes := count.ReadingMinutes(speed); minutes != 2 {
+		t.Errorf("ReadingMinutes(cjk) = %d, want 2", minutes)
+	}
+
+	count = CountText("Go言語 - 入門")
+	if count.Words != 1 || count.CJKChars != 4 {
+		t.Errorf("CountText(mixed) = %+v, want 1 word and 4 CJK characters", count)
+	}
+
+	if minutes := CountText("").ReadingMinutes(speed); minutes != 0 {
+		t.Errorf("ReadingMinutes(empty) = %d, want 0", minutes)
+	}
+	if seconds := CountText("").ReadingSeconds(speed); seconds != 0 {
+		t.Errorf("ReadingSeconds(empty) = %d, want 0", seconds)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	content := "Intro with **bold**, *em*, `a <b>` and [[other-note|a link]].\nSecond line\n\n" +
+		"## Steps\n\n- one\n- [docs](https://example.com)\n\n1. first\n\n> quoted\n\n```\nx := 1 < 2\n```\n"
+
+	expected := `<p>Intro with <strong>bold</strong>, <em>em</em>, <code>a &lt;b&gt;</code> and <a href="other-note.html">a link</a>. Second line</p>
+<h2>Steps</h2>
+<ul>
+<li>one</li>
+<li><a href="https://example.com">docs</a></li>
+</ul>
+<ol>
+<li>first</li>
+</ol>
+<blockquote><p>quoted</p></blockquote>
+<pre><code>x := 1 &lt; 2
+</code></pre>
+`
+	if result := renderMarkdown(content); result != expected {
+		t.Errorf("renderMarkdown() =\n%s\nwant\n%s", result, expected)
+	}
+
+	if result := renderInline("a ` b"); result != "a ` b" {
+		t.Errorf("renderInline() with unmatched backtick = %q", result)
+	}
+}
@@ -0,0 +1,74 @@
+package notes
+
+import (
+	"fmt"
+	"math"
+	"unicode"
+)
+
+// Default reading speeds. CJK text has no spaces between words, so it is
+// measured in characters per minute instead.
+const (
+	defaultWordsPerMinute    = 200
+	defaultCJKCharsPerMinute = 500
+)
+
+// ReadingSpeed is how fast notes are read, for reading time estimates
+type ReadingSpeed struct {
+	WordsPerMinute    int
+	CJKCharsPerMinute int
+}
+
+// TextCount counts the readable units of a note body
+type TextCount struct {
+	Words    int // Whitespace-delimited words outside CJK text
+	CJKChars int // Han, Hiragana, Katakana and Hangul characters
+}
+
+// CountText counts words and CJK characters. Each CJK character counts on
+// its own, and also ends any word it is attached to ("Go言語" is 1 word
+// and 2 characters).
+func CountText(content string) TextCount {
+	var count TextCount
+	inWord := false
+	for _, r := range content {
+		switch {
+		case isCJK(r):
+			count.CJKChars++
+			inWord = false
+		case unicode.IsSpace(r) || unicode.IsPunct(r) && !inWord:
+			inWord = false
+		default:
+			if !inWord {
+				count.Words++
+				inWord = true
+			}
+		}
+	}
+	return count
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// ReadingMinutes estimates the reading time in whole minutes, rounding up.
+// Any non-empty text takes at least a minute.
+func (c TextCount) ReadingMinutes(speed ReadingSpeed) int {
+	minutes := float64(c.Words)/float64(speed.WordsPerMinute) +
+		float64(c.CJKChars)/float64(speed.CJKCharsPerMinute)
+	return int(math.Ceil(minutes))
+}
+
+// ReadingSeconds estimates the reading time in seconds, rounding up. Empty
+// text takes no time.
+func (c TextCount) ReadingSeconds(speed ReadingSpeed) int {
+	seconds := float64(c.Words)*60/float64(speed.WordsPerMinute) +
+		float64(c.CJKChars)*60/float64(speed.CJKCharsPerMinute)
+	return int(math.Ceil(seconds))
+}
+
+// formatReadingTime renders minutes for display, e.g. "3 min"
+func formatReadingTime(minutes int) string {
+	return fmt.Sprintf("%d min", minutes)
+}
@@ -0,0 +1,247 @@
+package notes
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Markdown rendering for exports. This covers what notes typically use
+// (headings, paragraphs, lists, quotes, fenced code, emphasis, links and
+// wikilinks), not the full CommonMark spec.
+
+var (
+	headingPattern    = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	bulletPattern     = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedPattern    = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	linkPattern       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	renderWikiPattern = regexp.MustCompile(`\[\[([^\]|#]+)(?:#[^\]|]*)?(?:\|([^\]]*))?\]\]`)
+	boldPattern       = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	emphasisPattern   = regexp.MustCompile(`\*(.+?)\*`)
+)
+
+// exportHTMLTemplate wraps a rendered note; the arguments are extra head
+// elements, the title and the body
+const exportHTMLTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+%s<title>%s</title>
+<style>
+body { font-family: sans-serif; max-width: 46em; margin: 2em auto; line-height: 1.5; }
+.meta { color: #666; font-size: 0.9em; }
+.tag { background: #eee; border-radius: 3px; padding: 0 0.3em; margin-right: 0.3em; }
+pre { background: #f6f6f6; padding: 0.8em; overflow-x: auto; }
+blockquote { border-left: 3px solid #ccc; margin-left: 0; padding-left: 1em; color: #555; }
+</style>
+</head>
+<body>
+%s</body>
+</html>
+`
+
+// renderNoteHTML renders a note as a standalone HTML page with a header
+// showing its title, creation time, tags, summary and related notes
+func renderNoteHTML(note *Note) string {
+	title, body := splitTitle(note.Content)
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(note.Filename), ".md")
+	}
+
+	var b strings.Builder
+	b.WriteString("<header>\n")
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(title))
+	b.WriteString(`<p class="meta">`)
+	b.WriteString(html.EscapeString(note.Frontmatter.Created.Local().Format(noteTimeFormat)))
+	for _, tag := range note.Frontmatter.Tags {
+		fmt.Fprintf(&b, ` <span class="tag">%s</span>`, html.EscapeString(tag))
+	}
+	b.WriteString("</p>\n")
+	if note.Frontmatter.Summary != "" {
+		fmt.Fprintf(&b, "<p class=\"summary\">%s</p>\n", html.EscapeString(note.Frontmatter.Summary))
+	}
+	if len(note.Frontmatter.Related) > 0 {
+		b.WriteString(`<p class="related">Related:`)
+		for _, related := range note.Frontmatter.Related {
+			fmt.Fprintf(&b, ` <a href="%s">%s</a>`, html.EscapeString(exportLink(related)), html.EscapeString(strings.TrimSuffix(related, ".md")))
+		}
+		b.WriteString("</p>\n")
+	}
+	b.WriteString("</header>\n")
+	b.WriteString(renderMarkdown(body))
+
+	// Links are relative to the export's root, also from notes in subdirectories
+	var head string
+	if depth := strings.Count(note.Name(), "/"); depth > 0 {
+		head = fmt.Sprintf("<base href=\"%s\">\n", strings.Repeat("../", depth))
+	}
+
+	return fmt.Sprintf(exportHTMLTemplate, head, html.EscapeString(title), b.String())
+}
+
+// renderIndexHTML renders a page linking to every note, newest first
+func renderIndexHTML(notesList []*Note) string {
+	sorted := append([]*Note(nil), notesList...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Frontmatter.Created.After(sorted[j].Frontmatter.Created.Time)
+	})
+
+	var b strings.Builder
+	b.WriteString("<h1>Notes</h1>\n<ul class=\"index\">\n")
+	for _, note := range sorted {
+		filename := note.Name()
+		fmt.Fprintf(&b, `<li><a href="%s">%s</a> <span class="meta">%s</span>`,
+			html.EscapeString(exportLink(filename)),
+			html.EscapeString(note.GetSummaryOrFirstLine()),
+			html.EscapeString(note.Frontmatter.Created.Format("2006-01-02")))
+		for _, tag := range note.Frontmatter.Tags {
+			fmt.Fprintf(&b, ` <span class="tag">%s</span>`, html.EscapeString(tag))
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ul>\n")
+
+	return fmt.Sprintf(exportHTMLTemplate, "", "Notes", b.String())
+}
+
+// splitTitle returns the text of a leading "# " heading and the body without
+// it, or "" and the unchanged body if it doesn't start with one
+func splitTitle(content string) (string, string) {
+	trimmed := strings.TrimLeft(content, "\n")
+	line, rest, _ := strings.Cut(trimmed, "\n")
+	if !strings.HasPrefix(line, "# ") {
+		return "", content
+	}
+	return strings.TrimSpace(line[2:]), rest
+}
+
+// renderMarkdown converts a markdown body to HTML
+func renderMarkdown(content string) string {
+	var b strings.Builder
+	var paragraph []string
+	list := "" // "ul" or "ol" while inside a list
+	inCode := false
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			fmt.Fprintf(&b, "<p>%s</p>\n", renderInline(strings.Join(paragraph, " ")))
+			paragraph = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			fmt.Fprintf(&b, "</%s>\n", list)
+			list = ""
+		}
+	}
+	openList := func(kind string) {
+		if list != kind {
+			closeList()
+			fmt.Fprintf(&b, "<%s>\n", kind)
+			list = kind
+		}
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inCode {
+				b.WriteString("</code></pre>\n")
+				inCode = false
+			} else {
+				flushParagraph()
+				closeList()
+				b.WriteString("<pre><code>")
+				inCode = true
+			}
+			continue
+		}
+		if inCode {
+			b.WriteString(html.EscapeString(line))
+			b.WriteString("\n")
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			flushParagraph()
+			closeList()
+			continue
+		}
+
+		if m := headingPattern.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			closeList()
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", len(m[1]), renderInline(m[2]), len(m[1]))
+			continue
+		}
+		if m := bulletPattern.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			openList("ul")
+			fmt.Fprintf(&b, "<li>%s</li>\n", renderInline(m[1]))
+			continue
+		}
+		if m := orderedPattern.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			openList("ol")
+			fmt.Fprintf(&b, "<li>%s</li>\n", renderInline(m[1]))
+			continue
+		}
+		if quote, ok := strings.CutPrefix(line, ">"); ok {
+			flushParagraph()
+			closeList()
+			fmt.Fprintf(&b, "<blockquote><p>%s</p></blockquote>\n", renderInline(strings.TrimSpace(quote)))
+			continue
+		}
+
+		closeList()
+		paragraph = append(paragraph, strings.TrimSpace(line))
+	}
+
+	if inCode {
+		b.WriteString("</code></pre>\n")
+	}
+	flushParagraph()
+	closeList()
+
+	return b.String()
+}
+
+// renderInline escapes text and renders code spans, links, wikilinks and
+// emphasis. Nothing inside a code span is formatted.
+func renderInline(text string) string {
+	parts := strings.Split(text, "`")
+	for i, part := range parts {
+		escaped := html.EscapeString(part)
+		if i%2 == 1 && i < len(parts)-1 {
+			parts[i] = "<code>" + escaped + "</code>"
+			continue
+		}
+		escaped = renderWikiPattern.ReplaceAllStringFunc(escaped, func(match string) string {
+			m := renderWikiPattern.FindStringSubmatch(match)
+			target := strings.TrimSpace(m[1])
+			label := strings.TrimSpace(m[2])
+			if label == "" {
+				label = target
+			}
+			return fmt.Sprintf(`<a href="%s">%s</a>`, exportLink(target), label)
+		})
+		escaped = linkPattern.ReplaceAllString(escaped, `<a href="$2">$1</a>`)
+		escaped = boldPattern.ReplaceAllString(escaped, "<strong>$1</strong>")
+		escaped = emphasisPattern.ReplaceAllString(escaped, "<em>$1</em>")
+		parts[i] = escaped
+	}
+
+	// An unmatched backtick is kept as text
+	if len(parts)%2 == 0 {
+		last := len(parts) - 1
+		return strings.Join(parts[:last], "") + "`" + parts[last]
+	}
+	return strings.Join(parts, "")
+}
+
+// exportLink is the href of another exported note
+func exportLink(target string) string {
+	return strings.TrimSuffix(NormalizeFilename(target), ".md") + ".html"
+}
@@ -0,0 +1,148 @@
+package notes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// errNoteFound stops a walk once the note being looked up is found
+var errNoteFound = errors.New("note found")
+
+// ResolveNote maps a user-supplied name to a note filename. The name may be
+// a filename with or without .md, or a note's id; filenames take precedence.
+func ResolveNote(notesDir, name string) (string, error) {
+	filename := NormalizeFilename(name)
+	if _, err := os.Stat(filepath.Join(notesDir, filename)); err == nil {
+		return filename, nil
+	}
+
+	found, err := findNoteByID(notesDir, name)
+	if err != nil {
+		return "", err
+	}
+	if found == "" {
+		return "", fmt.Errorf("note not found: %s", filename)
+	}
+	return found, nil
+}
+
+// ResolveFilename is ResolveNote for names typed by hand: if no note has
+// the exact filename or id, a note whose filename starts with the name (or
+// failing that, contains it) is used when it is the only match. Several
+// matches are listed on stderr and reported as an error.
+func ResolveFilename(notesDir, name string) (string, error) {
+	filename, err := ResolveNote(notesDir, name)
+	if err == nil {
+		return filename, nil
+	}
+
+	matches, scanErr := matchFilenames(notesDir, name)
+	if scanErr != nil {
+		return "", scanErr
+	}
+	switch len(matches) {
+	case 0:
+		return "", err
+	case 1:
+		return matches[0], nil
+	}
+
+	fmt.Fprintf(os.Stderr, "%s matches %d notes:\n", name, len(matches))
+	for _, match := range matches {
+		fmt.Fprintf(os.Stderr, "  %s\n", match)
+	}
+	return "", fmt.Errorf("ambiguous note name: %s (matches %d notes)", name, len(matches))
+}
+
+// matchFilenames returns the sorted filenames starting with name, or if
+// there are none, those containing it. Case is ignored.
+func matchFilenames(notesDir, name string) ([]string, error) {
+	key := strings.ToLower(strings.TrimSuffix(name, ".md"))
+	if key == "" {
+		return nil, nil
+	}
+
+	names, err := listNoteFiles(notesDir, func(SkippedFile) {})
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefixes are matched against the filename, so "plan" finds work/plan.md
+	var prefixed, containing []string
+	for _, filename := range names {
+		base := strings.ToLower(strings.TrimSuffix(filename, ".md"))
+		switch {
+		case strings.HasPrefix(base, key) || strings.HasPrefix(path.Base(base), key):
+			prefixed = append(prefixed, filename)
+		case strings.Contains(base, key):
+			containing = append(containing, filename)
+		}
+	}
+
+	if len(prefixed) > 0 {
+		return prefixed, nil
+	}
+	return containing, nil
+}
+
+// findNoteByID returns the filename of the note with the given id, or "" if
+// there is none. .meta.json is consulted first so most lookups avoid a scan.
+func findNoteByID(notesDir, id string) (string, error) {
+	if id == "" {
+		return "", nil
+	}
+
+	meta, err := LoadMetaFile(notesDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to load meta file: %w", err)
+	}
+	for filename, fileMeta := range meta.Files {
+		if fileMeta.ID != id {
+			continue
+		}
+		// Only trust the entry if the note still carries the id
+		if note, err := ParseNote(filepath.Join(notesDir, filename)); err == nil && note.Frontmatter.ID == id {
+			return filename, nil
+		}
+	}
+
+	var found string
+	err = walkNotesDir(notesDir, func(note *Note) error {
+		if note.Frontmatter.ID == id {
+			found = note.Name()
+			return errNoteFound
+		}
+		return nil
+	}, func(SkippedFile) {})
+	if err != nil && err != errNoteFound {
+		return "", err
+	}
+	return found, nil
+}
+
+// NewNoteID generates a short random id for a note
+func NewNoteID() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// noteIDPattern keeps ids easy to type in a shell
+var noteIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// validateNoteID checks that a user-chosen id is usable
+func validateNoteID(id string) error {
+	if !noteIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid id %q: use letters, digits, '.', '_' and '-'", id)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package notes
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Verbose enables reporting of every file skipped while scanning notes
+var Verbose bool
+
+// SkippedFile records a directory entry that was not loaded as a note
+type SkippedFile struct {
+	Name   string
+	Reason string
+	Err    error // Set when the file looked like a note but failed to load
+}
+
+// LoadAllNotes parses all notes in the notes directory and its
+// subdirectories, reusing the notes already parsed during this run. Notes
+// that fail to parse are always reported on stderr; other skipped entries
+// (hidden directories, non-markdown files) are only reported in verbose mode.
+func LoadAllNotes(notesDir string) ([]*Note, error) {
+	notesList, skipped, err := scanNotesDir(notesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	reportSkipped(skipped)
+	return notesList, nil
+}
+
+// WalkNotes calls fn for every note in the notes directory as soon as it is
+// parsed, without holding all notes in memory: notes are not added to the
+// parse cache. Skipped files are reported like in LoadAllNotes. Returning an error from fn stops the walk.
+func WalkNotes(notesDir string, fn func(*Note) error) error {
+	return walkNotesDir(notesDir, fn, func(s SkippedFile) {
+		reportSkipped([]SkippedFile{s})
+	})
+}
+
+// scanNotesDir parses all notes in the notes directory and returns the
+// entries that were skipped along with the reason. Notes are parsed on a
+// pool of GOMAXPROCS workers but returned in the same order as WalkNotes
+// visits them.
+func scanNotesDir(notesDir string) ([]*Note, []SkippedFile, error) {
+	var skipped []SkippedFile
+	names, err := listNoteFiles(notesDir, func(s SkippedFile) {
+		skipped = append(skipped, s)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parsed := make([]*Note, len(names))
+	errs := make([]error, len(names))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				parsed[i], errs[i] = ParseNote(filepath.Join(notesDir, names[i]))
+			}
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var notesList []*Note
+	for i, name := range names {
+		if errs[i] != nil {
+			skipped = append(skipped, SkippedFile{
+				Name:   name,
+				Reason: fmt.Sprintf("failed to parse: %v", errs[i]),
+				Err:    errs[i],
+			})
+			continue
+		}
+		parsed[i].name = name
+		notesList = append(notesList, parsed[i])
+	}
+
+	return notesList, skipped, nil
+}
+
+func walkNotesDir(notesDir string, fn func(*Note) error, skip func(SkippedFile)) error {
+	names, err := listNoteFiles(notesDir, skip)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		note, err := parseNoteOnce(filepath.Join(notesDir, name))
+		if err != nil {
+			skip(SkippedFile{
+				Name:   name,
+				Reason: fmt.Sprintf("failed to parse: %v", err),
+				Err:    err,
+			})
+			continue
+		}
+		note.name = name
+
+		if err := fn(note); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listNoteFiles returns the names of all .md files in the notes directory
+// and its subdirectories, in lexical order and without parsing them. Hidden
+// directories such as .templates are not entered, and files and directories
+// matched by .notesignore are left out.
+func listNoteFiles(notesDir string, skip func(SkippedFile)) ([]string, error) {
+	ignore, err := LoadIgnore(notesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	err = filepath.WalkDir(notesDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == notesDir {
+			return nil
+		}
+
+		name := noteName(notesDir, path)
+		if entry.IsDir() {
+			if strings.HasPrefix(entry.Name(), ".") {
+				skip(SkippedFile{Name: name, Reason: "hidden directory"})
+				return filepath.SkipDir
+			}
+			if ignore.Match(name, true) {
+				skip(SkippedFile{Name: name, Reason: "ignored by " + IgnoreFile})
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(entry.Name(), ".md") {
+			skip(SkippedFile{Name: name, Reason: "not a .md file"})
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			skip(SkippedFile{Name: name, Reason: "hidden file"})
+			return nil
+		}
+		if ignore.Match(name, false) {
+			skip(SkippedFile{Name: name, Reason: "ignored by " + IgnoreFile})
+			return nil
+		}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notes directory: %w", err)
+	}
+	return names, nil
+}
+
+// noteName returns the name a note is known by: its path relative to the
+// notes directory with forward slashes, e.g. "work/plan.md". Notes at the top
+// level are known by their filename.
+func noteName(notesDir, path string) string {
+	if notesDir != "" {
+		if rel, err := filepath.Rel(notesDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.Base(path)
+}
+
+func reportSkipped(skipped []SkippedFile) {
+	for _, s := range skipped {
+		if s.Err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to parse %s: %v\n", s.Name, s.Err)
+		} else if Verbose {
+			fmt.Fprintf(os.Stderr, "Skipped %s: %s\n", s.Name, s.Reason)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package notes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Kinds of setting values, which decide how 'notes config --set' validates
+// and stores them
+const (
+	settingString = "string"
+	settingInt    = "int"
+	settingBool   = "bool"
+	settingChoice = "choice"
+)
+
+// setting is a persistent option. Its value is taken from, in order: a
+// command-line flag (where the command has one), the environment variable,
+// the config file, and finally the built-in default.
+type setting struct {
+	Key         string
+	Env         string // Environment variable overriding the config file, if any
+	Kind        string
+	Min         int      // Smallest allowed value for int settings
+	Choices     []string // Allowed values for choice settings
+	Description string
+}
+
+// settings lists every key accepted in the config file
+var settings = []setting{
+	{Key: "append_to_daily", Env: "NOTES_APPEND_TO_DAILY", Kind: settingBool, Description: "make 'notes new' append to today's note"},
+	{Key: "cjk_cpm", Env: "NOTES_CJK_CPM", Kind: settingInt, Min: 1, Description: "reading speed for CJK text in characters per minute"},
+	{Key: "color", Env: "NOTES_COLOR", Kind: settingChoice, Choices: []string{"auto", "always", "never"}, Description: "when to use colors and terminal formatting"},
+	{Key: "editor", Env: "EDITOR", Kind: settingString, Description: "editor for new and edit"},
+	{Key: "editor_wait", Env: "NOTES_EDITOR_WAIT", Kind: settingBool, Description: "wait for Enter after the editor returns"},
+	{Key: "time_format", Env: "NOTES_TIME_FORMAT", Kind: settingChoice, Choices: []string{"minute", "rfc3339"}, Description: "how created times are written: minute (local time to the minute) or rfc3339 (with seconds and time zone)"},
+	{Key: "tag_logic", Env: "NOTES_TAG_LOGIC", Kind: settingChoice, Choices: []string{tagLogicOr, tagLogicAnd}, Description: "default --tag-logic for list and search: or (any tag) or and (all tags)"},
+	{Key: "list_limit", Kind: settingInt, Min: 0, Description: "default --limit for 'notes list' (0 for no limit)"},
+	{Key: "wpm", Env: "NOTES_WPM", Kind: settingInt, Min: 1, Description: "reading speed in words per minute"},
+}
+
+// findSetting returns the setting for key, or nil if it is unknown
+func findSetting(key string) *setting {
+	for i := range settings {
+		if settings[i].Key == key {
+			return &settings[i]
+		}
+	}
+	return nil
+}
+
+// GetConfigPath returns the config file path: NOTES_CONFIG if set,
+// otherwise notes/config.json in the user's config directory
+func GetConfigPath() (string, error) {
+	if path := os.Getenv("NOTES_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "notes", "config.json"), nil
+}
+
+// loadConfigFile reads the config file. A missing file is an empty config.
+func loadConfigFile() (map[string]interface{}, error) {
+	path, err := GetConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	config := make(map[string]interface{})
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// saveConfigFile writes the config file, creating its directory if needed
+func saveConfigFile(config map[string]interface{}) error {
+	path, err := GetConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	defer forgetConfig()
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// parseSettingValue validates raw for s and converts it to the type stored
+// in the config file
+func parseSettingValue(s *setting, raw string) (interface{}, error) {
+	switch s.Kind {
+	case settingInt:
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < s.Min {
+			return nil, fmt.Errorf("invalid value for %s: %q (expected a whole number of at least %d)", s.Key, raw, s.Min)
+		}
+		return n, nil
+	case settingBool:
+		b, ok := parseBoolSetting(raw)
+		if !ok {
+			return nil, fmt.Errorf("invalid value for %s: %q (expected true or false)", s.Key, raw)
+		}
+		return b, nil
+	case settingChoice:
+		if !Contains(s.Choices, raw) {
+			return nil, fmt.Errorf("invalid value for %s: %q (expected %s)", s.Key, raw, strings.Join(s.Choices, ", "))
+		}
+		return raw, nil
+	default:
+		return raw, nil
+	}
+}
+
+// parseBoolSetting accepts the spellings used in environment variables
+func parseBoolSetting(raw string) (bool, bool) {
+	switch strings.ToLower(raw) {
+	case "1", "true", "yes", "on":
+		return true, true
+	case "0", "false", "no", "off", "":
+		return false, true
+	}
+	return false, false
+}
+
+// lookupSetting returns the value of key from its environment variable or
+// the config file, and where it came from ("env" or "config"). Both empty
+// means the built-in default applies. A broken config file is reported and
+// otherwise ignored, so it can't lock the user out of every command.
+func lookupSetting(key string) (value, source string) {
+	s := findSetting(key)
+	if s == nil {
+		panic("unknown setting: " + key)
+	}
+
+	if s.Env != "" {
+		if value := os.Getenv(s.Env); value != "" {
+			return value, "env"
+		}
+	}
+
+	config := cachedConfig()
+	if v, ok := config[key]; ok {
+		return formatConfigValue(v), "config"
+	}
+	return "", ""
+}
+
+// configCache holds the config file as read for lookupSetting, so a command
+// reading settings for every note it writes only parses it once. It is
+// keyed by path, as NOTES_CONFIG may change, and dropped when the file is
+// saved.
+var configCache = struct {
+	sync.Mutex
+	path   string
+	values map[string]interface{}
+}{}
+
+// cachedConfig returns the config file's values, reading it on first use.
+// A broken config file is reported once and treated as empty.
+func cachedConfig() map[string]interface{} {
+	configCache.Lock()
+	defer configCache.Unlock()
+
+	path, _ := GetConfigPath()
+	if configCache.values != nil && configCache.path == path {
+		return configCache.values
+	}
+
+	config, err := loadConfigFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring config file: %v\n", err)
+		config = make(map[string]interface{})
+	}
+	configCache.path = path
+	configCache.values = config
+	return config
+}
+
+// forgetConfig drops the cached config file, e.g. after it was written
+func forgetConfig() {
+	configCache.Lock()
+	defer configCache.Unlock()
+
+	configCache.values = nil
+}
+
+// formatConfigValue turns a value decoded from the config file back into
+// the string a flag or environment variable would give. JSON numbers decode
+// as float64, which fmt would print as 1e+06 once they are large enough.
+func formatConfigValue(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// settingKeys returns all known keys, sorted
+func settingKeys() []string {
+	keys := make([]string, len(settings))
+	for i, s := range settings {
+		keys[i] = s.Key
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,169 @@
+package notes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// cursorMarker marks where the editor cursor should be placed in a template
+const cursorMarker = "{{cursor}}"
+
+// defaultTemplate is written when editing the default template for the first time
+const defaultTemplate = `---
+tags: []
+summary: ""
+related: []
+---
+
+{{cursor}}
+`
+
+// TemplatesDir returns the directory holding note templates
+func TemplatesDir(notesDir string) string {
+	return filepath.Join(notesDir, ".templates")
+}
+
+// LoadTemplate loads a template from the templates directory. An empty name
+// loads default.md, which is optional: nil is returned if it doesn't exist.
+func LoadTemplate(notesDir, name string) (*Note, error) {
+	optional := name == ""
+	if optional {
+		name = "default"
+	}
+
+	path := filepath.Join(TemplatesDir(notesDir), NormalizeFilename(name))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if optional {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("template not found: %s", name)
+		}
+		return nil, err
+	}
+
+	expanded, err := expandTemplate(name, string(data), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := ParseNoteContent(path, []byte(expanded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// TemplateData holds the values templates can use through text/template
+// placeholders such as {{.Date}}
+type TemplateData struct {
+	Date string // The day the note is created, e.g. 2025-01-11
+}
+
+// expandTemplate fills in the placeholders of a template's text. The
+// {{cursor}} marker is kept for the editor.
+func expandTemplate(name, text string, now time.Time) (string, error) {
+	t, err := template.New(name).
+		Funcs(template.FuncMap{"cursor": func() string { return cursorMarker }}).
+		Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %s: %w", name, err)
+	}
+
+	var buf strings.Builder
+	if err := t.Execute(&buf, TemplateData{Date: now.Format("2006-01-02")}); err != nil {
+		return "", fmt.Errorf("invalid template %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// applyTemplate copies a template's metadata and body into note. If text is
+// given it replaces the cursor marker, or is appended when there is none.
+func applyTemplate(note *Note, tmpl *Note, text string) {
+	if tmpl.Frontmatter.Tags != nil {
+		note.Frontmatter.Tags = normalizeTagSlice(tmpl.Frontmatter.Tags)
+	}
+	note.Frontmatter.Summary = tmpl.Frontmatter.Summary
+	if tmpl.Frontmatter.Related != nil {
+		note.Frontmatter.Related = tmpl.Frontmatter.Related
+	}
+	note.Frontmatter.Extra = tmpl.Frontmatter.Extra
+
+	note.Content = tmpl.Content
+	if text == "" {
+		return
+	}
+
+	if strings.Contains(note.Content, cursorMarker) {
+		note.Content = strings.Replace(note.Content, cursorMarker, text, 1)
+		note.Content = strings.ReplaceAll(note.Content, cursorMarker, "")
+		return
+	}
+
+	if note.Content != "" && !strings.HasSuffix(note.Content, "\n") {
+		note.Content += "\n"
+	}
+	note.Content += text + "\n"
+}
+
+// stripCursor removes the cursor marker from rendered note text and returns
+// the text along with the 1-based line the marker was on (0 if absent)
+func stripCursor(rendered string) (string, int) {
+	idx := strings.Index(rendered, cursorMarker)
+	if idx == -1 {
+		return rendered, 0
+	}
+	line := strings.Count(rendered[:idx], "\n") + 1
+	return strings.ReplaceAll(rendered, cursorMarker, ""), line
+}
+
+// editDefaultTemplate opens the default template in the editor, creating it first if needed
+func editDefaultTemplate(notesDir string) error {
+	return editTemplate(notesDir, "default")
+}
+
+// editTemplate opens a template in the editor, creating it from
+// defaultTemplate first if needed
+func editTemplate(notesDir, name string) error {
+	dir := TemplatesDir(notesDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create templates directory: %w", err)
+	}
+
+	path := filepath.Join(dir, NormalizeFilename(name))
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := os.WriteFile(path, []byte(defaultTemplate), 0644); err != nil {
+			return fmt.Errorf("failed to create template: %w", err)
+		}
+	}
+
+	if err := runEditor(path, 0); err != nil {
+		return fmt.Errorf("editor failed: %w", err)
+	}
+	return nil
+}
+
+// ListTemplates returns the names of all templates, sorted
+func ListTemplates(notesDir string) ([]string, error) {
+	entries, err := os.ReadDir(TemplatesDir(notesDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read templates directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), ".md"))
+	}
+	return names, nil
+}